@@ -34,6 +34,8 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&deprecatedStr, "log_level", "", "deprecated and will be removed")
 	rootCmd.PersistentFlags().StringVar(&sctx.AdvertiseAddr, "advertise-addr", "", "advertise address")
 
+	rootCmd.AddCommand(newSimulateBalanceCmd(sctx))
+
 	metrics.MaxProcsGauge.Set(float64(runtime.GOMAXPROCS(0)))
 
 	rootCmd.RunE = func(cmd *cobra.Command, _ []string) error {
@@ -0,0 +1,70 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/balance/simulator"
+	"github.com/pingcap/tiproxy/pkg/sctx"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// newSimulateBalanceCmd creates a command that replays recorded backend states with the balance config in
+// the config file and outputs the balance decisions, without starting the server.
+func newSimulateBalanceCmd(sctx *sctx.Context) *cobra.Command {
+	simCmd := &cobra.Command{
+		Use:   "simulate-balance",
+		Short: "simulate the balance decisions offline with recorded backend states",
+		Long: "Read snapshots of backend states in JSON lines, feed them to the balance policy in the config file, " +
+			"and output a balance decision for each snapshot in JSON lines. Connections are not really migrated, " +
+			"so the connection counts are always the recorded ones.",
+	}
+	input := simCmd.Flags().String("input", "", "the file of recorded snapshots, read from stdin if it's empty")
+	output := simCmd.Flags().String("output", "", "the file to write decisions, write to stdout if it's empty")
+	simCmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		cfg := config.NewConfig()
+		if sctx.ConfigFile != "" {
+			content, err := os.ReadFile(sctx.ConfigFile)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := toml.Unmarshal(content, cfg); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if err := cfg.Check(); err != nil {
+			return err
+		}
+
+		var r io.Reader = cmd.InOrStdin()
+		if *input != "" {
+			f, err := os.Open(*input)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			defer f.Close()
+			r = f
+		}
+		var w io.Writer = cmd.OutOrStdout()
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		sim := simulator.NewSimulator(zap.NewNop(), cfg)
+		defer sim.Close()
+		return sim.Run(r, w)
+	}
+	return simCmd
+}
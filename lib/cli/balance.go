@@ -0,0 +1,65 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cli
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+func GetBalanceCmd(ctx *Context) *cobra.Command {
+	balanceCmd := &cobra.Command{
+		Use:   "balance [command]",
+		Short: "",
+	}
+	balanceCmd.AddCommand(GetBalanceHistoryCmd(ctx))
+	balanceCmd.AddCommand(GetBalanceScoresCmd(ctx))
+	return balanceCmd
+}
+
+func GetBalanceHistoryCmd(ctx *Context) *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history [flags]",
+		Short: "show the recent balance decisions",
+	}
+	namespace := historyCmd.PersistentFlags().String("namespace", "", "only show the decisions of the namespace")
+	historyCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		path := "/api/balance/history"
+		if *namespace != "" {
+			path += "?" + url.Values{"namespace": []string{*namespace}}.Encode()
+		}
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return historyCmd
+}
+
+func GetBalanceScoresCmd(ctx *Context) *cobra.Command {
+	scoresCmd := &cobra.Command{
+		Use:   "scores [flags]",
+		Short: "show the score breakdown of the backends",
+	}
+	namespace := scoresCmd.PersistentFlags().String("namespace", "", "only show the scores of the namespace")
+	scoresCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		path := "/api/balance/scores"
+		if *namespace != "" {
+			path += "?" + url.Values{"namespace": []string{*namespace}}.Encode()
+		}
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return scoresCmd
+}
@@ -4,14 +4,29 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/spf13/cobra"
 )
 
 const (
-	configPrefix = "/api/admin/config/"
+	configPrefix        = "/api/admin/config/"
+	clusterConfigPrefix = "/api/admin/config/cluster"
+	configHistoryPrefix = "/api/admin/config/history"
+	configRollback      = "/api/admin/config/rollback/"
+	configValidate      = "/api/admin/config/validate"
+	clusterPollInterval = 500 * time.Millisecond
 )
 
 func GetConfigCmd(ctx *Context) *cobra.Command {
@@ -26,8 +41,11 @@ func GetConfigCmd(ctx *Context) *cobra.Command {
 			Use: "set",
 		}
 		input := setProxy.Flags().String("input", "", "specify the input toml file for proxy config")
+		cluster := setProxy.Flags().Bool("cluster", false, "push the config to all the TiProxy instances that enable cluster-config")
+		label := setProxy.Flags().String("label", "", "only push the config to the instances with the label, in the format of name=value, works with --cluster")
+		wait := setProxy.Flags().Duration("wait", 10*time.Second, "how long to wait for the instances to apply the cluster config")
 		setProxy.RunE = func(cmd *cobra.Command, args []string) error {
-			b := cmd.InOrStdin()
+			var b io.Reader = cmd.InOrStdin()
 			if *input != "" {
 				f, err := os.Open(*input)
 				if err != nil {
@@ -37,6 +55,12 @@ func GetConfigCmd(ctx *Context) *cobra.Command {
 				b = f
 			}
 
+			if *cluster {
+				return setClusterConfig(cmd, ctx, b, *label, *wait)
+			}
+			if *label != "" {
+				return errors.New("--label only works with --cluster")
+			}
 			resp, err := doRequest(cmd.Context(), ctx, http.MethodPut, configPrefix, b)
 			if err != nil {
 				return err
@@ -65,5 +89,232 @@ func GetConfigCmd(ctx *Context) *cobra.Command {
 		rootCmd.AddCommand(getProxy)
 	}
 
+	// validate config
+	{
+		validate := &cobra.Command{
+			Use:   "validate",
+			Short: "check the config and show the changed items without applying it",
+		}
+		input := validate.Flags().String("input", "", "specify the input toml file for proxy config")
+		validate.RunE = func(cmd *cobra.Command, args []string) error {
+			var b io.Reader = cmd.InOrStdin()
+			if *input != "" {
+				f, err := os.Open(*input)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				b = f
+			}
+
+			resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, configValidate, b)
+			if err != nil {
+				return err
+			}
+
+			cmd.Println(resp)
+			return nil
+		}
+		rootCmd.AddCommand(validate)
+	}
+
+	// get config history
+	{
+		history := &cobra.Command{
+			Use:   "history",
+			Short: "list the recently applied configs",
+		}
+		history.RunE = func(cmd *cobra.Command, args []string) error {
+			resp, err := doRequest(cmd.Context(), ctx, http.MethodGet, configHistoryPrefix, nil)
+			if err != nil {
+				return err
+			}
+
+			cmd.Println(resp)
+			return nil
+		}
+		rootCmd.AddCommand(history)
+	}
+
+	// diff config versions
+	{
+		diff := &cobra.Command{
+			Use:   "diff <from-version> [to-version]",
+			Short: "show the changed config items between 2 versions, to-version defaults to the current one",
+			Args:  cobra.RangeArgs(1, 2),
+		}
+		diff.RunE = func(cmd *cobra.Command, args []string) error {
+			resp, err := doRequest(cmd.Context(), ctx, http.MethodGet, configHistoryPrefix, nil)
+			if err != nil {
+				return err
+			}
+			var history []config.ConfigVersion
+			if err := json.Unmarshal([]byte(resp), &history); err != nil {
+				return errors.WithStack(err)
+			}
+			if len(history) == 0 {
+				return errors.New("the config history is empty")
+			}
+			from, err := findConfigVersion(history, args[0])
+			if err != nil {
+				return err
+			}
+			to := history[len(history)-1]
+			if len(args) > 1 {
+				if to, err = findConfigVersion(history, args[1]); err != nil {
+					return err
+				}
+			}
+			diffs, err := diffConfigVersions(from, to)
+			if err != nil {
+				return err
+			}
+			for _, d := range diffs {
+				cmd.Println(d.String())
+			}
+			return nil
+		}
+		rootCmd.AddCommand(diff)
+	}
+
+	// rollback config
+	{
+		rollback := &cobra.Command{
+			Use:   "rollback <version>",
+			Short: "apply the config of a version in the history",
+			Args:  cobra.ExactArgs(1),
+		}
+		rollback.RunE = func(cmd *cobra.Command, args []string) error {
+			resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, configRollback+url.PathEscape(args[0]), nil)
+			if err != nil {
+				return err
+			}
+
+			cmd.Println(resp)
+			return nil
+		}
+		rootCmd.AddCommand(rollback)
+	}
+
 	return rootCmd
 }
+
+func findConfigVersion(history []config.ConfigVersion, version string) (config.ConfigVersion, error) {
+	v, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return config.ConfigVersion{}, errors.Wrapf(err, "invalid version %s", version)
+	}
+	for _, cv := range history {
+		if cv.Version == v {
+			return cv, nil
+		}
+	}
+	return config.ConfigVersion{}, errors.Errorf("version %d is not in the config history", v)
+}
+
+func diffConfigVersions(from, to config.ConfigVersion) ([]config.FieldDiff, error) {
+	var fromCfg, toCfg config.Config
+	if err := toml.Unmarshal([]byte(from.Data), &fromCfg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := toml.Unmarshal([]byte(to.Data), &toCfg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return config.DiffConfig(&fromCfg, &toCfg)
+}
+
+// setClusterConfig pushes the cluster config and reports which instances acknowledge it. It returns an error if
+// some instances don't acknowledge the config before the wait duration expires.
+// Only the instances that enable the cluster config and match the label should apply the config, and an instance
+// acknowledges the config once it reports the checksum of the pushed config.
+func setClusterConfig(cmd *cobra.Command, ctx *Context, b io.Reader, label string, wait time.Duration) error {
+	data, err := io.ReadAll(b)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	before, err := getClusterHealth(cmd, ctx)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("cluster", "true")
+	if label != "" {
+		query.Set("label", label)
+	}
+	if _, err := doRequest(cmd.Context(), ctx, http.MethodPut, configPrefix+"?"+query.Encode(), bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	var after []config.InstanceHealth
+	deadline := time.Now().Add(wait)
+	for {
+		if after, err = getClusterHealth(cmd, ctx); err != nil {
+			return err
+		}
+		if allAcknowledged(after, label, data) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(clusterPollInterval)
+	}
+
+	checksums := healthyChecksums(before)
+	for _, health := range after {
+		oldChecksum, ok := checksums[health.Addr]
+		switch {
+		case health.Error != "":
+			cmd.Println(fmt.Sprintf("%s: error: %s", health.Addr, health.Error))
+		case !health.ClusterConfig:
+			cmd.Println(fmt.Sprintf("%s: skipped, cluster config is disabled", health.Addr))
+		case !health.MatchClusterConfig(label):
+			cmd.Println(fmt.Sprintf("%s: skipped, label mismatched", health.Addr))
+		case !health.AppliedClusterConfig(label, data):
+			cmd.Println(fmt.Sprintf("%s: not acknowledged, checksum %d", health.Addr, health.ConfigChecksum))
+		case ok && oldChecksum != health.ConfigChecksum:
+			cmd.Println(fmt.Sprintf("%s: acknowledged, checksum %d -> %d", health.Addr, oldChecksum, health.ConfigChecksum))
+		default:
+			cmd.Println(fmt.Sprintf("%s: acknowledged, checksum %d", health.Addr, health.ConfigChecksum))
+		}
+	}
+	// Fail the command so that scripts can find out the instances that don't apply the config.
+	if !allAcknowledged(after, label, data) {
+		return errors.Errorf("not all the instances acknowledge the cluster config within %s", wait)
+	}
+	return nil
+}
+
+func getClusterHealth(cmd *cobra.Command, ctx *Context) ([]config.InstanceHealth, error) {
+	resp, err := doRequest(cmd.Context(), ctx, http.MethodGet, clusterConfigPrefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	var healths []config.InstanceHealth
+	if err := json.Unmarshal([]byte(resp), &healths); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return healths, nil
+}
+
+// allAcknowledged returns true if all the instances that should apply the config have applied it.
+// The unreachable instances are waited because it's unknown whether they should apply the config.
+func allAcknowledged(healths []config.InstanceHealth, label string, data []byte) bool {
+	for _, health := range healths {
+		if health.Error != "" {
+			return false
+		}
+		if health.MatchClusterConfig(label) && !health.AppliedClusterConfig(label, data) {
+			return false
+		}
+	}
+	return true
+}
+
+func healthyChecksums(healths []config.InstanceHealth) map[string]uint32 {
+	checksums := make(map[string]uint32, len(healths))
+	for _, health := range healths {
+		if health.Error == "" {
+			checksums[health.Addr] = health.ConfigChecksum
+		}
+	}
+	return checksums
+}
@@ -87,5 +87,6 @@ func GetRootCmd(tlsConfig *tls.Config) *cobra.Command {
 	rootCmd.AddCommand(GetConfigCmd(ctx))
 	rootCmd.AddCommand(GetHealthCmd(ctx))
 	rootCmd.AddCommand(GetTrafficCmd(ctx))
+	rootCmd.AddCommand(GetBalanceCmd(ctx))
 	return rootCmd
 }
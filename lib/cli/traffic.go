@@ -4,6 +4,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 
@@ -17,7 +18,12 @@ func GetTrafficCmd(ctx *Context) *cobra.Command {
 	}
 	trafficCmd.AddCommand(GetTrafficCaptureCmd(ctx))
 	trafficCmd.AddCommand(GetTrafficReplayCmd(ctx))
+	trafficCmd.AddCommand(GetTrafficConvertCmd(ctx))
+	trafficCmd.AddCommand(GetTrafficExportCmd(ctx))
 	trafficCmd.AddCommand(GetTrafficCancelCmd(ctx))
+	trafficCmd.AddCommand(GetTrafficPauseCmd(ctx))
+	trafficCmd.AddCommand(GetTrafficResumeCmd(ctx))
+	trafficCmd.AddCommand(GetTrafficSpeedCmd(ctx))
 	trafficCmd.AddCommand(GetTrafficShowCmd(ctx))
 	return trafficCmd
 }
@@ -55,18 +61,37 @@ func GetTrafficReplayCmd(ctx *Context) *cobra.Command {
 		Short: "",
 	}
 	input := replayCmd.PersistentFlags().String("input", "", "directory for traffic files")
+	format := replayCmd.PersistentFlags().String("format", "native", "the format of the input files: native, tidb-slow-log or mysql-general-log")
 	speed := replayCmd.PersistentFlags().Float64("speed", 1, "replay speed")
 	username := replayCmd.PersistentFlags().String("username", "", "the username to connect to TiDB for replay")
 	password := replayCmd.PersistentFlags().String("password", "", "the password to connect to TiDB for replay")
+	credentials := replayCmd.PersistentFlags().StringToString("credentials", nil, "the passwords of captured users, e.g. user1=password1,user2=password2. Users not listed use the default username and password")
+	schemaMapping := replayCmd.PersistentFlags().StringToString("schema-mapping", nil, "replay on shadow schemas by renaming captured schemas, e.g. app=app_shadow")
 	readonly := replayCmd.PersistentFlags().Bool("readonly", false, "only replay read-only queries, default is false")
 	replayCmd.RunE = func(cmd *cobra.Command, args []string) error {
-		reader := GetFormReader(map[string]string{
+		form := map[string]string{
 			"input":    *input,
+			"format":   *format,
 			"speed":    strconv.FormatFloat(*speed, 'f', -1, 64),
 			"username": *username,
 			"password": *password,
 			"readonly": strconv.FormatBool(*readonly),
-		})
+		}
+		if len(*credentials) > 0 {
+			b, err := json.Marshal(*credentials)
+			if err != nil {
+				return err
+			}
+			form["credentials"] = string(b)
+		}
+		if len(*schemaMapping) > 0 {
+			b, err := json.Marshal(*schemaMapping)
+			if err != nil {
+				return err
+			}
+			form["schema-mapping"] = string(b)
+		}
+		reader := GetFormReader(form)
 		resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, "/api/traffic/replay", reader)
 		if err != nil {
 			return err
@@ -78,6 +103,60 @@ func GetTrafficReplayCmd(ctx *Context) *cobra.Command {
 	return replayCmd
 }
 
+func GetTrafficConvertCmd(ctx *Context) *cobra.Command {
+	convertCmd := &cobra.Command{
+		Use:   "convert [flags]",
+		Short: "",
+	}
+	input := convertCmd.PersistentFlags().String("input", "", "directory for the log files to convert")
+	output := convertCmd.PersistentFlags().String("output", "", "output directory for traffic files")
+	format := convertCmd.PersistentFlags().String("format", "", "the format of the input files: tidb-slow-log or mysql-general-log")
+	encrypt := convertCmd.PersistentFlags().String("encrypt-method", "", "the encryption method used for encrypting traffic files")
+	compress := convertCmd.PersistentFlags().Bool("compress", true, "whether compress the traffic files")
+	convertCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		reader := GetFormReader(map[string]string{
+			"input":          *input,
+			"output":         *output,
+			"format":         *format,
+			"encrypt-method": *encrypt,
+			"compress":       strconv.FormatBool(*compress),
+		})
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, "/api/traffic/convert", reader)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return convertCmd
+}
+
+func GetTrafficExportCmd(ctx *Context) *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export [flags]",
+		Short: "",
+	}
+	input := exportCmd.PersistentFlags().String("input", "", "directory for traffic files")
+	output := exportCmd.PersistentFlags().String("output", "", "output directory for SQL files")
+	merge := exportCmd.PersistentFlags().Bool("merge", false, "write all connections to one file in time order instead of one file per connection")
+	exportCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		reader := GetFormReader(map[string]string{
+			"input":  *input,
+			"output": *output,
+			"merge":  strconv.FormatBool(*merge),
+		})
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, "/api/traffic/export", reader)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return exportCmd
+}
+
 func GetTrafficCancelCmd(ctx *Context) *cobra.Command {
 	cancelCmd := &cobra.Command{
 		Use:   "cancel",
@@ -95,6 +174,61 @@ func GetTrafficCancelCmd(ctx *Context) *cobra.Command {
 	return cancelCmd
 }
 
+func GetTrafficPauseCmd(ctx *Context) *cobra.Command {
+	pauseCmd := &cobra.Command{
+		Use:   "pause",
+		Short: "",
+	}
+	pauseCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, "/api/traffic/pause", nil)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return pauseCmd
+}
+
+func GetTrafficResumeCmd(ctx *Context) *cobra.Command {
+	resumeCmd := &cobra.Command{
+		Use:   "resume",
+		Short: "",
+	}
+	resumeCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, "/api/traffic/resume", nil)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return resumeCmd
+}
+
+func GetTrafficSpeedCmd(ctx *Context) *cobra.Command {
+	speedCmd := &cobra.Command{
+		Use:   "speed [flags]",
+		Short: "",
+	}
+	speed := speedCmd.PersistentFlags().Float64("speed", 1, "the new speed of the running replay")
+	speedCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		reader := GetFormReader(map[string]string{
+			"speed": strconv.FormatFloat(*speed, 'f', -1, 64),
+		})
+		resp, err := doRequest(cmd.Context(), ctx, http.MethodPost, "/api/traffic/speed", reader)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(resp)
+		return nil
+	}
+	return speedCmd
+}
+
 func GetTrafficShowCmd(ctx *Context) *cobra.Command {
 	showCmd := &cobra.Command{
 		Use:   "show",
@@ -3,7 +3,11 @@
 
 package config
 
-import "github.com/pingcap/tiproxy/lib/util/errors"
+import (
+	"strings"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
 
 const (
 	BalancePolicyResource   = "resource"
@@ -11,20 +15,223 @@ const (
 	BalancePolicyConnection = "connection"
 )
 
+const (
+	BalanceFactorStatus   = "status"
+	BalanceFactorLabel    = "label"
+	BalanceFactorHealth   = "health"
+	BalanceFactorMemory   = "memory"
+	BalanceFactorAffinity = "affinity"
+	BalanceFactorCPU      = "cpu"
+	BalanceFactorLatency  = "latency"
+	BalanceFactorLocation = "location"
+	// BalanceFactorResourceGroup balances the connections of each resource group.
+	BalanceFactorResourceGroup = "resource-group"
+	BalanceFactorConn          = "conn"
+)
+
+const (
+	AffinityKeyClientIP = "client-ip"
+	AffinityKeyUser     = "user"
+	AffinityKeyConnAttr = "conn-attr"
+	AffinityKeyProxyTLV = "proxy-tlv"
+)
+
+const (
+	// ConnLimitStrategyReject rejects the connection immediately when all backends are full.
+	ConnLimitStrategyReject = "reject"
+	// ConnLimitStrategyQueue keeps retrying until a backend is available or the connection times out.
+	ConnLimitStrategyQueue = "queue"
+)
+
+var balanceFactorNames = []string{
+	BalanceFactorStatus,
+	BalanceFactorLabel,
+	BalanceFactorHealth,
+	BalanceFactorMemory,
+	BalanceFactorAffinity,
+	BalanceFactorCPU,
+	BalanceFactorLatency,
+	BalanceFactorLocation,
+	BalanceFactorResourceGroup,
+	BalanceFactorConn,
+}
+
 type Balance struct {
 	LabelName string `yaml:"label-name,omitempty" toml:"label-name,omitempty" json:"label-name,omitempty"`
 	Policy    string `yaml:"policy,omitempty" toml:"policy,omitempty" json:"policy,omitempty"`
+	// Factors overrides the factors decided by the policy if it's not empty.
+	// The factors are ordered by priority, the first one is the most important.
+	Factors []BalanceFactor `yaml:"factors,omitempty" toml:"factors,omitempty" json:"factors,omitempty"`
+	// DryRun only logs the connections to be migrated instead of migrating them.
+	// It's used to verify the balance config before applying it.
+	DryRun bool `yaml:"dry-run,omitempty" toml:"dry-run,omitempty" json:"dry-run,omitempty"`
+	// Affinity routes the connections of the same client to the same backend.
+	Affinity BalanceAffinity `yaml:"affinity,omitempty" toml:"affinity,omitempty" json:"affinity,omitempty"`
+	// UserResourceGroups maps the users to their resource groups. The connections of each resource group are balanced
+	// if it's not empty. The resource groups may also be updated by the session states when the sessions migrate.
+	UserResourceGroups map[string]string `yaml:"user-resource-groups,omitempty" toml:"user-resource-groups,omitempty" json:"user-resource-groups,omitempty"`
+	// WarmUpSeconds is the window in which a new or recovered backend ramps up its share of connections, so that it
+	// won't be flooded while its caches are cold. 0 disables warm-up.
+	WarmUpSeconds float64 `yaml:"warm-up-seconds,omitempty" toml:"warm-up-seconds,omitempty" json:"warm-up-seconds,omitempty"`
+	// ConnLimit limits the connection count of each backend.
+	ConnLimit BalanceConnLimit `yaml:"conn-limit,omitempty" toml:"conn-limit,omitempty" json:"conn-limit,omitempty"`
+}
+
+// BalanceConnLimit protects the backends from too many sessions. The connections are neither routed nor migrated to
+// the backends that reach the limit.
+type BalanceConnLimit struct {
+	// MaxConnections is the max connection count of each backend. 0 means unlimited.
+	MaxConnections int `yaml:"max-connections,omitempty" toml:"max-connections,omitempty" json:"max-connections,omitempty"`
+	// LabelMaxConnections overrides MaxConnections for the backends with the labels. The keys are in the form of
+	// `label-name=label-value`. If a backend matches multiple labels, the least limit takes effect.
+	LabelMaxConnections map[string]int `yaml:"label-max-connections,omitempty" toml:"label-max-connections,omitempty" json:"label-max-connections,omitempty"`
+	// Strategy decides how to handle new connections when all backends are full: reject or queue. It's reject by default.
+	Strategy string `yaml:"strategy,omitempty" toml:"strategy,omitempty" json:"strategy,omitempty"`
+}
+
+// BalanceAffinity configures session affinity. The connections with the same key are hashed to the same backend
+// unless the backend is unhealthy.
+type BalanceAffinity struct {
+	// Key is the client identity to hash: client-ip, user, conn-attr, or proxy-tlv. Empty means disabled.
+	Key string `yaml:"key,omitempty" toml:"key,omitempty" json:"key,omitempty"`
+	// ConnAttr is the name of the connection attribute if the key is conn-attr.
+	ConnAttr string `yaml:"conn-attr,omitempty" toml:"conn-attr,omitempty" json:"conn-attr,omitempty"`
+	// ProxyTLV is the name of the TLV in the PROXY protocol header if the key is proxy-tlv, e.g. aws-vpce-id.
+	ProxyTLV string `yaml:"proxy-tlv,omitempty" toml:"proxy-tlv,omitempty" json:"proxy-tlv,omitempty"`
+}
+
+// BalanceFactor configures one factor of the factor-based balance.
+// The zero values of Threshold and BalanceSeconds mean using the default values.
+type BalanceFactor struct {
+	Name string `yaml:"name,omitempty" toml:"name,omitempty" json:"name,omitempty"`
+	// Disable keeps the factor in the list but doesn't use it.
+	Disable bool `yaml:"disable,omitempty" toml:"disable,omitempty" json:"disable,omitempty"`
+	// Threshold decides when to balance, and it means differently for each factor:
+	// cpu: the CPU usage (0~1) below which the backend is not balanced.
+	// memory: the memory usage (0~1) above which the backend has an OOM risk.
+	// latency: the ratio of the p99 latency to the peers' above which the backend is slow.
+	// conn: the ratio of the connection counts above which the backends are unbalanced.
+	Threshold float64 `yaml:"threshold,omitempty" toml:"threshold,omitempty" json:"threshold,omitempty"`
+	// BalanceSeconds decides the balance speed of status, health, memory, cpu, and latency.
+	// The smaller it is, the more connections are migrated per second.
+	BalanceSeconds float64 `yaml:"balance-seconds,omitempty" toml:"balance-seconds,omitempty" json:"balance-seconds,omitempty"`
 }
 
 func (b *Balance) Check() error {
 	switch b.Policy {
 	case BalancePolicyResource, BalancePolicyLocation, BalancePolicyConnection:
-		return nil
 	case "":
 		b.Policy = BalancePolicyResource
 	default:
 		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.policy")
 	}
+	switch b.Affinity.Key {
+	case "", AffinityKeyClientIP, AffinityKeyUser:
+	case AffinityKeyConnAttr:
+		if b.Affinity.ConnAttr == "" {
+			return errors.Wrapf(ErrInvalidConfigValue, "balance.affinity.conn-attr must be set if the key is conn-attr")
+		}
+	case AffinityKeyProxyTLV:
+		if b.Affinity.ProxyTLV == "" {
+			return errors.Wrapf(ErrInvalidConfigValue, "balance.affinity.proxy-tlv must be set if the key is proxy-tlv")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.affinity.key")
+	}
+	if b.WarmUpSeconds < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "balance.warm-up-seconds must be non-negative")
+	}
+	if err := b.ConnLimit.check(); err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(b.Factors))
+	for _, factor := range b.Factors {
+		if err := factor.check(); err != nil {
+			return err
+		}
+		if _, ok := names[factor.Name]; ok {
+			return errors.Wrapf(ErrInvalidConfigValue, "duplicated balance factor %s", factor.Name)
+		}
+		names[factor.Name] = struct{}{}
+	}
+	return nil
+}
+
+// Factor returns the config of the factor. It returns an empty config if the factor is not configured.
+func (b *Balance) Factor(name string) BalanceFactor {
+	for _, factor := range b.Factors {
+		if factor.Name == name {
+			return factor
+		}
+	}
+	return BalanceFactor{Name: name}
+}
+
+// MaxConnectionsOf returns the max connection count of the backend with the labels. 0 means unlimited.
+func (c *BalanceConnLimit) MaxConnectionsOf(labels map[string]string) int {
+	limit, matched := c.MaxConnections, false
+	for label, labelLimit := range c.LabelMaxConnections {
+		name, value, _ := strings.Cut(label, "=")
+		if v, ok := labels[name]; !ok || v != value {
+			continue
+		}
+		if !matched || labelLimit < limit {
+			limit, matched = labelLimit, true
+		}
+	}
+	return limit
+}
+
+func (c *BalanceConnLimit) check() error {
+	switch c.Strategy {
+	case "":
+		c.Strategy = ConnLimitStrategyReject
+	case ConnLimitStrategyReject, ConnLimitStrategyQueue:
+	default:
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance.conn-limit.strategy")
+	}
+	if c.MaxConnections < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "balance.conn-limit.max-connections must be non-negative")
+	}
+	for label, limit := range c.LabelMaxConnections {
+		if name, _, ok := strings.Cut(label, "="); !ok || name == "" {
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid label %s in balance.conn-limit.label-max-connections", label)
+		}
+		if limit <= 0 {
+			return errors.Wrapf(ErrInvalidConfigValue, "the limit of %s in balance.conn-limit.label-max-connections must be positive", label)
+		}
+	}
+	return nil
+}
+
+func (f *BalanceFactor) check() error {
+	known := false
+	for _, name := range balanceFactorNames {
+		if f.Name == name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid balance factor %s", f.Name)
+	}
+	// The status factor keeps connections away from unavailable backends and the conn factor is the last resort.
+	if f.Disable && (f.Name == BalanceFactorStatus || f.Name == BalanceFactorConn) {
+		return errors.Wrapf(ErrInvalidConfigValue, "balance factor %s can not be disabled", f.Name)
+	}
+	if f.Threshold < 0 || f.BalanceSeconds < 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "threshold and balance-seconds of balance factor %s must be non-negative", f.Name)
+	}
+	switch f.Name {
+	case BalanceFactorCPU, BalanceFactorMemory:
+		if f.Threshold > 1 {
+			return errors.Wrapf(ErrInvalidConfigValue, "threshold of balance factor %s must be between 0 and 1", f.Name)
+		}
+	case BalanceFactorLatency, BalanceFactorConn:
+		if f.Threshold > 0 && f.Threshold <= 1 {
+			return errors.Wrapf(ErrInvalidConfigValue, "threshold of balance factor %s must be greater than 1", f.Name)
+		}
+	}
 	return nil
 }
 
@@ -3,10 +3,53 @@
 
 package config
 
-import "time"
+import (
+	"hash/crc32"
+	"strings"
+	"time"
+)
 
 type HealthInfo struct {
 	ConfigChecksum uint32 `json:"config_checksum"`
+	// ClusterConfig is true if the instance applies the cluster config.
+	ClusterConfig bool              `json:"cluster_config,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	// ClusterConfigChecksums are the checksums of the applied cluster config and label overrides. The key is empty
+	// for the cluster config and it's the label for an override, e.g. zone=z1.
+	ClusterConfigChecksums map[string]uint32 `json:"cluster_config_checksums,omitempty"`
+}
+
+// MatchClusterConfig returns true if the instance should apply the cluster config pushed with the label.
+// The label is empty if the config is pushed to all the instances.
+func (hi *HealthInfo) MatchClusterConfig(label string) bool {
+	if !hi.ClusterConfig {
+		return false
+	}
+	if label == "" {
+		return true
+	}
+	name, value, _ := strings.Cut(label, "=")
+	v, ok := hi.Labels[name]
+	return ok && v == value
+}
+
+// AppliedClusterConfig returns true if the instance has applied the cluster config pushed with the label.
+// Empty data with a label removes the override.
+func (hi *HealthInfo) AppliedClusterConfig(label string, data []byte) bool {
+	checksum, ok := hi.ClusterConfigChecksums[label]
+	if label != "" && len(data) == 0 {
+		return !ok
+	}
+	return ok && checksum == crc32.ChecksumIEEE(data)
+}
+
+// InstanceHealth is the health of a TiProxy instance in the cluster.
+// It's used to check whether the instances apply the cluster config.
+type InstanceHealth struct {
+	HealthInfo
+	// Addr is the status address of the instance.
+	Addr  string `json:"addr"`
+	Error string `json:"error,omitempty"`
 }
 
 const (
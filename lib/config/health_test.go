@@ -0,0 +1,81 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterConfigAcknowledged(t *testing.T) {
+	data := []byte("proxy.max-connections = 100")
+	checksum := crc32.ChecksumIEEE(data)
+	tests := []struct {
+		info    HealthInfo
+		label   string
+		data    []byte
+		match   bool
+		applied bool
+	}{
+		{
+			info:  HealthInfo{},
+			data:  data,
+			match: false,
+		},
+		{
+			info:    HealthInfo{ClusterConfig: true, ClusterConfigChecksums: map[string]uint32{"": checksum}},
+			data:    data,
+			match:   true,
+			applied: true,
+		},
+		{
+			info:    HealthInfo{ClusterConfig: true, ClusterConfigChecksums: map[string]uint32{"": checksum + 1}},
+			data:    data,
+			match:   true,
+			applied: false,
+		},
+		{
+			info:    HealthInfo{ClusterConfig: true, ClusterConfigChecksums: map[string]uint32{"": 0}},
+			data:    nil,
+			match:   true,
+			applied: true,
+		},
+		{
+			info:  HealthInfo{ClusterConfig: true, Labels: map[string]string{"zone": "z1"}},
+			label: "zone=z2",
+			data:  data,
+			match: false,
+		},
+		{
+			info:    HealthInfo{ClusterConfig: true, Labels: map[string]string{"zone": "z2"}, ClusterConfigChecksums: map[string]uint32{"": 0, "zone=z2": checksum}},
+			label:   "zone=z2",
+			data:    data,
+			match:   true,
+			applied: true,
+		},
+		{
+			info:    HealthInfo{ClusterConfig: true, Labels: map[string]string{"zone": "z2"}, ClusterConfigChecksums: map[string]uint32{"": checksum}},
+			label:   "zone=z2",
+			data:    data,
+			match:   true,
+			applied: false,
+		},
+		{
+			// The override is removed.
+			info:    HealthInfo{ClusterConfig: true, Labels: map[string]string{"zone": "z2"}, ClusterConfigChecksums: map[string]uint32{"": checksum}},
+			label:   "zone=z2",
+			data:    nil,
+			match:   true,
+			applied: true,
+		},
+	}
+	for i, test := range tests {
+		require.Equal(t, test.match, test.info.MatchClusterConfig(test.label), "case %d", i)
+		if test.match {
+			require.Equal(t, test.applied, test.info.AppliedClusterConfig(test.label, test.data), "case %d", i)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// ConfigVersion is an applied config in the config history.
+type ConfigVersion struct {
+	Version uint64    `json:"version"`
+	Time    time.Time `json:"time"`
+	// Source is who changes the config, e.g. the config file or the address of the HTTP client.
+	Source   string      `json:"source"`
+	Checksum uint32      `json:"checksum"`
+	Diff     []FieldDiff `json:"diff,omitempty"`
+	// Data is the whole config in TOML.
+	Data string `json:"data"`
+}
+
+// FieldDiff is the change of a config item. The field is the TOML key, e.g. proxy.max-connections.
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+func (fd FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", fd.Field, fd.Old, fd.New)
+}
+
+// DiffConfig returns the changed config items from old to new, sorted by the fields.
+func DiffConfig(old, new *Config) ([]FieldDiff, error) {
+	oldFields, err := flattenConfig(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := flattenConfig(new)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []FieldDiff
+	for field, newValue := range newFields {
+		if oldValue, ok := oldFields[field]; !ok || oldValue != newValue {
+			diffs = append(diffs, FieldDiff{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	for field, oldValue := range oldFields {
+		if _, ok := newFields[field]; !ok {
+			diffs = append(diffs, FieldDiff{Field: field, Old: oldValue})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Field < diffs[j].Field
+	})
+	return diffs, nil
+}
+
+// flattenConfig returns the config items keyed by the TOML keys. Empty items are omitted.
+func flattenConfig(cfg *Config) (map[string]string, error) {
+	fields := make(map[string]string)
+	if cfg == nil {
+		return fields, nil
+	}
+	data, err := cfg.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	flattenTable("", m, fields)
+	return fields, nil
+}
+
+func flattenTable(prefix string, table map[string]any, fields map[string]string) {
+	for k, v := range table {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]any); ok {
+			flattenTable(key, sub, fields)
+			continue
+		}
+		fields[key] = fmt.Sprint(v)
+	}
+}
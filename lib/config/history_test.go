@@ -0,0 +1,36 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfig(t *testing.T) {
+	oldCfg := NewConfig()
+	oldCfg.Labels = map[string]string{"zone": "z1"}
+	newCfg := oldCfg.Clone()
+	diffs, err := DiffConfig(oldCfg, newCfg)
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+
+	newCfg.Proxy.MaxConnections = 100
+	newCfg.Labels = map[string]string{"region": "r1"}
+	newCfg.Log.Level = "warn"
+	diffs, err = DiffConfig(oldCfg, newCfg)
+	require.NoError(t, err)
+	require.Equal(t, []FieldDiff{
+		{Field: "labels.region", New: "r1"},
+		{Field: "labels.zone", Old: "z1"},
+		{Field: "log.level", Old: "info", New: "warn"},
+		{Field: "proxy.max-connections", Old: "0", New: "100"},
+	}, diffs)
+	require.Equal(t, "log.level: info -> warn", diffs[2].String())
+
+	diffs, err = DiffConfig(nil, newCfg)
+	require.NoError(t, err)
+	require.Contains(t, diffs, FieldDiff{Field: "proxy.addr", New: "0.0.0.0:6000"})
+}
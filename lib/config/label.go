@@ -4,14 +4,50 @@
 package config
 
 const (
+	// RegionLabelName indicates the label name of the region, which is the highest location level.
+	RegionLabelName = "region"
 	// LocationLabelName indicates the label name that decides the location of TiProxy and backends.
 	// We use `zone` because the follower read in TiDB also uses `zone` to decide location.
 	LocationLabelName = "zone"
+	// RackLabelName indicates the label name of the rack, which is the lowest location level.
+	RackLabelName = "rack"
 )
 
+// LocationLabelNames are the label names of the location levels, ordered from the highest level to the lowest.
+var LocationLabelNames = []string{RegionLabelName, LocationLabelName, RackLabelName}
+
 func (cfg *Config) GetLocation() string {
 	if len(cfg.Labels) == 0 {
 		return ""
 	}
 	return cfg.Labels[LocationLabelName]
 }
+
+// CrossLocationLevel returns the highest location level on which the labels differ from the labels of TiProxy.
+// The levels that TiProxy doesn't define are ignored. It returns empty if they are in the same location.
+func (cfg *Config) CrossLocationLevel(labels map[string]string) string {
+	if len(cfg.Labels) == 0 {
+		return ""
+	}
+	for _, name := range LocationLabelNames {
+		selfLocation := cfg.Labels[name]
+		if len(selfLocation) == 0 {
+			continue
+		}
+		if labels[name] != selfLocation {
+			return name
+		}
+	}
+	return ""
+}
+
+// LocationDistance returns the distance of the location level. The farther, the larger.
+// It returns 0 if the level is empty, which means the same location.
+func LocationDistance(level string) int {
+	for i, name := range LocationLabelNames {
+		if name == level {
+			return len(LocationLabelNames) - i
+		}
+	}
+	return 0
+}
@@ -5,8 +5,19 @@ package config
 
 import (
 	"bytes"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+const (
+	// NamespaceStorageMemory keeps namespaces in memory, so they are lost after restart.
+	NamespaceStorageMemory = "memory"
+	// NamespaceStorageLocal stores each namespace as a file in a local directory.
+	NamespaceStorageLocal = "local"
+	// NamespaceStorageEtcd stores namespaces in the PD etcd so that they are shared by all TiProxy instances.
+	NamespaceStorageEtcd = "etcd"
 )
 
 type Namespace struct {
@@ -21,10 +32,34 @@ type FrontendNamespace struct {
 }
 
 type BackendNamespace struct {
+	// Instances are the static backend addresses, which are host:port, unix:///path/to/socket,
+	// or npipe:////./pipe/name for Windows named pipes.
 	Instances []string  `yaml:"instances" json:"instances" toml:"instances"`
 	Security  TLSConfig `yaml:"security" json:"security" toml:"security"`
 }
 
+// NamespaceStorage decides where the namespaces put by the HTTP API are persisted.
+type NamespaceStorage struct {
+	Type string `yaml:"type,omitempty" toml:"type,omitempty" json:"type,omitempty"`
+	// Dir is the directory of namespace files when Type is local. It defaults to <workdir>/namespace.
+	Dir string `yaml:"dir,omitempty" toml:"dir,omitempty" json:"dir,omitempty"`
+}
+
+func (ns *NamespaceStorage) check(workdir string) error {
+	switch ns.Type {
+	case "":
+		ns.Type = NamespaceStorageMemory
+	case NamespaceStorageMemory, NamespaceStorageEtcd:
+	case NamespaceStorageLocal:
+		if ns.Dir == "" {
+			ns.Dir = filepath.Join(workdir, "namespace")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidConfigValue, "invalid namespace-storage.type %s", ns.Type)
+	}
+	return nil
+}
+
 func NewNamespace(data []byte) (*Namespace, error) {
 	var cfg Namespace
 	if err := toml.Unmarshal(data, &cfg); err != nil {
@@ -9,6 +9,8 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +18,13 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/errors"
 )
 
+const (
+	// UnixSocketScheme is the prefix of Unix socket addresses, e.g. unix:///tmp/tiproxy.sock.
+	UnixSocketScheme = "unix://"
+	// NamedPipeScheme is the prefix of Windows named pipe addresses, e.g. npipe:////./pipe/tidb for \\.\pipe\tidb.
+	NamedPipeScheme = "npipe://"
+)
+
 var (
 	ErrUnsupportedProxyProtocolVersion = errors.New("unsupported proxy protocol version")
 	ErrInvalidConfigValue              = errors.New("invalid config value")
@@ -31,6 +40,8 @@ type Config struct {
 	Balance  Balance           `yaml:"balance,omitempty" toml:"balance,omitempty" json:"balance,omitempty"`
 	Labels   map[string]string `yaml:"labels,omitempty" toml:"labels,omitempty" json:"labels,omitempty"`
 	HA       HA                `yaml:"ha,omitempty" toml:"ha,omitempty" json:"ha,omitempty"`
+	// NamespaceStorage is only read at startup.
+	NamespaceStorage NamespaceStorage `yaml:"namespace-storage,omitempty" toml:"namespace-storage,omitempty" json:"namespace-storage,omitempty"`
 }
 
 type KeepAlive struct {
@@ -54,17 +65,28 @@ type ProxyServerOnline struct {
 	BackendHealthyKeepalive KeepAlive `yaml:"backend-healthy-keepalive" toml:"backend-healthy-keepalive" json:"backend-healthy-keepalive"`
 	// BackendUnhealthyKeepalive applies when the observer treats the backend as unhealthy.
 	// The config values can be aggressive because the backend may stop anytime.
-	BackendUnhealthyKeepalive  KeepAlive `yaml:"backend-unhealthy-keepalive" toml:"backend-unhealthy-keepalive" json:"backend-unhealthy-keepalive"`
-	ProxyProtocol              string    `yaml:"proxy-protocol,omitempty" toml:"proxy-protocol,omitempty" json:"proxy-protocol,omitempty"`
-	GracefulWaitBeforeShutdown int       `yaml:"graceful-wait-before-shutdown,omitempty" toml:"graceful-wait-before-shutdown,omitempty" json:"graceful-wait-before-shutdown,omitempty"`
-	GracefulCloseConnTimeout   int       `yaml:"graceful-close-conn-timeout,omitempty" toml:"graceful-close-conn-timeout,omitempty" json:"graceful-close-conn-timeout,omitempty"`
+	BackendUnhealthyKeepalive KeepAlive `yaml:"backend-unhealthy-keepalive" toml:"backend-unhealthy-keepalive" json:"backend-unhealthy-keepalive"`
+	ProxyProtocol             string    `yaml:"proxy-protocol,omitempty" toml:"proxy-protocol,omitempty" json:"proxy-protocol,omitempty"`
+	// ProxyProtocolTrustedCIDRs are the peers allowed to send the proxy headers, e.g. the subnet of the load balancer.
+	// The connections sending the proxy headers from other peers are rejected. Empty means all peers are trusted.
+	ProxyProtocolTrustedCIDRs  []string `yaml:"proxy-protocol-trusted-cidrs,omitempty" toml:"proxy-protocol-trusted-cidrs,omitempty" json:"proxy-protocol-trusted-cidrs,omitempty"`
+	GracefulWaitBeforeShutdown int      `yaml:"graceful-wait-before-shutdown,omitempty" toml:"graceful-wait-before-shutdown,omitempty" json:"graceful-wait-before-shutdown,omitempty"`
+	GracefulCloseConnTimeout   int      `yaml:"graceful-close-conn-timeout,omitempty" toml:"graceful-close-conn-timeout,omitempty" json:"graceful-close-conn-timeout,omitempty"`
 }
 
 type ProxyServer struct {
-	Addr              string `yaml:"addr,omitempty" toml:"addr,omitempty" json:"addr,omitempty"`
-	AdvertiseAddr     string `yaml:"advertise-addr,omitempty" toml:"advertise-addr,omitempty" json:"advertise-addr,omitempty"`
-	PDAddrs           string `yaml:"pd-addrs,omitempty" toml:"pd-addrs,omitempty" json:"pd-addrs,omitempty"`
-	ProxyServerOnline `yaml:",inline" toml:",inline" json:",inline"`
+	Addr          string `yaml:"addr,omitempty" toml:"addr,omitempty" json:"addr,omitempty"`
+	AdvertiseAddr string `yaml:"advertise-addr,omitempty" toml:"advertise-addr,omitempty" json:"advertise-addr,omitempty"`
+	PDAddrs       string `yaml:"pd-addrs,omitempty" toml:"pd-addrs,omitempty" json:"pd-addrs,omitempty"`
+	// ClusterConfig makes the instance watch the cluster config in the PD etcd and apply it. It's only read at startup.
+	ClusterConfig bool `yaml:"cluster-config,omitempty" toml:"cluster-config,omitempty" json:"cluster-config,omitempty"`
+	// HandoffSocket is the path of the Unix socket that passes the listeners and sessions to the new process when
+	// upgrading. It's only read at startup.
+	HandoffSocket string `yaml:"handoff-socket,omitempty" toml:"handoff-socket,omitempty" json:"handoff-socket,omitempty"`
+	// UnixSocketPermission is the file mode in octal of the Unix sockets in Addr, e.g. "0666".
+	// The mode is decided by the umask if it's empty.
+	UnixSocketPermission string `yaml:"unix-socket-permission,omitempty" toml:"unix-socket-permission,omitempty" json:"unix-socket-permission,omitempty"`
+	ProxyServerOnline    `yaml:",inline" toml:",inline" json:",inline"`
 }
 
 type API struct {
@@ -136,6 +158,7 @@ func NewConfig() *Config {
 	cfg.Security.ClusterTLS.MinTLSVersion = "1.2"
 
 	cfg.Balance = DefaultBalance()
+	cfg.NamespaceStorage.Type = NamespaceStorageMemory
 
 	return &cfg
 }
@@ -143,6 +166,10 @@ func NewConfig() *Config {
 func (cfg *Config) Clone() *Config {
 	newCfg := *cfg
 	newCfg.Labels = maps.Clone(cfg.Labels)
+	newCfg.Proxy.ProxyProtocolTrustedCIDRs = slices.Clone(cfg.Proxy.ProxyProtocolTrustedCIDRs)
+	newCfg.Balance.Factors = slices.Clone(cfg.Balance.Factors)
+	newCfg.Balance.UserResourceGroups = maps.Clone(cfg.Balance.UserResourceGroups)
+	newCfg.Balance.ConnLimit.LabelMaxConnections = maps.Clone(cfg.Balance.ConnLimit.LabelMaxConnections)
 	return &newCfg
 }
 
@@ -156,11 +183,29 @@ func (cfg *Config) Check() error {
 	}
 
 	switch cfg.Proxy.ProxyProtocol {
-	case "v2":
+	case "v1", "v2", "auto":
 	case "":
 	default:
 		return errors.Wrapf(ErrUnsupportedProxyProtocolVersion, "%s", cfg.Proxy.ProxyProtocol)
 	}
+	for _, cidr := range cfg.Proxy.ProxyProtocolTrustedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid proxy.proxy-protocol-trusted-cidrs %q", cidr)
+		}
+	}
+
+	for _, addr := range strings.Split(cfg.Proxy.Addr, ",") {
+		if path, ok := ParseUnixSocketAddr(addr); ok && path == "" {
+			return errors.Wrapf(ErrInvalidConfigValue, "invalid unix socket address %s", addr)
+		}
+	}
+	if _, err := cfg.Proxy.GetUnixSocketPermission(); err != nil {
+		return err
+	}
+	// The sessions are handed off within graceful-close-conn-timeout, so they would be all closed without it.
+	if cfg.Proxy.HandoffSocket != "" && cfg.Proxy.GracefulCloseConnTimeout <= 0 {
+		return errors.Wrapf(ErrInvalidConfigValue, "graceful-close-conn-timeout must be positive when handoff-socket is set")
+	}
 
 	if cfg.Proxy.ConnBufferSize > 0 && (cfg.Proxy.ConnBufferSize > 16*1024*1024 || cfg.Proxy.ConnBufferSize < 1024) {
 		return errors.Wrapf(ErrInvalidConfigValue, "conn-buffer-size must be between 1K and 16M")
@@ -170,6 +215,10 @@ func (cfg *Config) Check() error {
 		return err
 	}
 
+	if err := cfg.NamespaceStorage.check(cfg.Workdir); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -179,12 +228,47 @@ func (cfg *Config) ToBytes() ([]byte, error) {
 	return b.Bytes(), errors.WithStack(err)
 }
 
+// ParseUnixSocketAddr returns the socket path if the address is in the form of unix:///path/to/socket.
+func ParseUnixSocketAddr(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, UnixSocketScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(addr, UnixSocketScheme), true
+}
+
+// ParseNamedPipeAddr returns the pipe path if the address is in the form of npipe:////./pipe/name.
+// The slashes are replaced with backslashes, so the returned path is \\.\pipe\name.
+func ParseNamedPipeAddr(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, NamedPipeScheme) {
+		return "", false
+	}
+	return strings.ReplaceAll(strings.TrimPrefix(addr, NamedPipeScheme), "/", `\`), true
+}
+
+// GetUnixSocketPermission returns 0 if the permission is not set.
+func (ps *ProxyServer) GetUnixSocketPermission() (os.FileMode, error) {
+	if ps.UnixSocketPermission == "" {
+		return 0, nil
+	}
+	perm, err := strconv.ParseUint(ps.UnixSocketPermission, 8, 32)
+	if err != nil || perm > uint64(os.ModePerm) {
+		return 0, errors.Wrapf(ErrInvalidConfigValue, "invalid unix-socket-permission %s", ps.UnixSocketPermission)
+	}
+	return os.FileMode(perm), nil
+}
+
+// GetIPPort returns the IP and port of the first TCP address in proxy.addr and the port of api.addr.
+// The port is empty if TiProxy only listens on Unix sockets.
 func (cfg *Config) GetIPPort() (ip, port, statusPort string, err error) {
-	addrs := strings.Split(cfg.Proxy.Addr, ",")
-	ip, port, err = net.SplitHostPort(addrs[0])
-	if err != nil {
-		err = errors.WithStack(err)
-		return
+	for _, addr := range strings.Split(cfg.Proxy.Addr, ",") {
+		if _, ok := ParseUnixSocketAddr(addr); ok {
+			continue
+		}
+		if ip, port, err = net.SplitHostPort(addr); err != nil {
+			err = errors.WithStack(err)
+			return
+		}
+		break
 	}
 	_, statusPort, err = net.SplitHostPort(cfg.API.Addr)
 	if err != nil {
@@ -20,12 +20,14 @@ var testProxyConfig = Config{
 		IgnoreWrongNamespace: true,
 	},
 	Proxy: ProxyServer{
-		Addr:    "0.0.0.0:4000",
-		PDAddrs: "127.0.0.1:4089",
+		Addr:          "0.0.0.0:4000",
+		PDAddrs:       "127.0.0.1:4089",
+		ClusterConfig: true,
 		ProxyServerOnline: ProxyServerOnline{
 			MaxConnections:             1,
 			FrontendKeepalive:          KeepAlive{Enabled: true},
 			ProxyProtocol:              "v2",
+			ProxyProtocolTrustedCIDRs:  []string{"10.0.0.0/8"},
 			GracefulWaitBeforeShutdown: 10,
 			ConnBufferSize:             32 * 1024,
 		},
@@ -74,6 +76,30 @@ var testProxyConfig = Config{
 		},
 		RequireBackendTLS: true,
 	},
+	Balance: Balance{
+		Policy: BalancePolicyResource,
+		Factors: []BalanceFactor{
+			{Name: BalanceFactorStatus},
+			{Name: BalanceFactorCPU, Threshold: 0.5, BalanceSeconds: 10},
+			{Name: BalanceFactorMemory, Disable: true},
+			{Name: BalanceFactorConn, Threshold: 1.5},
+		},
+		Affinity: BalanceAffinity{
+			Key:      AffinityKeyConnAttr,
+			ConnAttr: "app_name",
+		},
+		UserResourceGroups: map[string]string{"user1": "rg1"},
+		WarmUpSeconds:      60,
+		ConnLimit: BalanceConnLimit{
+			MaxConnections:      1000,
+			LabelMaxConnections: map[string]int{"zone=z1": 500},
+			Strategy:            ConnLimitStrategyQueue,
+		},
+	},
+	NamespaceStorage: NamespaceStorage{
+		Type: NamespaceStorageLocal,
+		Dir:  "./ns",
+	},
 }
 
 func TestProxyConfig(t *testing.T) {
@@ -105,16 +131,176 @@ func TestProxyCheck(t *testing.T) {
 		},
 		{
 			pre: func(t *testing.T, c *Config) {
-				c.Proxy.ProxyProtocol = "v1"
+				c.Proxy.ProxyProtocol = "v3"
 			},
 			err: ErrUnsupportedProxyProtocolVersion,
 		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.ProxyProtocolTrustedCIDRs = []string{"10.0.0.1"}
+			},
+			err: ErrInvalidConfigValue,
+		},
 		{
 			pre: func(t *testing.T, c *Config) {
 				c.Proxy.ConnBufferSize = 100 * 1024 * 1024
 			},
 			err: ErrInvalidConfigValue,
 		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.HandoffSocket = "/tmp/tiproxy-handoff.sock"
+				c.Proxy.GracefulCloseConnTimeout = 0
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Addr = "0.0.0.0:6000,unix://"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.UnixSocketPermission = "0999"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.UnixSocketPermission = "01777"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Proxy.Addr = "unix:///tmp/tiproxy.sock"
+				c.Proxy.UnixSocketPermission = "0666"
+			},
+			post: func(t *testing.T, c *Config) {
+				perm, err := c.Proxy.GetUnixSocketPermission()
+				require.NoError(t, err)
+				require.Equal(t, os.FileMode(0666), perm)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Factors = []BalanceFactor{{Name: "unknown"}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Factors = []BalanceFactor{{Name: BalanceFactorCPU}, {Name: BalanceFactorCPU}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Factors = []BalanceFactor{{Name: BalanceFactorStatus, Disable: true}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Factors = []BalanceFactor{{Name: BalanceFactorMemory, Threshold: 1.5}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Factors = []BalanceFactor{{Name: BalanceFactorLatency, Threshold: 0.5}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Factors = []BalanceFactor{{Name: BalanceFactorHealth, BalanceSeconds: -1}}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Affinity = BalanceAffinity{Key: "unknown"}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Affinity = BalanceAffinity{Key: AffinityKeyConnAttr}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Affinity = BalanceAffinity{Key: AffinityKeyProxyTLV}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.WarmUpSeconds = -1
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.ConnLimit.Strategy = "unknown"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.ConnLimit.LabelMaxConnections = map[string]int{"zone": 100}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.ConnLimit.LabelMaxConnections = map[string]int{"zone=z1": 0}
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.ConnLimit.Strategy = ""
+			},
+			post: func(t *testing.T, c *Config) {
+				require.Equal(t, ConnLimitStrategyReject, c.Balance.ConnLimit.Strategy)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.NamespaceStorage.Type = "unknown"
+			},
+			err: ErrInvalidConfigValue,
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.NamespaceStorage.Dir = ""
+			},
+			post: func(t *testing.T, c *Config) {
+				require.Equal(t, filepath.Join(c.Workdir, "namespace"), c.NamespaceStorage.Dir)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.NamespaceStorage = NamespaceStorage{}
+			},
+			post: func(t *testing.T, c *Config) {
+				require.Equal(t, NamespaceStorageMemory, c.NamespaceStorage.Type)
+			},
+		},
+		{
+			pre: func(t *testing.T, c *Config) {
+				c.Balance.Policy = ""
+			},
+			post: func(t *testing.T, c *Config) {
+				require.Equal(t, BalancePolicyResource, c.Balance.Policy)
+				require.Equal(t, 0.5, c.Balance.Factor(BalanceFactorCPU).Threshold)
+				require.Equal(t, BalanceFactor{Name: BalanceFactorLatency}, c.Balance.Factor(BalanceFactorLatency))
+			},
+		},
 	}
 	for _, tc := range testcases {
 		cfg := testProxyConfig
@@ -169,6 +355,22 @@ func TestGetIPPort(t *testing.T) {
 		require.Equal(t, cas.port, port)
 		require.Equal(t, cas.port, statusPort)
 	}
+
+	// The Unix sockets are skipped.
+	cfg := &Config{
+		Proxy: ProxyServer{Addr: "unix:///tmp/tiproxy.sock,127.0.0.1:6000"},
+		API:   API{Addr: "127.0.0.1:3080"},
+	}
+	ip, port, statusPort, err := cfg.GetIPPort()
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", ip)
+	require.Equal(t, "6000", port)
+	require.Equal(t, "3080", statusPort)
+	cfg.Proxy.Addr = "unix:///tmp/tiproxy.sock"
+	ip, port, _, err = cfg.GetIPPort()
+	require.NoError(t, err)
+	require.Equal(t, sys.GetGlobalUnicastIP(), ip)
+	require.Empty(t, port)
 }
 
 func TestCloneConfig(t *testing.T) {
@@ -178,4 +380,38 @@ func TestCloneConfig(t *testing.T) {
 	require.Equal(t, cfg, *clone)
 	cfg.Labels["c"] = "d"
 	require.NotContains(t, clone.Labels, "c")
+	clone.Proxy.ProxyProtocolTrustedCIDRs[0] = "192.168.0.0/16"
+	require.Equal(t, "10.0.0.0/8", cfg.Proxy.ProxyProtocolTrustedCIDRs[0])
+	clone.Balance.Factors[0].Disable = true
+	require.False(t, cfg.Balance.Factors[0].Disable)
+	clone.Balance.UserResourceGroups["user2"] = "rg2"
+	require.NotContains(t, cfg.Balance.UserResourceGroups, "user2")
+	clone.Balance.ConnLimit.LabelMaxConnections["zone=z2"] = 100
+	require.NotContains(t, cfg.Balance.ConnLimit.LabelMaxConnections, "zone=z2")
+}
+
+func TestMaxConnectionsOf(t *testing.T) {
+	limit := BalanceConnLimit{
+		MaxConnections:      1000,
+		LabelMaxConnections: map[string]int{"zone=z1": 500, "rack=r1": 200, "zone=z2": 2000},
+	}
+	require.Equal(t, 1000, limit.MaxConnectionsOf(nil))
+	require.Equal(t, 500, limit.MaxConnectionsOf(map[string]string{"zone": "z1"}))
+	require.Equal(t, 200, limit.MaxConnectionsOf(map[string]string{"zone": "z1", "rack": "r1"}))
+	require.Equal(t, 1000, limit.MaxConnectionsOf(map[string]string{"zone": "z3"}))
+	// The label limit is not restricted by the global limit.
+	require.Equal(t, 2000, limit.MaxConnectionsOf(map[string]string{"zone": "z2"}))
+	limit.MaxConnections = 0
+	require.Equal(t, 0, limit.MaxConnectionsOf(nil))
+	require.Equal(t, 500, limit.MaxConnectionsOf(map[string]string{"zone": "z1"}))
+}
+
+func TestParseNamedPipeAddr(t *testing.T) {
+	path, ok := ParseNamedPipeAddr("npipe:////./pipe/tidb")
+	require.True(t, ok)
+	require.Equal(t, `\\.\pipe\tidb`, path)
+	_, ok = ParseNamedPipeAddr("unix:///tmp/tidb.sock")
+	require.False(t, ok)
+	_, ok = ParseNamedPipeAddr("127.0.0.1:4000")
+	require.False(t, ok)
 }
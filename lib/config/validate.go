@@ -0,0 +1,53 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ConfigValidation is the result of validating a config without applying it.
+type ConfigValidation struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Diff is the changed config items compared with the current config.
+	Diff []FieldDiff `json:"diff,omitempty"`
+	// OnlineFields are the changed fields that take effect online.
+	OnlineFields []string `json:"online_fields,omitempty"`
+	// RestartFields are the changed fields that take effect after restart.
+	RestartFields []string `json:"restart_fields,omitempty"`
+}
+
+// onlineFields are the TOML keys of the online config items, which are defined in ProxyServerOnline and LogOnline.
+// The listen addresses are not in ProxyServerOnline but they are also reloaded online.
+// The whole sections below are watched online:
+// - balance and labels by the router and the balance factors
+// - security by the cert manager and the SQL server, and the encryption key is read when a traffic job starts
+var onlineFields = func() []string {
+	fields := []string{"proxy.addr", "api.addr", "api.proxy-protocol", "balance", "labels", "security"}
+	for prefix, typ := range map[string]reflect.Type{
+		"proxy": reflect.TypeOf(ProxyServerOnline{}),
+		"log":   reflect.TypeOf(LogOnline{}),
+	} {
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("toml"), ",")
+			if name != "" && name != "-" {
+				fields = append(fields, prefix+"."+name)
+			}
+		}
+	}
+	return fields
+}()
+
+// IsOnlineField returns true if the config item takes effect without restart.
+// The field is the TOML key, e.g. proxy.max-connections.
+func IsOnlineField(field string) bool {
+	for _, f := range onlineFields {
+		if field == f || strings.HasPrefix(field, f+".") {
+			return true
+		}
+	}
+	return false
+}
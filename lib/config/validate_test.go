@@ -0,0 +1,42 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsOnlineField(t *testing.T) {
+	tests := []struct {
+		field  string
+		online bool
+	}{
+		{"proxy.max-connections", true},
+		{"proxy.frontend-keepalive.idle", true},
+		{"proxy.graceful-close-conn-timeout", true},
+		{"proxy.addr", true},
+		{"proxy.pd-addrs", false},
+		{"proxy.max-connections-x", false},
+		{"log.level", true},
+		{"log.log-file.max-size", true},
+		{"log.encoder", false},
+		{"api.addr", true},
+		{"api.proxy-protocol", true},
+		{"labels.zone", true},
+		{"labels", true},
+		{"balance.policy", true},
+		{"balance.conn-limit.max-connections", true},
+		{"balance.affinity.key", true},
+		{"security.server-tls.cert", true},
+		{"security.require-backend-tls", true},
+		{"balancer.policy", false},
+		{"workdir", false},
+		{"namespace-storage.type", false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.online, IsOnlineField(test.field), test.field)
+	}
+}
@@ -3,7 +3,10 @@
 
 package factor
 
-import "github.com/pingcap/tiproxy/lib/config"
+import (
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+)
 
 type Factor interface {
 	// Name returns the name of the factor.
@@ -18,3 +21,23 @@ type Factor interface {
 	SetConfig(cfg *config.Config)
 	Close()
 }
+
+// hintedFactor is implemented by the factors that score backends differently for each connection to route.
+// The hint is empty when balancing.
+type hintedFactor interface {
+	SetRouteHint(hint policy.RouteHint)
+}
+
+// inputReporter is implemented by the factors that calculate scores from raw values, such as metrics.
+// The values are reported for debugging.
+type inputReporter interface {
+	Inputs(backend scoredBackend) map[string]float64
+}
+
+// configOrDefault returns the configured value if it's set, otherwise returns the default value.
+func configOrDefault(value, defaultValue float64) float64 {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+)
+
+const (
+	// affinityVirtualNodes is the number of virtual nodes of each backend on the hash ring.
+	// More virtual nodes make the keys spread more evenly.
+	affinityVirtualNodes = 100
+)
+
+var _ Factor = (*FactorAffinity)(nil)
+var _ hintedFactor = (*FactorAffinity)(nil)
+
+// FactorAffinity routes the connections with the same affinity key to the same backend by consistent hashing.
+// The backend that owns the key on the hash ring gets score 0 and the others get score 1, so the factors with
+// higher priorities (e.g. health) override affinity and the ones with lower priorities only take effect when
+// the owner is unavailable.
+// When backends are added or removed, only the keys on the affected part of the ring are moved.
+// It doesn't affect balancing because the connections to balance don't have affinity keys.
+type FactorAffinity struct {
+	// affinityKey is the key of the connection to route.
+	affinityKey string
+	// points caches the sorted hash points of each backend on the hash ring.
+	points map[string][]uint32
+	bitNum int
+}
+
+func NewFactorAffinity() *FactorAffinity {
+	return &FactorAffinity{
+		points: make(map[string][]uint32),
+		bitNum: 1,
+	}
+}
+
+func (fa *FactorAffinity) Name() string {
+	return "affinity"
+}
+
+func (fa *FactorAffinity) SetRouteHint(hint policy.RouteHint) {
+	fa.affinityKey = hint.AffinityKey
+}
+
+func (fa *FactorAffinity) UpdateScore(backends []scoredBackend) {
+	if fa.affinityKey == "" {
+		return
+	}
+	owner := fa.owner(backends)
+	for i := 0; i < len(backends); i++ {
+		score := 1
+		if backends[i].Addr() == owner {
+			score = 0
+		}
+		backends[i].addScore(score, fa.bitNum)
+	}
+}
+
+// owner returns the address of the healthy backend that owns the affinity key on the hash ring.
+func (fa *FactorAffinity) owner(backends []scoredBackend) string {
+	// Remove the backends that no longer exist to avoid the cache growing infinitely.
+	if len(fa.points) > 2*len(backends) {
+		fa.points = make(map[string][]uint32, len(backends))
+	}
+	keyHash := hashString(fa.affinityKey)
+	var owner string
+	var minDistance uint32
+	for i := 0; i < len(backends); i++ {
+		if !backends[i].Healthy() {
+			continue
+		}
+		addr := backends[i].Addr()
+		points := fa.backendPoints(addr)
+		// Find the first point clockwise from the key. The distance wraps around the ring.
+		idx := sort.Search(len(points), func(j int) bool { return points[j] >= keyHash })
+		if idx == len(points) {
+			idx = 0
+		}
+		distance := points[idx] - keyHash
+		if owner == "" || distance < minDistance || (distance == minDistance && addr < owner) {
+			owner, minDistance = addr, distance
+		}
+	}
+	return owner
+}
+
+func (fa *FactorAffinity) backendPoints(addr string) []uint32 {
+	if points, ok := fa.points[addr]; ok {
+		return points
+	}
+	points := make([]uint32, 0, affinityVirtualNodes)
+	for i := 0; i < affinityVirtualNodes; i++ {
+		points = append(points, hashString(addr+"#"+strconv.Itoa(i)))
+	}
+	slices.Sort(points)
+	fa.points[addr] = points
+	return points
+}
+
+// hashString hashes the string with FNV-1a and mixes the bits with the finalizer of MurmurHash3 because the keys
+// are usually similar, such as IP addresses, and FNV-1a doesn't spread them well.
+func hashString(str string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(str))
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+func (fa *FactorAffinity) ScoreBitNum() int {
+	return fa.bitNum
+}
+
+func (fa *FactorAffinity) BalanceCount(from, to scoredBackend) float64 {
+	return 0
+}
+
+func (fa *FactorAffinity) SetConfig(cfg *config.Config) {
+}
+
+func (fa *FactorAffinity) Close() {
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func routeByAffinity(fa *FactorAffinity, backends []scoredBackend, key string) string {
+	fa.SetRouteHint(policy.RouteHint{AffinityKey: key})
+	for i := 0; i < len(backends); i++ {
+		backends[i].scoreBits = 0
+		backends[i].prepareScore(fa.ScoreBitNum())
+	}
+	fa.UpdateScore(backends)
+	owner := ""
+	for _, backend := range backends {
+		if backend.score() == 0 {
+			if owner != "" {
+				return ""
+			}
+			owner = backend.Addr()
+		}
+	}
+	return owner
+}
+
+func TestAffinityScore(t *testing.T) {
+	fa := NewFactorAffinity()
+	backends := make([]scoredBackend, 0, 5)
+	for i := 0; i < 5; i++ {
+		backends = append(backends, createBackend(i, 0, 0))
+	}
+
+	// No affinity key.
+	fa.SetRouteHint(policy.RouteHint{})
+	fa.UpdateScore(backends)
+	for _, backend := range backends {
+		require.Zero(t, backend.score())
+	}
+
+	// The same key is always routed to the same backend and the keys are spread to all the backends.
+	owners := make(map[string]string, 1000)
+	counts := make(map[string]int, len(backends))
+	for i := 0; i < 1000; i++ {
+		key := "10.0.0." + strconv.Itoa(i)
+		owner := routeByAffinity(fa, backends, key)
+		require.NotEmpty(t, owner)
+		require.Equal(t, owner, routeByAffinity(fa, backends, key))
+		owners[key] = owner
+		counts[owner]++
+	}
+	require.Len(t, counts, len(backends))
+	for _, count := range counts {
+		require.Greater(t, count, 100)
+	}
+
+	// When a backend is removed, only its keys are moved.
+	removed := backends[2].Addr()
+	remaining := append(append([]scoredBackend{}, backends[:2]...), backends[3:]...)
+	for key, owner := range owners {
+		newOwner := routeByAffinity(fa, remaining, key)
+		if owner != removed {
+			require.Equal(t, owner, newOwner)
+		} else {
+			require.NotEqual(t, removed, newOwner)
+		}
+	}
+
+	// The unhealthy backends are skipped and the keys are moved back after they recover.
+	backends[2].BackendCtx.(*mockBackend).healthy = false
+	for key, owner := range owners {
+		newOwner := routeByAffinity(fa, backends, key)
+		require.Equal(t, routeByAffinity(fa, remaining, key), newOwner)
+		if owner != removed {
+			require.Equal(t, owner, newOwner)
+		}
+	}
+	backends[2].BackendCtx.(*mockBackend).healthy = true
+	for key, owner := range owners {
+		require.Equal(t, owner, routeByAffinity(fa, backends, key))
+	}
+}
+
+func TestAffinityOverriddenByHealth(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	fm := NewFactorBasedBalance(lg, newMockMetricsReader())
+	fs, fa, fc := NewFactorStatus(), NewFactorAffinity(), NewFactorConnCount()
+	fm.factors = []Factor{fs, fa, fc}
+	require.NoError(t, fm.updateBitNum())
+	backends := []policy.BackendCtx{createBackend(0, 0, 100).BackendCtx, createBackend(1, 0, 0).BackendCtx}
+
+	// Find a key that is routed to the busier backend.
+	var key string
+	for i := 0; ; i++ {
+		key = strconv.Itoa(i)
+		if fm.BackendToRoute(backends, policy.RouteHint{AffinityKey: key}) == backends[0] {
+			break
+		}
+	}
+	// Without the key, it's routed to the idler one.
+	require.Equal(t, backends[1], fm.BackendToRoute(backends, policy.RouteHint{}))
+	// Health overrides affinity.
+	backends[0].(*mockBackend).healthy = false
+	require.Equal(t, backends[1], fm.BackendToRoute(backends, policy.RouteHint{AffinityKey: key}))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/pingcap/tiproxy/pkg/balance/policy"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 const (
@@ -16,102 +17,145 @@ const (
 )
 
 var _ policy.BalancePolicy = (*FactorBasedBalance)(nil)
+var _ policy.ResourceGroupBalancer = (*FactorBasedBalance)(nil)
+
+// factorScores is the score breakdown of a backend, ordered by the priorities of factors.
+type factorScores []policy.FactorScore
+
+func (fs factorScores) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, s := range fs {
+		enc.AddUint64(s.Factor, s.Score)
+	}
+	return nil
+}
 
 // FactorBasedBalance is the default balance policy.
 // It's not concurrency-safe for now.
 type FactorBasedBalance struct {
 	factors []Factor
+	// factorMap contains all the created factors, including the ones that are not in use, keyed by their names.
+	// The factors are not recreated when the config changes so that the statistics are kept.
+	factorMap map[string]Factor
 	// to reduce memory allocation
-	cachedList      []scoredBackend
-	mr              metricsreader.MetricsReader
-	lg              *zap.Logger
-	factorStatus    *FactorStatus
-	factorLabel     *FactorLabel
-	factorHealth    *FactorHealth
-	factorMemory    *FactorMemory
-	factorCPU       *FactorCPU
-	factorLocation  *FactorLocation
-	factorConnCount *FactorConnCount
-	totalBitNum     int
+	cachedList  []scoredBackend
+	mr          metricsreader.MetricsReader
+	lg          *zap.Logger
+	totalBitNum int
+	// rgToBalance is the resource group decided by the last BackendsToBalance.
+	rgToBalance string
 }
 
 func NewFactorBasedBalance(lg *zap.Logger, mr metricsreader.MetricsReader) *FactorBasedBalance {
 	return &FactorBasedBalance{
 		lg:         lg,
 		mr:         mr,
+		factorMap:  make(map[string]Factor),
 		cachedList: make([]scoredBackend, 0, 512),
 	}
 }
 
 // Init creates factors at the first time.
-// TODO: create factors according to config and update policy when config changes.
 func (fbb *FactorBasedBalance) Init(cfg *config.Config) {
-	fbb.factors = make([]Factor, 0, 7)
+	fbb.factors = make([]Factor, 0, 8)
 	fbb.setFactors(cfg)
 }
 
-func (fbb *FactorBasedBalance) setFactors(cfg *config.Config) {
-	fbb.factors = fbb.factors[:0]
-
-	if fbb.factorStatus == nil {
-		fbb.factorStatus = NewFactorStatus()
-	}
-	fbb.factors = append(fbb.factors, fbb.factorStatus)
-
-	if cfg.Balance.LabelName != "" {
-		if fbb.factorLabel == nil {
-			fbb.factorLabel = NewFactorLabel()
+// factorNames returns the names of the factors in use, ordered by priority.
+func factorNames(cfg *config.Balance) []string {
+	names := make([]string, 0, 8)
+	if len(cfg.Factors) > 0 {
+		// The status factor must be the first one to evict connections from unavailable backends,
+		// and the conn factor must be the last one to balance the backends when all the other factors are equal.
+		names = append(names, config.BalanceFactorStatus)
+		for _, factor := range cfg.Factors {
+			if factor.Disable || factor.Name == config.BalanceFactorStatus || factor.Name == config.BalanceFactorConn {
+				continue
+			}
+			if factor.Name == config.BalanceFactorLabel && cfg.LabelName == "" {
+				continue
+			}
+			names = append(names, factor.Name)
 		}
-		fbb.factors = append(fbb.factors, fbb.factorLabel)
-	} else if fbb.factorLabel != nil {
-		fbb.factorLabel.Close()
-		fbb.factorLabel = nil
+		return append(names, config.BalanceFactorConn)
 	}
 
-	switch cfg.Balance.Policy {
-	case config.BalancePolicyResource, config.BalancePolicyLocation:
-		if fbb.factorLocation == nil {
-			fbb.factorLocation = NewFactorLocation()
-		}
-		if fbb.factorHealth == nil {
-			fbb.factorHealth = NewFactorHealth(fbb.mr)
-		}
-		if fbb.factorMemory == nil {
-			fbb.factorMemory = NewFactorMemory(fbb.mr)
-		}
-		if fbb.factorCPU == nil {
-			fbb.factorCPU = NewFactorCPU(fbb.mr)
-		}
-	default:
-		if fbb.factorLocation != nil {
-			fbb.factorLocation.Close()
-			fbb.factorLocation = nil
-		}
-		if fbb.factorHealth != nil {
-			fbb.factorHealth.Close()
-			fbb.factorHealth = nil
-		}
-		if fbb.factorMemory != nil {
-			fbb.factorMemory.Close()
-			fbb.factorMemory = nil
-		}
-		if fbb.factorCPU != nil {
-			fbb.factorCPU.Close()
-			fbb.factorCPU = nil
-		}
+	names = append(names, config.BalanceFactorStatus)
+	if cfg.LabelName != "" {
+		names = append(names, config.BalanceFactorLabel)
 	}
-
-	switch cfg.Balance.Policy {
+	// The affinity factor is after the health-related factors so that they can override affinity.
+	switch cfg.Policy {
 	case config.BalancePolicyResource:
-		fbb.factors = append(fbb.factors, fbb.factorHealth, fbb.factorMemory, fbb.factorCPU, fbb.factorLocation)
+		names = append(names, config.BalanceFactorHealth, config.BalanceFactorMemory)
+		names = appendAffinity(names, cfg)
+		names = append(names, config.BalanceFactorCPU, config.BalanceFactorLatency, config.BalanceFactorLocation)
 	case config.BalancePolicyLocation:
-		fbb.factors = append(fbb.factors, fbb.factorLocation, fbb.factorHealth, fbb.factorMemory, fbb.factorCPU)
+		names = append(names, config.BalanceFactorLocation, config.BalanceFactorHealth, config.BalanceFactorMemory)
+		names = appendAffinity(names, cfg)
+		names = append(names, config.BalanceFactorCPU, config.BalanceFactorLatency)
+	default:
+		names = appendAffinity(names, cfg)
+	}
+	if len(cfg.UserResourceGroups) > 0 {
+		names = append(names, config.BalanceFactorResourceGroup)
+	}
+	return append(names, config.BalanceFactorConn)
+}
+
+func appendAffinity(names []string, cfg *config.Balance) []string {
+	if cfg.Affinity.Key != "" {
+		names = append(names, config.BalanceFactorAffinity)
 	}
+	return names
+}
 
-	if fbb.factorConnCount == nil {
-		fbb.factorConnCount = NewFactorConnCount()
+func (fbb *FactorBasedBalance) newFactor(name string) Factor {
+	switch name {
+	case config.BalanceFactorStatus:
+		return NewFactorStatus()
+	case config.BalanceFactorLabel:
+		return NewFactorLabel()
+	case config.BalanceFactorHealth:
+		return NewFactorHealth(fbb.mr)
+	case config.BalanceFactorMemory:
+		return NewFactorMemory(fbb.mr)
+	case config.BalanceFactorAffinity:
+		return NewFactorAffinity()
+	case config.BalanceFactorCPU:
+		return NewFactorCPU(fbb.mr)
+	case config.BalanceFactorLatency:
+		return NewFactorLatency()
+	case config.BalanceFactorLocation:
+		return NewFactorLocation()
+	case config.BalanceFactorResourceGroup:
+		return NewFactorResourceGroup()
+	case config.BalanceFactorConn:
+		return NewFactorConnCount()
+	}
+	// The config has been checked, so it should never happen.
+	panic("unknown balance factor " + name)
+}
+
+func (fbb *FactorBasedBalance) setFactors(cfg *config.Config) {
+	names := factorNames(&cfg.Balance)
+	fbb.factors = fbb.factors[:0]
+	inUse := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		factor, ok := fbb.factorMap[name]
+		if !ok {
+			factor = fbb.newFactor(name)
+			fbb.factorMap[name] = factor
+		}
+		fbb.factors = append(fbb.factors, factor)
+		inUse[name] = struct{}{}
+	}
+	// Close the unused factors to stop querying their metrics.
+	for name, factor := range fbb.factorMap {
+		if _, ok := inUse[name]; !ok {
+			factor.Close()
+			delete(fbb.factorMap, name)
+		}
 	}
-	fbb.factors = append(fbb.factors, fbb.factorConnCount)
 
 	err := fbb.updateBitNum()
 	if err != nil {
@@ -134,13 +178,16 @@ func (fbb *FactorBasedBalance) updateBitNum() error {
 	return nil
 }
 
-// updateScore updates backend scores.
-func (fbb *FactorBasedBalance) updateScore(backends []policy.BackendCtx) []scoredBackend {
+// updateScore updates backend scores for the connection described by the hint.
+func (fbb *FactorBasedBalance) updateScore(backends []policy.BackendCtx, hint policy.RouteHint) []scoredBackend {
 	scoredBackends := fbb.cachedList[:0]
 	for _, backend := range backends {
 		scoredBackends = append(scoredBackends, newScoredBackend(backend))
 	}
 	for _, factor := range fbb.factors {
+		if hf, ok := factor.(hintedFactor); ok {
+			hf.SetRouteHint(hint)
+		}
 		bitNum := factor.ScoreBitNum()
 		for j := 0; j < len(scoredBackends); j++ {
 			scoredBackends[j].prepareScore(bitNum)
@@ -151,14 +198,14 @@ func (fbb *FactorBasedBalance) updateScore(backends []policy.BackendCtx) []score
 }
 
 // BackendToRoute returns the idlest backend.
-func (fbb *FactorBasedBalance) BackendToRoute(backends []policy.BackendCtx) policy.BackendCtx {
+func (fbb *FactorBasedBalance) BackendToRoute(backends []policy.BackendCtx, hint policy.RouteHint) policy.BackendCtx {
 	if len(backends) == 0 {
 		return nil
 	}
 	if len(backends) == 1 {
 		return backends[0]
 	}
-	scoredBackends := fbb.updateScore(backends)
+	scoredBackends := fbb.updateScore(backends, hint)
 
 	// Find the idlest backend.
 	idlestBackend := scoredBackends[0]
@@ -177,11 +224,33 @@ func (fbb *FactorBasedBalance) BackendToRoute(backends []policy.BackendCtx) poli
 // balanceCount: the count of connections to migrate in this round. 0 indicates no need to balance.
 // reason: the debug information to be logged.
 func (fbb *FactorBasedBalance) BackendsToBalance(backends []policy.BackendCtx) (from, to policy.BackendCtx, balanceCount float64, reason string, logFields []zap.Field) {
+	fbb.rgToBalance = ""
 	if len(backends) <= 1 {
 		return
 	}
-	scoredBackends := fbb.updateScore(backends)
+	scoredBackends := fbb.updateScore(backends, policy.RouteHint{})
+	busiestBackend, idlestBackend, balanceCount, factor := fbb.backendsToBalance(scoredBackends)
+	if balanceCount == 0 {
+		return
+	}
+	reason = factor.Name()
+	maxScore, minScore := busiestBackend.score(), idlestBackend.score()
+	fields := []zap.Field{
+		zap.String("factor", reason),
+		zap.Uint64("from_score", maxScore),
+		zap.Uint64("to_score", minScore),
+		zap.Object("from_factor_scores", fbb.factorScores(maxScore)),
+		zap.Object("to_factor_scores", fbb.factorScores(minScore)),
+	}
+	if frg, ok := factor.(*FactorResourceGroup); ok {
+		fbb.rgToBalance = frg.GroupToBalance()
+		fields = append(fields, zap.String("resource_group", fbb.rgToBalance))
+	}
+	return busiestBackend.BackendCtx, idlestBackend.BackendCtx, balanceCount, reason, fields
+}
 
+// backendsToBalance finds the unbalanced backends from the scored backends and the factor that drives balancing.
+func (fbb *FactorBasedBalance) backendsToBalance(scoredBackends []scoredBackend) (from, to *scoredBackend, balanceCount float64, factor Factor) {
 	// Get the unbalanced backends and their scores.
 	var idlestBackend, busiestBackend *scoredBackend
 	minScore, maxScore := uint64(1<<maxBitNum-1), uint64(0)
@@ -204,7 +273,6 @@ func (fbb *FactorBasedBalance) BackendsToBalance(backends []policy.BackendCtx) (
 	}
 
 	// Get the unbalanced factor and the connection count to migrate.
-	var factor Factor
 	leftBitNum := fbb.totalBitNum
 	for _, factor = range fbb.factors {
 		bitNum := factor.ScoreBitNum()
@@ -226,17 +294,67 @@ func (fbb *FactorBasedBalance) BackendsToBalance(backends []policy.BackendCtx) (
 			// backend1 factor scores: 1, 0, 1
 			// backend2 factor scores: 0, 1, 0
 			// Balancing the third factor may make the second factor unbalanced, although it's in the same order with the first factor.
-			return
+			return nil, nil, 0, nil
 		}
 		leftBitNum -= bitNum
 	}
-	reason = factor.Name()
-	fields := []zap.Field{
-		zap.String("factor", reason),
-		zap.Uint64("from_score", maxScore),
-		zap.Uint64("to_score", minScore),
+	return busiestBackend, idlestBackend, balanceCount, factor
+}
+
+// ResourceGroupToBalance implements policy.ResourceGroupBalancer.
+// It returns the resource group to balance if the last BackendsToBalance is decided by the resource-group factor.
+func (fbb *FactorBasedBalance) ResourceGroupToBalance() string {
+	return fbb.rgToBalance
+}
+
+// BalanceScores returns the score breakdown of each backend and the factor that currently drives balancing.
+func (fbb *FactorBasedBalance) BalanceScores(backends []policy.BackendCtx) policy.BalanceScores {
+	var scores policy.BalanceScores
+	if len(backends) == 0 {
+		return scores
 	}
-	return busiestBackend.BackendCtx, idlestBackend.BackendCtx, balanceCount, reason, fields
+	scoredBackends := fbb.updateScore(backends, policy.RouteHint{})
+	scores.Backends = make([]policy.BackendScore, 0, len(scoredBackends))
+	for _, backend := range scoredBackends {
+		inputs := make(map[string]float64)
+		for _, factor := range fbb.factors {
+			if reporter, ok := factor.(inputReporter); ok {
+				for key, value := range reporter.Inputs(backend) {
+					inputs[key] = value
+				}
+			}
+		}
+		scores.Backends = append(scores.Backends, policy.BackendScore{
+			Addr:         backend.Addr(),
+			Healthy:      backend.Healthy(),
+			Score:        backend.score(),
+			FactorScores: fbb.factorScores(backend.score()),
+			Inputs:       inputs,
+		})
+	}
+	if len(scoredBackends) > 1 {
+		from, to, balanceCount, factor := fbb.backendsToBalance(scoredBackends)
+		if balanceCount > 0 {
+			scores.BalanceFactor, scores.From, scores.To, scores.BalanceCount = factor.Name(), from.Addr(), to.Addr(), balanceCount
+		}
+	}
+	return scores
+}
+
+// factorScores splits the combined score into the scores of each factor.
+func (fbb *FactorBasedBalance) factorScores(score uint64) factorScores {
+	scores := make(factorScores, 0, len(fbb.factors))
+	leftBitNum := fbb.totalBitNum
+	for _, factor := range fbb.factors {
+		bitNum := factor.ScoreBitNum()
+		scores = append(scores, policy.FactorScore{
+			Factor: factor.Name(),
+			Score:  score << (maxBitNum - leftBitNum) >> (maxBitNum - bitNum),
+			BitNum: bitNum,
+		})
+		leftBitNum -= bitNum
+	}
+	return scores
 }
 
 func (fbb *FactorBasedBalance) SetConfig(cfg *config.Config) {
@@ -10,6 +10,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/logger"
 	"github.com/pingcap/tiproxy/pkg/balance/policy"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestRouteWithOneFactor(t *testing.T) {
@@ -39,7 +40,7 @@ func TestRouteWithOneFactor(t *testing.T) {
 			}
 		}
 		backends := createBackends(len(test.scores))
-		backend := fm.BackendToRoute(backends)
+		backend := fm.BackendToRoute(backends, policy.RouteHint{})
 		require.Equal(t, backends[test.expectedIdx], backend, "test index %d", tIdx)
 	}
 }
@@ -94,7 +95,7 @@ func TestRouteWith2Factors(t *testing.T) {
 			}
 		}
 		backends := createBackends(len(test.scores1))
-		backend := fm.BackendToRoute(backends)
+		backend := fm.BackendToRoute(backends, policy.RouteHint{})
 		require.Equal(t, backends[test.expectedIdx], backend, "test index %d", tIdx)
 	}
 }
@@ -293,7 +294,7 @@ func TestScoreAlwaysUpdated(t *testing.T) {
 	}
 	factors[1].updateScore = func(backends []scoredBackend) {}
 	backends := createBackends(1)
-	scoredBackends := fm.updateScore(backends)
+	scoredBackends := fm.updateScore(backends, policy.RouteHint{})
 	require.EqualValues(t, 1<<2, scoredBackends[0].score())
 }
 
@@ -312,26 +313,26 @@ func TestSetFactors(t *testing.T) {
 	}{
 		{
 			setFunc:       func(balance *config.Balance) {},
-			expectedNames: []string{"status", "health", "memory", "cpu", "location", "conn"},
+			expectedNames: []string{"status", "health", "memory", "cpu", "latency", "location", "conn"},
 		},
 		{
 			setFunc: func(balance *config.Balance) {
 				balance.Policy = config.BalancePolicyLocation
 			},
-			expectedNames: []string{"status", "location", "health", "memory", "cpu", "conn"},
+			expectedNames: []string{"status", "location", "health", "memory", "cpu", "latency", "conn"},
 		},
 		{
 			setFunc: func(balance *config.Balance) {
 				balance.LabelName = "group"
 			},
-			expectedNames: []string{"status", "label", "health", "memory", "cpu", "location", "conn"},
+			expectedNames: []string{"status", "label", "health", "memory", "cpu", "latency", "location", "conn"},
 		},
 		{
 			setFunc: func(balance *config.Balance) {
 				balance.Policy = config.BalancePolicyLocation
 				balance.LabelName = "group"
 			},
-			expectedNames: []string{"status", "label", "location", "health", "memory", "cpu", "conn"},
+			expectedNames: []string{"status", "label", "location", "health", "memory", "cpu", "latency", "conn"},
 		},
 		{
 			setFunc: func(balance *config.Balance) {
@@ -346,6 +347,59 @@ func TestSetFactors(t *testing.T) {
 			},
 			expectedNames: []string{"status", "label", "conn"},
 		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Factors = []config.BalanceFactor{{Name: "cpu"}, {Name: "memory", Disable: true}, {Name: "location"}, {Name: "latency"}}
+			},
+			expectedNames: []string{"status", "cpu", "location", "latency", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Policy = config.BalancePolicyConnection
+				balance.Factors = []config.BalanceFactor{{Name: "conn"}, {Name: "label"}, {Name: "health"}, {Name: "status"}}
+			},
+			expectedNames: []string{"status", "health", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.LabelName = "group"
+				balance.Factors = []config.BalanceFactor{{Name: "location"}, {Name: "label"}}
+			},
+			expectedNames: []string{"status", "location", "label", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Affinity.Key = config.AffinityKeyClientIP
+			},
+			expectedNames: []string{"status", "health", "memory", "affinity", "cpu", "latency", "location", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Policy = config.BalancePolicyLocation
+				balance.Affinity.Key = config.AffinityKeyUser
+			},
+			expectedNames: []string{"status", "location", "health", "memory", "affinity", "cpu", "latency", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Policy = config.BalancePolicyConnection
+				balance.Affinity.Key = config.AffinityKeyUser
+			},
+			expectedNames: []string{"status", "affinity", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.UserResourceGroups = map[string]string{"u1": "rg1"}
+			},
+			expectedNames: []string{"status", "health", "memory", "cpu", "latency", "location", "resource-group", "conn"},
+		},
+		{
+			setFunc: func(balance *config.Balance) {
+				balance.Policy = config.BalancePolicyConnection
+				balance.UserResourceGroups = map[string]string{"u1": "rg1"}
+			},
+			expectedNames: []string{"status", "resource-group", "conn"},
+		},
 	}
 
 	lg, _ := logger.CreateLoggerForTest(t)
@@ -363,6 +417,115 @@ func TestSetFactors(t *testing.T) {
 		for j := 0; j < len(fm.factors); j++ {
 			require.Equal(t, test.expectedNames[j], fm.factors[j].Name(), "test index %d", i)
 		}
+		require.Len(t, fm.factorMap, len(test.expectedNames), "test index %d", i)
+	}
+	fm.Close()
+}
+
+func TestReuseFactors(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	mmr := newMockMetricsReader()
+	fm := NewFactorBasedBalance(lg, mmr)
+	cfg := &config.Config{
+		Balance: config.Balance{
+			Policy: config.BalancePolicyResource,
+		},
 	}
+	fm.Init(cfg)
+	cpu := fm.factorMap["cpu"]
+	require.NotNil(t, cpu)
+
+	// The factors are kept when the config changes so that the statistics are kept.
+	cfg.Balance.Factors = []config.BalanceFactor{{Name: "cpu", Threshold: 0.5}, {Name: "memory", Disable: true}}
+	fm.SetConfig(cfg)
+	require.Same(t, cpu, fm.factorMap["cpu"])
+	require.Equal(t, 0.5, cpu.(*FactorCPU).threshold)
+	require.NotContains(t, fm.factorMap, "memory")
+
+	cfg.Balance.Factors = nil
+	fm.SetConfig(cfg)
+	require.Same(t, cpu, fm.factorMap["cpu"])
+	require.Zero(t, cpu.(*FactorCPU).threshold)
+	require.Contains(t, fm.factorMap, "memory")
 	fm.Close()
 }
+
+func TestFactorScoresInLogFields(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	fm := NewFactorBasedBalance(lg, newMockMetricsReader())
+	factor1 := &mockFactor{name: "f1", bitNum: 2, balanceCount: 1}
+	factor2 := &mockFactor{name: "f2", bitNum: 10, balanceCount: 1}
+	fm.factors = []Factor{factor1, factor2}
+	require.NoError(t, fm.updateBitNum())
+	scores1, scores2 := []int{1, 0}, []int{3, 500}
+	factor1.updateScore = func(backends []scoredBackend) {
+		for i := 0; i < len(backends); i++ {
+			backends[i].addScore(scores1[i], factor1.bitNum)
+		}
+	}
+	factor2.updateScore = func(backends []scoredBackend) {
+		for i := 0; i < len(backends); i++ {
+			backends[i].addScore(scores2[i], factor2.bitNum)
+		}
+	}
+	backends := createBackends(2)
+	_, _, count, reason, fields := fm.BackendsToBalance(backends)
+	require.EqualValues(t, 1, count)
+	require.Equal(t, "f1", reason)
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	require.Equal(t, map[string]any{"f1": uint64(1), "f2": uint64(3)}, enc.Fields["from_factor_scores"])
+	require.Equal(t, map[string]any{"f1": uint64(0), "f2": uint64(500)}, enc.Fields["to_factor_scores"])
+}
+
+func TestBalanceScores(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	fm := NewFactorBasedBalance(lg, newMockMetricsReader())
+	factor1 := &mockFactor{name: "f1", bitNum: 2}
+	factor1.updateScore = func(backends []scoredBackend) {
+		for i := 0; i < len(backends); i++ {
+			backends[i].addScore(1, factor1.bitNum)
+		}
+	}
+	factor2 := NewFactorConnCount()
+	fm.factors = []Factor{factor1, factor2}
+	require.NoError(t, fm.updateBitNum())
+
+	backend1, backend2 := createBackend(0, 90, 100), createBackend(1, 0, 0)
+	scores := fm.BalanceScores([]policy.BackendCtx{backend1.BackendCtx, backend2.BackendCtx})
+	require.Equal(t, "conn", scores.BalanceFactor)
+	require.Equal(t, backend1.Addr(), scores.From)
+	require.Equal(t, backend2.Addr(), scores.To)
+	require.Greater(t, scores.BalanceCount, 0.0)
+	require.Equal(t, []policy.BackendScore{
+		{
+			Addr:    backend1.Addr(),
+			Healthy: true,
+			Score:   1<<16 + 100,
+			FactorScores: []policy.FactorScore{
+				{Factor: "f1", Score: 1, BitNum: 2},
+				{Factor: "conn", Score: 100, BitNum: 16},
+			},
+			Inputs: map[string]float64{"conn_count": 90, "conn_score": 100},
+		},
+		{
+			Addr:    backend2.Addr(),
+			Healthy: true,
+			Score:   1 << 16,
+			FactorScores: []policy.FactorScore{
+				{Factor: "f1", Score: 1, BitNum: 2},
+				{Factor: "conn", Score: 0, BitNum: 16},
+			},
+			Inputs: map[string]float64{"conn_count": 0, "conn_score": 0},
+		},
+	}, scores.Backends)
+
+	// Balanced.
+	backend2 = createBackend(1, 100, 100)
+	scores = fm.BalanceScores([]policy.BackendCtx{backend1.BackendCtx, backend2.BackendCtx})
+	require.Empty(t, scores.BalanceFactor)
+	require.Len(t, scores.Backends, 2)
+}
@@ -15,14 +15,17 @@ const (
 )
 
 var _ Factor = (*FactorConnCount)(nil)
+var _ inputReporter = (*FactorConnCount)(nil)
 
 type FactorConnCount struct {
-	bitNum int
+	balancedRatio float64
+	bitNum        int
 }
 
 func NewFactorConnCount() *FactorConnCount {
 	return &FactorConnCount{
-		bitNum: 16,
+		bitNum:        16,
+		balancedRatio: connBalancedRatio,
 	}
 }
 
@@ -32,22 +35,41 @@ func (fcc *FactorConnCount) Name() string {
 
 func (fcc *FactorConnCount) UpdateScore(backends []scoredBackend) {
 	for i := 0; i < len(backends); i++ {
-		backends[i].addScore(backends[i].ConnScore(), fcc.bitNum)
+		backends[i].addScore(int(warmConnScore(backends[i])), fcc.bitNum)
 	}
 }
 
+// warmConnScore scales up the connection score of a warming-up backend so that it takes a smaller share of
+// connections until it warms up.
+func warmConnScore(backend scoredBackend) float64 {
+	return float64(backend.ConnScore()) / backend.WarmUpRatio()
+}
+
+func (fcc *FactorConnCount) Inputs(backend scoredBackend) map[string]float64 {
+	inputs := map[string]float64{
+		"conn_count": float64(backend.ConnCount()),
+		"conn_score": float64(backend.ConnScore()),
+	}
+	// Only report it when the backend is warming up.
+	if ratio := backend.WarmUpRatio(); ratio < 1 {
+		inputs["warm_up_ratio"] = ratio
+	}
+	return inputs
+}
+
 func (fcc *FactorConnCount) ScoreBitNum() int {
 	return fcc.bitNum
 }
 
 func (fcc *FactorConnCount) BalanceCount(from, to scoredBackend) float64 {
-	if float64(from.ConnScore()) > float64(to.ConnScore()+1)*connBalancedRatio {
+	if warmConnScore(from) > (warmConnScore(to)+1)*fcc.balancedRatio {
 		return balanceCount4Conn
 	}
 	return 0
 }
 
 func (fcc *FactorConnCount) SetConfig(cfg *config.Config) {
+	fcc.balancedRatio = configOrDefault(cfg.Balance.Factor(fcc.Name()).Threshold, connBalancedRatio)
 }
 
 func (fcc *FactorConnCount) Close() {
@@ -6,6 +6,7 @@ package factor
 import (
 	"testing"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -41,3 +42,35 @@ func TestFactorConnCount(t *testing.T) {
 		require.Equal(t, test.expectedScore, backends[i].score(), "test idx: %d", i)
 	}
 }
+
+func TestFactorConnConfig(t *testing.T) {
+	factor := NewFactorConnCount()
+	from := scoredBackend{BackendCtx: &mockBackend{connScore: 12}}
+	to := scoredBackend{BackendCtx: &mockBackend{connScore: 10}}
+	require.Zero(t, factor.BalanceCount(from, to))
+
+	cfg := &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "conn", Threshold: 1.05}}}}
+	factor.SetConfig(cfg)
+	require.EqualValues(t, balanceCount4Conn, factor.BalanceCount(from, to))
+
+	factor.SetConfig(&config.Config{})
+	require.Zero(t, factor.BalanceCount(from, to))
+}
+
+func TestFactorConnWarmUp(t *testing.T) {
+	factor := NewFactorConnCount()
+	warm := scoredBackend{BackendCtx: &mockBackend{connScore: 100}}
+	cold := scoredBackend{BackendCtx: &mockBackend{connScore: 20, warmUpRatio: 0.1}}
+	backends := []scoredBackend{warm, cold}
+	factor.UpdateScore(backends)
+	// The warming-up backend looks busier than it is.
+	require.EqualValues(t, 100, backends[0].score())
+	require.EqualValues(t, 200, backends[1].score())
+	require.Zero(t, factor.BalanceCount(warm, cold))
+	require.EqualValues(t, balanceCount4Conn, factor.BalanceCount(cold, warm))
+	require.Equal(t, 0.1, factor.Inputs(cold)["warm_up_ratio"])
+	require.NotContains(t, factor.Inputs(warm), "warm_up_ratio")
+
+	cold.BackendCtx.(*mockBackend).warmUpRatio = 1
+	require.EqualValues(t, balanceCount4Conn, factor.BalanceCount(warm, cold))
+}
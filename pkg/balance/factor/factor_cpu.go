@@ -23,10 +23,12 @@ const (
 	cpuBalancedRatio = 1.2
 	// If the CPU difference of 2 backends is 30% and we're narrowing it to 20% in 30 seconds,
 	// then in each round, we migrate ((30% - 20%) / 2) / usagePerConn / 30 = 1 / usagePerConn / 600 connections.
-	balanceRatio4Cpu = 600
+	cpuUsage2Balance   = 0.05
+	balanceSeconds4Cpu = 30.0
 )
 
 var _ Factor = (*FactorCPU)(nil)
+var _ inputReporter = (*FactorCPU)(nil)
 
 var (
 	cpuQueryExpr = metricsreader.QueryExpr{
@@ -86,15 +88,19 @@ type FactorCPU struct {
 	lastMetricTime time.Time
 	// The estimated average CPU usage used by one connection.
 	usagePerConn float64
-	mr           metricsreader.MetricsReader
-	bitNum       int
+	// Only balance when the CPU usage of the busiest backend is above threshold.
+	threshold      float64
+	balanceSeconds float64
+	mr             metricsreader.MetricsReader
+	bitNum         int
 }
 
 func NewFactorCPU(mr metricsreader.MetricsReader) *FactorCPU {
 	fc := &FactorCPU{
-		mr:       mr,
-		bitNum:   5,
-		snapshot: make(map[string]cpuBackendSnapshot),
+		mr:             mr,
+		bitNum:         5,
+		balanceSeconds: balanceSeconds4Cpu,
+		snapshot:       make(map[string]cpuBackendSnapshot),
 	}
 	mr.AddQueryExpr(fc.Name(), cpuQueryExpr, cpuQueryRule)
 	return fc
@@ -237,6 +243,17 @@ func (fc *FactorCPU) getUsage(backend scoredBackend) (avgUsage, latestUsage floa
 	return
 }
 
+func (fc *FactorCPU) Inputs(backend scoredBackend) map[string]float64 {
+	if _, ok := fc.snapshot[backend.Addr()]; !ok {
+		return nil
+	}
+	avgUsage, latestUsage := fc.getUsage(backend)
+	return map[string]float64{
+		"cpu_avg_usage":    avgUsage,
+		"cpu_latest_usage": latestUsage,
+	}
+}
+
 func (fc *FactorCPU) ScoreBitNum() int {
 	return fc.bitNum
 }
@@ -244,16 +261,22 @@ func (fc *FactorCPU) ScoreBitNum() int {
 func (fc *FactorCPU) BalanceCount(from, to scoredBackend) float64 {
 	fromAvgUsage, fromLatestUsage := fc.getUsage(from)
 	toAvgUsage, toLatestUsage := fc.getUsage(to)
+	if fromAvgUsage < fc.threshold {
+		return 0
+	}
 	// The higher the CPU usage, the more sensitive the load balance should be.
 	// E.g. 10% vs 25% don't need rebalance, but 80% vs 95% need rebalance.
 	// Use the average usage to avoid thrash when CPU jitters too much and use the latest usage to avoid migrate too many connections.
 	if 1.3-toAvgUsage > (1.3-fromAvgUsage)*cpuBalancedRatio && 1.3-toLatestUsage > (1.3-fromLatestUsage)*cpuBalancedRatio {
-		return 1 / fc.usagePerConn / balanceRatio4Cpu
+		return cpuUsage2Balance / fc.usagePerConn / fc.balanceSeconds
 	}
 	return 0
 }
 
 func (fc *FactorCPU) SetConfig(cfg *config.Config) {
+	factorCfg := cfg.Balance.Factor(fc.Name())
+	fc.threshold = factorCfg.Threshold
+	fc.balanceSeconds = configOrDefault(factorCfg.BalanceSeconds, balanceSeconds4Cpu)
 }
 
 func (fc *FactorCPU) Close() {
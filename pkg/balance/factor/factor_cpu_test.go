@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
@@ -363,3 +364,29 @@ tidb_server_maxprocs 2
 		}
 	}
 }
+
+func TestCPUConfig(t *testing.T) {
+	fc := NewFactorCPU(newMockMetricsReader())
+	fc.usagePerConn = 0.01
+	from := createBackend(0, 10, 10)
+	to := createBackend(1, 10, 10)
+	fc.snapshot = map[string]cpuBackendSnapshot{
+		from.Addr(): {avgUsage: 0.8, latestUsage: 0.8, connCount: 10},
+		to.Addr():   {avgUsage: 0.2, latestUsage: 0.2, connCount: 10},
+	}
+	count := fc.BalanceCount(from, to)
+	require.Greater(t, count, 0.0)
+
+	// The balance speed is halved.
+	cfg := &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "cpu", BalanceSeconds: 2 * balanceSeconds4Cpu}}}}
+	fc.SetConfig(cfg)
+	require.InDelta(t, count/2, fc.BalanceCount(from, to), 0.0001)
+
+	// The CPU usage is below the threshold.
+	cfg = &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "cpu", Threshold: 0.9}}}}
+	fc.SetConfig(cfg)
+	require.Zero(t, fc.BalanceCount(from, to))
+
+	fc.SetConfig(&config.Config{})
+	require.Equal(t, count, fc.BalanceCount(from, to))
+}
@@ -142,6 +142,7 @@ var (
 )
 
 var _ Factor = (*FactorHealth)(nil)
+var _ inputReporter = (*FactorHealth)(nil)
 
 // The snapshot of backend statistics when the metric was updated.
 type healthBackendSnapshot struct {
@@ -161,18 +162,20 @@ type errIndicator struct {
 }
 
 type FactorHealth struct {
-	snapshot   map[string]healthBackendSnapshot
-	indicators []errIndicator
-	mr         metricsreader.MetricsReader
-	bitNum     int
+	snapshot       map[string]healthBackendSnapshot
+	indicators     []errIndicator
+	mr             metricsreader.MetricsReader
+	balanceSeconds float64
+	bitNum         int
 }
 
 func NewFactorHealth(mr metricsreader.MetricsReader) *FactorHealth {
 	return &FactorHealth{
-		mr:         mr,
-		snapshot:   make(map[string]healthBackendSnapshot),
-		indicators: initErrIndicator(mr),
-		bitNum:     2,
+		mr:             mr,
+		snapshot:       make(map[string]healthBackendSnapshot),
+		indicators:     initErrIndicator(mr),
+		balanceSeconds: balanceSeconds4Health,
+		bitNum:         2,
 	}
 }
 
@@ -264,7 +267,7 @@ func (fh *FactorHealth) updateSnapshot(backends []scoredBackend) {
 			if existSnapshot && snapshot.balanceCount > 0.0001 {
 				balanceCount = snapshot.balanceCount
 			} else {
-				balanceCount = float64(backend.ConnScore()) / balanceSeconds4Health
+				balanceCount = float64(backend.ConnScore()) / fh.balanceSeconds
 			}
 		}
 
@@ -309,6 +312,22 @@ func (fh *FactorHealth) caclErrScore(addr string) int {
 	return int(fh.snapshot[addr].valueRange)
 }
 
+// Inputs returns the latest error counts of the backend, keyed by the indicator keys.
+func (fh *FactorHealth) Inputs(backend scoredBackend) map[string]float64 {
+	inputs := make(map[string]float64, len(fh.indicators))
+	for i := 0; i < len(fh.indicators); i++ {
+		if time.Since(fh.indicators[i].queryResult.UpdateTime) > errMetricExpDuration {
+			continue
+		}
+		sample := fh.indicators[i].queryResult.GetSample4Backend(backend)
+		if sample == nil || math.IsNaN(float64(sample.Value)) {
+			continue
+		}
+		inputs[fh.indicators[i].key] = float64(sample.Value)
+	}
+	return inputs
+}
+
 func (fh *FactorHealth) ScoreBitNum() int {
 	return fh.bitNum
 }
@@ -324,6 +343,7 @@ func (fh *FactorHealth) BalanceCount(from, to scoredBackend) float64 {
 }
 
 func (fh *FactorHealth) SetConfig(cfg *config.Config) {
+	fh.balanceSeconds = configOrDefault(cfg.Balance.Factor(fh.Name()).BalanceSeconds, balanceSeconds4Health)
 }
 
 func (fh *FactorHealth) Close() {
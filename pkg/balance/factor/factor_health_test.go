@@ -80,6 +80,10 @@ func TestHealthScore(t *testing.T) {
 	for i, test := range tests {
 		require.Equal(t, test.score, backends[i].score(), "test index %d", i)
 	}
+	// The NaN values are not reported.
+	require.Empty(t, fh.Inputs(backends[0]))
+	require.Equal(t, map[string]float64{"health_tikv": 0}, fh.Inputs(backends[1]))
+	require.Equal(t, map[string]float64{"health_pd": tests[2].errCounts[0], "health_tikv": 0}, fh.Inputs(backends[2]))
 }
 
 func TestHealthBalance(t *testing.T) {
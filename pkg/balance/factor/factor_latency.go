@@ -0,0 +1,185 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
+
+const (
+	latencyEwmaAlpha = 0.5
+	// Collect the latencies periodically so that each window has enough samples.
+	latencyUpdateInterval = 15 * time.Second
+	// If a command type has no enough samples, we use the old latency temporarily for no longer than latencyExpDuration.
+	// After it expires, the connections can be routed to the backend again to verify whether it recovers.
+	latencyExpDuration = 2 * time.Minute
+	// Only compare the command types that have enough samples in a window, otherwise the p99 is inaccurate.
+	minSamples4Latency = 20
+	latencyQuantile    = 0.99
+	// A backend is slow only if its p99 is at least twice the peers' and the difference is noticeable.
+	// Every time the ratio multiplies by latencySlowRatio, the score increases by 1.
+	latencySlowRatio = 2
+	minLatencyDiff   = 10 * time.Millisecond
+	// Migrate the connections gradually from a slow backend in about 60 seconds,
+	// so that we can stop migrating once the latency recovers.
+	balanceSeconds4Latency = 60
+)
+
+var _ Factor = (*FactorLatency)(nil)
+
+type latencyBackendSnapshot struct {
+	// smoothed p99 latency of each command type in seconds, 0 means no data
+	p99 [pnet.ComEnd]float64
+	// the latest time when p99 is updated
+	updatedTime [pnet.ComEnd]time.Time
+	score       int
+}
+
+// FactorLatency scores the backends by the command latencies that are measured by TiProxy itself.
+// It detects the backends that are much slower than their peers, e.g. a TiDB on a degraded disk or network,
+// which may not be reflected by CPU or memory usage.
+type FactorLatency struct {
+	// The snapshot of backend latencies when they were collected last time.
+	snapshot       map[string]latencyBackendSnapshot
+	lastUpdateTime time.Time
+	slowRatio      float64
+	balanceSeconds float64
+	bitNum         int
+}
+
+func NewFactorLatency() *FactorLatency {
+	return &FactorLatency{
+		bitNum:         2,
+		slowRatio:      latencySlowRatio,
+		balanceSeconds: balanceSeconds4Latency,
+		snapshot:       make(map[string]latencyBackendSnapshot),
+	}
+}
+
+func (fl *FactorLatency) Name() string {
+	return "latency"
+}
+
+func (fl *FactorLatency) UpdateScore(backends []scoredBackend) {
+	if len(backends) <= 1 {
+		return
+	}
+	if now := time.Now(); now.Sub(fl.lastUpdateTime) >= latencyUpdateInterval {
+		fl.lastUpdateTime = now
+		fl.updateSnapshot(backends, now)
+	}
+	for i := 0; i < len(backends); i++ {
+		if snapshot, ok := fl.snapshot[backends[i].Addr()]; ok {
+			backends[i].addScore(snapshot.score, fl.bitNum)
+		}
+	}
+}
+
+func (fl *FactorLatency) updateSnapshot(backends []scoredBackend, now time.Time) {
+	snapshots := make(map[string]latencyBackendSnapshot, len(backends))
+	for _, backend := range backends {
+		addr := backend.Addr()
+		// The connections on this backend report latencies even if the backend is unhealthy, so always collect them.
+		hists := backend.CollectLatency()
+		snapshot := fl.snapshot[addr]
+		for cmd := range hists {
+			if hists[cmd].Count() >= minSamples4Latency {
+				p99 := hists[cmd].Quantile(latencyQuantile).Seconds()
+				// The latency may jitter, so use the EWMA algorithm to make it smooth.
+				if snapshot.p99[cmd] > 0 {
+					p99 = snapshot.p99[cmd]*(1-latencyEwmaAlpha) + p99*latencyEwmaAlpha
+				}
+				snapshot.p99[cmd] = p99
+				snapshot.updatedTime[cmd] = now
+			} else if now.Sub(snapshot.updatedTime[cmd]) > latencyExpDuration {
+				snapshot.p99[cmd] = 0
+			}
+		}
+		snapshot.score = 0
+		snapshots[addr] = snapshot
+	}
+	fl.snapshot = snapshots
+
+	// Compare each backend with the median of its healthy peers for each command type.
+	peers := make([]float64, 0, len(backends))
+	for _, backend := range backends {
+		if !backend.Healthy() {
+			continue
+		}
+		snapshot := snapshots[backend.Addr()]
+		for cmd := range snapshot.p99 {
+			if snapshot.p99[cmd] <= 0 {
+				continue
+			}
+			peers = peers[:0]
+			for _, peer := range backends {
+				if peer.Addr() == backend.Addr() || !peer.Healthy() {
+					continue
+				}
+				if p99 := snapshots[peer.Addr()].p99[cmd]; p99 > 0 {
+					peers = append(peers, p99)
+				}
+			}
+			if score := fl.calcScore(snapshot.p99[cmd], median(peers)); score > snapshot.score {
+				snapshot.score = score
+			}
+		}
+		snapshots[backend.Addr()] = snapshot
+	}
+}
+
+func (fl *FactorLatency) calcScore(p99, baseline float64) int {
+	if baseline <= 0 || p99-baseline < minLatencyDiff.Seconds() {
+		return 0
+	}
+	ratio := p99 / baseline
+	if ratio < fl.slowRatio {
+		return 0
+	}
+	score := int(math.Log2(ratio) / math.Log2(fl.slowRatio))
+	if maxScore := 1<<fl.bitNum - 1; score > maxScore {
+		score = maxScore
+	}
+	return score
+}
+
+// median returns 0 if values are empty. It sorts values in place.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+func (fl *FactorLatency) ScoreBitNum() int {
+	return fl.bitNum
+}
+
+func (fl *FactorLatency) BalanceCount(from, to scoredBackend) float64 {
+	fromScore := fl.snapshot[from.Addr()].score
+	toScore := fl.snapshot[to.Addr()].score
+	if fromScore > toScore {
+		return float64(from.ConnScore()) / fl.balanceSeconds
+	}
+	return 0
+}
+
+func (fl *FactorLatency) SetConfig(cfg *config.Config) {
+	factorCfg := cfg.Balance.Factor(fl.Name())
+	fl.slowRatio = configOrDefault(factorCfg.Threshold, latencySlowRatio)
+	fl.balanceSeconds = configOrDefault(factorCfg.BalanceSeconds, balanceSeconds4Latency)
+}
+
+func (fl *FactorLatency) Close() {
+}
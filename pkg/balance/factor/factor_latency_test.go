@@ -0,0 +1,167 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func mockLatency(latencies map[pnet.Command]time.Duration, count int) latency.Histograms {
+	tracker := latency.NewTracker()
+	for cmd, d := range latencies {
+		for i := 0; i < count; i++ {
+			tracker.Observe(cmd, d)
+		}
+	}
+	return tracker.Collect()
+}
+
+func TestLatencyCalcScore(t *testing.T) {
+	tests := []struct {
+		p99      float64
+		baseline float64
+		score    int
+	}{
+		{0.1, 0, 0},
+		{0.1, 0.1, 0},
+		{0.19, 0.1, 0},
+		{0.2, 0.1, 1},
+		{0.39, 0.1, 1},
+		{0.4, 0.1, 2},
+		{0.8, 0.1, 3},
+		{10, 0.1, 3},
+		// The difference is too small.
+		{0.005, 0.001, 0},
+	}
+	fl := NewFactorLatency()
+	for i, test := range tests {
+		require.Equal(t, test.score, fl.calcScore(test.p99, test.baseline), "case %d", i)
+	}
+
+	cfg := &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "latency", Threshold: 4}}}}
+	fl.SetConfig(cfg)
+	require.Equal(t, 0, fl.calcScore(0.3, 0.1))
+	require.Equal(t, 1, fl.calcScore(0.5, 0.1))
+	require.Equal(t, 2, fl.calcScore(1.6, 0.1))
+}
+
+func TestLatencyUpdateScore(t *testing.T) {
+	tests := []struct {
+		latencies []map[pnet.Command]time.Duration
+		unhealthy []bool
+		count     int
+		scores    []int
+	}{
+		{
+			latencies: []map[pnet.Command]time.Duration{{pnet.ComQuery: time.Millisecond}, {pnet.ComQuery: time.Millisecond}},
+			scores:    []int{0, 0},
+		},
+		{
+			latencies: []map[pnet.Command]time.Duration{{pnet.ComQuery: time.Millisecond}, {pnet.ComQuery: time.Millisecond}, {pnet.ComQuery: time.Second}},
+			scores:    []int{0, 0, 3},
+		},
+		{
+			latencies: []map[pnet.Command]time.Duration{{pnet.ComQuery: 20 * time.Millisecond}, {pnet.ComQuery: 30 * time.Millisecond}, {pnet.ComQuery: 200 * time.Millisecond}},
+			scores:    []int{0, 0, 2},
+		},
+		{
+			// Different command types are compared separately.
+			latencies: []map[pnet.Command]time.Duration{
+				{pnet.ComQuery: 100 * time.Millisecond, pnet.ComStmtExecute: time.Millisecond},
+				{pnet.ComQuery: 100 * time.Millisecond, pnet.ComStmtExecute: 50 * time.Millisecond},
+			},
+			scores: []int{0, 3},
+		},
+		{
+			// Unhealthy backends are not compared.
+			latencies: []map[pnet.Command]time.Duration{{pnet.ComQuery: time.Millisecond}, {pnet.ComQuery: time.Second}, {pnet.ComQuery: time.Second}},
+			unhealthy: []bool{false, true, true},
+			scores:    []int{0, 0, 0},
+		},
+		{
+			// No enough samples.
+			latencies: []map[pnet.Command]time.Duration{{pnet.ComQuery: time.Millisecond}, {pnet.ComQuery: time.Second}},
+			count:     minSamples4Latency - 1,
+			scores:    []int{0, 0},
+		},
+		{
+			// A backend without samples is not compared.
+			latencies: []map[pnet.Command]time.Duration{{pnet.ComQuery: time.Millisecond}, {}},
+			scores:    []int{0, 0},
+		},
+	}
+
+	for i, test := range tests {
+		backends := make([]scoredBackend, 0, len(test.latencies))
+		count := test.count
+		if count == 0 {
+			count = 100
+		}
+		for j, latencies := range test.latencies {
+			backend := newMockBackend(len(test.unhealthy) == 0 || !test.unhealthy[j], 100)
+			backend.addr = strconv.Itoa(j)
+			backend.latency = mockLatency(latencies, count)
+			backends = append(backends, newScoredBackend(backend))
+		}
+		fl := NewFactorLatency()
+		fl.UpdateScore(backends)
+		for j := range backends {
+			require.Equal(t, test.scores[j], int(backends[j].score()), "case %d, backend %d", i, j)
+		}
+	}
+}
+
+func TestLatencySnapshot(t *testing.T) {
+	backends := make([]scoredBackend, 0, 2)
+	mockBackends := make([]*mockBackend, 0, 2)
+	for i := 0; i < 2; i++ {
+		backend := newMockBackend(true, 100)
+		backend.addr = strconv.Itoa(i)
+		mockBackends = append(mockBackends, backend)
+		backends = append(backends, newScoredBackend(backend))
+	}
+	mockBackends[0].latency = mockLatency(map[pnet.Command]time.Duration{pnet.ComQuery: time.Millisecond}, 100)
+	mockBackends[1].latency = mockLatency(map[pnet.Command]time.Duration{pnet.ComQuery: time.Second}, 100)
+	fl := NewFactorLatency()
+	fl.UpdateScore(backends)
+	slowP99 := fl.snapshot["1"].p99[pnet.ComQuery]
+	require.Equal(t, 3, fl.snapshot["1"].score)
+	require.Greater(t, fl.BalanceCount(backends[1], backends[0]), 0.0)
+	require.Zero(t, fl.BalanceCount(backends[0], backends[1]))
+
+	// The latencies are not collected again within the interval.
+	mockBackends[1].latency = mockLatency(map[pnet.Command]time.Duration{pnet.ComQuery: time.Millisecond}, 100)
+	fl.UpdateScore(backends)
+	require.Equal(t, slowP99, fl.snapshot["1"].p99[pnet.ComQuery])
+
+	// The latency is smoothed.
+	fl.lastUpdateTime = time.Time{}
+	fl.UpdateScore(backends)
+	p99 := fl.snapshot["1"].p99[pnet.ComQuery]
+	require.Less(t, p99, slowP99)
+	require.Greater(t, p99, fl.snapshot["0"].p99[pnet.ComQuery])
+
+	// The old latency is used if there are no enough samples.
+	fl.lastUpdateTime = time.Time{}
+	fl.UpdateScore(backends)
+	require.Equal(t, p99, fl.snapshot["1"].p99[pnet.ComQuery])
+	require.Greater(t, fl.snapshot["1"].score, 0)
+
+	// The old latency expires.
+	snapshot := fl.snapshot["1"]
+	snapshot.updatedTime[pnet.ComQuery] = time.Now().Add(-latencyExpDuration - time.Second)
+	fl.snapshot["1"] = snapshot
+	fl.lastUpdateTime = time.Time{}
+	fl.UpdateScore(backends)
+	require.Zero(t, fl.snapshot["1"].p99[pnet.ComQuery])
+	require.Zero(t, fl.snapshot["1"].score)
+	require.Zero(t, fl.BalanceCount(backends[1], backends[0]))
+}
@@ -10,15 +10,19 @@ const (
 	balanceCount4Location = 1
 )
 
-var _ Factor = (*FactorLabel)(nil)
+var _ Factor = (*FactorLocation)(nil)
+var _ inputReporter = (*FactorLocation)(nil)
 
+// FactorLocation prefers the backends that are closer to TiProxy: the same rack, then the same zone, then the same
+// region. The score is the distance of the highest location level on which the backend differs from TiProxy.
 type FactorLocation struct {
 	bitNum int
 }
 
 func NewFactorLocation() *FactorLocation {
 	return &FactorLocation{
-		bitNum: 1,
+		// The max distance is 3 (cross-region).
+		bitNum: 2,
 	}
 }
 
@@ -31,11 +35,13 @@ func (fl *FactorLocation) UpdateScore(backends []scoredBackend) {
 		return
 	}
 	for i := 0; i < len(backends); i++ {
-		score := 1
-		if backends[i].Local() {
-			score = 0
-		}
-		backends[i].addScore(score, fl.bitNum)
+		backends[i].addScore(config.LocationDistance(backends[i].CrossLocationLevel()), fl.bitNum)
+	}
+}
+
+func (fl *FactorLocation) Inputs(backend scoredBackend) map[string]float64 {
+	return map[string]float64{
+		"location_distance": float64(config.LocationDistance(backend.CrossLocationLevel())),
 	}
 }
 
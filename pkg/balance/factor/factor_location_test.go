@@ -6,22 +6,39 @@ package factor
 import (
 	"testing"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/stretchr/testify/require"
 )
 
 func TestFactorLocationScore(t *testing.T) {
 	tests := []struct {
 		local         bool
+		crossLevel    string
 		expectedScore uint64
 	}{
 		{
 			local:         false,
-			expectedScore: 1,
+			expectedScore: 2,
 		},
 		{
 			local:         true,
 			expectedScore: 0,
 		},
+		{
+			local:         false,
+			crossLevel:    config.RackLabelName,
+			expectedScore: 1,
+		},
+		{
+			local:         false,
+			crossLevel:    config.LocationLabelName,
+			expectedScore: 2,
+		},
+		{
+			local:         false,
+			crossLevel:    config.RegionLabelName,
+			expectedScore: 3,
+		},
 	}
 
 	factor := NewFactorLocation()
@@ -29,7 +46,8 @@ func TestFactorLocationScore(t *testing.T) {
 	for _, test := range tests {
 		backends = append(backends, scoredBackend{
 			BackendCtx: &mockBackend{
-				local: test.local,
+				local:      test.local,
+				crossLevel: test.crossLevel,
 			},
 		})
 	}
@@ -24,7 +24,8 @@ const (
 	balanceSeconds4OOMRisk = 5.0
 )
 
-var _ Factor = (*FactorCPU)(nil)
+var _ Factor = (*FactorMemory)(nil)
+var _ inputReporter = (*FactorMemory)(nil)
 
 var (
 	memQueryExpr = metricsreader.QueryExpr{
@@ -69,17 +70,27 @@ var (
 	}
 )
 
+// newOOMRiskLevels returns the risk levels whose low-risk memory usage is threshold.
+// The high-risk memory usage is in the middle of the threshold and oomMemoryUsage.
+func newOOMRiskLevels(threshold float64) []oomRiskLevel {
+	levels := make([]oomRiskLevel, len(oomRiskLevels))
+	copy(levels, oomRiskLevels)
+	levels[0].memUsage = math.Max(threshold, (threshold+oomMemoryUsage)/2)
+	levels[1].memUsage = threshold
+	return levels
+}
+
 // 2: high risk
 // 1: low risk
 // 0: no risk
-func getRiskLevel(usage float64, timeToOOM time.Duration) (int, bool) {
+func (fm *FactorMemory) getRiskLevel(usage float64, timeToOOM time.Duration) (int, bool) {
 	level := 0
-	for j := 0; j < len(oomRiskLevels); j++ {
-		if timeToOOM < oomRiskLevels[j].timeToOOM {
-			return len(oomRiskLevels) - j, true
+	for j := 0; j < len(fm.riskLevels); j++ {
+		if timeToOOM < fm.riskLevels[j].timeToOOM {
+			return len(fm.riskLevels) - j, true
 		}
-		if usage > oomRiskLevels[j].memUsage {
-			return len(oomRiskLevels) - j, false
+		if usage > fm.riskLevels[j].memUsage {
+			return len(fm.riskLevels) - j, false
 		}
 	}
 	return level, false
@@ -98,7 +109,9 @@ type FactorMemory struct {
 	snapshot map[string]memBackendSnapshot
 	// The updated time of the metric that we've read last time.
 	lastMetricTime time.Time
+	riskLevels     []oomRiskLevel
 	mr             metricsreader.MetricsReader
+	balanceSeconds float64
 	bitNum         int
 }
 
@@ -111,9 +124,11 @@ func NewFactorMemory(mr metricsreader.MetricsReader) *FactorMemory {
 		levels = levels >> 1
 	}
 	fm := &FactorMemory{
-		mr:       mr,
-		bitNum:   bitNum,
-		snapshot: make(map[string]memBackendSnapshot),
+		mr:             mr,
+		bitNum:         bitNum,
+		riskLevels:     oomRiskLevels,
+		balanceSeconds: balanceSeconds4HighMemory,
+		snapshot:       make(map[string]memBackendSnapshot),
 	}
 	mr.AddQueryExpr(fm.Name(), memQueryExpr, memoryQueryRule)
 	return fm
@@ -232,17 +247,17 @@ func (fm *FactorMemory) calcMemScore(addr string) (int, bool) {
 	if !ok || usage < 0 {
 		return 0, false
 	}
-	return getRiskLevel(usage, timeToOOM)
+	return fm.getRiskLevel(usage, timeToOOM)
 }
 
 func (fm *FactorMemory) calcBalanceCount(backend scoredBackend, usage float64, timeToOOM time.Duration) float64 {
-	score, isOOM := getRiskLevel(usage, timeToOOM)
+	score, isOOM := fm.getRiskLevel(usage, timeToOOM)
 	if score < 2 {
 		return 0
 	}
 	// Assuming all backends have high memory and the user scales out a new backend, we don't want all the connections
 	// are migrated all at once.
-	seconds := balanceSeconds4HighMemory
+	seconds := fm.balanceSeconds
 	if isOOM {
 		seconds = balanceSeconds4OOMRisk
 	}
@@ -254,6 +269,17 @@ func (fm *FactorMemory) calcBalanceCount(backend scoredBackend, usage float64, t
 	return balanceCount
 }
 
+func (fm *FactorMemory) Inputs(backend scoredBackend) map[string]float64 {
+	snapshot, ok := fm.snapshot[backend.Addr()]
+	if !ok {
+		return nil
+	}
+	return map[string]float64{
+		"memory_usage":               snapshot.memUsage,
+		"memory_time_to_oom_seconds": snapshot.timeToOOM.Seconds(),
+	}
+}
+
 func (fm *FactorMemory) ScoreBitNum() int {
 	return fm.bitNum
 }
@@ -271,6 +297,12 @@ func (fm *FactorMemory) BalanceCount(from, to scoredBackend) float64 {
 }
 
 func (fm *FactorMemory) SetConfig(cfg *config.Config) {
+	factorCfg := cfg.Balance.Factor(fm.Name())
+	fm.riskLevels = oomRiskLevels
+	if factorCfg.Threshold > 0 {
+		fm.riskLevels = newOOMRiskLevels(factorCfg.Threshold)
+	}
+	fm.balanceSeconds = configOrDefault(factorCfg.BalanceSeconds, balanceSeconds4HighMemory)
 }
 
 func (fm *FactorMemory) Close() {
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
@@ -382,3 +383,34 @@ tidb_server_memory_quota_bytes 8e+08
 		require.Equal(t, test.finalValue, value, "case %d", i)
 	}
 }
+
+func TestMemoryConfig(t *testing.T) {
+	tests := []struct {
+		threshold float64
+		usage     float64
+		level     int
+	}{
+		{0, 0.55, 0},
+		{0, 0.65, 1},
+		{0, 0.8, 2},
+		{0.7, 0.65, 0},
+		{0.7, 0.75, 1},
+		{0.7, 0.85, 2},
+		{0.95, 0.9, 0},
+	}
+
+	fm := NewFactorMemory(newMockMetricsReader())
+	for i, test := range tests {
+		cfg := &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "memory", Threshold: test.threshold}}}}
+		fm.SetConfig(cfg)
+		level, _ := fm.getRiskLevel(test.usage, time.Duration(math.MaxInt64))
+		require.Equal(t, test.level, level, "test index %d", i)
+	}
+
+	cfg := &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "memory", BalanceSeconds: 10}}}}
+	fm.SetConfig(cfg)
+	require.EqualValues(t, 10, fm.balanceSeconds)
+	fm.SetConfig(&config.Config{})
+	require.EqualValues(t, balanceSeconds4HighMemory, fm.balanceSeconds)
+	require.Equal(t, oomRiskLevels, fm.riskLevels)
+}
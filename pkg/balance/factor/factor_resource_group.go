@@ -0,0 +1,142 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+)
+
+const (
+	// rgBalancedRatio is the threshold of ratio of the most connection count and least count of a resource group.
+	// If the ratio exceeds the threshold, we migrate connections of the resource group.
+	rgBalancedRatio = 1.2
+)
+
+var _ Factor = (*FactorResourceGroup)(nil)
+var _ hintedFactor = (*FactorResourceGroup)(nil)
+var _ inputReporter = (*FactorResourceGroup)(nil)
+
+// FactorResourceGroup spreads the connections of each resource group evenly across backends.
+// Without it, the connections of a resource group may gather on a few backends although the total connection counts
+// are balanced, and the backends can't make full use of the RU quota of the resource group.
+// When routing, the backend with the fewest connections of the resource group is preferred.
+// When balancing, the score of a backend is the largest excess connection count of all the unbalanced resource groups.
+type FactorResourceGroup struct {
+	// resourceGroup is the resource group of the connection to route.
+	resourceGroup string
+	// groupToBalance is the resource group decided by the last BalanceCount.
+	groupToBalance string
+	balancedRatio  float64
+	bitNum         int
+}
+
+func NewFactorResourceGroup() *FactorResourceGroup {
+	return &FactorResourceGroup{
+		bitNum:        16,
+		balancedRatio: rgBalancedRatio,
+	}
+}
+
+func (frg *FactorResourceGroup) Name() string {
+	return "resource-group"
+}
+
+func (frg *FactorResourceGroup) SetRouteHint(hint policy.RouteHint) {
+	frg.resourceGroup = hint.ResourceGroup
+}
+
+func (frg *FactorResourceGroup) UpdateScore(backends []scoredBackend) {
+	if len(backends) <= 1 {
+		return
+	}
+	if frg.resourceGroup != "" {
+		for i := 0; i < len(backends); i++ {
+			backends[i].addScore(backends[i].ResourceGroupConnCounts()[frg.resourceGroup], frg.bitNum)
+		}
+		return
+	}
+	minCounts := frg.minCounts(backends)
+	for i := 0; i < len(backends); i++ {
+		maxExcess := 0
+		for rg, count := range backends[i].ResourceGroupConnCounts() {
+			minCount := minCounts[rg]
+			if float64(count) > float64(minCount+1)*frg.balancedRatio && count-minCount > maxExcess {
+				maxExcess = count - minCount
+			}
+		}
+		backends[i].addScore(maxExcess, frg.bitNum)
+	}
+}
+
+// minCounts returns the least connection count of each resource group among the healthy backends.
+// A resource group that is absent on a healthy backend has count 0.
+func (frg *FactorResourceGroup) minCounts(backends []scoredBackend) map[string]int {
+	minCounts := make(map[string]int)
+	for i := 0; i < len(backends); i++ {
+		for rg := range backends[i].ResourceGroupConnCounts() {
+			minCounts[rg] = -1
+		}
+	}
+	for rg := range minCounts {
+		for i := 0; i < len(backends); i++ {
+			if !backends[i].Healthy() {
+				continue
+			}
+			count := backends[i].ResourceGroupConnCounts()[rg]
+			if minCounts[rg] < 0 || count < minCounts[rg] {
+				minCounts[rg] = count
+			}
+		}
+		// All the backends are unhealthy.
+		if minCounts[rg] < 0 {
+			minCounts[rg] = 0
+		}
+	}
+	return minCounts
+}
+
+func (frg *FactorResourceGroup) Inputs(backend scoredBackend) map[string]float64 {
+	counts := backend.ResourceGroupConnCounts()
+	inputs := make(map[string]float64, len(counts))
+	for rg, count := range counts {
+		inputs["rg_conn_count_"+rg] = float64(count)
+	}
+	return inputs
+}
+
+func (frg *FactorResourceGroup) ScoreBitNum() int {
+	return frg.bitNum
+}
+
+// BalanceCount finds the resource group with the largest skew between the 2 backends and records it so that only the
+// connections of the resource group are migrated.
+func (frg *FactorResourceGroup) BalanceCount(from, to scoredBackend) float64 {
+	frg.groupToBalance = ""
+	toCounts := to.ResourceGroupConnCounts()
+	maxSkew := 0
+	for rg, count := range from.ResourceGroupConnCounts() {
+		toCount := toCounts[rg]
+		if float64(count) > float64(toCount+1)*frg.balancedRatio && count-toCount > maxSkew {
+			maxSkew = count - toCount
+			frg.groupToBalance = rg
+		}
+	}
+	if frg.groupToBalance == "" {
+		return 0
+	}
+	return balanceCount4Conn
+}
+
+// GroupToBalance returns the resource group decided by the last BalanceCount.
+func (frg *FactorResourceGroup) GroupToBalance() string {
+	return frg.groupToBalance
+}
+
+func (frg *FactorResourceGroup) SetConfig(cfg *config.Config) {
+	frg.balancedRatio = configOrDefault(cfg.Balance.Factor(frg.Name()).Threshold, rgBalancedRatio)
+}
+
+func (frg *FactorResourceGroup) Close() {
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package factor
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceGroupScore(t *testing.T) {
+	tests := []struct {
+		rgConns        []map[string]int
+		healthy        []bool
+		hint           string
+		expectedScores []uint64
+	}{
+		{
+			// Balanced.
+			rgConns:        []map[string]int{{"rg1": 10, "rg2": 10}, {"rg1": 10, "rg2": 11}},
+			expectedScores: []uint64{0, 0},
+		},
+		{
+			// The total counts are balanced but each resource group is not.
+			rgConns:        []map[string]int{{"rg1": 20}, {"rg2": 20}},
+			expectedScores: []uint64{20, 20},
+		},
+		{
+			rgConns:        []map[string]int{{"rg1": 20, "rg2": 5}, {"rg1": 5, "rg2": 5}, {"rg1": 8}},
+			expectedScores: []uint64{15, 5, 3},
+		},
+		{
+			// The unhealthy backends are not counted as the least.
+			rgConns:        []map[string]int{{"rg1": 20}, {"rg1": 10}, {}},
+			healthy:        []bool{true, true, false},
+			expectedScores: []uint64{10, 0, 0},
+		},
+		{
+			// Route the connection of rg1.
+			rgConns:        []map[string]int{{"rg1": 20, "rg2": 5}, {"rg1": 5, "rg2": 50}, {}},
+			hint:           "rg1",
+			expectedScores: []uint64{20, 5, 0},
+		},
+		{
+			rgConns:        []map[string]int{{"rg1": 20, "rg2": 5}, {"rg1": 5, "rg2": 50}, {}},
+			hint:           "rg3",
+			expectedScores: []uint64{0, 0, 0},
+		},
+	}
+	for i, test := range tests {
+		factor := NewFactorResourceGroup()
+		backends := make([]scoredBackend, 0, len(test.rgConns))
+		for j, rgConns := range test.rgConns {
+			healthy := test.healthy == nil || test.healthy[j]
+			backend := newMockBackend(healthy, 0)
+			backend.rgConns = rgConns
+			backends = append(backends, scoredBackend{BackendCtx: backend})
+		}
+		factor.SetRouteHint(policy.RouteHint{ResourceGroup: test.hint})
+		factor.UpdateScore(backends)
+		for j, expected := range test.expectedScores {
+			require.Equal(t, expected, backends[j].score(), "test idx: %d, backend idx: %d", i, j)
+		}
+	}
+}
+
+func TestResourceGroupBalanceCount(t *testing.T) {
+	factor := NewFactorResourceGroup()
+	from := scoredBackend{BackendCtx: &mockBackend{rgConns: map[string]int{"rg1": 12, "rg2": 30, "rg3": 20}}}
+	to := scoredBackend{BackendCtx: &mockBackend{rgConns: map[string]int{"rg1": 2, "rg2": 25, "rg3": 20}}}
+	// rg2 is more skewed but it's within the threshold.
+	require.EqualValues(t, balanceCount4Conn, factor.BalanceCount(from, to))
+	require.Equal(t, "rg1", factor.GroupToBalance())
+	require.Zero(t, factor.BalanceCount(to, from))
+	require.Empty(t, factor.GroupToBalance())
+
+	cfg := &config.Config{Balance: config.Balance{Factors: []config.BalanceFactor{{Name: "resource-group", Threshold: 1.1}}}}
+	factor.SetConfig(cfg)
+	require.EqualValues(t, balanceCount4Conn, factor.BalanceCount(from, to))
+	require.Equal(t, "rg1", factor.GroupToBalance())
+	from.BackendCtx.(*mockBackend).rgConns["rg2"] = 40
+	require.EqualValues(t, balanceCount4Conn, factor.BalanceCount(from, to))
+	require.Equal(t, "rg2", factor.GroupToBalance())
+}
+
+func TestBalanceResourceGroup(t *testing.T) {
+	fbb := NewFactorBasedBalance(nil, newMockMetricsReader())
+	fbb.Init(&config.Config{Balance: config.Balance{UserResourceGroups: map[string]string{"u1": "rg1"}}})
+	backend1, backend2 := newMockBackend(true, 20), newMockBackend(true, 20)
+	backend1.addr, backend2.addr = "1", "2"
+	backend1.rgConns = map[string]int{"rg1": 15, "rg2": 5}
+	backend2.rgConns = map[string]int{"rg1": 5, "rg2": 12}
+	from, to, count, reason, fields := fbb.BackendsToBalance([]policy.BackendCtx{backend1, backend2})
+	require.Equal(t, "resource-group", reason)
+	require.Greater(t, count, 0.0)
+	require.Equal(t, backend1, from)
+	require.Equal(t, backend2, to)
+	// Only the connections of the skewed resource group on the busiest backend should be migrated.
+	require.Equal(t, "rg1", fbb.ResourceGroupToBalance())
+	require.Equal(t, "resource_group", fields[len(fields)-1].Key)
+
+	// Balanced by the conn factor, so any connection can be migrated.
+	backend1.rgConns, backend2.connScore = map[string]int{"rg1": 6}, 5
+	backend2.rgConns = map[string]int{"rg1": 5}
+	_, _, count, reason, _ = fbb.BackendsToBalance([]policy.BackendCtx{backend1, backend2})
+	require.Equal(t, "conn", reason)
+	require.Greater(t, count, 0.0)
+	require.Empty(t, fbb.ResourceGroupToBalance())
+}
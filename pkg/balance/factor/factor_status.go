@@ -22,14 +22,16 @@ type statusBackendSnapshot struct {
 var _ Factor = (*FactorStatus)(nil)
 
 type FactorStatus struct {
-	snapshot map[string]statusBackendSnapshot
-	bitNum   int
+	snapshot       map[string]statusBackendSnapshot
+	balanceSeconds float64
+	bitNum         int
 }
 
 func NewFactorStatus() *FactorStatus {
 	return &FactorStatus{
-		bitNum:   1,
-		snapshot: make(map[string]statusBackendSnapshot),
+		bitNum:         1,
+		balanceSeconds: balanceSeconds4Status,
+		snapshot:       make(map[string]statusBackendSnapshot),
 	}
 }
 
@@ -58,7 +60,7 @@ func (fs *FactorStatus) updateSnapshot(backends []scoredBackend) {
 			if existSnapshot && snapshot.balanceCount > 0.0001 {
 				balanceCount = snapshot.balanceCount
 			} else {
-				balanceCount = float64(backends[i].ConnScore()) / balanceSeconds4Status
+				balanceCount = float64(backends[i].ConnScore()) / fs.balanceSeconds
 			}
 		}
 		snapshots[addr] = statusBackendSnapshot{
@@ -77,6 +79,7 @@ func (fs *FactorStatus) BalanceCount(from, to scoredBackend) float64 {
 }
 
 func (fs *FactorStatus) SetConfig(cfg *config.Config) {
+	fs.balanceSeconds = configOrDefault(cfg.Balance.Factor(fs.Name()).BalanceSeconds, balanceSeconds4Status)
 }
 
 func (fs *FactorStatus) Close() {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"github.com/pingcap/tiproxy/pkg/balance/policy"
@@ -25,6 +26,12 @@ type mockBackend struct {
 	connCount int
 	healthy   bool
 	local     bool
+	// crossLevel is the location level on which the backend differs. It's zone by default if it's not local.
+	crossLevel string
+	latency    latency.Histograms
+	rgConns    map[string]int
+	// warmUpRatio is 1 by default.
+	warmUpRatio float64
 }
 
 func newMockBackend(healthy bool, connScore int) *mockBackend {
@@ -58,9 +65,37 @@ func (mb *mockBackend) Local() bool {
 	return mb.local
 }
 
+func (mb *mockBackend) CrossLocationLevel() string {
+	if mb.local {
+		return ""
+	}
+	if len(mb.crossLevel) == 0 {
+		return config.LocationLabelName
+	}
+	return mb.crossLevel
+}
+
+func (mb *mockBackend) CollectLatency() latency.Histograms {
+	hists := mb.latency
+	mb.latency = latency.Histograms{}
+	return hists
+}
+
+func (mb *mockBackend) ResourceGroupConnCounts() map[string]int {
+	return mb.rgConns
+}
+
+func (mb *mockBackend) WarmUpRatio() float64 {
+	if mb.warmUpRatio == 0 {
+		return 1
+	}
+	return mb.warmUpRatio
+}
+
 var _ Factor = (*mockFactor)(nil)
 
 type mockFactor struct {
+	name         string
 	bitNum       int
 	balanceCount float64
 	updateScore  func(backends []scoredBackend)
@@ -68,6 +103,9 @@ type mockFactor struct {
 }
 
 func (mf *mockFactor) Name() string {
+	if mf.name != "" {
+		return mf.name
+	}
 	return "mock"
 }
 
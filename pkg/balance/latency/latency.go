@@ -0,0 +1,108 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package latency
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
+
+const (
+	// The upper bound of bucket i is minBound << i, so the buckets range from 100us to about 14 minutes.
+	// The resolution is coarse, but it's enough to tell whether a backend is much slower than others.
+	minBound  = 100 * time.Microsecond
+	bucketNum = 24
+)
+
+// Histogram is the distribution of the latencies of one command type.
+type Histogram [bucketNum]uint64
+
+// Histograms are the histograms of all the command types.
+type Histograms [pnet.ComEnd]Histogram
+
+// Count returns the total count of the samples.
+func (h *Histogram) Count() uint64 {
+	var count uint64
+	for _, c := range h {
+		count += c
+	}
+	return count
+}
+
+// Quantile returns the estimated q-quantile (0 < q <= 1) by interpolating linearly within the bucket.
+// It returns 0 if there are no samples.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+	rank := q * float64(count)
+	var cum float64
+	for i, c := range h {
+		if c == 0 {
+			continue
+		}
+		if cum+float64(c) >= rank {
+			lower, upper := bucketLowerBound(i), bucketUpperBound(i)
+			return lower + time.Duration(float64(upper-lower)*(rank-cum)/float64(c))
+		}
+		cum += float64(c)
+	}
+	return bucketUpperBound(bucketNum - 1)
+}
+
+func bucketLowerBound(idx int) time.Duration {
+	if idx == 0 {
+		return 0
+	}
+	return minBound << (idx - 1)
+}
+
+func bucketUpperBound(idx int) time.Duration {
+	return minBound << idx
+}
+
+func bucketIndex(d time.Duration) int {
+	if d <= minBound {
+		return 0
+	}
+	// The smallest idx that satisfies d <= minBound << idx.
+	idx := bits.Len64(uint64((d - 1) / minBound))
+	if idx >= bucketNum {
+		idx = bucketNum - 1
+	}
+	return idx
+}
+
+// Tracker records the latencies of the commands on a backend.
+// Observe is called by each connection concurrently, so it's lock-free.
+type Tracker struct {
+	buckets [pnet.ComEnd][bucketNum]atomic.Uint64
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Observe records the latency of a command.
+func (t *Tracker) Observe(cmd pnet.Command, d time.Duration) {
+	if cmd >= pnet.ComEnd {
+		return
+	}
+	t.buckets[cmd][bucketIndex(d)].Add(1)
+}
+
+// Collect returns the histograms since the last collection and resets them.
+func (t *Tracker) Collect() Histograms {
+	var hists Histograms
+	for cmd := range t.buckets {
+		for i := range t.buckets[cmd] {
+			hists[cmd][i] = t.buckets[cmd][i].Swap(0)
+		}
+	}
+	return hists
+}
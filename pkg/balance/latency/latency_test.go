@@ -0,0 +1,81 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package latency
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketIndex(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		idx     int
+	}{
+		{0, 0},
+		{minBound, 0},
+		{minBound + 1, 1},
+		{2 * minBound, 1},
+		{3 * minBound, 2},
+		{4 * minBound, 2},
+		{1000 * minBound, 10},
+		{time.Hour, bucketNum - 1},
+	}
+	for i, test := range tests {
+		idx := bucketIndex(test.latency)
+		require.Equal(t, test.idx, idx, "case %d", i)
+		require.LessOrEqual(t, bucketLowerBound(idx), test.latency, "case %d", i)
+		if idx < bucketNum-1 {
+			require.GreaterOrEqual(t, bucketUpperBound(idx), test.latency, "case %d", i)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	var hist Histogram
+	require.Zero(t, hist.Quantile(0.99))
+
+	// 99 fast samples and 1 slow sample.
+	hist[bucketIndex(time.Millisecond)] = 99
+	hist[bucketIndex(time.Second)] = 1
+	require.EqualValues(t, 100, hist.Count())
+	p50 := hist.Quantile(0.5)
+	require.Greater(t, p50, bucketLowerBound(bucketIndex(time.Millisecond)))
+	require.LessOrEqual(t, p50, bucketUpperBound(bucketIndex(time.Millisecond)))
+	require.LessOrEqual(t, hist.Quantile(0.99), bucketUpperBound(bucketIndex(time.Millisecond)))
+	p100 := hist.Quantile(1)
+	require.Greater(t, p100, bucketLowerBound(bucketIndex(time.Second)))
+	require.LessOrEqual(t, p100, bucketUpperBound(bucketIndex(time.Second)))
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tracker.Observe(pnet.ComQuery, time.Millisecond)
+				tracker.Observe(pnet.ComStmtExecute, 10*time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	tracker.Observe(pnet.ComEnd, time.Millisecond)
+
+	hists := tracker.Collect()
+	require.EqualValues(t, 1000, hists[pnet.ComQuery].Count())
+	require.EqualValues(t, 1000, hists[pnet.ComStmtExecute].Count())
+	require.Zero(t, hists[pnet.ComPing].Count())
+	require.Greater(t, hists[pnet.ComStmtExecute].Quantile(0.99), hists[pnet.ComQuery].Quantile(0.99))
+
+	// Collecting resets the histograms.
+	hists = tracker.Collect()
+	require.Zero(t, hists[pnet.ComQuery].Count())
+}
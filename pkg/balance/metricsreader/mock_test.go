@@ -13,6 +13,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/logger"
 	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"github.com/pingcap/tiproxy/pkg/balance/policy"
 	"github.com/pingcap/tiproxy/pkg/manager/cert"
@@ -150,6 +151,22 @@ func (mb *mockBackend) Local() bool {
 	return true
 }
 
+func (mb *mockBackend) CrossLocationLevel() string {
+	return ""
+}
+
+func (mb *mockBackend) CollectLatency() latency.Histograms {
+	return latency.Histograms{}
+}
+
+func (mb *mockBackend) ResourceGroupConnCounts() map[string]int {
+	return nil
+}
+
+func (mb *mockBackend) WarmUpRatio() float64 {
+	return 1
+}
+
 func mockMfs() map[string]*dto.MetricFamily {
 	floats := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
 	return map[string]*dto.MetricFamily{
@@ -84,7 +84,7 @@ func (qr QueryResult) GetSamplePair4Backend(backend policy.BackendCtx) []model.S
 		return nil
 	}
 	matrix := qr.Value.(model.Matrix)
-	labelValue := getLabel4Backend(backend)
+	labelValue := GetLabel4Backend(backend)
 	for _, m := range matrix {
 		if label, ok := m.Metric[LabelNameInstance]; ok {
 			if labelValue == (string)(label) {
@@ -104,7 +104,7 @@ func (qr QueryResult) GetSample4Backend(backend policy.BackendCtx) *model.Sample
 		return nil
 	}
 	vector := qr.Value.(model.Vector)
-	labelValue := getLabel4Backend(backend)
+	labelValue := GetLabel4Backend(backend)
 	for _, m := range vector {
 		if label, ok := m.Metric[LabelNameInstance]; ok {
 			if labelValue == (string)(label) {
@@ -115,7 +115,8 @@ func (qr QueryResult) GetSample4Backend(backend policy.BackendCtx) *model.Sample
 	return nil
 }
 
-func getLabel4Backend(backend policy.BackendCtx) string {
+// GetLabel4Backend returns the value of the `instance` label of the backend in the metrics.
+func GetLabel4Backend(backend policy.BackendCtx) string {
 	addr := backend.Addr()
 	if strings.Contains(addr, ".svc:") {
 		// In operator deployment, the label value of `instance` is the pod name.
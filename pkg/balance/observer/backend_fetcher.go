@@ -79,6 +79,8 @@ func (pf *PDFetcher) fetchBackendList(ctx context.Context) map[string]*infosync.
 }
 
 // StaticFetcher uses configured static addrs. This is only used for testing.
+// The addresses are host:port, Unix sockets in the form of unix:///path/to/socket,
+// or Windows named pipes in the form of npipe:////./pipe/name.
 type StaticFetcher struct {
 	backends map[string]*BackendInfo
 }
@@ -16,29 +16,21 @@ type BackendHealth struct {
 	PingErr error
 	// The backend version that returned to the client during handshake.
 	ServerVersion string
-	// Whether the backend in the same zone with TiProxy. If TiProxy location is undefined, take all backends as local.
+	// Whether the backend in the same location with TiProxy. If TiProxy location is undefined, take all backends as local.
 	Local bool
+	// The highest location level (region, zone or rack) on which the backend differs from TiProxy.
+	// Empty if the backend is local.
+	CrossLocationLevel string
 }
 
 func (bh *BackendHealth) setLocal(cfg *config.Config) {
-	if cfg.Labels == nil {
-		bh.Local = true
-		return
-	}
-	selfLocation := cfg.GetLocation()
-	if len(selfLocation) == 0 {
-		bh.Local = true
-		return
-	}
-	if bh.Labels != nil && bh.Labels[config.LocationLabelName] == selfLocation {
-		bh.Local = true
-		return
-	}
-	bh.Local = false
+	bh.CrossLocationLevel = cfg.CrossLocationLevel(bh.Labels)
+	bh.Local = len(bh.CrossLocationLevel) == 0
 }
 
 func (bh *BackendHealth) Equals(health BackendHealth) bool {
-	return bh.Healthy == health.Healthy && bh.ServerVersion == health.ServerVersion && bh.Local == health.Local
+	return bh.Healthy == health.Healthy && bh.ServerVersion == health.ServerVersion && bh.Local == health.Local &&
+		bh.CrossLocationLevel == health.CrossLocationLevel
 }
 
 func (bh *BackendHealth) String() string {
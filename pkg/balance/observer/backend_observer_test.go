@@ -158,6 +158,7 @@ func TestLocal(t *testing.T) {
 		selfLabels    map[string]string
 		backendLabels map[string]string
 		local         bool
+		crossLevel    string
 	}{
 		{
 			selfLabels:    nil,
@@ -178,22 +179,49 @@ func TestLocal(t *testing.T) {
 			selfLabels:    map[string]string{config.LocationLabelName: "b"},
 			backendLabels: map[string]string{"a": "b"},
 			local:         false,
+			crossLevel:    config.LocationLabelName,
 		},
 		{
 			selfLabels:    map[string]string{config.LocationLabelName: "b"},
 			backendLabels: map[string]string{config.LocationLabelName: "c"},
 			local:         false,
+			crossLevel:    config.LocationLabelName,
 		},
 		{
 			selfLabels:    map[string]string{config.LocationLabelName: "b"},
 			backendLabels: map[string]string{config.LocationLabelName: "c", "a": "c"},
 			local:         false,
+			crossLevel:    config.LocationLabelName,
 		},
 		{
 			selfLabels:    map[string]string{config.LocationLabelName: "b"},
 			backendLabels: map[string]string{config.LocationLabelName: "b", "a": "c"},
 			local:         true,
 		},
+		{
+			selfLabels:    map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z1", config.RackLabelName: "k1"},
+			backendLabels: map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z1", config.RackLabelName: "k2"},
+			local:         false,
+			crossLevel:    config.RackLabelName,
+		},
+		{
+			selfLabels:    map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z1", config.RackLabelName: "k1"},
+			backendLabels: map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z2", config.RackLabelName: "k1"},
+			local:         false,
+			crossLevel:    config.LocationLabelName,
+		},
+		{
+			selfLabels:    map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z1"},
+			backendLabels: map[string]string{config.RegionLabelName: "r2", config.LocationLabelName: "z1"},
+			local:         false,
+			crossLevel:    config.RegionLabelName,
+		},
+		{
+			// The rack is ignored because TiProxy doesn't define it.
+			selfLabels:    map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z1"},
+			backendLabels: map[string]string{config.RegionLabelName: "r1", config.LocationLabelName: "z1", config.RackLabelName: "k1"},
+			local:         true,
+		},
 	}
 
 	for i, test := range tests {
@@ -207,7 +235,7 @@ func TestLocal(t *testing.T) {
 			result := ts.getResultFromCh()
 			require.NoError(ts.t, result.Error())
 			health, ok := result.Backends()[backend]
-			return ok && health.Local == test.local
+			return ok && health.Local == test.local && health.CrossLocationLevel == test.crossLevel
 		}, 3*time.Second, 10*time.Millisecond, "test case %d", i)
 		ts.removeBackend(backend)
 	}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"net"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -86,6 +87,35 @@ func TestHealthCheck(t *testing.T) {
 	backend.close()
 }
 
+func TestHealthCheckUnixSocket(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	cfg := newHealthCheckConfigForTest()
+	hc := NewDefaultHealthCheck(nil, cfg, lg)
+	path := filepath.Join(t.TempDir(), "tidb.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	var wg waitgroup.WaitGroup
+	wg.Run(func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			require.NoError(t, packet.NewConn(conn).WritePacket([]byte{0, 0, 0, 0, 0}))
+			_ = conn.Close()
+		}
+	})
+
+	// The static backends have no status port, so only the SQL port is checked.
+	addr := "unix://" + path
+	health := hc.Check(context.Background(), addr, &BackendInfo{})
+	require.True(t, health.Healthy)
+	require.NoError(t, listener.Close())
+	wg.Wait()
+	health = hc.Check(context.Background(), addr, &BackendInfo{})
+	require.False(t, health.Healthy)
+}
+
 type backendServer struct {
 	t            *testing.T
 	sqlListener  net.Listener
@@ -5,18 +5,59 @@ package policy
 
 import (
 	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"go.uber.org/zap"
 )
 
 type BalancePolicy interface {
 	Init(cfg *config.Config)
-	BackendToRoute(backends []BackendCtx) BackendCtx
+	BackendToRoute(backends []BackendCtx, hint RouteHint) BackendCtx
 	// balanceCount is the count of connections to balance per second.
 	BackendsToBalance(backends []BackendCtx) (from, to BackendCtx, balanceCount float64, reason string, logFields []zap.Field)
+	// BalanceScores returns the score breakdown of the backends for debugging.
+	BalanceScores(backends []BackendCtx) BalanceScores
 	SetConfig(cfg *config.Config)
 }
 
+// RouteHint is the information of the connection to be routed.
+type RouteHint struct {
+	// AffinityKey identifies the client. The connections with the same key are preferably routed to the same backend.
+	// Empty means no affinity.
+	AffinityKey string
+	// ResourceGroup is the resource group of the connection.
+	ResourceGroup string
+}
+
+// FactorScore is the score of a backend calculated by a factor.
+type FactorScore struct {
+	Factor string `json:"factor"`
+	Score  uint64 `json:"score"`
+	BitNum int    `json:"bit_num"`
+}
+
+// BackendScore is the score breakdown of a backend.
+type BackendScore struct {
+	Addr    string `json:"addr"`
+	Healthy bool   `json:"healthy"`
+	// Score is the combined score of all the factors.
+	Score uint64 `json:"score"`
+	// FactorScores are ordered by the priorities of the factors.
+	FactorScores []FactorScore `json:"factor_scores,omitempty"`
+	// Inputs are the raw values to calculate the scores, such as CPU usage and connection score.
+	Inputs map[string]float64 `json:"inputs,omitempty"`
+}
+
+// BalanceScores are the scores of all the backends and the current balance decision.
+type BalanceScores struct {
+	Backends []BackendScore `json:"backends"`
+	// BalanceFactor is the factor that currently drives balancing. It's empty if the backends are balanced.
+	BalanceFactor string  `json:"balance_factor,omitempty"`
+	From          string  `json:"from,omitempty"`
+	To            string  `json:"to,omitempty"`
+	BalanceCount  float64 `json:"balance_count,omitempty"`
+}
+
 type BackendCtx interface {
 	Addr() string
 	// ConnCount indicates the count of current connections.
@@ -25,5 +66,22 @@ type BackendCtx interface {
 	ConnScore() int
 	Healthy() bool
 	Local() bool
+	// CrossLocationLevel returns the highest location level on which the backend differs from TiProxy.
+	// Empty means the backend is local.
+	CrossLocationLevel() string
 	GetBackendInfo() observer.BackendInfo
+	// CollectLatency returns the command latencies since the last collection.
+	CollectLatency() latency.Histograms
+	// ResourceGroupConnCounts returns the connection counts of each resource group, including the incoming connections.
+	ResourceGroupConnCounts() map[string]int
+	// WarmUpRatio returns the share (0~1] of connections that the backend can take while it's warming up.
+	// 1 means the backend has warmed up.
+	WarmUpRatio() float64
+}
+
+// ResourceGroupBalancer is implemented by the policies that may balance the connections of a specific resource group.
+type ResourceGroupBalancer interface {
+	// ResourceGroupToBalance returns the resource group of the connections to migrate, which is decided by the last
+	// BackendsToBalance call. Empty means the connections of any resource group.
+	ResourceGroupToBalance() string
 }
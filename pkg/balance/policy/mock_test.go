@@ -3,7 +3,10 @@
 
 package policy
 
-import "github.com/pingcap/tiproxy/pkg/balance/observer"
+import (
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
+	"github.com/pingcap/tiproxy/pkg/balance/observer"
+)
 
 var _ BackendCtx = (*mockBackend)(nil)
 
@@ -42,3 +45,19 @@ func (mb *mockBackend) Local() bool {
 func (mb *mockBackend) GetBackendInfo() observer.BackendInfo {
 	return observer.BackendInfo{}
 }
+
+func (mb *mockBackend) CollectLatency() latency.Histograms {
+	return latency.Histograms{}
+}
+
+func (mb *mockBackend) CrossLocationLevel() string {
+	return ""
+}
+
+func (mb *mockBackend) ResourceGroupConnCounts() map[string]int {
+	return nil
+}
+
+func (mb *mockBackend) WarmUpRatio() float64 {
+	return 1
+}
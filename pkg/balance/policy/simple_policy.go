@@ -33,7 +33,7 @@ func NewSimpleBalancePolicy() *SimpleBalancePolicy {
 func (sbp *SimpleBalancePolicy) Init(cfg *config.Config) {
 }
 
-func (sbp *SimpleBalancePolicy) BackendToRoute(backends []BackendCtx) BackendCtx {
+func (sbp *SimpleBalancePolicy) BackendToRoute(backends []BackendCtx, _ RouteHint) BackendCtx {
 	if len(backends) == 0 {
 		return nil
 	}
@@ -72,6 +72,23 @@ func (sbp *SimpleBalancePolicy) BackendsToBalance(backends []BackendCtx) (from,
 	return
 }
 
+func (sbp *SimpleBalancePolicy) BalanceScores(backends []BackendCtx) BalanceScores {
+	var scores BalanceScores
+	scores.Backends = make([]BackendScore, 0, len(backends))
+	for _, backend := range backends {
+		scores.Backends = append(scores.Backends, BackendScore{
+			Addr:    backend.Addr(),
+			Healthy: backend.Healthy(),
+			Inputs:  map[string]float64{"conn_score": float64(backend.ConnScore())},
+		})
+	}
+	from, to, balanceCount, reason, _ := sbp.BackendsToBalance(backends)
+	if balanceCount > 0 {
+		scores.BalanceFactor, scores.From, scores.To, scores.BalanceCount = reason, from.Addr(), to.Addr(), balanceCount
+	}
+	return scores
+}
+
 func sortBackends(backends []BackendCtx) {
 	sort.Slice(backends, func(i, j int) bool {
 		if backends[i].Healthy() && !backends[j].Healthy() {
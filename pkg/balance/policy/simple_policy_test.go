@@ -74,7 +74,7 @@ func TestSimplePolicy(t *testing.T) {
 		if test.toIdx >= 0 {
 			toBackend = newMockBackend(test.backends[test.toIdx].Healthy(), test.backends[test.toIdx].ConnScore())
 		}
-		backend := sbp.BackendToRoute(test.backends)
+		backend := sbp.BackendToRoute(test.backends, RouteHint{})
 		if test.routeIdx >= 0 {
 			require.Equal(t, routeBackend.healthy, backend.Healthy(), "test idx: %d", idx)
 			require.Equal(t, routeBackend.connScore, backend.ConnScore(), "test idx: %d", idx)
@@ -92,5 +92,8 @@ func TestSimplePolicy(t *testing.T) {
 			require.Nil(t, from, "test idx: %d", idx)
 			require.Nil(t, to, "test idx: %d", idx)
 		}
+		scores := sbp.BalanceScores(test.backends)
+		require.Len(t, scores.Backends, len(test.backends), "test idx: %d", idx)
+		require.Equal(t, test.reason, scores.BalanceFactor, "test idx: %d", idx)
 	}
 }
@@ -0,0 +1,99 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// balanceHistorySize is the max number of balance records kept in each router.
+	balanceHistorySize = 1000
+)
+
+// The results of balance decisions.
+const (
+	// The connection is redirecting.
+	BalanceResultPending = "pending"
+	BalanceResultSucceed = "succeed"
+	BalanceResultFail    = "fail"
+	// The connection refuses to redirect, e.g. it's closing.
+	BalanceResultRefused = "refused"
+	// The connection is not redirected because of the dry-run mode.
+	BalanceResultDryRun = "dry-run"
+)
+
+// BalanceRecord is a balance decision to migrate a connection.
+type BalanceRecord struct {
+	ID     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	ConnID uint64    `json:"conn_id"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+	// Fields are the log fields returned by the balance policy, including the score breakdown of each factor.
+	Fields map[string]any `json:"fields,omitempty"`
+	Result string         `json:"result"`
+}
+
+// balanceHistory is a ring buffer of balance records. It's not concurrency-safe.
+type balanceHistory struct {
+	records []BalanceRecord
+	// lastID is the ID of the latest record. IDs start from 1 so that 0 means no record.
+	lastID uint64
+}
+
+func newBalanceHistory(size int) *balanceHistory {
+	return &balanceHistory{
+		records: make([]BalanceRecord, size),
+	}
+}
+
+// add appends a record and returns its ID. The oldest record is overwritten if the buffer is full.
+func (bh *balanceHistory) add(record BalanceRecord) uint64 {
+	bh.lastID++
+	record.ID = bh.lastID
+	bh.records[bh.lastID%uint64(len(bh.records))] = record
+	return record.ID
+}
+
+// setResult updates the result of a record. It does nothing if the record has been overwritten.
+func (bh *balanceHistory) setResult(id uint64, result string) {
+	if id == 0 {
+		return
+	}
+	record := &bh.records[id%uint64(len(bh.records))]
+	if record.ID == id {
+		record.Result = result
+	}
+}
+
+// list returns the records in chronological order.
+func (bh *balanceHistory) list() []BalanceRecord {
+	size := uint64(len(bh.records))
+	first := uint64(1)
+	if bh.lastID > size {
+		first = bh.lastID - size + 1
+	}
+	records := make([]BalanceRecord, 0, bh.lastID-first+1)
+	for id := first; id <= bh.lastID; id++ {
+		records = append(records, bh.records[id%size])
+	}
+	return records
+}
+
+// fieldsToMap converts the log fields to a map so that they can be marshalled to JSON.
+func fieldsToMap(fields []zap.Field) map[string]any {
+	if len(fields) == 0 {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	return enc.Fields
+}
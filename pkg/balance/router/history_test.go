@@ -0,0 +1,44 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBalanceHistoryRing(t *testing.T) {
+	bh := newBalanceHistory(3)
+	require.Empty(t, bh.list())
+
+	ids := make([]uint64, 0, 5)
+	for i := 0; i < 5; i++ {
+		ids = append(ids, bh.add(BalanceRecord{ConnID: uint64(i), Result: BalanceResultPending}))
+	}
+	require.Equal(t, []uint64{1, 2, 3, 4, 5}, ids)
+
+	// The overwritten record is not updated.
+	bh.setResult(ids[0], BalanceResultSucceed)
+	bh.setResult(ids[3], BalanceResultFail)
+	bh.setResult(0, BalanceResultFail)
+	records := bh.list()
+	require.Len(t, records, 3)
+	for i, record := range records {
+		require.EqualValues(t, i+2, record.ConnID)
+		require.Equal(t, ids[i+2], record.ID)
+		if record.ID == ids[3] {
+			require.Equal(t, BalanceResultFail, record.Result)
+		} else {
+			require.Equal(t, BalanceResultPending, record.Result)
+		}
+	}
+}
+
+func TestFieldsToMap(t *testing.T) {
+	require.Nil(t, fieldsToMap(nil))
+	m := fieldsToMap([]zap.Field{zap.String("factor", "cpu"), zap.Uint64("from_score", 10)})
+	require.Equal(t, map[string]any{"factor": "cpu", "from_score": uint64(10)}, m)
+}
@@ -33,6 +33,10 @@ func addMigrateMetrics(from, to, reason string, succeed bool, startTime time.Tim
 	metrics.MigrateDurationHistogram.WithLabelValues(from, to, resLabel).Observe(cost.Seconds())
 }
 
+func addDryRunMigrateMetrics(from, to, reason string, count int) {
+	metrics.DryRunMigrateCounter.WithLabelValues(from, to, reason).Add(float64(count))
+}
+
 func readMigrateCounter(from, to string, succeed bool) (int, error) {
 	v1, err := metrics.ReadCounter(metrics.MigrateCounter.WithLabelValues(from, to, "status", succeedToLabel(succeed)))
 	if err != nil {
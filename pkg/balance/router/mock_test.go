@@ -26,6 +26,8 @@ type mockRedirectableConn struct {
 	to       BackendInst
 	receiver ConnEventReceiver
 	closing  bool
+	// resourceGroup is the resource group read from the session states.
+	resourceGroup string
 }
 
 func newMockRedirectableConn(t *testing.T, id uint64) *mockRedirectableConn {
@@ -80,6 +82,12 @@ func (conn *mockRedirectableConn) ConnectionID() uint64 {
 	return conn.connID
 }
 
+func (conn *mockRedirectableConn) ResourceGroup() string {
+	conn.Lock()
+	defer conn.Unlock()
+	return conn.resourceGroup
+}
+
 func (conn *mockRedirectableConn) getAddr() (string, string) {
 	conn.Lock()
 	defer conn.Unlock()
@@ -186,13 +194,14 @@ type mockBalancePolicy struct {
 	cfg               atomic.Pointer[config.Config]
 	backendsToBalance func([]policy.BackendCtx) (from policy.BackendCtx, to policy.BackendCtx, balanceCount float64, reason string, logFields []zapcore.Field)
 	backendToRoute    func([]policy.BackendCtx) policy.BackendCtx
+	rgToBalance       string
 }
 
 func (m *mockBalancePolicy) Init(cfg *config.Config) {
 	m.cfg.Store(cfg)
 }
 
-func (m *mockBalancePolicy) BackendToRoute(backends []policy.BackendCtx) policy.BackendCtx {
+func (m *mockBalancePolicy) BackendToRoute(backends []policy.BackendCtx, _ policy.RouteHint) policy.BackendCtx {
 	if m.backendToRoute != nil {
 		return m.backendToRoute(backends)
 	}
@@ -206,6 +215,14 @@ func (m *mockBalancePolicy) BackendsToBalance(backends []policy.BackendCtx) (fro
 	return nil, nil, 0, "", nil
 }
 
+func (m *mockBalancePolicy) ResourceGroupToBalance() string {
+	return m.rgToBalance
+}
+
+func (m *mockBalancePolicy) BalanceScores(backends []policy.BackendCtx) policy.BalanceScores {
+	return policy.BalanceScores{}
+}
+
 func (m *mockBalancePolicy) SetConfig(cfg *config.Config) {
 	m.cfg.Store(cfg)
 }
@@ -9,11 +9,18 @@ import (
 
 	glist "github.com/bahlo/generic-list-go"
 	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
 )
 
 var (
 	ErrNoBackend = errors.New("no available backend")
+	// ErrBackendsFull means all backends reach the max connections and the connection should be rejected.
+	ErrBackendsFull = errors.New("all backends reach the max connections")
+	// ErrWaitForBackend means all backends reach the max connections and the connection should retry later.
+	ErrWaitForBackend = errors.New("all backends reach the max connections, wait for available backends")
 )
 
 // ConnEventReceiver receives connection events.
@@ -28,13 +35,18 @@ type Router interface {
 	// ConnEventReceiver handles connection events to balance connections if possible.
 	ConnEventReceiver
 
-	GetBackendSelector() BackendSelector
+	// GetBackendSelector returns a selector to route the client connection.
+	GetBackendSelector(info ClientInfo) BackendSelector
 	HealthyBackendCount() int
 	RefreshBackend()
 	RedirectConnections() error
 	ConnCount() int
 	// ServerVersion returns the TiDB version.
 	ServerVersion() string
+	// BalanceHistory returns the recent balance decisions in chronological order.
+	BalanceHistory() []BalanceRecord
+	// BalanceScores returns the score breakdown of the backends.
+	BalanceScores() policy.BalanceScores
 	Close()
 }
 
@@ -57,8 +69,21 @@ const (
 	// After a connection fails to redirect, it may contain some unmigratable status.
 	// Limit its redirection interval to avoid unnecessary retrial to reduce latency jitter.
 	redirectFailMinInterval = 3 * time.Second
+	// The resource group of the users that are not configured, which is the same as TiDB.
+	defaultResourceGroup = "default"
+	// minWarmUpRatio is the share of connections that a backend can take when it just starts warming up.
+	minWarmUpRatio = 0.1
 )
 
+// ClientInfo is the identity of a client connection. It's used to route the connections with session affinity.
+type ClientInfo struct {
+	ClientAddr string
+	User       string
+	Attrs      map[string]string
+	// ProxyTLVs are the TLVs in the PROXY protocol header, indexed by the names in the proxyprotocol package.
+	ProxyTLVs map[string]string
+}
+
 // RedirectableConn indicates a redirect-able connection.
 type RedirectableConn interface {
 	SetEventReceiver(receiver ConnEventReceiver)
@@ -67,6 +92,8 @@ type RedirectableConn interface {
 	// Redirect returns false if the current conn is not redirectable.
 	Redirect(backend BackendInst) bool
 	ConnectionID() uint64
+	// ResourceGroup returns the resource group read from the session states. Empty means unknown.
+	ResourceGroup() string
 }
 
 // BackendInst defines a backend that a connection is redirecting to.
@@ -74,6 +101,10 @@ type BackendInst interface {
 	Addr() string
 	Healthy() bool
 	Local() bool
+	// CrossLocationLevel returns the highest location level on which the backend differs from TiProxy.
+	CrossLocationLevel() string
+	// ObserveLatency records the latency of a command executed on the backend.
+	ObserveLatency(cmd pnet.Command, d time.Duration)
 }
 
 // backendWrapper contains the connections on the backend.
@@ -86,15 +117,24 @@ type backendWrapper struct {
 	// connScore is used for calculating backend scores and check if the backend can be removed from the list.
 	// connScore = connList.Len() + incoming connections - outgoing connections.
 	connScore int
+	// rgConnScores is the connScore of each resource group.
+	rgConnScores map[string]int
 	// A list of *connWrapper and is ordered by the connecting or redirecting time.
 	// connList only includes the connections that are currently on this backend.
 	connList *glist.List[*connWrapper]
+	// latency records the command latencies reported by the connections on this backend.
+	latency *latency.Tracker
+	// warmUpStart is the time when the backend becomes healthy. It's zero if the backend doesn't need warm-up.
+	warmUpStart    time.Time
+	warmUpDuration time.Duration
 }
 
 func newBackendWrapper(addr string, health observer.BackendHealth) *backendWrapper {
 	wrapper := &backendWrapper{
-		addr:     addr,
-		connList: glist.New[*connWrapper](),
+		addr:         addr,
+		connList:     glist.New[*connWrapper](),
+		latency:      latency.NewTracker(),
+		rgConnScores: make(map[string]int),
 	}
 	wrapper.setHealth(health)
 	return wrapper
@@ -110,6 +150,46 @@ func (b *backendWrapper) ConnScore() int {
 	return b.connScore
 }
 
+// addConnScore updates connScore and the connScore of the resource group.
+func (b *backendWrapper) addConnScore(resourceGroup string, delta int) {
+	b.connScore += delta
+	// The resource group is empty if resource-group balance is disabled.
+	if resourceGroup == "" {
+		return
+	}
+	score := b.rgConnScores[resourceGroup] + delta
+	if score == 0 {
+		delete(b.rgConnScores, resourceGroup)
+	} else {
+		b.rgConnScores[resourceGroup] = score
+	}
+}
+
+// startWarmUp ramps the share of connections of the backend in the duration.
+func (b *backendWrapper) startWarmUp(now time.Time, duration time.Duration) {
+	b.warmUpStart = now
+	b.warmUpDuration = duration
+}
+
+// WarmUpRatio ramps up linearly from minWarmUpRatio to 1 during warm-up.
+func (b *backendWrapper) WarmUpRatio() float64 {
+	if b.warmUpStart.IsZero() || b.warmUpDuration <= 0 {
+		return 1
+	}
+	ratio := float64(time.Since(b.warmUpStart)) / float64(b.warmUpDuration)
+	if ratio >= 1 {
+		// Avoid calculating it again.
+		b.warmUpStart = time.Time{}
+		return 1
+	}
+	return minWarmUpRatio + (1-minWarmUpRatio)*ratio
+}
+
+// ResourceGroupConnCounts returns the connScore of each resource group. The caller must not modify it.
+func (b *backendWrapper) ResourceGroupConnCounts() map[string]int {
+	return b.rgConnScores
+}
+
 func (b *backendWrapper) Addr() string {
 	return b.addr
 }
@@ -139,6 +219,13 @@ func (b *backendWrapper) Local() bool {
 	return local
 }
 
+func (b *backendWrapper) CrossLocationLevel() string {
+	b.mu.RLock()
+	level := b.mu.CrossLocationLevel
+	b.mu.RUnlock()
+	return level
+}
+
 func (b *backendWrapper) GetBackendInfo() observer.BackendInfo {
 	b.mu.RLock()
 	info := b.mu.BackendInfo
@@ -146,6 +233,14 @@ func (b *backendWrapper) GetBackendInfo() observer.BackendInfo {
 	return info
 }
 
+func (b *backendWrapper) ObserveLatency(cmd pnet.Command, d time.Duration) {
+	b.latency.Observe(cmd, d)
+}
+
+func (b *backendWrapper) CollectLatency() latency.Histograms {
+	return b.latency.Collect()
+}
+
 func (b *backendWrapper) Equals(health observer.BackendHealth) bool {
 	b.mu.RLock()
 	equal := b.mu.BackendHealth.Equals(health)
@@ -169,5 +264,11 @@ type connWrapper struct {
 	redirectingBackend *backendWrapper
 	// Last redirect start time of this connection.
 	lastRedirect time.Time
-	phase        connPhase
+	// The ID of the balance record of the current redirection, 0 if it's not recorded.
+	historyID uint64
+	// The connection is routed with an affinity key, so it's only migrated when the backend is unhealthy.
+	affinity bool
+	// The resource group of the connection.
+	resourceGroup string
+	phase         connPhase
 }
@@ -43,6 +43,21 @@ type ScoreBasedRouter struct {
 	serverVersion string
 	// To limit the speed of redirection.
 	lastRedirectTime time.Time
+	// In dry-run mode, the connections to be migrated are only logged.
+	dryRun bool
+	// The config of session affinity.
+	affinity config.BalanceAffinity
+	// userResourceGroups maps the users to their resource groups.
+	userResourceGroups map[string]string
+	// warmUp is the window in which a new or recovered backend ramps up its share of connections.
+	warmUp time.Duration
+	// connLimit limits the connection count of each backend.
+	connLimit config.BalanceConnLimit
+	// healthInitialized is set after the first health result. The backends in the first result don't warm up
+	// because they may have been serving before TiProxy starts.
+	healthInitialized bool
+	// history records the recent balance decisions.
+	history *balanceHistory
 }
 
 // NewScoreBasedRouter creates a ScoreBasedRouter.
@@ -50,6 +65,7 @@ func NewScoreBasedRouter(logger *zap.Logger) *ScoreBasedRouter {
 	return &ScoreBasedRouter{
 		logger:   logger,
 		backends: make(map[string]*backendWrapper),
+		history:  newBalanceHistory(balanceHistorySize),
 	}
 }
 
@@ -57,6 +73,13 @@ func (r *ScoreBasedRouter) Init(ctx context.Context, ob observer.BackendObserver
 	r.observer = ob
 	r.healthCh = r.observer.Subscribe("score_based_router")
 	r.policy = balancePolicy
+	if cfg != nil {
+		r.dryRun = cfg.Balance.DryRun
+		r.affinity = cfg.Balance.Affinity
+		r.userResourceGroups = cfg.Balance.UserResourceGroups
+		r.warmUp = warmUpDuration(cfg)
+		r.connLimit = cfg.Balance.ConnLimit
+	}
 	balancePolicy.Init(cfg)
 	childCtx, cancelFunc := context.WithCancel(ctx)
 	r.cancelFunc = cancelFunc
@@ -68,13 +91,67 @@ func (r *ScoreBasedRouter) Init(ctx context.Context, ob observer.BackendObserver
 }
 
 // GetBackendSelector implements Router.GetBackendSelector interface.
-func (router *ScoreBasedRouter) GetBackendSelector() BackendSelector {
+func (router *ScoreBasedRouter) GetBackendSelector(info ClientInfo) BackendSelector {
+	router.Lock()
+	hint := policy.RouteHint{
+		AffinityKey:   affinityKey(router.affinity, info),
+		ResourceGroup: resourceGroup(router.userResourceGroups, info.User),
+	}
+	router.Unlock()
 	return BackendSelector{
-		routeOnce: router.routeOnce,
-		onCreate:  router.onCreateConn,
+		routeOnce: func(excluded []BackendInst) (BackendInst, error) {
+			return router.routeOnce(excluded, hint)
+		},
+		onCreate: func(backend BackendInst, conn RedirectableConn, succeed bool) {
+			router.onCreateConn(backend, conn, hint, succeed)
+		},
 	}
 }
 
+// affinityKey returns the key to hash the client to a backend. It returns empty if affinity is disabled.
+func affinityKey(cfg config.BalanceAffinity, info ClientInfo) string {
+	switch cfg.Key {
+	case config.AffinityKeyClientIP:
+		host, _, err := net.SplitHostPort(info.ClientAddr)
+		if err != nil {
+			return info.ClientAddr
+		}
+		return host
+	case config.AffinityKeyUser:
+		return info.User
+	case config.AffinityKeyConnAttr:
+		return info.Attrs[cfg.ConnAttr]
+	case config.AffinityKeyProxyTLV:
+		return info.ProxyTLVs[cfg.ProxyTLV]
+	}
+	return ""
+}
+
+// resourceGroup returns the resource group of the user. It returns empty if resource-group balance is disabled.
+func resourceGroup(userResourceGroups map[string]string, user string) string {
+	if len(userResourceGroups) == 0 {
+		return ""
+	}
+	if rg, ok := userResourceGroups[user]; ok {
+		return rg
+	}
+	return defaultResourceGroup
+}
+
+func warmUpDuration(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.Balance.WarmUpSeconds * float64(time.Second))
+}
+
+// healthReason returns true if the connections are migrated for health reasons. In this case, the connections are
+// migrated regardless of affinity and warm-up.
+func healthReason(reason string) bool {
+	switch reason {
+	case config.BalanceFactorStatus, config.BalanceFactorHealth, config.BalanceFactorMemory:
+		return true
+	}
+	return false
+}
+
 func (router *ScoreBasedRouter) HealthyBackendCount() int {
 	router.Lock()
 	defer router.Unlock()
@@ -99,7 +176,7 @@ func (router *ScoreBasedRouter) setConnWrapper(conn RedirectableConn, ce *glist.
 	conn.SetValue(_routerKey, ce)
 }
 
-func (router *ScoreBasedRouter) routeOnce(excluded []BackendInst) (BackendInst, error) {
+func (router *ScoreBasedRouter) routeOnce(excluded []BackendInst, hint policy.RouteHint) (BackendInst, error) {
 	router.Lock()
 	defer router.Unlock()
 	if router.observeError != nil {
@@ -107,10 +184,15 @@ func (router *ScoreBasedRouter) routeOnce(excluded []BackendInst) (BackendInst,
 	}
 
 	backends := make([]policy.BackendCtx, 0, len(router.backends))
+	full := false
 	for _, backend := range router.backends {
 		if !backend.Healthy() {
 			continue
 		}
+		if router.availableConns(backend) == 0 {
+			full = true
+			continue
+		}
 		// Exclude the backends that are already tried.
 		found := false
 		for _, e := range excluded {
@@ -125,7 +207,13 @@ func (router *ScoreBasedRouter) routeOnce(excluded []BackendInst) (BackendInst,
 		backends = append(backends, backend)
 	}
 
-	idlestBackend := router.policy.BackendToRoute(backends)
+	idlestBackend := router.policy.BackendToRoute(backends, hint)
+	if (idlestBackend == nil || reflect.ValueOf(idlestBackend).IsNil()) && full {
+		if router.connLimit.Strategy == config.ConnLimitStrategyQueue {
+			return nil, ErrWaitForBackend
+		}
+		return nil, ErrBackendsFull
+	}
 	if idlestBackend == nil || reflect.ValueOf(idlestBackend).IsNil() {
 		// No available backends, maybe the health check result is outdated during rolling restart.
 		// Refresh the backends asynchronously in this case.
@@ -135,11 +223,20 @@ func (router *ScoreBasedRouter) routeOnce(excluded []BackendInst) (BackendInst,
 		return nil, ErrNoBackend
 	}
 	backend := idlestBackend.(*backendWrapper)
-	backend.connScore++
+	backend.addConnScore(hint.ResourceGroup, 1)
 	return backend, nil
 }
 
-func (router *ScoreBasedRouter) onCreateConn(backendInst BackendInst, conn RedirectableConn, succeed bool) {
+// availableConns returns how many more connections the backend can take. It returns -1 if it's unlimited.
+func (router *ScoreBasedRouter) availableConns(backend *backendWrapper) int {
+	limit := router.connLimit.MaxConnectionsOf(backend.GetBackendInfo().Labels)
+	if limit <= 0 {
+		return -1
+	}
+	return max(limit-backend.connScore, 0)
+}
+
+func (router *ScoreBasedRouter) onCreateConn(backendInst BackendInst, conn RedirectableConn, hint policy.RouteHint, succeed bool) {
 	router.Lock()
 	defer router.Unlock()
 	backend := router.ensureBackend(backendInst.Addr())
@@ -147,11 +244,13 @@ func (router *ScoreBasedRouter) onCreateConn(backendInst BackendInst, conn Redir
 		connWrapper := &connWrapper{
 			RedirectableConn: conn,
 			phase:            phaseNotRedirected,
+			affinity:         hint.AffinityKey != "",
+			resourceGroup:    hint.ResourceGroup,
 		}
 		router.addConn(backend, connWrapper)
 		conn.SetEventReceiver(router)
 	} else {
-		backend.connScore--
+		backend.addConnScore(hint.ResourceGroup, -1)
 	}
 }
 
@@ -184,6 +283,7 @@ func (router *ScoreBasedRouter) RedirectConnections() error {
 			if connWrapper.phase != phaseRedirectNotify {
 				connWrapper.phase = phaseRedirectNotify
 				connWrapper.redirectReason = "test"
+				connWrapper.historyID = 0
 				// Ignore the results.
 				_ = connWrapper.Redirect(backend)
 				connWrapper.redirectingBackend = backend
@@ -232,15 +332,27 @@ func (router *ScoreBasedRouter) onRedirectFinished(from, to string, conn Redirec
 	connWrapper := router.getConnWrapper(conn).Value
 	if succeed {
 		router.removeConn(fromBackend, router.getConnWrapper(conn))
+		// The resource group may be changed by the session, and it's updated from the session states.
+		if rg := conn.ResourceGroup(); rg != "" && connWrapper.resourceGroup != "" && rg != connWrapper.resourceGroup {
+			toBackend.addConnScore(connWrapper.resourceGroup, -1)
+			toBackend.addConnScore(rg, 1)
+			connWrapper.resourceGroup = rg
+		}
 		router.addConn(toBackend, connWrapper)
 		connWrapper.phase = phaseRedirectEnd
 	} else {
-		fromBackend.connScore++
-		toBackend.connScore--
+		fromBackend.addConnScore(connWrapper.resourceGroup, 1)
+		toBackend.addConnScore(connWrapper.resourceGroup, -1)
 		router.removeBackendIfEmpty(toBackend)
 		connWrapper.phase = phaseRedirectFail
 	}
 	connWrapper.redirectingBackend = nil
+	if succeed {
+		router.history.setResult(connWrapper.historyID, BalanceResultSucceed)
+	} else {
+		router.history.setResult(connWrapper.historyID, BalanceResultFail)
+	}
+	connWrapper.historyID = 0
 	addMigrateMetrics(from, to, connWrapper.redirectReason, succeed, connWrapper.lastRedirect)
 }
 
@@ -253,11 +365,13 @@ func (router *ScoreBasedRouter) OnConnClosed(addr string, conn RedirectableConn)
 	redirectingBackend := connWrapper.Value.redirectingBackend
 	// If this connection is redirecting, decrease the score of the target backend.
 	if redirectingBackend != nil {
-		redirectingBackend.connScore--
+		redirectingBackend.addConnScore(connWrapper.Value.resourceGroup, -1)
 		connWrapper.Value.redirectingBackend = nil
 		router.removeBackendIfEmpty(redirectingBackend)
+		// The connection is closed before the redirection finishes.
+		router.history.setResult(connWrapper.Value.historyID, BalanceResultFail)
 	} else {
-		backend.connScore--
+		backend.addConnScore(connWrapper.Value.resourceGroup, -1)
 	}
 	router.removeConn(backend, connWrapper)
 	return nil
@@ -283,14 +397,25 @@ func (router *ScoreBasedRouter) updateBackendHealth(healthResults observer.Healt
 			}
 		}
 	}
+	warmUp := router.healthInitialized && router.warmUp > 0
+	router.healthInitialized = true
+	now := time.Now()
 	var serverVersion string
 	for addr, health := range backends {
 		backend, ok := router.backends[addr]
 		if !ok && health.Healthy {
-			router.backends[addr] = newBackendWrapper(addr, *health)
+			backend = newBackendWrapper(addr, *health)
+			if warmUp {
+				backend.startWarmUp(now, router.warmUp)
+			}
+			router.backends[addr] = backend
 			serverVersion = health.ServerVersion
 		} else if ok {
 			if !backend.Equals(*health) {
+				// The recovered backend also needs to warm up.
+				if warmUp && !backend.Healthy() && health.Healthy {
+					backend.startWarmUp(now, router.warmUp)
+				}
 				backend.setHealth(*health)
 				router.removeBackendIfEmpty(backend)
 				if health.Healthy {
@@ -313,8 +438,21 @@ func (router *ScoreBasedRouter) rebalanceLoop(ctx context.Context) {
 			return
 		case healthResults := <-router.healthCh:
 			router.updateBackendHealth(healthResults)
-		case cfg := <-router.cfgCh:
+		case cfg, ok := <-router.cfgCh:
+			// The channel is closed when the config manager closes, and a nil channel never receives.
+			if !ok {
+				router.cfgCh = nil
+				continue
+			}
+			// The policy is read under the lock when routing and rebalancing.
+			router.Lock()
 			router.policy.SetConfig(cfg)
+			router.dryRun = cfg.Balance.DryRun
+			router.affinity = cfg.Balance.Affinity
+			router.userResourceGroups = cfg.Balance.UserResourceGroups
+			router.warmUp = warmUpDuration(cfg)
+			router.connLimit = cfg.Balance.ConnLimit
+			router.Unlock()
 		case <-ticker.C:
 			router.rebalance(ctx)
 		}
@@ -341,6 +479,15 @@ func (router *ScoreBasedRouter) rebalance(ctx context.Context) {
 		return
 	}
 	fromBackend, toBackend := busiestBackend.(*backendWrapper), idlestBackend.(*backendWrapper)
+	// Migrate slowly to a warming-up backend unless the connections are evicted for health reasons.
+	if !healthReason(reason) {
+		balanceCount *= toBackend.WarmUpRatio()
+	}
+	// Only migrate the connections of the unbalanced resource group if the policy decides so.
+	var rgToBalance string
+	if rgBalancer, ok := router.policy.(policy.ResourceGroupBalancer); ok {
+		rgToBalance = rgBalancer.ResourceGroupToBalance()
+	}
 
 	// Control the speed of migration.
 	curTime := time.Now()
@@ -357,30 +504,68 @@ func (router *ScoreBasedRouter) rebalance(ctx context.Context) {
 			return
 		}
 	}
+	// Don't migrate more connections than the target backend can take.
+	if available := router.availableConns(toBackend); available >= 0 {
+		count = min(count, available)
+	}
 	// Migrate balanceCount connections.
-	for i := 0; i < count && ctx.Err() == nil; i++ {
-		var ce *glist.Element[*connWrapper]
-		for ele := fromBackend.connList.Front(); ele != nil; ele = ele.Next() {
-			conn := ele.Value
-			switch conn.phase {
-			case phaseRedirectNotify:
-				// A connection cannot be redirected again when it has not finished redirecting.
+	migrated := 0
+	keepAffinity := !healthReason(reason)
+	// The fields are shared by the records of this round.
+	fieldMap := fieldsToMap(logFields)
+	for ele := fromBackend.connList.Front(); ele != nil && migrated < count && ctx.Err() == nil; ele = ele.Next() {
+		conn := ele.Value
+		if keepAffinity && conn.affinity {
+			continue
+		}
+		if rgToBalance != "" && conn.resourceGroup != rgToBalance {
+			continue
+		}
+		switch conn.phase {
+		case phaseRedirectNotify:
+			// A connection cannot be redirected again when it has not finished redirecting.
+			continue
+		case phaseRedirectFail:
+			// If it failed recently, it will probably fail this time.
+			if conn.lastRedirect.Add(redirectFailMinInterval).After(curTime) {
 				continue
-			case phaseRedirectFail:
-				// If it failed recently, it will probably fail this time.
-				if conn.lastRedirect.Add(redirectFailMinInterval).After(curTime) {
-					continue
-				}
 			}
-			ce = ele
-			break
 		}
-		if ce == nil {
-			break
+		record := BalanceRecord{
+			Time:   curTime,
+			ConnID: conn.ConnectionID(),
+			From:   fromBackend.addr,
+			To:     toBackend.addr,
+			Reason: reason,
+			Fields: fieldMap,
+			Result: BalanceResultDryRun,
+		}
+		if router.dryRun {
+			router.history.add(record)
+		} else {
+			router.redirectConn(conn, fromBackend, toBackend, reason, logFields, curTime)
+			if conn.phase == phaseRedirectNotify {
+				record.Result = BalanceResultPending
+				conn.historyID = router.history.add(record)
+			} else {
+				record.Result = BalanceResultRefused
+				router.history.add(record)
+			}
 		}
-		router.redirectConn(ce.Value, fromBackend, toBackend, reason, logFields, curTime)
+		migrated++
 		router.lastRedirectTime = curTime
 	}
+	if router.dryRun && migrated > 0 {
+		fields := []zap.Field{
+			zap.String("from", fromBackend.addr),
+			zap.String("to", toBackend.addr),
+			zap.Int("count", migrated),
+			zap.Float64("balance_count", balanceCount),
+		}
+		fields = append(fields, logFields...)
+		router.logger.Info("dry-run: skip migrating connections", fields...)
+		addDryRunMigrateMetrics(fromBackend.addr, toBackend.addr, reason, migrated)
+	}
 }
 
 func (router *ScoreBasedRouter) redirectConn(conn *connWrapper, fromBackend *backendWrapper, toBackend *backendWrapper,
@@ -394,9 +579,9 @@ func (router *ScoreBasedRouter) redirectConn(conn *connWrapper, fromBackend *bac
 		}
 		fields = append(fields, logFields...)
 		router.logger.Debug("begin redirect connection", fields...)
-		fromBackend.connScore--
+		fromBackend.addConnScore(conn.resourceGroup, -1)
 		router.removeBackendIfEmpty(fromBackend)
-		toBackend.connScore++
+		toBackend.addConnScore(conn.resourceGroup, 1)
 		conn.phase = phaseRedirectNotify
 		conn.redirectReason = reason
 		conn.redirectingBackend = toBackend
@@ -417,6 +602,24 @@ func (router *ScoreBasedRouter) removeBackendIfEmpty(backend *backendWrapper) bo
 	return false
 }
 
+// BalanceHistory implements Router.BalanceHistory interface.
+func (router *ScoreBasedRouter) BalanceHistory() []BalanceRecord {
+	router.Lock()
+	defer router.Unlock()
+	return router.history.list()
+}
+
+// BalanceScores implements Router.BalanceScores interface.
+func (router *ScoreBasedRouter) BalanceScores() policy.BalanceScores {
+	router.Lock()
+	defer router.Unlock()
+	backends := make([]policy.BackendCtx, 0, len(router.backends))
+	for _, backend := range router.backends {
+		backends = append(backends, backend)
+	}
+	return router.policy.BalanceScores(backends)
+}
+
 func (router *ScoreBasedRouter) ConnCount() int {
 	router.Lock()
 	defer router.Unlock()
@@ -117,6 +117,10 @@ func (tester *routerTester) updateBackendLocalityByAddr(addr string, local bool)
 	health, ok := tester.backends[addr]
 	require.True(tester.t, ok)
 	health.Local = local
+	health.CrossLocationLevel = ""
+	if !local {
+		health.CrossLocationLevel = config.LocationLabelName
+	}
 	tester.notifyHealth()
 }
 
@@ -128,7 +132,7 @@ func (tester *routerTester) getBackendByIndex(index int) *backendWrapper {
 }
 
 func (tester *routerTester) simpleRoute(conn RedirectableConn) BackendInst {
-	selector := tester.router.GetBackendSelector()
+	selector := tester.router.GetBackendSelector(ClientInfo{})
 	backend, err := selector.Next()
 	if err != ErrNoBackend {
 		require.NoError(tester.t, err)
@@ -336,7 +340,7 @@ func TestNoBackends(t *testing.T) {
 func TestSelectorReturnOrder(t *testing.T) {
 	tester := newRouterTester(t, nil)
 	tester.addBackends(3)
-	selector := tester.router.GetBackendSelector()
+	selector := tester.router.GetBackendSelector(ClientInfo{})
 	for i := 0; i < 3; i++ {
 		addrs := make(map[string]struct{}, 3)
 		for j := 0; j < 3; j++ {
@@ -373,7 +377,7 @@ func TestRouteConcurrently(t *testing.T) {
 	selectors := make([]BackendSelector, 0, 30)
 	// All the clients are calling Next() but not yet Finish().
 	for i := 0; i < 30; i++ {
-		selector := tester.router.GetBackendSelector()
+		selector := tester.router.GetBackendSelector(ClientInfo{})
 		backend, err := selector.Next()
 		require.NoError(t, err)
 		addrs[backend.Addr()]++
@@ -603,7 +607,7 @@ func TestConcurrency(t *testing.T) {
 					if conn == nil {
 						// not connected, connect
 						conn = newMockRedirectableConn(t, connID)
-						selector := router.GetBackendSelector()
+						selector := router.GetBackendSelector(ClientInfo{})
 						backend, err := selector.Next()
 						if err == ErrNoBackend {
 							conn = nil
@@ -657,7 +661,7 @@ func TestRefresh(t *testing.T) {
 	t.Cleanup(bo.Close)
 	t.Cleanup(rt.Close)
 	// The initial backends are empty.
-	selector := rt.GetBackendSelector()
+	selector := rt.GetBackendSelector(ClientInfo{})
 	_, err := selector.Next()
 	require.Equal(t, ErrNoBackend, err)
 	// Refresh is called internally and there comes a new one.
@@ -679,7 +683,7 @@ func TestObserveError(t *testing.T) {
 	// Mock an observe error.
 	bo.notify(errors.New("mock observe error"))
 	require.Eventually(t, func() bool {
-		selector := rt.GetBackendSelector()
+		selector := rt.GetBackendSelector(ClientInfo{})
 		_, err := selector.Next()
 		return err != nil && err != ErrNoBackend
 	}, 3*time.Second, 10*time.Millisecond)
@@ -687,7 +691,7 @@ func TestObserveError(t *testing.T) {
 	bo.addBackend("0")
 	bo.notify(nil)
 	require.Eventually(t, func() bool {
-		selector := rt.GetBackendSelector()
+		selector := rt.GetBackendSelector(ClientInfo{})
 		_, err := selector.Next()
 		return err == nil
 	}, 3*time.Second, 10*time.Millisecond)
@@ -772,6 +776,8 @@ func TestUpdateBackendHealth(t *testing.T) {
 	tester.updateBackendLocalityByAddr(tester.getBackendByIndex(1).Addr(), true)
 	require.Equal(t, false, tester.router.backends[tester.getBackendByIndex(0).Addr()].Local())
 	require.Equal(t, true, tester.router.backends[tester.getBackendByIndex(1).Addr()].Local())
+	require.Equal(t, config.LocationLabelName, tester.router.backends[tester.getBackendByIndex(0).Addr()].CrossLocationLevel())
+	require.Empty(t, tester.router.backends[tester.getBackendByIndex(1).Addr()].CrossLocationLevel())
 	// Test some backends are not in the list anymore.
 	tester.removeBackends(1)
 	tester.checkBackendNum(2)
@@ -804,6 +810,11 @@ func TestWatchConfig(t *testing.T) {
 	require.Eventually(t, func() bool {
 		return policy.getConfig().Labels["k1"] == "v2"
 	}, 3*time.Second, 10*time.Millisecond)
+
+	// The router keeps the last config after the channel is closed.
+	close(cfgCh)
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, "v2", policy.getConfig().Labels["k1"])
 }
 
 func TestControlSpeed(t *testing.T) {
@@ -928,3 +939,273 @@ func TestRedirectFail(t *testing.T) {
 	require.Equal(t, 1, tester.getBackendByIndex(0).connScore)
 	require.Equal(t, 0, tester.getBackendByIndex(1).connScore)
 }
+
+func TestDryRun(t *testing.T) {
+	tester := newRouterTester(t, nil)
+	tester.addBackends(1)
+	tester.addConnections(10)
+	tester.killBackends(1)
+	tester.addBackends(1)
+	tester.router.dryRun = true
+	tester.rebalance(1)
+	// The connections are not redirected in dry-run mode.
+	tester.checkRedirectingNum(0)
+	require.Equal(t, 10, tester.getBackendByIndex(0).connScore)
+	require.Equal(t, 0, tester.getBackendByIndex(1).connScore)
+	from, to := tester.getBackendByIndex(0).Addr(), tester.getBackendByIndex(1).Addr()
+	count, err := metrics.ReadCounter(metrics.DryRunMigrateCounter.WithLabelValues(from, to, "status"))
+	require.NoError(t, err)
+	require.Greater(t, count, 0)
+
+	tester.router.dryRun = false
+	tester.rebalance(1)
+	require.Equal(t, 0, tester.getBackendByIndex(0).connScore)
+	require.Equal(t, 10, tester.getBackendByIndex(1).connScore)
+}
+
+func TestBalanceHistory(t *testing.T) {
+	tester := newRouterTester(t, nil)
+	tester.addBackends(1)
+	tester.addConnections(10)
+	tester.killBackends(1)
+	tester.addBackends(1)
+	tester.rebalance(1)
+	tester.checkRedirectingNum(10)
+	records := tester.router.BalanceHistory()
+	require.Len(t, records, 10)
+	from, to := tester.getBackendByIndex(0).Addr(), tester.getBackendByIndex(1).Addr()
+	for i, record := range records {
+		require.EqualValues(t, i+1, record.ID)
+		require.Equal(t, from, record.From)
+		require.Equal(t, to, record.To)
+		require.Equal(t, "status", record.Reason)
+		require.Equal(t, BalanceResultPending, record.Result)
+	}
+
+	tester.redirectFinish(6, true)
+	tester.redirectFinish(4, false)
+	results := make(map[string]int)
+	for _, record := range tester.router.BalanceHistory() {
+		results[record.Result]++
+	}
+	require.Equal(t, map[string]int{BalanceResultSucceed: 6, BalanceResultFail: 4}, results)
+}
+
+func TestAffinityKey(t *testing.T) {
+	info := ClientInfo{
+		ClientAddr: "10.0.0.1:3306",
+		User:       "root",
+		Attrs:      map[string]string{"app_name": "app1"},
+		ProxyTLVs:  map[string]string{"aws-vpce-id": "vpce-1"},
+	}
+	tests := []struct {
+		cfg    config.BalanceAffinity
+		info   ClientInfo
+		expect string
+	}{
+		{
+			cfg:    config.BalanceAffinity{},
+			info:   info,
+			expect: "",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyClientIP},
+			info:   info,
+			expect: "10.0.0.1",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyClientIP},
+			info:   ClientInfo{ClientAddr: "/tmp/tidb.sock"},
+			expect: "/tmp/tidb.sock",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyUser},
+			info:   info,
+			expect: "root",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyConnAttr, ConnAttr: "app_name"},
+			info:   info,
+			expect: "app1",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyConnAttr, ConnAttr: "program_name"},
+			info:   info,
+			expect: "",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyProxyTLV, ProxyTLV: "aws-vpce-id"},
+			info:   info,
+			expect: "vpce-1",
+		},
+		{
+			cfg:    config.BalanceAffinity{Key: config.AffinityKeyProxyTLV, ProxyTLV: "aws-vpce-id"},
+			info:   ClientInfo{ClientAddr: "10.0.0.1:3306"},
+			expect: "",
+		},
+	}
+	for i, test := range tests {
+		require.Equal(t, test.expect, affinityKey(test.cfg, test.info), "case %d", i)
+	}
+}
+
+func TestKeepAffinityWhenBalancing(t *testing.T) {
+	tester := newRouterTester(t, nil)
+	tester.router.affinity = config.BalanceAffinity{Key: config.AffinityKeyUser}
+	tester.addBackends(1)
+	for i := 0; i < 10; i++ {
+		conn := tester.createConn()
+		selector := tester.router.GetBackendSelector(ClientInfo{User: "user" + strconv.Itoa(i)})
+		backend, err := selector.Next()
+		require.NoError(t, err)
+		selector.Finish(conn, true)
+		conn.from = backend
+		tester.conns[conn.connID] = conn
+	}
+	// The connections with affinity are not migrated to balance connection counts.
+	tester.addBackends(1)
+	tester.rebalance(1)
+	tester.checkRedirectingNum(0)
+
+	// But they are migrated when the backend is unhealthy.
+	tester.updateBackendStatusByAddr(tester.getBackendByIndex(0).Addr(), false)
+	tester.rebalance(1)
+	tester.checkRedirectingNum(10)
+}
+
+func TestBalanceResourceGroup(t *testing.T) {
+	bp := &mockBalancePolicy{}
+	bp.backendToRoute = func(backends []policy.BackendCtx) policy.BackendCtx {
+		return backends[0]
+	}
+	tester := newRouterTester(t, bp)
+	tester.router.userResourceGroups = map[string]string{"u1": "rg1", "u2": "rg2"}
+	tester.addBackends(1)
+	for i := 0; i < 9; i++ {
+		conn := tester.createConn()
+		selector := tester.router.GetBackendSelector(ClientInfo{User: "u" + strconv.Itoa(i%3+1)})
+		backend, err := selector.Next()
+		require.NoError(t, err)
+		selector.Finish(conn, true)
+		conn.from = backend
+		tester.conns[conn.connID] = conn
+	}
+	// The users that are not configured are in the default resource group.
+	backend1 := tester.getBackendByIndex(0)
+	require.Equal(t, map[string]int{"rg1": 3, "rg2": 3, "default": 3}, backend1.ResourceGroupConnCounts())
+	require.Equal(t, 9, backend1.ConnScore())
+
+	// Only the connections of the resource group to balance are migrated.
+	tester.addBackends(1)
+	backend2 := tester.getBackendByIndex(1)
+	bp.rgToBalance = "rg1"
+	bp.backendsToBalance = func(backends []policy.BackendCtx) (from, to policy.BackendCtx, balanceCount float64, reason string, logFields []zapcore.Field) {
+		return backend1, backend2, 100, "conn", nil
+	}
+	tester.rebalance(10)
+	tester.checkRedirectingNum(3)
+	require.Equal(t, map[string]int{"rg1": 3}, backend2.ResourceGroupConnCounts())
+	require.Equal(t, map[string]int{"rg2": 3, "default": 3}, backend1.ResourceGroupConnCounts())
+
+	// The resource group is updated from the session states after migration.
+	for _, conn := range tester.conns {
+		if len(conn.GetRedirectingAddr()) > 0 {
+			conn.resourceGroup = "rg3"
+			break
+		}
+	}
+	tester.redirectFinish(3, true)
+	require.Equal(t, map[string]int{"rg1": 2, "rg3": 1}, backend2.ResourceGroupConnCounts())
+
+	tester.closeConnections(9, false)
+	require.Empty(t, backend1.ResourceGroupConnCounts())
+	require.Empty(t, backend2.ResourceGroupConnCounts())
+}
+
+func TestWarmUp(t *testing.T) {
+	bp := &mockBalancePolicy{}
+	bp.backendToRoute = func(backends []policy.BackendCtx) policy.BackendCtx {
+		return backends[0]
+	}
+	tester := newRouterTester(t, bp)
+	tester.router.warmUp = time.Hour
+	// The backends in the first health result don't warm up.
+	tester.addBackends(1)
+	backend1 := tester.getBackendByIndex(0)
+	require.EqualValues(t, 1, backend1.WarmUpRatio())
+	tester.addConnections(20)
+
+	// The new backend warms up.
+	tester.addBackends(1)
+	backend2 := tester.getBackendByIndex(1)
+	require.InDelta(t, minWarmUpRatio, backend2.WarmUpRatio(), 0.01)
+
+	// Migrate slowly to the warming-up backend.
+	reason := "conn"
+	bp.backendsToBalance = func(backends []policy.BackendCtx) (from, to policy.BackendCtx, balanceCount float64, r string, logFields []zapcore.Field) {
+		return backend1, backend2, 500, reason, nil
+	}
+	tester.rebalance(1)
+	tester.checkRedirectingNum(1)
+	tester.redirectFinish(1, true)
+	// But evict connections quickly for health reasons.
+	reason = "status"
+	tester.rebalance(1)
+	tester.checkRedirectingNum(5)
+	tester.redirectFinish(5, true)
+
+	// The recovered backend also warms up.
+	tester.updateBackendStatusByAddr(backend1.Addr(), false)
+	require.EqualValues(t, 1, backend1.WarmUpRatio())
+	tester.updateBackendStatusByAddr(backend1.Addr(), true)
+	require.InDelta(t, minWarmUpRatio, backend1.WarmUpRatio(), 0.01)
+
+	// It ramps up and finally warms up.
+	backend1.startWarmUp(time.Now().Add(-30*time.Minute), time.Hour)
+	require.InDelta(t, 0.55, backend1.WarmUpRatio(), 0.01)
+	backend1.startWarmUp(time.Now().Add(-time.Hour), time.Hour)
+	require.EqualValues(t, 1, backend1.WarmUpRatio())
+}
+
+func TestConnLimit(t *testing.T) {
+	tester := newRouterTester(t, nil)
+	tester.router.connLimit = config.BalanceConnLimit{MaxConnections: 2, Strategy: config.ConnLimitStrategyReject}
+	tester.addBackends(2)
+	tester.addConnections(4)
+	for i := 0; i < 2; i++ {
+		require.Equal(t, 2, tester.getBackendByIndex(i).connScore)
+	}
+
+	// Reject the connection when all backends are full.
+	selector := tester.router.GetBackendSelector(ClientInfo{})
+	_, err := selector.Next()
+	require.ErrorIs(t, err, ErrBackendsFull)
+
+	// Queue the connection.
+	tester.router.connLimit.Strategy = config.ConnLimitStrategyQueue
+	_, err = selector.Next()
+	require.ErrorIs(t, err, ErrWaitForBackend)
+
+	// The connection can be routed after some connections are closed.
+	tester.closeConnections(1, false)
+	tester.addConnections(1)
+	_, err = selector.Next()
+	require.ErrorIs(t, err, ErrWaitForBackend)
+
+	// Don't migrate more connections than the target can take.
+	tester.router.connLimit.MaxConnections = 3
+	tester.addBackends(1)
+	backend1, backend3 := tester.getBackendByIndex(0), tester.getBackendByIndex(2)
+	bp := &mockBalancePolicy{}
+	bp.backendsToBalance = func(backends []policy.BackendCtx) (from, to policy.BackendCtx, balanceCount float64, reason string, logFields []zapcore.Field) {
+		return backend1, backend3, 1000, "conn", nil
+	}
+	tester.addConnections(2)
+	require.Equal(t, 2, backend3.connScore)
+	tester.router.policy = bp
+	tester.rebalance(1)
+	tester.checkRedirectingNum(1)
+	require.Equal(t, 3, backend3.connScore)
+	tester.rebalance(1)
+	tester.checkRedirectingNum(1)
+}
@@ -3,7 +3,13 @@
 
 package router
 
-import "sync/atomic"
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
 
 var _ Router = &StaticRouter{}
 
@@ -20,7 +26,7 @@ func NewStaticRouter(addrs []string) *StaticRouter {
 	return &StaticRouter{backends: backends}
 }
 
-func (r *StaticRouter) GetBackendSelector() BackendSelector {
+func (r *StaticRouter) GetBackendSelector(_ ClientInfo) BackendSelector {
 	return BackendSelector{
 		routeOnce: func(excluded []BackendInst) (BackendInst, error) {
 			for _, backend := range r.backends {
@@ -63,6 +69,14 @@ func (r *StaticRouter) ServerVersion() string {
 	return ""
 }
 
+func (r *StaticRouter) BalanceHistory() []BalanceRecord {
+	return nil
+}
+
+func (r *StaticRouter) BalanceScores() policy.BalanceScores {
+	return policy.BalanceScores{}
+}
+
 func (r *StaticRouter) Close() {
 }
 
@@ -107,3 +121,10 @@ func (b *StaticBackend) SetHealthy(healthy bool) {
 func (b *StaticBackend) Local() bool {
 	return true
 }
+
+func (b *StaticBackend) CrossLocationLevel() string {
+	return ""
+}
+
+func (b *StaticBackend) ObserveLatency(cmd pnet.Command, d time.Duration) {
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulator
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
+	"github.com/prometheus/common/model"
+)
+
+// metricValues extracts the recorded values for the query expressions of the factors.
+var metricValues = map[string]func(snapshot *BackendSnapshot) *float64{
+	"cpu":         func(snapshot *BackendSnapshot) *float64 { return snapshot.CPU },
+	"memory":      func(snapshot *BackendSnapshot) *float64 { return snapshot.Memory },
+	"health_pd":   func(snapshot *BackendSnapshot) *float64 { return snapshot.PDErrors },
+	"health_tikv": func(snapshot *BackendSnapshot) *float64 { return snapshot.TiKVErrors },
+}
+
+type sample struct {
+	ts    time.Time
+	value float64
+}
+
+var _ metricsreader.MetricsReader = (*replayReader)(nil)
+
+// replayReader serves the recorded metrics to the factors like the BackendReader.
+// The factors check the metric time with the wall clock, so the recorded time is shifted to the current time.
+type replayReader struct {
+	rules   map[string]metricsreader.QueryRule
+	results map[string]metricsreader.QueryResult
+	// query key -> instance label -> the history within the retention
+	history map[string]map[string][]sample
+}
+
+func newReplayReader() *replayReader {
+	return &replayReader{
+		rules:   make(map[string]metricsreader.QueryRule),
+		results: make(map[string]metricsreader.QueryResult),
+		history: make(map[string]map[string][]sample),
+	}
+}
+
+func (rr *replayReader) Start(ctx context.Context) error {
+	return nil
+}
+
+func (rr *replayReader) AddQueryExpr(key string, queryExpr metricsreader.QueryExpr, queryRule metricsreader.QueryRule) {
+	rr.rules[key] = queryRule
+}
+
+func (rr *replayReader) RemoveQueryExpr(key string) {
+	delete(rr.rules, key)
+	delete(rr.results, key)
+	delete(rr.history, key)
+}
+
+func (rr *replayReader) GetQueryResult(key string) metricsreader.QueryResult {
+	return rr.results[key]
+}
+
+func (rr *replayReader) GetBackendMetrics() []byte {
+	return nil
+}
+
+func (rr *replayReader) PreClose() {
+}
+
+func (rr *replayReader) Close() {
+}
+
+// replay appends the metrics in the snapshot to the history and updates the query results.
+// now is the shifted time of the snapshot.
+func (rr *replayReader) replay(snapshot *Snapshot, backends []*backend, now time.Time) {
+	for key, rule := range rr.rules {
+		getValue, ok := metricValues[key]
+		if !ok {
+			continue
+		}
+		keyHistory, ok := rr.history[key]
+		if !ok {
+			keyHistory = make(map[string][]sample)
+			rr.history[key] = keyHistory
+		}
+		for _, backend := range backends {
+			if value := getValue(backend.BackendSnapshot); value != nil {
+				label := metricsreader.GetLabel4Backend(backend)
+				keyHistory[label] = append(keyHistory[label], sample{ts: snapshot.Time, value: *value})
+			}
+		}
+
+		var value model.Value
+		switch rule.ResultType {
+		case model.ValVector:
+			vector := make(model.Vector, 0, len(keyHistory))
+			for label, samples := range keyHistory {
+				samples = purgeSamples(samples, rule.Retention, snapshot.Time)
+				keyHistory[label] = samples
+				if len(samples) == 0 {
+					delete(keyHistory, label)
+					continue
+				}
+				last := samples[len(samples)-1]
+				vector = append(vector, &model.Sample{
+					Metric:    model.Metric{metricsreader.LabelNameInstance: model.LabelValue(label)},
+					Value:     model.SampleValue(last.value),
+					Timestamp: shiftTime(last.ts, snapshot.Time, now),
+				})
+			}
+			value = vector
+		default:
+			matrix := make(model.Matrix, 0, len(keyHistory))
+			for label, samples := range keyHistory {
+				samples = purgeSamples(samples, rule.Retention, snapshot.Time)
+				keyHistory[label] = samples
+				if len(samples) == 0 {
+					delete(keyHistory, label)
+					continue
+				}
+				pairs := make([]model.SamplePair, 0, len(samples))
+				for _, s := range samples {
+					pairs = append(pairs, model.SamplePair{Timestamp: shiftTime(s.ts, snapshot.Time, now), Value: model.SampleValue(s.value)})
+				}
+				matrix = append(matrix, &model.SampleStream{
+					Metric: model.Metric{metricsreader.LabelNameInstance: model.LabelValue(label)},
+					Values: pairs,
+				})
+			}
+			value = matrix
+		}
+		rr.results[key] = metricsreader.QueryResult{
+			Value:      value,
+			UpdateTime: now,
+		}
+	}
+}
+
+func purgeSamples(samples []sample, retention time.Duration, curTime time.Time) []sample {
+	idx := 0
+	for ; idx < len(samples); idx++ {
+		if !samples[idx].ts.Before(curTime.Add(-retention)) {
+			break
+		}
+	}
+	return samples[idx:]
+}
+
+// shiftTime maps the recorded time ts to the wall clock, where the recorded time curTime is mapped to now.
+func shiftTime(ts, curTime, now time.Time) model.Time {
+	return model.TimeFromUnixNano(now.Add(ts.Sub(curTime)).UnixNano())
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulator
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/balance/factor"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	"go.uber.org/zap"
+)
+
+// Simulator replays the recorded backend states to FactorBasedBalance and outputs the balance decisions.
+// It's used to verify the balance config offline before applying it in production.
+type Simulator struct {
+	mr      *replayReader
+	balance *factor.FactorBasedBalance
+	// The shifted time of the last snapshot.
+	lastNow     time.Time
+	lastRecTime time.Time
+}
+
+func NewSimulator(lg *zap.Logger, cfg *config.Config) *Simulator {
+	mr := newReplayReader()
+	balance := factor.NewFactorBasedBalance(lg, mr)
+	balance.Init(cfg)
+	return &Simulator{
+		mr:      mr,
+		balance: balance,
+	}
+}
+
+// Simulate feeds a snapshot to the balance policy and returns the decision.
+// The snapshots must be in chronological order.
+func (s *Simulator) Simulate(snapshot *Snapshot) (Decision, error) {
+	decision := Decision{Time: snapshot.Time}
+	if !s.lastRecTime.IsZero() && snapshot.Time.Before(s.lastRecTime) {
+		return decision, errors.Errorf("snapshot at %s is earlier than the previous one", snapshot.Time.Format(time.RFC3339Nano))
+	}
+	// The metric time is in milliseconds, and the factors ignore the metrics whose time is not updated.
+	now := time.Now()
+	if minNow := s.lastNow.Add(time.Millisecond); now.Before(minNow) {
+		now = minNow
+	}
+	s.lastNow, s.lastRecTime = now, snapshot.Time
+
+	backends := make([]*backend, 0, len(snapshot.Backends))
+	for i := range snapshot.Backends {
+		backends = append(backends, newBackend(&snapshot.Backends[i]))
+	}
+	s.mr.replay(snapshot, backends, now)
+
+	allBackends := make([]policy.BackendCtx, 0, len(backends))
+	healthyBackends := make([]policy.BackendCtx, 0, len(backends))
+	for _, backend := range backends {
+		allBackends = append(allBackends, backend)
+		if backend.Healthy() {
+			healthyBackends = append(healthyBackends, backend)
+		}
+	}
+	if routeTo := s.balance.BackendToRoute(healthyBackends, policy.RouteHint{}); routeTo != nil {
+		decision.RouteTo = routeTo.Addr()
+	}
+	from, to, balanceCount, reason, _ := s.balance.BackendsToBalance(allBackends)
+	if balanceCount > 0 {
+		decision.From, decision.To, decision.Reason, decision.BalanceCount = from.Addr(), to.Addr(), reason, balanceCount
+	}
+	return decision, nil
+}
+
+// Run reads snapshots in JSON lines from r and writes decisions in JSON lines to w.
+func (s *Simulator) Run(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	encoder := json.NewEncoder(w)
+	var last *Decision
+	var lastConnCount int
+	for lineIdx := 1; scanner.Scan(); lineIdx++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(line, &snapshot); err != nil {
+			return errors.Wrapf(err, "line %d", lineIdx)
+		}
+		decision, err := s.Simulate(&snapshot)
+		if err != nil {
+			return errors.Wrapf(err, "line %d", lineIdx)
+		}
+		// The migrations are estimated when the next snapshot arrives.
+		if last != nil {
+			last.Migrations = estimateMigrations(last.BalanceCount, snapshot.Time.Sub(last.Time), lastConnCount)
+			if err := encoder.Encode(last); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		last = &decision
+		lastConnCount = 0
+		for _, backend := range snapshot.Backends {
+			if backend.Addr == decision.From {
+				lastConnCount = backend.ConnCount
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	if last != nil {
+		if err := encoder.Encode(last); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func estimateMigrations(balanceCount float64, duration time.Duration, connCount int) int {
+	migrations := int(balanceCount * duration.Seconds())
+	if migrations > connCount {
+		migrations = connCount
+	}
+	return migrations
+}
+
+func (s *Simulator) Close() {
+	s.balance.Close()
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConfig(policy string) *config.Config {
+	cfg := config.NewConfig()
+	cfg.Balance.Policy = policy
+	return cfg
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestSimulate(t *testing.T) {
+	tests := []struct {
+		policy    string
+		snapshots []Snapshot
+		decision  Decision
+	}{
+		{
+			policy: config.BalancePolicyResource,
+			snapshots: []Snapshot{
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: false, ConnCount: 100},
+					{Addr: "b:4000", Healthy: true, ConnCount: 100},
+				}},
+			},
+			decision: Decision{RouteTo: "b:4000", From: "a:4000", To: "b:4000", Reason: "status"},
+		},
+		{
+			policy: config.BalancePolicyResource,
+			snapshots: []Snapshot{
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.9)},
+					{Addr: "b:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.2)},
+				}},
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.9)},
+					{Addr: "b:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.2)},
+				}},
+			},
+			decision: Decision{RouteTo: "b:4000", From: "a:4000", To: "b:4000", Reason: "cpu"},
+		},
+		{
+			policy: config.BalancePolicyResource,
+			snapshots: []Snapshot{
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.5), Memory: ptr(0.1)},
+					{Addr: "b:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.5), Memory: ptr(0.1)},
+				}},
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.5), Memory: ptr(0.85)},
+					{Addr: "b:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.5), Memory: ptr(0.1)},
+				}},
+			},
+			decision: Decision{RouteTo: "b:4000", From: "a:4000", To: "b:4000", Reason: "memory"},
+		},
+		{
+			policy: config.BalancePolicyConnection,
+			snapshots: []Snapshot{
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: true, ConnCount: 100, CPU: ptr(0.2)},
+					{Addr: "b:4000", Healthy: true, ConnCount: 10, CPU: ptr(0.9)},
+				}},
+			},
+			decision: Decision{RouteTo: "b:4000", From: "a:4000", To: "b:4000", Reason: "conn"},
+		},
+		{
+			policy: config.BalancePolicyConnection,
+			snapshots: []Snapshot{
+				{Backends: []BackendSnapshot{
+					{Addr: "a:4000", Healthy: true, ConnCount: 100},
+					{Addr: "b:4000", Healthy: true, ConnCount: 100},
+				}},
+			},
+			decision: Decision{RouteTo: "a:4000"},
+		},
+	}
+
+	lg, _ := logger.CreateLoggerForTest(t)
+	for i, test := range tests {
+		simulator := NewSimulator(lg, newTestConfig(test.policy))
+		now := time.Now()
+		var decision Decision
+		for j := range test.snapshots {
+			test.snapshots[j].Time = now.Add(time.Duration(j) * 15 * time.Second)
+			var err error
+			decision, err = simulator.Simulate(&test.snapshots[j])
+			require.NoError(t, err, "case %d", i)
+		}
+		if test.decision.RouteTo == "a:4000" {
+			// Any backend is fine when they are balanced.
+			test.decision.RouteTo = decision.RouteTo
+		}
+		require.Equal(t, test.decision.From, decision.From, "case %d", i)
+		require.Equal(t, test.decision.To, decision.To, "case %d", i)
+		require.Equal(t, test.decision.Reason, decision.Reason, "case %d", i)
+		require.Equal(t, test.decision.RouteTo, decision.RouteTo, "case %d", i)
+		if test.decision.Reason != "" {
+			require.Greater(t, decision.BalanceCount, 0.0, "case %d", i)
+		} else {
+			require.Zero(t, decision.BalanceCount, "case %d", i)
+		}
+		simulator.Close()
+	}
+}
+
+func TestRun(t *testing.T) {
+	start := time.Now()
+	var input bytes.Buffer
+	for i := 0; i < 3; i++ {
+		snapshot := Snapshot{
+			Time: start.Add(time.Duration(i) * 10 * time.Second),
+			Backends: []BackendSnapshot{
+				{Addr: "a:4000", Healthy: i == 0, ConnCount: 100},
+				{Addr: "b:4000", Healthy: true, ConnCount: 0},
+			},
+		}
+		b, err := json.Marshal(snapshot)
+		require.NoError(t, err)
+		input.Write(b)
+		input.WriteString("\n")
+	}
+
+	lg, _ := logger.CreateLoggerForTest(t)
+	simulator := NewSimulator(lg, newTestConfig(config.BalancePolicyResource))
+	defer simulator.Close()
+	var output bytes.Buffer
+	require.NoError(t, simulator.Run(&input, &output))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 3)
+	decisions := make([]Decision, 0, len(lines))
+	for _, line := range lines {
+		var decision Decision
+		require.NoError(t, json.Unmarshal([]byte(line), &decision))
+		decisions = append(decisions, decision)
+	}
+	require.Equal(t, "conn", decisions[0].Reason)
+	require.Equal(t, 10, decisions[0].Migrations)
+	// All the connections are migrated in 5 seconds when the backend is down.
+	require.Equal(t, "status", decisions[1].Reason)
+	require.Equal(t, 100, decisions[1].Migrations)
+	require.Equal(t, "status", decisions[2].Reason)
+	require.Zero(t, decisions[2].Migrations)
+}
+
+func TestRunError(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{
+			input: "{",
+			err:   "line 1",
+		},
+		{
+			input: `{"time":"2024-01-01T00:00:10Z","backends":[]}` + "\n" + `{"time":"2024-01-01T00:00:00Z","backends":[]}`,
+			err:   "earlier than the previous one",
+		},
+	}
+
+	lg, _ := logger.CreateLoggerForTest(t)
+	for i, test := range tests {
+		simulator := NewSimulator(lg, newTestConfig(config.BalancePolicyResource))
+		err := simulator.Run(strings.NewReader(test.input), &bytes.Buffer{})
+		require.ErrorContains(t, err, test.err, "case %d", i)
+		simulator.Close()
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulator
+
+import (
+	"net"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/pkg/balance/latency"
+	"github.com/pingcap/tiproxy/pkg/balance/observer"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+)
+
+const defaultStatusPort = 10080
+
+// Snapshot is the recorded state of the backends at a time point.
+type Snapshot struct {
+	Time     time.Time         `json:"time"`
+	Backends []BackendSnapshot `json:"backends"`
+}
+
+// BackendSnapshot is the recorded state of a backend. A nil metric means the metric is missing.
+type BackendSnapshot struct {
+	Addr string `json:"addr"`
+	// StatusPort is used to match the backend with the metrics. It's 10080 by default.
+	StatusPort uint `json:"status_port,omitempty"`
+	Healthy    bool `json:"healthy"`
+	Local      bool `json:"local,omitempty"`
+	// CrossLocationLevel is the highest location level on which the backend differs from TiProxy.
+	// It's zone by default if the backend is not local.
+	CrossLocationLevel string            `json:"cross_location_level,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	ConnCount          int               `json:"conn_count"`
+	// ResourceGroups is the connection count of each resource group.
+	ResourceGroups map[string]int `json:"resource_groups,omitempty"`
+	// CPU is the CPU usage, ranging from 0 to 1.
+	CPU *float64 `json:"cpu,omitempty"`
+	// Memory is the memory usage, ranging from 0 to 1.
+	Memory *float64 `json:"memory,omitempty"`
+	// PDErrors is the increase of PD backoff errors in 2 minutes.
+	PDErrors *float64 `json:"pd_errors,omitempty"`
+	// TiKVErrors is the increase of TiKV backoff errors in 2 minutes.
+	TiKVErrors *float64 `json:"tikv_errors,omitempty"`
+}
+
+// Decision is the balance decision for a snapshot.
+type Decision struct {
+	Time time.Time `json:"time"`
+	// RouteTo is the backend that new connections are routed to.
+	RouteTo string `json:"route_to,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	// BalanceCount is the count of connections to migrate per second.
+	BalanceCount float64 `json:"balance_count,omitempty"`
+	// Migrations is the estimated count of connections to migrate until the next snapshot.
+	Migrations int `json:"migrations,omitempty"`
+}
+
+var _ policy.BackendCtx = (*backend)(nil)
+
+// backend is a backend replayed from a BackendSnapshot.
+// The connections are not really migrated, so the connection count is always the recorded one.
+type backend struct {
+	*BackendSnapshot
+	info observer.BackendInfo
+}
+
+func newBackend(snapshot *BackendSnapshot) *backend {
+	statusPort := snapshot.StatusPort
+	if statusPort == 0 {
+		statusPort = defaultStatusPort
+	}
+	host, _, err := net.SplitHostPort(snapshot.Addr)
+	if err != nil {
+		host = snapshot.Addr
+	}
+	return &backend{
+		BackendSnapshot: snapshot,
+		info: observer.BackendInfo{
+			IP:         host,
+			StatusPort: statusPort,
+			Labels:     snapshot.Labels,
+		},
+	}
+}
+
+func (b *backend) Addr() string {
+	return b.BackendSnapshot.Addr
+}
+
+func (b *backend) ConnCount() int {
+	return b.BackendSnapshot.ConnCount
+}
+
+func (b *backend) ConnScore() int {
+	return b.BackendSnapshot.ConnCount
+}
+
+func (b *backend) Healthy() bool {
+	return b.BackendSnapshot.Healthy
+}
+
+func (b *backend) Local() bool {
+	return b.BackendSnapshot.Local
+}
+
+func (b *backend) CrossLocationLevel() string {
+	if b.BackendSnapshot.Local {
+		return ""
+	}
+	if len(b.BackendSnapshot.CrossLocationLevel) == 0 {
+		return config.LocationLabelName
+	}
+	return b.BackendSnapshot.CrossLocationLevel
+}
+
+func (b *backend) GetBackendInfo() observer.BackendInfo {
+	return b.info
+}
+
+// CollectLatency returns nothing because the latencies are measured by TiProxy itself and are not recorded.
+func (b *backend) CollectLatency() latency.Histograms {
+	return latency.Histograms{}
+}
+
+func (b *backend) ResourceGroupConnCounts() map[string]int {
+	return b.BackendSnapshot.ResourceGroups
+}
+
+// WarmUpRatio returns 1 because the snapshots don't record warm-up.
+func (b *backend) WarmUpRatio() float64 {
+	return 1
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"hash/crc32"
+	"maps"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// The cluster config is stored in config/cluster and the override for instances with label zone=z1 is stored in
+	// config/label/zone=z1.
+	clusterConfigKey   = "cluster"
+	clusterLabelPrefix = "label"
+)
+
+var (
+	ErrClusterConfigDisabled = errors.New("cluster config is disabled, set proxy.cluster-config and proxy.pd-addrs to enable it")
+)
+
+// InitClusterConfig starts watching the cluster config if it's enabled.
+// The cluster config is applied on top of the local config, and then the overrides whose labels match the instance
+// are applied in the order of the labels.
+func (e *ConfigManager) InitClusterConfig(etcdCli *clientv3.Client) error {
+	if !e.GetConfig().Proxy.ClusterConfig {
+		return nil
+	}
+	if etcdCli == nil {
+		return errors.New("the cluster config requires pd-addrs")
+	}
+	e.logger.Info("cluster config is enabled")
+	e.cluster.Lock()
+	e.cluster.labelData = make(map[string][]byte)
+	e.cluster.Unlock()
+	e.clusterKV = newEtcdStorage(e.logger.Named("cluster"), etcdCli, pathPrefixConfig, e.onClusterConfigChange)
+	return nil
+}
+
+// SetClusterConfig stores the config for all the instances or the instances with the label if label is not empty.
+// The label is in the format of "name=value". An empty config removes the label override, but the config items that
+// were overridden keep their values until they are set again.
+func (e *ConfigManager) SetClusterConfig(ctx context.Context, label string, data []byte) error {
+	if e.clusterKV == nil {
+		return ErrClusterConfigDisabled
+	}
+	key := path.Join(pathPrefixConfig, clusterConfigKey)
+	if label != "" {
+		if name, _, ok := strings.Cut(label, "="); !ok || name == "" || strings.Contains(label, "/") {
+			return errors.Wrapf(config.ErrInvalidConfigValue, "invalid label %s, it should be in the format of name=value", label)
+		}
+		key = path.Join(pathPrefixConfig, clusterLabelPrefix, label)
+		if len(data) == 0 {
+			return e.clusterKV.del(ctx, key)
+		}
+	}
+	// Check the config before pushing it to all the instances.
+	cfg := config.NewConfig()
+	if err := toml.Unmarshal(data, cfg); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := cfg.Check(); err != nil {
+		return err
+	}
+	return e.clusterKV.set(ctx, key, data)
+}
+
+// onClusterConfigChange is called by the storage and it applies the latest cluster config.
+func (e *ConfigManager) onClusterConfigChange(events []KVEvent) {
+	changed := false
+	e.cluster.Lock()
+	for _, ev := range events {
+		key := strings.TrimPrefix(ev.Key, pathPrefixConfig+"/")
+		switch {
+		case key == clusterConfigKey:
+			e.cluster.data = ev.Value
+		case strings.HasPrefix(key, clusterLabelPrefix+"/"):
+			label := strings.TrimPrefix(key, clusterLabelPrefix+"/")
+			if ev.Deleted {
+				delete(e.cluster.labelData, label)
+			} else {
+				e.cluster.labelData[label] = ev.Value
+			}
+		default:
+			continue
+		}
+		changed = true
+	}
+	data := e.cluster.data
+	labelData := make(map[string][]byte, len(e.cluster.labelData))
+	for label, value := range e.cluster.labelData {
+		labelData[label] = value
+	}
+	e.cluster.Unlock()
+	if !changed {
+		return
+	}
+
+	var appliedLabels []string
+	err := e.updateConfig("cluster", func(base *config.Config) error {
+		if err := toml.Unmarshal(data, base); err != nil {
+			return errors.WithStack(err)
+		}
+		labels := make([]string, 0, len(labelData))
+		for label := range labelData {
+			name, value, _ := strings.Cut(label, "=")
+			if v, ok := base.Labels[name]; ok && v == value {
+				labels = append(labels, label)
+			}
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			if err := toml.Unmarshal(labelData[label], base); err != nil {
+				return errors.Wrapf(errors.WithStack(err), "invalid config for label %s", label)
+			}
+		}
+		appliedLabels = labels
+		return nil
+	})
+	if err != nil {
+		e.logger.Error("apply cluster config failed", zap.Error(err))
+		return
+	}
+	applied := make(map[string]uint32, len(appliedLabels)+1)
+	applied[""] = crc32.ChecksumIEEE(data)
+	for _, label := range appliedLabels {
+		applied[label] = crc32.ChecksumIEEE(labelData[label])
+	}
+	e.cluster.Lock()
+	e.cluster.applied = applied
+	e.cluster.Unlock()
+	e.logger.Info("apply cluster config", zap.Strings("labels", appliedLabels))
+}
+
+// ClusterConfigChecksums returns the checksums of the applied cluster config and label overrides, which are used to
+// check whether the pushed config is applied. It returns false if the cluster config is disabled.
+func (e *ConfigManager) ClusterConfigChecksums() (map[string]uint32, bool) {
+	if e.clusterKV == nil {
+		return nil, false
+	}
+	e.cluster.Lock()
+	defer e.cluster.Unlock()
+	return maps.Clone(e.cluster.applied), true
+}
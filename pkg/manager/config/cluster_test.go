@@ -0,0 +1,130 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"hash/crc32"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/pkg/manager/cert"
+	"github.com/pingcap/tiproxy/pkg/util/etcd"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func createEtcdClient(t *testing.T) *clientv3.Client {
+	lg, _ := logger.CreateLoggerForTest(t)
+	server, err := etcd.CreateEtcdServer("0.0.0.0:0", t.TempDir(), lg)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	cfg := etcd.ConfigForEtcdTest(server.Clients[0].Addr().String())
+	certMgr := cert.NewCertManager()
+	require.NoError(t, certMgr.Init(cfg, lg, nil))
+	client, err := etcd.InitEtcdClient(lg, cfg, certMgr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	return client
+}
+
+func TestClusterConfig(t *testing.T) {
+	client := createEtcdClient(t)
+	cfgmgrs := make([]*ConfigManager, 0, 3)
+	for _, zone := range []string{"z1", "z2", "z3"} {
+		cfgmgr, _, _ := testConfigManager(t, "", "")
+		require.NoError(t, cfgmgr.SetTOMLConfig([]byte(`
+labels = { zone = "`+zone+`" }
+[proxy]
+cluster-config = true`)))
+		require.NoError(t, cfgmgr.InitClusterConfig(client))
+		cfgmgrs = append(cfgmgrs, cfgmgr)
+	}
+	checkAll := func(check func(cfg *config.Config, i int) bool) {
+		require.Eventually(t, func() bool {
+			for i, cfgmgr := range cfgmgrs {
+				if !check(cfgmgr.GetConfig(), i) {
+					return false
+				}
+			}
+			return true
+		}, 3*time.Second, 10*time.Millisecond)
+	}
+
+	ctx := context.Background()
+	require.NoError(t, cfgmgrs[0].SetClusterConfig(ctx, "", []byte("proxy.max-connections = 100")))
+	checkAll(func(cfg *config.Config, _ int) bool {
+		return cfg.Proxy.MaxConnections == 100
+	})
+
+	// The override only applies to the instances with the label.
+	require.NoError(t, cfgmgrs[1].SetClusterConfig(ctx, "zone=z2", []byte("proxy.max-connections = 200")))
+	checkAll(func(cfg *config.Config, i int) bool {
+		if i == 1 {
+			return cfg.Proxy.MaxConnections == 200
+		}
+		return cfg.Proxy.MaxConnections == 100
+	})
+
+	// The override is applied after the cluster config.
+	require.NoError(t, cfgmgrs[0].SetClusterConfig(ctx, "", []byte("proxy.max-connections = 300\nproxy.conn-buffer-size = 4096")))
+	checkAll(func(cfg *config.Config, i int) bool {
+		if cfg.Proxy.ConnBufferSize != 4096 {
+			return false
+		}
+		if i == 1 {
+			return cfg.Proxy.MaxConnections == 200
+		}
+		return cfg.Proxy.MaxConnections == 300
+	})
+
+	// The checksums are the same only if the configs are the same.
+	require.NotEqual(t, cfgmgrs[0].GetConfigChecksum(), cfgmgrs[1].GetConfigChecksum())
+
+	// The instances report the checksums of the applied cluster config and overrides.
+	baseChecksum := crc32.ChecksumIEEE([]byte("proxy.max-connections = 300\nproxy.conn-buffer-size = 4096"))
+	for i, cfgmgr := range cfgmgrs {
+		expected := map[string]uint32{"": baseChecksum}
+		if i == 1 {
+			expected["zone=z2"] = crc32.ChecksumIEEE([]byte("proxy.max-connections = 200"))
+		}
+		require.Eventually(t, func() bool {
+			checksums, ok := cfgmgr.ClusterConfigChecksums()
+			return ok && maps.Equal(expected, checksums)
+		}, 3*time.Second, 10*time.Millisecond, "instance %d", i)
+	}
+
+	// Invalid configs are rejected before being pushed.
+	require.ErrorIs(t, cfgmgrs[0].SetClusterConfig(ctx, "", []byte("proxy.conn-buffer-size = 1")), config.ErrInvalidConfigValue)
+	require.ErrorIs(t, cfgmgrs[0].SetClusterConfig(ctx, "zone", []byte("proxy.max-connections = 1")), config.ErrInvalidConfigValue)
+	require.Error(t, cfgmgrs[0].SetClusterConfig(ctx, "", []byte("proxy.max-connections = ")))
+
+	// A new instance applies the existing cluster config.
+	cfgmgr, _, _ := testConfigManager(t, "", "")
+	require.NoError(t, cfgmgr.SetTOMLConfig([]byte(`
+labels = { zone = "z2" }
+[proxy]
+cluster-config = true`)))
+	require.NoError(t, cfgmgr.InitClusterConfig(client))
+	require.Eventually(t, func() bool {
+		return cfgmgr.GetConfig().Proxy.MaxConnections == 200
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, cfgmgrs[1].GetConfigChecksum(), cfgmgr.GetConfigChecksum())
+}
+
+func TestClusterConfigDisabled(t *testing.T) {
+	cfgmgr, _, ctx := testConfigManager(t, "", "")
+	require.NoError(t, cfgmgr.InitClusterConfig(nil))
+	require.ErrorIs(t, cfgmgr.SetClusterConfig(ctx, "", []byte("proxy.max-connections = 100")), ErrClusterConfigDisabled)
+	_, ok := cfgmgr.ClusterConfigChecksums()
+	require.False(t, ok)
+
+	require.NoError(t, cfgmgr.SetTOMLConfig([]byte("proxy.cluster-config = true")))
+	require.Error(t, cfgmgr.InitClusterConfig(nil))
+}
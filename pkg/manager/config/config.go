@@ -24,7 +24,7 @@ func (e *ConfigManager) reloadConfigFile(file string) error {
 	}
 	e.fileContent = content
 
-	return e.SetTOMLConfig(content)
+	return e.SetTOMLConfigFrom(content, "file "+file)
 }
 
 // SetTOMLConfig will do partial config update. Usually, user will expect config changes
@@ -33,29 +33,27 @@ func (e *ConfigManager) reloadConfigFile(file string) error {
 // So we always update the current config with a TOML string, which only overwrite fields
 // that are specified by users.
 func (e *ConfigManager) SetTOMLConfig(data []byte) (err error) {
-	e.sts.Lock()
-	defer e.sts.Unlock()
-
-	base := e.sts.current
-	if base == nil {
-		base = config.NewConfig()
-	} else {
-		base = base.Clone()
-	}
+	return e.SetTOMLConfigFrom(data, "unknown")
+}
 
-	if err = toml.Unmarshal(data, base); err != nil {
-		return errors.WithStack(err)
-	}
+// SetTOMLConfigFrom is the same as SetTOMLConfig, and the source is recorded in the config history.
+func (e *ConfigManager) SetTOMLConfigFrom(data []byte, source string) (err error) {
+	return e.updateConfig(source, func(base *config.Config) error {
+		return errors.WithStack(toml.Unmarshal(data, base))
+	})
+}
 
-	// Overwrite the config with command line args.
-	if len(e.advertiseAddr) > 0 {
-		base.Proxy.AdvertiseAddr = e.advertiseAddr
-	}
+// updateConfig applies the update on a copy of the current config, checks it, and notifies the listeners if it changes.
+func (e *ConfigManager) updateConfig(source string, update func(base *config.Config) error) (err error) {
+	e.sts.Lock()
+	defer e.sts.Unlock()
 
-	if err = base.Check(); err != nil {
-		return
+	base, err := e.mergeConfig(e.sts.current, update)
+	if err != nil {
+		return err
 	}
 
+	original := e.sts.current
 	e.sts.current = base
 	originalData := e.sts.data
 	var buf bytes.Buffer
@@ -68,6 +66,7 @@ func (e *ConfigManager) SetTOMLConfig(data []byte) (err error) {
 	if originalData == nil || !bytes.Equal(originalData, newData) {
 		e.sts.checksum = crc32.ChecksumIEEE(newData)
 		e.sts.data = newData
+		e.addHistory(source, original, base)
 		e.logger.Info("current config", zap.Any("cfg", e.sts.current))
 		for _, list := range e.sts.listeners {
 			list <- base.Clone()
@@ -76,6 +75,55 @@ func (e *ConfigManager) SetTOMLConfig(data []byte) (err error) {
 	return
 }
 
+// mergeConfig applies the update on a copy of the current config and checks it. The current config is unchanged.
+func (e *ConfigManager) mergeConfig(current *config.Config, update func(base *config.Config) error) (*config.Config, error) {
+	var base *config.Config
+	if current == nil {
+		base = config.NewConfig()
+	} else {
+		base = current.Clone()
+	}
+
+	if err := update(base); err != nil {
+		return nil, err
+	}
+
+	// Overwrite the config with command line args.
+	if len(e.advertiseAddr) > 0 {
+		base.Proxy.AdvertiseAddr = e.advertiseAddr
+	}
+
+	if err := base.Check(); err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+// ValidateTOMLConfig checks the config as SetTOMLConfig does, but it doesn't apply the config.
+// It reports the changed items and whether they need restart.
+func (e *ConfigManager) ValidateTOMLConfig(data []byte) *config.ConfigValidation {
+	current := e.GetConfig()
+	cfg, err := e.mergeConfig(current, func(base *config.Config) error {
+		return errors.WithStack(toml.Unmarshal(data, base))
+	})
+	if err != nil {
+		return &config.ConfigValidation{Error: err.Error()}
+	}
+	diffs, err := config.DiffConfig(current, cfg)
+	if err != nil {
+		return &config.ConfigValidation{Error: err.Error()}
+	}
+	validation := &config.ConfigValidation{Valid: true, Diff: diffs}
+	for _, diff := range diffs {
+		if config.IsOnlineField(diff.Field) {
+			validation.OnlineFields = append(validation.OnlineFields, diff.Field)
+		} else {
+			validation.RestartFields = append(validation.RestartFields, diff.Field)
+		}
+	}
+	return validation
+}
+
 func (e *ConfigManager) GetConfig() *config.Config {
 	e.sts.Lock()
 	v := e.sts.current
@@ -97,3 +145,17 @@ func (e *ConfigManager) WatchConfig() <-chan *config.Config {
 	e.sts.Unlock()
 	return ch
 }
+
+// UnwatchConfig removes and closes the channel returned by WatchConfig.
+// The receiver must keep receiving until it returns because the config may be being sent.
+func (e *ConfigManager) UnwatchConfig(ch <-chan *config.Config) {
+	e.sts.Lock()
+	defer e.sts.Unlock()
+	for i, list := range e.sts.listeners {
+		if list == ch {
+			e.sts.listeners = append(e.sts.listeners[:i], e.sts.listeners[i+1:]...)
+			close(list)
+			return
+		}
+	}
+}
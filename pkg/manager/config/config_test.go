@@ -318,3 +318,36 @@ func TestChecksum(t *testing.T) {
 	require.NotEqual(t, c1, c3)
 	require.NotEqual(t, c2, c3)
 }
+
+func TestValidateConfig(t *testing.T) {
+	cfgmgr, _, _ := testConfigManager(t, "", "")
+	checksum := cfgmgr.GetConfigChecksum()
+
+	validation := cfgmgr.ValidateTOMLConfig([]byte("proxy.max-connections = 100\nproxy.pd-addrs = '127.0.0.1:2380'\nlog.level = 'warn'"))
+	require.True(t, validation.Valid)
+	require.Empty(t, validation.Error)
+	require.Equal(t, []config.FieldDiff{
+		{Field: "log.level", Old: "info", New: "warn"},
+		{Field: "proxy.max-connections", Old: "0", New: "100"},
+		{Field: "proxy.pd-addrs", Old: "127.0.0.1:2379", New: "127.0.0.1:2380"},
+	}, validation.Diff)
+	require.Equal(t, []string{"log.level", "proxy.max-connections"}, validation.OnlineFields)
+	require.Equal(t, []string{"proxy.pd-addrs"}, validation.RestartFields)
+
+	// The balance config and the labels are applied online by the router.
+	validation = cfgmgr.ValidateTOMLConfig([]byte("balance.conn-limit.max-connections = 100\nlabels.zone = 'us-west-1a'"))
+	require.True(t, validation.Valid)
+	require.Equal(t, []string{"balance.conn-limit.max-connections", "labels.zone"}, validation.OnlineFields)
+	require.Empty(t, validation.RestartFields)
+
+	validation = cfgmgr.ValidateTOMLConfig([]byte("proxy.conn-buffer-size = 1"))
+	require.False(t, validation.Valid)
+	require.Contains(t, validation.Error, "conn-buffer-size")
+	validation = cfgmgr.ValidateTOMLConfig([]byte("proxy.max-connections = "))
+	require.False(t, validation.Valid)
+	require.NotEmpty(t, validation.Error)
+
+	// The config is not applied.
+	require.Equal(t, checksum, cfgmgr.GetConfigChecksum())
+	require.Len(t, cfgmgr.GetConfigHistory(), 1)
+}
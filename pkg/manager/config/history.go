@@ -0,0 +1,66 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"go.uber.org/zap"
+)
+
+// addHistory records the applied config. It must be called with sts locked and after sts.data is updated.
+func (e *ConfigManager) addHistory(source string, original, current *config.Config) {
+	var diffs []config.FieldDiff
+	if original != nil {
+		var err error
+		if diffs, err = config.DiffConfig(original, current); err != nil {
+			e.logger.Warn("diff config failed", zap.Error(err))
+		}
+	}
+	e.sts.version++
+	e.sts.history = append(e.sts.history, config.ConfigVersion{
+		Version:  e.sts.version,
+		Time:     time.Now(),
+		Source:   source,
+		Checksum: e.sts.checksum,
+		Diff:     diffs,
+		Data:     string(e.sts.data),
+	})
+	if len(e.sts.history) > maxConfigHistory {
+		e.sts.history = e.sts.history[len(e.sts.history)-maxConfigHistory:]
+	}
+}
+
+// GetConfigHistory returns the recently applied configs, from the oldest to the latest.
+func (e *ConfigManager) GetConfigHistory() []config.ConfigVersion {
+	e.sts.Lock()
+	defer e.sts.Unlock()
+	return append([]config.ConfigVersion{}, e.sts.history...)
+}
+
+// RollbackConfig applies the config of the version in the history. The rollback itself is recorded as a new version.
+func (e *ConfigManager) RollbackConfig(version uint64, source string) error {
+	var data string
+	found := false
+	e.sts.Lock()
+	for _, v := range e.sts.history {
+		if v.Version == version {
+			data, found = v.Data, true
+			break
+		}
+	}
+	e.sts.Unlock()
+	if !found {
+		return errors.Wrapf(ErrNoResults, "config version %d", version)
+	}
+	return e.updateConfig(fmt.Sprintf("%s, rollback to version %d", source, version), func(base *config.Config) error {
+		// The data contains the whole config, so the items absent from it should be reset rather than kept.
+		*base = config.Config{}
+		return errors.WithStack(toml.Unmarshal([]byte(data), base))
+	})
+}
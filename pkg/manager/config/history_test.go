@@ -0,0 +1,58 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigHistory(t *testing.T) {
+	cfgmgr, _, _ := testConfigManager(t, "", "")
+	history := cfgmgr.GetConfigHistory()
+	require.Len(t, history, 1)
+	require.Equal(t, "default", history[0].Source)
+	require.Empty(t, history[0].Diff)
+	require.Equal(t, cfgmgr.GetConfigChecksum(), history[0].Checksum)
+
+	require.NoError(t, cfgmgr.SetTOMLConfigFrom([]byte("proxy.max-connections = 100"), "api 127.0.0.1"))
+	// The same config is not recorded.
+	require.NoError(t, cfgmgr.SetTOMLConfigFrom([]byte("proxy.max-connections = 100"), "api 127.0.0.1"))
+	require.NoError(t, cfgmgr.SetTOMLConfigFrom([]byte("proxy.max-connections = 200\nlabels = { zone = 'z1' }"), "api 127.0.0.2"))
+	// Invalid configs are not recorded.
+	require.Error(t, cfgmgr.SetTOMLConfigFrom([]byte("proxy.conn-buffer-size = 1"), "api 127.0.0.1"))
+	history = cfgmgr.GetConfigHistory()
+	require.Len(t, history, 3)
+	require.EqualValues(t, 2, history[1].Version)
+	require.Equal(t, "api 127.0.0.1", history[1].Source)
+	require.Equal(t, []config.FieldDiff{{Field: "proxy.max-connections", Old: "0", New: "100"}}, history[1].Diff)
+	require.EqualValues(t, 3, history[2].Version)
+	require.Equal(t, []config.FieldDiff{
+		{Field: "labels.zone", New: "z1"},
+		{Field: "proxy.max-connections", Old: "100", New: "200"},
+	}, history[2].Diff)
+	require.Equal(t, cfgmgr.GetConfigChecksum(), history[2].Checksum)
+
+	// Rollback resets the items that are absent in the old version.
+	require.NoError(t, cfgmgr.RollbackConfig(2, "api 127.0.0.1"))
+	cfg := cfgmgr.GetConfig()
+	require.EqualValues(t, 100, cfg.Proxy.MaxConnections)
+	require.Empty(t, cfg.Labels)
+	history = cfgmgr.GetConfigHistory()
+	require.Len(t, history, 4)
+	require.Equal(t, "api 127.0.0.1, rollback to version 2", history[3].Source)
+	require.Equal(t, history[1].Checksum, history[3].Checksum)
+	require.ErrorIs(t, cfgmgr.RollbackConfig(100, "api 127.0.0.1"), ErrNoResults)
+
+	// Only the latest versions are kept.
+	for i := 0; i < maxConfigHistory; i++ {
+		require.NoError(t, cfgmgr.SetTOMLConfig([]byte(fmt.Sprintf("proxy.max-connections = %d", 1000+i))))
+	}
+	history = cfgmgr.GetConfigHistory()
+	require.Len(t, history, maxConfigHistory)
+	require.EqualValues(t, 4+maxConfigHistory, history[len(history)-1].Version)
+}
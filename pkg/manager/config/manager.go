@@ -11,7 +11,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/lib/util/waitgroup"
-	"github.com/tidwall/btree"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +22,8 @@ const (
 
 const (
 	checkFileInterval = 2 * time.Second
+	// maxConfigHistory is the max number of config versions kept in the history.
+	maxConfigHistory = 100
 )
 
 var (
@@ -39,16 +41,33 @@ type ConfigManager struct {
 	logger        *zap.Logger
 	advertiseAddr string
 
-	kv *btree.BTreeG[KVValue]
+	kv  kvStorage
+	nss struct {
+		sync.Mutex
+		listeners []chan<- []NamespaceEvent
+		// pending is the events that are not sent to the listeners yet and notify wakes up the sender.
+		pending [][]NamespaceEvent
+		notify  chan struct{}
+	}
+	clusterKV kvStorage
+	cluster   struct {
+		sync.Mutex
+		data      []byte
+		labelData map[string][]byte
+		// applied is the checksums of the applied cluster config and label overrides.
+		applied map[string]uint32
+	}
 
 	checkFileInterval time.Duration
 	fileContent       []byte // used to compare whether the config file has changed
 	sts               struct {
 		sync.Mutex
-		listeners []chan<- *config.Config
+		listeners []chan *config.Config
 		current   *config.Config
 		data      []byte // used to strictly compare whether the config has changed
 		checksum  uint32 // checksum of the unmarshalled toml
+		history   []config.ConfigVersion
+		version   uint64
 	}
 }
 
@@ -65,10 +84,14 @@ func (e *ConfigManager) Init(ctx context.Context, logger *zap.Logger, configFile
 	e.logger = logger
 	e.advertiseAddr = advertiseAddr
 
-	// for namespace persistence
-	e.kv = btree.NewBTreeG(func(a, b KVValue) bool {
-		return a.Key < b.Key
-	})
+	// The namespace events are sent in another goroutine so that the storage writes are not blocked by the listeners.
+	e.nss.notify = make(chan struct{}, 1)
+	e.wg.RunWithRecover(func() {
+		e.sendNamespaceEvents(nctx)
+	}, nil, e.logger)
+
+	// for namespace persistence, it's replaced by InitNamespaceStorage if the namespaces need to be durable.
+	e.kv = newMemoryStorage(e.onNamespaceChange)
 
 	if configFile != "" {
 		if err := e.reloadConfigFile(configFile); err != nil {
@@ -95,7 +118,7 @@ func (e *ConfigManager) Init(ctx context.Context, logger *zap.Logger, configFile
 			}
 		}, nil, e.logger)
 	} else {
-		if err := e.SetTOMLConfig(nil); err != nil {
+		if err := e.SetTOMLConfigFrom(nil, "default"); err != nil {
 			return err
 		}
 	}
@@ -103,6 +126,32 @@ func (e *ConfigManager) Init(ctx context.Context, logger *zap.Logger, configFile
 	return nil
 }
 
+// InitNamespaceStorage switches the namespace storage to the one in the config.
+// It's called after Init because the etcd client is created after the config is loaded.
+func (e *ConfigManager) InitNamespaceStorage(etcdCli *clientv3.Client) error {
+	cfg := e.GetConfig().NamespaceStorage
+	var storage kvStorage
+	switch cfg.Type {
+	case config.NamespaceStorageLocal:
+		ls, err := newLocalStorage(e.logger.Named("storage"), cfg.Dir, e.checkFileInterval, e.onNamespaceChange)
+		if err != nil {
+			return err
+		}
+		storage = ls
+	case config.NamespaceStorageEtcd:
+		if etcdCli == nil {
+			return errors.New("the etcd namespace storage requires pd-addrs")
+		}
+		storage = newEtcdStorage(e.logger.Named("storage"), etcdCli, pathPrefixNamespace, e.onNamespaceChange)
+	default:
+		return nil
+	}
+	e.logger.Info("init namespace storage", zap.String("type", cfg.Type), zap.String("dir", cfg.Dir))
+	e.kv.close()
+	e.kv = storage
+	return nil
+}
+
 func (e *ConfigManager) Close() error {
 	var wcherr error
 	if e.cancel != nil {
@@ -115,6 +164,19 @@ func (e *ConfigManager) Close() error {
 	}
 	e.sts.listeners = nil
 	e.sts.Unlock()
+	if e.kv != nil {
+		e.kv.close()
+	}
+	if e.clusterKV != nil {
+		e.clusterKV.close()
+	}
+	// Wait for the namespace sender to exit before closing the channels.
 	e.wg.Wait()
+	e.nss.Lock()
+	for _, ch := range e.nss.listeners {
+		close(ch)
+	}
+	e.nss.listeners = nil
+	e.nss.Unlock()
 	return wcherr
 }
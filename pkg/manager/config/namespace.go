@@ -7,16 +7,26 @@ import (
 	"context"
 	"encoding/json"
 	"path"
-	"strings"
 
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
 )
 
+// NamespaceEvent is a change of a namespace in the storage, made by this instance or others.
+// Namespace only contains the name if Deleted is true.
+type NamespaceEvent struct {
+	Namespace *config.Namespace
+	Deleted   bool
+}
+
 func (e *ConfigManager) get(ctx context.Context, ns, key string) (KVValue, error) {
 	nkey := path.Clean(path.Join(ns, key))
-	v, ok := e.kv.Get(KVValue{Key: nkey})
+	v, ok, err := e.kv.get(ctx, nkey)
+	if err != nil {
+		return v, err
+	}
 	if !ok {
 		return v, errors.WithStack(errors.Wrapf(ErrNoResults, "key=%s", nkey))
 	}
@@ -24,27 +34,82 @@ func (e *ConfigManager) get(ctx context.Context, ns, key string) (KVValue, error
 }
 
 func (e *ConfigManager) list(ctx context.Context, ns string, ops ...clientv3.OpOption) ([]KVValue, error) {
-	k := path.Clean(ns)
-	var resp []KVValue
-	e.kv.Ascend(KVValue{Key: k}, func(item KVValue) bool {
-		if !strings.HasPrefix(item.Key, k) {
-			return false
-		}
-		resp = append(resp, item)
-		return true
-	})
-	return resp, nil
+	return e.kv.list(ctx, path.Clean(ns))
 }
 
 func (e *ConfigManager) set(ctx context.Context, ns, key string, val []byte) error {
-	v := KVValue{Key: path.Clean(path.Join(ns, key)), Value: val}
-	_, _ = e.kv.Set(v)
-	return nil
+	return e.kv.set(ctx, path.Clean(path.Join(ns, key)), val)
 }
 
 func (e *ConfigManager) del(ctx context.Context, ns, key string) error {
-	_, _ = e.kv.Delete(KVValue{Key: path.Clean(path.Join(ns, key))})
-	return nil
+	return e.kv.del(ctx, path.Clean(path.Join(ns, key)))
+}
+
+// onNamespaceChange is called by the storage with its write lock held, so it only queues the events and
+// sendNamespaceEvents notifies the namespace listeners.
+func (e *ConfigManager) onNamespaceChange(events []KVEvent) {
+	nsEvents := make([]NamespaceEvent, 0, len(events))
+	for _, ev := range events {
+		dir, name := path.Split(ev.Key)
+		if path.Clean(dir) != pathPrefixNamespace {
+			continue
+		}
+		if ev.Deleted {
+			nsEvents = append(nsEvents, NamespaceEvent{Namespace: &config.Namespace{Namespace: name}, Deleted: true})
+			continue
+		}
+		var nsCfg config.Namespace
+		if err := json.Unmarshal(ev.Value, &nsCfg); err != nil {
+			e.logger.Warn("invalid namespace in storage", zap.String("key", ev.Key), zap.Error(err))
+			continue
+		}
+		nsEvents = append(nsEvents, NamespaceEvent{Namespace: &nsCfg})
+	}
+	if len(nsEvents) == 0 {
+		return
+	}
+	e.nss.Lock()
+	e.nss.pending = append(e.nss.pending, nsEvents)
+	e.nss.Unlock()
+	select {
+	case e.nss.notify <- struct{}{}:
+	default:
+	}
+}
+
+// sendNamespaceEvents sends the queued events to the listeners in order without holding any lock.
+func (e *ConfigManager) sendNamespaceEvents(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.nss.notify:
+		}
+		e.nss.Lock()
+		pending, listeners := e.nss.pending, e.nss.listeners
+		e.nss.pending = nil
+		e.nss.Unlock()
+		for _, nsEvents := range pending {
+			for _, ch := range listeners {
+				select {
+				case ch <- nsEvents:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// WatchNamespace returns a channel that receives the namespace changes, including the ones made by other instances
+// if the storage is shared.
+// It should be called before InitNamespaceStorage to receive the namespaces that are loaded from the storage.
+func (e *ConfigManager) WatchNamespace() <-chan []NamespaceEvent {
+	ch := make(chan []NamespaceEvent, 1)
+	e.nss.Lock()
+	e.nss.listeners = append(e.nss.listeners, ch)
+	e.nss.Unlock()
+	return ch
 }
 
 func (e *ConfigManager) GetNamespace(ctx context.Context, ns string) (*config.Namespace, error) {
@@ -0,0 +1,289 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/tidwall/btree"
+	"go.uber.org/zap"
+)
+
+// KVEvent is a change of a key in the storage.
+type KVEvent struct {
+	KVValue
+	Deleted bool
+}
+
+// kvStorage persists the key-values of the ConfigManager.
+// Every change, no matter whether it's made by this instance or others, is reported by the onChange callback
+// that is passed to the constructor of the storage.
+type kvStorage interface {
+	get(ctx context.Context, key string) (KVValue, bool, error)
+	// list returns the key-values whose keys have the prefix, sorted by the keys.
+	list(ctx context.Context, prefix string) ([]KVValue, error)
+	set(ctx context.Context, key string, val []byte) error
+	del(ctx context.Context, key string) error
+	close()
+}
+
+// diffKVs returns the events that change old to new, sorted by the keys.
+func diffKVs(old, new map[string][]byte) []KVEvent {
+	var events []KVEvent
+	for k, v := range new {
+		if ov, ok := old[k]; !ok || !bytes.Equal(ov, v) {
+			events = append(events, KVEvent{KVValue: KVValue{Key: k, Value: v}})
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			events = append(events, KVEvent{KVValue: KVValue{Key: k}, Deleted: true})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
+
+var _ kvStorage = (*memoryStorage)(nil)
+
+// memoryStorage keeps the key-values in memory, so they are lost after restart.
+type memoryStorage struct {
+	// mu serializes the writes so that the events are reported in order.
+	mu       sync.Mutex
+	kv       *btree.BTreeG[KVValue]
+	onChange func([]KVEvent)
+}
+
+func newMemoryStorage(onChange func([]KVEvent)) *memoryStorage {
+	return &memoryStorage{
+		kv: btree.NewBTreeG(func(a, b KVValue) bool {
+			return a.Key < b.Key
+		}),
+		onChange: onChange,
+	}
+}
+
+func (ms *memoryStorage) get(_ context.Context, key string) (KVValue, bool, error) {
+	v, ok := ms.kv.Get(KVValue{Key: key})
+	return v, ok, nil
+}
+
+func (ms *memoryStorage) list(_ context.Context, prefix string) ([]KVValue, error) {
+	var resp []KVValue
+	ms.kv.Ascend(KVValue{Key: prefix}, func(item KVValue) bool {
+		if !strings.HasPrefix(item.Key, prefix) {
+			return false
+		}
+		resp = append(resp, item)
+		return true
+	})
+	return resp, nil
+}
+
+func (ms *memoryStorage) set(_ context.Context, key string, val []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	v := KVValue{Key: key, Value: val}
+	prev, replaced := ms.kv.Set(v)
+	if !replaced || !bytes.Equal(prev.Value, val) {
+		ms.onChange([]KVEvent{{KVValue: v}})
+	}
+	return nil
+}
+
+func (ms *memoryStorage) del(_ context.Context, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, deleted := ms.kv.Delete(KVValue{Key: key}); deleted {
+		ms.onChange([]KVEvent{{KVValue: KVValue{Key: key}, Deleted: true}})
+	}
+	return nil
+}
+
+func (ms *memoryStorage) close() {
+}
+
+var _ kvStorage = (*localStorage)(nil)
+
+// localStorage stores each key as a file under the directory.
+// The directory is read periodically to find the changes made by other processes, for the same reason as
+// reading the config file periodically.
+type localStorage struct {
+	wg       waitgroup.WaitGroup
+	cancel   context.CancelFunc
+	logger   *zap.Logger
+	dir      string
+	onChange func([]KVEvent)
+	// mu protects snapshot and serializes the writes.
+	mu       sync.Mutex
+	snapshot map[string][]byte
+}
+
+func newLocalStorage(logger *zap.Logger, dir string, checkInterval time.Duration, onChange func([]KVEvent)) (*localStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ls := &localStorage{
+		logger:   logger,
+		dir:      dir,
+		onChange: onChange,
+		snapshot: make(map[string][]byte),
+	}
+	ls.mu.Lock()
+	err := ls.reload()
+	ls.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, ls.cancel = context.WithCancel(context.Background())
+	ls.wg.RunWithRecover(func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ls.mu.Lock()
+				if err := ls.reload(); err != nil {
+					ls.logger.Warn("failed to read namespace storage", zap.String("dir", ls.dir), zap.Error(err))
+				}
+				ls.mu.Unlock()
+			}
+		}
+	}, nil, logger)
+	return ls, nil
+}
+
+// reload reads all the files and reports the changes since the last reload. It must be called with mu held.
+func (ls *localStorage) reload() error {
+	kvs := make(map[string][]byte, len(ls.snapshot))
+	err := filepath.WalkDir(ls.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the temporary files that are being written.
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(ls.dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		kvs[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if events := diffKVs(ls.snapshot, kvs); len(events) > 0 {
+		ls.snapshot = kvs
+		ls.onChange(events)
+	}
+	return nil
+}
+
+func (ls *localStorage) filePath(key string) (string, error) {
+	key = path.Clean(key)
+	if key == "." || key == ".." || strings.HasPrefix(key, "../") || strings.HasPrefix(path.Base(key), ".") {
+		return "", errors.Errorf("invalid key %s", key)
+	}
+	return filepath.Join(ls.dir, filepath.FromSlash(key)), nil
+}
+
+func (ls *localStorage) get(_ context.Context, key string) (KVValue, bool, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.reload(); err != nil {
+		return KVValue{}, false, err
+	}
+	v, ok := ls.snapshot[key]
+	return KVValue{Key: key, Value: v}, ok, nil
+}
+
+func (ls *localStorage) list(_ context.Context, prefix string) ([]KVValue, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.reload(); err != nil {
+		return nil, err
+	}
+	var resp []KVValue
+	for k, v := range ls.snapshot {
+		if strings.HasPrefix(k, prefix) {
+			resp = append(resp, KVValue{Key: k, Value: v})
+		}
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Key < resp[j].Key
+	})
+	return resp, nil
+}
+
+func (ls *localStorage) set(_ context.Context, key string, val []byte) error {
+	p, err := ls.filePath(key)
+	if err != nil {
+		return err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	// Write to a temporary file and rename it so that readers never see a partial file.
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = f.Write(val)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+	return ls.reload()
+}
+
+func (ls *localStorage) del(_ context.Context, key string) error {
+	p, err := ls.filePath(key)
+	if err != nil {
+		return err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	return ls.reload()
+}
+
+func (ls *localStorage) close() {
+	if ls.cancel != nil {
+		ls.cancel()
+	}
+	ls.wg.Wait()
+}
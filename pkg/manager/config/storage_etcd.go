@@ -0,0 +1,166 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// etcdKeyPrefix is the prefix of all the keys in etcd, e.g. the namespace ns1 is stored in /tiproxy/ns/ns1.
+	etcdKeyPrefix  = "/tiproxy/"
+	etcdTimeout    = 5 * time.Second
+	etcdRetryIntvl = time.Second
+)
+
+var _ kvStorage = (*etcdStorage)(nil)
+
+// etcdStorage stores the key-values in the PD etcd so that they are shared by all TiProxy instances.
+// The changes are reported by watching etcd, so the changes made by any instance are reported to all instances.
+// Only the keys under the directory are watched because /tiproxy/ also contains other keys, such as the owner keys.
+type etcdStorage struct {
+	wg       waitgroup.WaitGroup
+	cancel   context.CancelFunc
+	logger   *zap.Logger
+	cli      *clientv3.Client
+	onChange func([]KVEvent)
+	// prefix is the watched prefix, e.g. /tiproxy/ns/.
+	prefix string
+	// snapshot is only accessed by the watch goroutine. It's used to find the changes after re-watching.
+	snapshot map[string][]byte
+}
+
+func newEtcdStorage(logger *zap.Logger, cli *clientv3.Client, dir string, onChange func([]KVEvent)) *etcdStorage {
+	es := &etcdStorage{
+		logger:   logger,
+		cli:      cli,
+		onChange: onChange,
+		prefix:   etcdKeyPrefix + dir + "/",
+		snapshot: make(map[string][]byte),
+	}
+	var ctx context.Context
+	ctx, es.cancel = context.WithCancel(context.Background())
+	es.wg.RunWithRecover(func() {
+		es.watchLoop(ctx)
+	}, nil, logger)
+	return es
+}
+
+// watchLoop loads the snapshot and then watches the changes. If the watch fails, e.g. the revision is compacted,
+// it reloads the snapshot and reports the differences before watching again.
+func (es *etcdStorage) watchLoop(ctx context.Context) {
+	for ctx.Err() == nil {
+		rev, err := es.resync(ctx)
+		if err != nil {
+			es.logger.Warn("failed to load key-values from etcd", zap.String("prefix", es.prefix), zap.Error(err))
+		} else {
+			es.watch(ctx, rev)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(etcdRetryIntvl):
+		}
+	}
+}
+
+func (es *etcdStorage) resync(ctx context.Context) (int64, error) {
+	childCtx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	resp, err := es.cli.Get(childCtx, es.prefix, clientv3.WithPrefix())
+	cancel()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	kvs := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		kvs[strings.TrimPrefix(string(kv.Key), etcdKeyPrefix)] = kv.Value
+	}
+	if events := diffKVs(es.snapshot, kvs); len(events) > 0 {
+		es.snapshot = kvs
+		es.onChange(events)
+	}
+	return resp.Header.Revision, nil
+}
+
+func (es *etcdStorage) watch(ctx context.Context, rev int64) {
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	for resp := range es.cli.Watch(watchCtx, es.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1)) {
+		if err := resp.Err(); err != nil {
+			es.logger.Warn("watch key-values in etcd failed", zap.String("prefix", es.prefix), zap.Error(err))
+			return
+		}
+		events := make([]KVEvent, 0, len(resp.Events))
+		for _, ev := range resp.Events {
+			key := strings.TrimPrefix(string(ev.Kv.Key), etcdKeyPrefix)
+			if ev.Type == clientv3.EventTypeDelete {
+				delete(es.snapshot, key)
+				events = append(events, KVEvent{KVValue: KVValue{Key: key}, Deleted: true})
+			} else if v, ok := es.snapshot[key]; !ok || !bytes.Equal(v, ev.Kv.Value) {
+				// Putting the same value also generates an event, ignore it.
+				es.snapshot[key] = ev.Kv.Value
+				events = append(events, KVEvent{KVValue: KVValue{Key: key, Value: ev.Kv.Value}})
+			}
+		}
+		if len(events) > 0 {
+			es.onChange(events)
+		}
+	}
+}
+
+func (es *etcdStorage) get(ctx context.Context, key string) (KVValue, bool, error) {
+	childCtx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	resp, err := es.cli.Get(childCtx, etcdKeyPrefix+key)
+	cancel()
+	if err != nil {
+		return KVValue{}, false, errors.WithStack(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return KVValue{Key: key}, false, nil
+	}
+	return KVValue{Key: key, Value: resp.Kvs[0].Value}, true, nil
+}
+
+func (es *etcdStorage) list(ctx context.Context, prefix string) ([]KVValue, error) {
+	childCtx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	resp, err := es.cli.Get(childCtx, etcdKeyPrefix+prefix, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	cancel()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	kvs := make([]KVValue, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		kvs = append(kvs, KVValue{Key: strings.TrimPrefix(string(kv.Key), etcdKeyPrefix), Value: kv.Value})
+	}
+	return kvs, nil
+}
+
+func (es *etcdStorage) set(ctx context.Context, key string, val []byte) error {
+	childCtx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	_, err := es.cli.Put(childCtx, etcdKeyPrefix+key, string(val))
+	cancel()
+	return errors.WithStack(err)
+}
+
+func (es *etcdStorage) del(ctx context.Context, key string) error {
+	childCtx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	_, err := es.cli.Delete(childCtx, etcdKeyPrefix+key)
+	cancel()
+	return errors.WithStack(err)
+}
+
+func (es *etcdStorage) close() {
+	if es.cancel != nil {
+		es.cancel()
+	}
+	es.wg.Wait()
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/stretchr/testify/require"
+)
+
+type eventCollector struct {
+	sync.Mutex
+	events []KVEvent
+}
+
+func (ec *eventCollector) onChange(events []KVEvent) {
+	ec.Lock()
+	ec.events = append(ec.events, events...)
+	ec.Unlock()
+}
+
+func (ec *eventCollector) get() []KVEvent {
+	ec.Lock()
+	defer ec.Unlock()
+	return append([]KVEvent{}, ec.events...)
+}
+
+// testStorage checks the storage. Only the events of the keys under the prefix are expected if it's not empty.
+func testStorage(t *testing.T, storage kvStorage, ec *eventCollector, prefix string) {
+	ctx := context.Background()
+	require.NoError(t, storage.set(ctx, "ns/b", []byte("b")))
+	require.NoError(t, storage.set(ctx, "ns/a", []byte("a")))
+	require.NoError(t, storage.set(ctx, "config/a", []byte("c")))
+	kv, ok, err := storage.get(ctx, "ns/a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "a", string(kv.Value))
+	_, ok, err = storage.get(ctx, "ns/c")
+	require.NoError(t, err)
+	require.False(t, ok)
+	kvs, err := storage.list(ctx, "ns")
+	require.NoError(t, err)
+	require.Equal(t, []KVValue{{Key: "ns/a", Value: []byte("a")}, {Key: "ns/b", Value: []byte("b")}}, kvs)
+
+	// Setting the same value is not reported.
+	require.NoError(t, storage.set(ctx, "ns/a", []byte("a")))
+	require.NoError(t, storage.set(ctx, "ns/a", []byte("a2")))
+	require.NoError(t, storage.del(ctx, "ns/b"))
+	require.NoError(t, storage.del(ctx, "ns/c"))
+	kvs, err = storage.list(ctx, "ns")
+	require.NoError(t, err)
+	require.Equal(t, []KVValue{{Key: "ns/a", Value: []byte("a2")}}, kvs)
+
+	expected := []KVEvent{
+		{KVValue: KVValue{Key: "ns/b", Value: []byte("b")}},
+		{KVValue: KVValue{Key: "ns/a", Value: []byte("a")}},
+		{KVValue: KVValue{Key: "config/a", Value: []byte("c")}},
+		{KVValue: KVValue{Key: "ns/a", Value: []byte("a2")}},
+		{KVValue: KVValue{Key: "ns/b"}, Deleted: true},
+	}
+	expected = slices.DeleteFunc(expected, func(ev KVEvent) bool {
+		return !strings.HasPrefix(ev.Key, prefix)
+	})
+	require.Eventually(t, func() bool {
+		return len(ec.get()) >= len(expected)
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, expected, ec.get())
+}
+
+func TestMemoryStorage(t *testing.T) {
+	ec := &eventCollector{}
+	storage := newMemoryStorage(ec.onChange)
+	testStorage(t, storage, ec, "")
+	storage.close()
+}
+
+func TestLocalStorage(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	dir := t.TempDir()
+	ec := &eventCollector{}
+	storage, err := newLocalStorage(lg, dir, 10*time.Millisecond, ec.onChange)
+	require.NoError(t, err)
+	testStorage(t, storage, ec, "")
+	_, err = os.Stat(filepath.Join(dir, "ns", "a"))
+	require.NoError(t, err)
+	storage.close()
+
+	// The key-values are still there after restart.
+	ec = &eventCollector{}
+	storage, err = newLocalStorage(lg, dir, 10*time.Millisecond, ec.onChange)
+	require.NoError(t, err)
+	kvs, err := storage.list(context.Background(), "ns")
+	require.NoError(t, err)
+	require.Equal(t, []KVValue{{Key: "ns/a", Value: []byte("a2")}}, kvs)
+
+	// The changes made by other processes are reported.
+	ec.Lock()
+	ec.events = nil
+	ec.Unlock()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ns", "b"), []byte("b"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "ns", "a")))
+	require.Eventually(t, func() bool {
+		return len(ec.get()) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, []KVEvent{
+		{KVValue: KVValue{Key: "ns/a"}, Deleted: true},
+		{KVValue: KVValue{Key: "ns/b", Value: []byte("b")}},
+	}, ec.get())
+
+	require.Error(t, storage.set(context.Background(), "../a", []byte("a")))
+	storage.close()
+}
+
+func TestEtcdStorage(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	client := createEtcdClient(t)
+
+	ec1, ec2 := &eventCollector{}, &eventCollector{}
+	storage1 := newEtcdStorage(lg, client, pathPrefixNamespace, ec1.onChange)
+	storage2 := newEtcdStorage(lg, client, pathPrefixNamespace, ec2.onChange)
+	// Wait for the watches to start.
+	time.Sleep(100 * time.Millisecond)
+	// The keys out of the directory, such as config/a, are not watched.
+	testStorage(t, storage1, ec1, pathPrefixNamespace+"/")
+	// The changes made by storage1 are also reported to storage2.
+	require.Eventually(t, func() bool {
+		return len(ec2.get()) == len(ec1.get())
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, ec1.get(), ec2.get())
+	storage1.close()
+	storage2.close()
+}
+
+func TestWatchNamespace(t *testing.T) {
+	cfgmgr, _, ctx := testConfigManager(t, "", "")
+	require.NoError(t, cfgmgr.SetTOMLConfig([]byte(`
+[namespace-storage]
+type = "local"
+dir = "`+filepath.ToSlash(t.TempDir())+`"`)))
+	require.NoError(t, cfgmgr.InitNamespaceStorage(nil))
+
+	ch := cfgmgr.WatchNamespace()
+	nsc := &config.Namespace{Namespace: "ns1", Backend: config.BackendNamespace{Instances: []string{"127.0.0.1:4000"}}}
+	// The writes don't wait for the listeners to consume the events.
+	require.NoError(t, cfgmgr.SetNamespace(ctx, nsc.Namespace, nsc))
+	require.NoError(t, cfgmgr.DelNamespace(ctx, nsc.Namespace))
+	require.Equal(t, []NamespaceEvent{{Namespace: nsc}}, <-ch)
+	require.Equal(t, []NamespaceEvent{{Namespace: &config.Namespace{Namespace: "ns1"}, Deleted: true}}, <-ch)
+
+	// The namespaces in the storage are reported if the watcher is registered before the storage starts.
+	dir := t.TempDir()
+	data, err := json.Marshal(nsc)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, pathPrefixNamespace), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, pathPrefixNamespace, nsc.Namespace), data, 0644))
+	cfgmgr2, _, _ := testConfigManager(t, "", "")
+	require.NoError(t, cfgmgr2.SetTOMLConfig([]byte(`
+[namespace-storage]
+type = "local"
+dir = "`+filepath.ToSlash(dir)+`"`)))
+	ch = cfgmgr2.WatchNamespace()
+	require.NoError(t, cfgmgr2.InitNamespaceStorage(nil))
+	require.Equal(t, []NamespaceEvent{{Namespace: nsc}}, <-ch)
+
+	// The etcd storage requires an etcd client.
+	require.NoError(t, cfgmgr.SetTOMLConfig([]byte(`
+[namespace-storage]
+type = "etcd"`)))
+	require.Error(t, cfgmgr.InitNamespaceStorage(nil))
+}
@@ -243,25 +243,34 @@ func (is *InfoSyncer) removeTopology(ctx context.Context) error {
 }
 
 func (is *InfoSyncer) GetTiDBTopology(ctx context.Context) (map[string]*TiDBTopologyInfo, error) {
+	return getTopology[TiDBTopologyInfo](ctx, is.etcdCli, is.lg, tidbTopologyInformationPath)
+}
+
+// GetTiProxyTopology returns the alive TiProxy instances, including this one.
+func (is *InfoSyncer) GetTiProxyTopology(ctx context.Context) (map[string]*TopologyInfo, error) {
+	return getTopology[TopologyInfo](ctx, is.etcdCli, is.lg, tiproxyTopologyPath)
+}
+
+func getTopology[T any](ctx context.Context, etcdCli *clientv3.Client, lg *zap.Logger, topologyPath string) (map[string]*T, error) {
 	// etcdCli.Get will retry infinitely internally.
-	res, err := is.etcdCli.Get(ctx, tidbTopologyInformationPath, clientv3.WithPrefix())
+	res, err := etcdCli.Get(ctx, topologyPath, clientv3.WithPrefix())
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	infos := make(map[string]*TiDBTopologyInfo, len(res.Kvs)/2)
+	infos := make(map[string]*T, len(res.Kvs)/2)
 	ttls := make(map[string]struct{}, len(res.Kvs)/2)
 	for _, kv := range res.Kvs {
 		key := hack.String(kv.Key)
 		switch {
 		case strings.HasSuffix(key, ttlSuffix):
-			addr := key[len(tidbTopologyInformationPath)+1 : len(key)-len(ttlSuffix)-1]
+			addr := key[len(topologyPath)+1 : len(key)-len(ttlSuffix)-1]
 			ttls[addr] = struct{}{}
 		case strings.HasSuffix(key, infoSuffix):
-			var topology *TiDBTopologyInfo
-			addr := key[len(tidbTopologyInformationPath)+1 : len(key)-len(infoSuffix)-1]
+			var topology *T
+			addr := key[len(topologyPath)+1 : len(key)-len(infoSuffix)-1]
 			if err = json.Unmarshal(kv.Value, &topology); err != nil {
-				is.lg.Error("unmarshal topology info failed", zap.String("key", key),
+				lg.Error("unmarshal topology info failed", zap.String("key", key),
 					zap.String("value", hack.String(kv.Value)), zap.Error(err))
 			} else {
 				infos[addr] = topology
@@ -170,6 +170,23 @@ func TestFetchTiDBTopology(t *testing.T) {
 	}
 }
 
+// The TiProxy itself can be fetched from the topology.
+func TestFetchTiProxyTopology(t *testing.T) {
+	ts := newEtcdTestSuite(t)
+	t.Cleanup(ts.close)
+	require.Eventually(t, func() bool {
+		infos, err := ts.is.GetTiProxyTopology(context.Background())
+		require.NoError(t, err)
+		if len(infos) != 1 {
+			return false
+		}
+		for addr, info := range infos {
+			require.Equal(t, net.JoinHostPort(info.IP, info.Port), addr)
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
 func TestGetTopology(t *testing.T) {
 	ts := newEtcdTestSuite(t)
 	t.Cleanup(ts.close)
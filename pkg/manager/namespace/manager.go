@@ -16,6 +16,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/balance/factor"
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	mconfig "github.com/pingcap/tiproxy/pkg/manager/config"
 	"github.com/pingcap/tiproxy/pkg/util/http"
@@ -30,12 +31,16 @@ type NamespaceManager interface {
 	GetNamespace(nm string) (*Namespace, bool)
 	GetNamespaceByUser(user string) (*Namespace, bool)
 	RedirectConnections() []error
+	BalanceHistory() map[string][]router.BalanceRecord
+	BalanceScores() map[string]policy.BalanceScores
 	Ready() bool
 	Close() error
 }
 
 type namespaceManager struct {
 	sync.RWMutex
+	// commitMu serializes the commits so that each replaced namespace is closed only once.
+	commitMu      sync.Mutex
 	nsm           map[string]*Namespace
 	tpFetcher     observer.TopologyFetcher
 	promFetcher   metricsreader.PromInfoFetcher
@@ -67,17 +72,27 @@ func (mgr *namespaceManager) buildNamespace(cfg *config.Namespace) (*Namespace,
 	bo := observer.NewDefaultBackendObserver(logger.Named("observer"), healthCheckCfg, fetcher, hc, mgr.cfgMgr)
 	bo.Start(context.Background())
 	balancePolicy := factor.NewFactorBasedBalance(logger.Named("factor"), mgr.metricsReader)
-	rt.Init(context.Background(), bo, balancePolicy, mgr.cfgMgr.GetConfig(), mgr.cfgMgr.WatchConfig())
+	cfgCh := mgr.cfgMgr.WatchConfig()
+	rt.Init(context.Background(), bo, balancePolicy, mgr.cfgMgr.GetConfig(), cfgCh)
 
 	return &Namespace{
 		name:   cfg.Namespace,
 		user:   cfg.Frontend.User,
+		cfg:    cfg,
 		bo:     bo,
 		router: rt,
+		cfgMgr: mgr.cfgMgr,
+		cfgCh:  cfgCh,
 	}, nil
 }
 
+// CommitNamespaces builds the changed namespaces and closes the ones that are replaced or deleted.
+// The namespaces whose configs don't change are kept, such as the ones that are reported again by the storage
+// after Init.
 func (mgr *namespaceManager) CommitNamespaces(nss []*config.Namespace, nssDelete []bool) error {
+	mgr.commitMu.Lock()
+	defer mgr.commitMu.Unlock()
+
 	nsm := make(map[string]*Namespace)
 	mgr.RLock()
 	for k, v := range mgr.nsm {
@@ -85,22 +100,48 @@ func (mgr *namespaceManager) CommitNamespaces(nss []*config.Namespace, nssDelete
 	}
 	mgr.RUnlock()
 
+	// The namespaces built in this commit are closed immediately if they are replaced because they are not used yet.
+	built := make(map[string]*Namespace)
+	var retired []*Namespace
+	retire := func(name string) {
+		old, ok := nsm[name]
+		if !ok {
+			return
+		}
+		if built[name] == old {
+			old.Close()
+		} else {
+			retired = append(retired, old)
+		}
+	}
 	for i, nsc := range nss {
 		if nssDelete != nil && nssDelete[i] {
+			retire(nsc.Namespace)
 			delete(nsm, nsc.Namespace)
 			continue
 		}
+		if old, ok := nsm[nsc.Namespace]; ok && reflect.DeepEqual(old.cfg, nsc) {
+			continue
+		}
 
 		ns, err := mgr.buildNamespace(nsc)
 		if err != nil {
+			for _, ns := range built {
+				ns.Close()
+			}
 			return fmt.Errorf("%w: create namespace error, namespace: %s", err, nsc.Namespace)
 		}
+		retire(ns.Name())
 		nsm[ns.Name()] = ns
+		built[ns.Name()] = ns
 	}
 
 	mgr.Lock()
 	mgr.nsm = nsm
 	mgr.Unlock()
+	for _, ns := range retired {
+		ns.Close()
+	}
 	return nil
 }
 
@@ -152,6 +193,30 @@ func (mgr *namespaceManager) RedirectConnections() []error {
 	return errs
 }
 
+// BalanceHistory returns the recent balance decisions of each namespace.
+func (mgr *namespaceManager) BalanceHistory() map[string][]router.BalanceRecord {
+	mgr.RLock()
+	defer mgr.RUnlock()
+
+	history := make(map[string][]router.BalanceRecord, len(mgr.nsm))
+	for name, ns := range mgr.nsm {
+		history[name] = ns.GetRouter().BalanceHistory()
+	}
+	return history
+}
+
+// BalanceScores returns the score breakdown of the backends of each namespace.
+func (mgr *namespaceManager) BalanceScores() map[string]policy.BalanceScores {
+	mgr.RLock()
+	defer mgr.RUnlock()
+
+	scores := make(map[string]policy.BalanceScores, len(mgr.nsm))
+	for name, ns := range mgr.nsm {
+		scores[name] = ns.GetRouter().BalanceScores()
+	}
+	return scores
+}
+
 func (mgr *namespaceManager) Ready() bool {
 	mgr.RLock()
 	defer mgr.RUnlock()
@@ -4,9 +4,16 @@
 package namespace
 
 import (
+	"context"
+	"crypto/tls"
 	"testing"
 
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
+	mconfig "github.com/pingcap/tiproxy/pkg/manager/config"
+	"github.com/pingcap/tiproxy/pkg/util/http"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -30,3 +37,75 @@ func TestReady(t *testing.T) {
 	ns.router = rt
 	require.True(t, nsMgr.Ready())
 }
+
+func TestCommitNamespaces(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	cfgMgr := mconfig.NewConfigManager()
+	require.NoError(t, cfgMgr.Init(context.Background(), lg, "", ""))
+	t.Cleanup(func() {
+		require.NoError(t, cfgMgr.Close())
+	})
+	newNamespace := func(name, addr string) *config.Namespace {
+		return &config.Namespace{Namespace: name, Backend: config.BackendNamespace{Instances: []string{addr}}}
+	}
+	nsMgr := NewNamespaceManager()
+	httpCli := http.NewHTTPClient(func() *tls.Config { return nil })
+	require.NoError(t, nsMgr.Init(lg, []*config.Namespace{newNamespace("ns1", "127.0.0.1:4000")}, nil, nil, httpCli, cfgMgr, &mockMetricsReader{}))
+	t.Cleanup(func() {
+		require.NoError(t, nsMgr.Close())
+	})
+	ns1, ok := nsMgr.GetNamespace("ns1")
+	require.True(t, ok)
+
+	// The namespace is kept if the config doesn't change.
+	require.NoError(t, nsMgr.CommitNamespaces([]*config.Namespace{newNamespace("ns1", "127.0.0.1:4000")}, nil))
+	ns, ok := nsMgr.GetNamespace("ns1")
+	require.True(t, ok)
+	require.Same(t, ns1, ns)
+
+	// The replaced namespace is closed.
+	require.NoError(t, nsMgr.CommitNamespaces([]*config.Namespace{newNamespace("ns1", "127.0.0.1:4001")}, nil))
+	ns, ok = nsMgr.GetNamespace("ns1")
+	require.True(t, ok)
+	require.NotSame(t, ns1, ns)
+	_, ok = <-ns1.cfgCh
+	require.False(t, ok)
+
+	// The deleted namespace is closed.
+	require.NoError(t, nsMgr.CommitNamespaces([]*config.Namespace{{Namespace: "ns1"}}, []bool{true}))
+	_, ok = nsMgr.GetNamespace("ns1")
+	require.False(t, ok)
+	_, ok = <-ns.cfgCh
+	require.False(t, ok)
+
+	// The closed namespaces don't block the config updates.
+	require.NoError(t, cfgMgr.SetTOMLConfig([]byte(`log.level = "debug"`)))
+}
+
+var _ metricsreader.MetricsReader = (*mockMetricsReader)(nil)
+
+type mockMetricsReader struct{}
+
+func (mmr *mockMetricsReader) Start(context.Context) error {
+	return nil
+}
+
+func (mmr *mockMetricsReader) AddQueryExpr(string, metricsreader.QueryExpr, metricsreader.QueryRule) {
+}
+
+func (mmr *mockMetricsReader) RemoveQueryExpr(string) {
+}
+
+func (mmr *mockMetricsReader) GetQueryResult(string) metricsreader.QueryResult {
+	return metricsreader.QueryResult{}
+}
+
+func (mmr *mockMetricsReader) GetBackendMetrics() []byte {
+	return nil
+}
+
+func (mmr *mockMetricsReader) PreClose() {
+}
+
+func (mmr *mockMetricsReader) Close() {
+}
@@ -7,15 +7,20 @@
 package namespace
 
 import (
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
+	mconfig "github.com/pingcap/tiproxy/pkg/manager/config"
 )
 
 type Namespace struct {
 	name   string
 	user   string
+	cfg    *config.Namespace
 	bo     observer.BackendObserver
 	router router.Router
+	cfgMgr *mconfig.ConfigManager
+	cfgCh  <-chan *config.Config
 }
 
 func (n *Namespace) Name() string {
@@ -31,6 +36,8 @@ func (n *Namespace) GetRouter() router.Router {
 }
 
 func (n *Namespace) Close() {
+	// Unwatch before closing the router because the router keeps receiving the config until the channel is closed.
+	n.cfgMgr.UnwatchConfig(n.cfgCh)
 	n.router.Close()
 	n.bo.Close()
 }
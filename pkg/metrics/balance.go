@@ -36,6 +36,22 @@ var (
 			Help:      "Number and result of session migration.",
 		}, []string{LblFrom, LblTo, LblReason, LblMigrateResult})
 
+	DryRunMigrateCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelBalance,
+			Name:      "dry_run_migrate_total",
+			Help:      "Number of session migrations that are skipped in dry-run mode.",
+		}, []string{LblFrom, LblTo, LblReason})
+
+	ConnLimitCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelBalance,
+			Name:      "conn_limit_total",
+			Help:      "Number of connections that fail to connect because all backends reach the max connections, labeled by the strategy.",
+		}, []string{LblType})
+
 	MigrateDurationHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: ModuleProxy,
@@ -98,6 +98,7 @@ func init() {
 	colls = []prometheus.Collector{
 		ConnGauge,
 		CreateConnCounter,
+		UntrustedProxyHeaderCounter,
 		DisConnCounter,
 		MaxProcsGauge,
 		OwnerGauge,
@@ -115,6 +116,8 @@ func init() {
 		BackendConnGauge,
 		HealthCheckCycleGauge,
 		MigrateCounter,
+		DryRunMigrateCounter,
+		ConnLimitCounter,
 		MigrateDurationHistogram,
 		InboundBytesCounter,
 		InboundPacketsCounter,
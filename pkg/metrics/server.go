@@ -34,6 +34,14 @@ var (
 			Help:      "Number of create connections.",
 		})
 
+	UntrustedProxyHeaderCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: ModuleProxy,
+			Subsystem: LabelServer,
+			Name:      "untrusted_proxy_header_total",
+			Help:      "Number of connections rejected for sending proxy protocol headers from untrusted peers.",
+		})
+
 	DisConnCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: ModuleProxy,
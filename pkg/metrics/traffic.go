@@ -5,6 +5,11 @@ package metrics
 
 import "github.com/prometheus/client_golang/prometheus"
 
+const (
+	// LblLevel is the location level, such as region, zone and rack.
+	LblLevel = "level"
+)
+
 var (
 	InboundBytesCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -38,11 +43,11 @@ var (
 			Help:      "Counter of packets to backends.",
 		}, []string{LblBackend})
 
-	CrossLocationBytesCounter = prometheus.NewCounter(
+	CrossLocationBytesCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: ModuleProxy,
 			Subsystem: LabelTraffic,
 			Name:      "cross_location_bytes",
-			Help:      "Counter of bytes between TiProxy and cross-location backends.",
-		})
+			Help:      "Counter of bytes between TiProxy and cross-location backends, labeled by the highest differing location level.",
+		}, []string{LblLevel})
 )
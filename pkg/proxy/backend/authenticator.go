@@ -55,8 +55,13 @@ func NewAuthenticator(config *BCConfig) *Authenticator {
 
 func (auth *Authenticator) writeProxyProtocol(clientIO, backendIO pnet.PacketIO) error {
 	if auth.proxyProtocol {
-		proxy := clientIO.Proxy()
-		if proxy == nil {
+		var proxy *proxyprotocol.Proxy
+		if clientProxy := clientIO.Proxy(); clientProxy != nil {
+			// The client header may be v1, but the backends always receive v2 with the TLVs.
+			copied := *clientProxy
+			copied.Version = proxyprotocol.ProxyVersion2
+			proxy = &copied
+		} else {
 			proxy = &proxyprotocol.Proxy{
 				SrcAddress: clientIO.RemoteAddr(),
 				DstAddress: backendIO.RemoteAddr(),
@@ -65,6 +70,14 @@ func (auth *Authenticator) writeProxyProtocol(clientIO, backendIO pnet.PacketIO)
 		}
 		// either from another proxy or directly from clients, we are acting as a proxy
 		proxy.Command = proxyprotocol.ProxyCommandProxy
+		// The address of a Unix socket client can't be sent to a TCP backend, so the backend uses the address of
+		// TiProxy instead, the same as a connection without the PROXY header.
+		if proxyprotocol.AddressFamilyMismatch(proxy.SrcAddress, proxy.DstAddress) {
+			proxy = &proxyprotocol.Proxy{
+				Version: proxyprotocol.ProxyVersion2,
+				Command: proxyprotocol.ProxyCommandLocal,
+			}
+		}
 		backendIO.EnableProxyClient(proxy)
 	}
 	return nil
@@ -25,6 +25,7 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/waitgroup"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"github.com/siddontang/go/hack"
 	"go.uber.org/zap"
@@ -54,6 +55,7 @@ const (
 	sessionStatesCol = "Session_states"
 	sessionTokenCol  = "Session_token"
 	currentDBKey     = "current-db"
+	resourceGroupKey = "rs-group"
 )
 
 type signalType int
@@ -93,8 +95,13 @@ type BCConfig struct {
 	CheckBackendInterval time.Duration
 	ConnectTimeout       time.Duration
 	ConnBufferSize       int
-	ProxyProtocol        bool
-	RequireBackendTLS    bool
+	// ProxyProtocol accepts the proxy headers from clients and sends v2 headers to backends.
+	ProxyProtocol bool
+	// ProxyProtocolVersion is the only accepted version of the client proxy headers. 0 accepts both v1 and v2.
+	ProxyProtocolVersion proxyprotocol.ProxyVersion
+	// ProxyProtocolAllowlist contains the peers allowed to send the proxy headers. Empty means all peers are trusted.
+	ProxyProtocolAllowlist proxyprotocol.Allowlist
+	RequireBackendTLS      bool
 }
 
 func (cfg *BCConfig) check() {
@@ -136,6 +143,8 @@ type BackendConnManager struct {
 	redirectResCh chan *redirectResult
 	// GracefulClose() sets it without lock.
 	closeStatus atomic.Int32
+	// The resource group read from the session states. It's read by the router without lock.
+	resourceGroup atomic.Pointer[string]
 	// The last time when the backend is active.
 	lastActiveTime time.Time
 	// The traffic recorded last time.
@@ -177,6 +186,14 @@ func NewBackendConnManager(logger *zap.Logger, handshakeHandler HandshakeHandler
 	return mgr
 }
 
+// ResourceGroup implements RedirectableConn.ResourceGroup interface.
+func (mgr *BackendConnManager) ResourceGroup() string {
+	if resourceGroup := mgr.resourceGroup.Load(); resourceGroup != nil {
+		return *resourceGroup
+	}
+	return ""
+}
+
 // ConnectionID implements RedirectableConn.ConnectionID interface.
 // It returns the ID of the frontend connection. The ID stays still after session migration.
 func (mgr *BackendConnManager) ConnectionID() uint64 {
@@ -216,6 +233,13 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 		mgr.quitSource = src
 		return err
 	}
+	mgr.onConnected(ctx, startTime)
+	return nil
+}
+
+// onConnected starts watching redirection signals after connecting to the first backend.
+// NOTE: processLock should be held before calling this function.
+func (mgr *BackendConnManager) onConnected(ctx context.Context, startTime time.Time) {
 	mgr.handshakeHandler.OnHandshake(mgr, mgr.ServerAddr(), nil, SrcNone)
 	endTime := time.Now()
 	addHandshakeMetrics(mgr.ServerAddr(), endTime.Sub(startTime))
@@ -226,7 +250,7 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 	mgr.cancelFunc = cancelFunc
 	mgr.lastActiveTime = endTime
 	if mgr.cpt != nil && !reflect.ValueOf(mgr.cpt).IsNil() {
-		mgr.cpt.InitConn(endTime, mgr.connectionID, mgr.authenticator.dbname)
+		mgr.cpt.InitConn(endTime, mgr.connectionID, mgr.authenticator.user, mgr.authenticator.dbname)
 	}
 	mgr.wg.RunWithRecover(func() {
 		mgr.processSignals(childCtx)
@@ -235,7 +259,6 @@ func (mgr *BackendConnManager) Connect(ctx context.Context, clientIO pnet.Packet
 		// Serverless Tier.
 		_ = mgr.Close()
 	}, mgr.logger)
-	return nil
 }
 
 func (mgr *BackendConnManager) newExponentialBackOff() *backoff.ExponentialBackOff {
@@ -261,24 +284,42 @@ func (mgr *BackendConnManager) getBackendIO(ctx context.Context, cctx ConnContex
 	// - The TiDB instances may not be initialized yet
 	// - One TiDB may be just shut down and another is just started but not ready yet
 	bctx, cancel := context.WithTimeout(ctx, mgr.config.ConnectTimeout)
-	selector := r.GetBackendSelector()
+	info := router.ClientInfo{ClientAddr: cctx.ClientAddr()}
+	if resp != nil {
+		info.User, info.Attrs = resp.User, resp.Attrs
+	}
+	if mgr.clientIO != nil {
+		if proxy := mgr.clientIO.Proxy(); proxy != nil {
+			info.ProxyTLVs = proxy.TLVValues()
+		}
+	}
+	selector := r.GetBackendSelector(info)
 	startTime := time.Now()
 	var addr string
 	var backend router.BackendInst
 	var origErr error
+	// connLimit is the strategy of the conn limit if all backends are full.
+	var connLimit string
 	io, err := backoff.RetryNotifyWithData(
 		func() (pnet.PacketIO, error) {
 			addr = ""
 			// Try to connect to all backup backends one by one.
 			if backend, err = selector.Next(); err == router.ErrNoBackend {
 				return nil, ErrProxyNoBackend
+			} else if err == router.ErrWaitForBackend {
+				// Wait until some connections are closed.
+				connLimit = config.ConnLimitStrategyQueue
+				return nil, ErrProxyBackendFull
+			} else if err == router.ErrBackendsFull {
+				connLimit = config.ConnLimitStrategyReject
+				return nil, backoff.Permanent(ErrProxyBackendFull)
 			} else if err != nil {
 				return nil, backoff.Permanent(errors.Wrap(err, ErrProxyErr))
 			}
 
 			var cn net.Conn
 			addr = backend.Addr()
-			cn, err = net.DialTimeout("tcp", addr, DialTimeout)
+			cn, err = pnet.DialTimeout(addr, DialTimeout)
 			selector.Finish(mgr, err == nil)
 			if err != nil {
 				return nil, errors.Wrap(errors.Wrapf(err, "dial backend %s error", addr), ErrBackendHandshake)
@@ -314,6 +355,10 @@ func (mgr *BackendConnManager) getBackendIO(ctx context.Context, cctx ConnContex
 			err = origErr
 		}
 	}
+	// Count the connection once no matter how many times it retries in the queue.
+	if errors.Is(err, ErrProxyBackendFull) {
+		addConnLimitMetrics(connLimit)
+	}
 	return io, err
 }
 
@@ -364,6 +409,8 @@ func (mgr *BackendConnManager) ExecuteCmd(ctx context.Context, request []byte) (
 	holdRequest, err = mgr.cmdProcessor.executeCmd(request, mgr.clientIO, backendIO, waitingRedirect)
 	if !holdRequest {
 		addCmdMetrics(cmd, backendIO.RemoteAddr().String(), startTime)
+		// The latency of a held request includes the redirection time, so it's not observed.
+		mgr.curBackend.ObserveLatency(cmd, time.Since(startTime))
 		mgr.updateTraffic(backendIO)
 	}
 	if err != nil {
@@ -416,7 +463,7 @@ func (mgr *BackendConnManager) ExecuteCmd(ctx context.Context, request []byte) (
 
 func (mgr *BackendConnManager) updateTraffic(backendIO pnet.PacketIO) {
 	inBytes, inPackets, outBytes, outPackets := backendIO.InBytes(), backendIO.InPackets(), backendIO.OutBytes(), backendIO.OutPackets()
-	addTraffic(backendIO.RemoteAddr().String(), inBytes-mgr.inBytes, inPackets-mgr.inPackets, outBytes-mgr.outBytes, outPackets-mgr.outPackets, mgr.curBackend.Local())
+	addTraffic(backendIO.RemoteAddr().String(), inBytes-mgr.inBytes, inPackets-mgr.inPackets, outBytes-mgr.outBytes, outPackets-mgr.outPackets, mgr.curBackend.CrossLocationLevel())
 	mgr.inBytes, mgr.inPackets, mgr.outBytes, mgr.outPackets = inBytes, inPackets, outBytes, outPackets
 }
 
@@ -456,22 +503,22 @@ func (mgr *BackendConnManager) querySessionStates(backendIO pnet.PacketIO) (sess
 	return
 }
 
-func (mgr *BackendConnManager) initForCapture() (string, error) {
+func (mgr *BackendConnManager) initForCapture() (string, string, error) {
 	mgr.processLock.Lock()
 	defer mgr.processLock.Unlock()
 	if mgr.closeStatus.Load() >= statusClosing {
-		return "", ErrClosing
+		return "", "", ErrClosing
 	}
 	if !mgr.cmdProcessor.finishedTxn() {
-		return "", ErrInTxn
+		return "", "", ErrInTxn
 	}
 	sessionStates, _, err := mgr.querySessionStates(*mgr.backendIO.Load())
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	sessionStates = strings.ReplaceAll(sessionStates, "\\", "\\\\")
 	sessionStates = strings.ReplaceAll(sessionStates, "'", "\\'")
-	return fmt.Sprintf(sqlSetState, sessionStates), nil
+	return mgr.authenticator.user, fmt.Sprintf(sqlSetState, sessionStates), nil
 }
 
 // processSignals runs in a goroutine to:
@@ -566,7 +613,7 @@ func (mgr *BackendConnManager) tryRedirect(ctx context.Context) {
 	}
 
 	var cn net.Conn
-	cn, rs.err = net.DialTimeout("tcp", rs.to, DialTimeout)
+	cn, rs.err = pnet.DialTimeout(rs.to, DialTimeout)
 	if rs.err != nil {
 		mgr.handshakeHandler.OnHandshake(mgr, rs.to, rs.err, SrcBackendNetwork)
 		return
@@ -608,6 +655,9 @@ func (mgr *BackendConnManager) updateAuthInfoFromSessionStates(sessionStates []b
 	if currentDB, ok := statesMap[currentDBKey].(string); ok {
 		mgr.authenticator.updateCurrentDB(currentDB)
 	}
+	if resourceGroup, ok := statesMap[resourceGroupKey].(string); ok {
+		mgr.resourceGroup.Store(&resourceGroup)
+	}
 	return nil
 }
 
@@ -829,6 +879,18 @@ func (mgr *BackendConnManager) UpdateLogger(fields ...zap.Field) {
 }
 
 // ConnInfo returns detailed info of the connection, which should not be logged too many times.
+// proxyFields records who the client really is and how it came in, e.g. through which VPC endpoint.
+func proxyFields(proxy *proxyprotocol.Proxy) []zap.Field {
+	fields := []zap.Field{zap.Int("proxy_version", int(proxy.Version))}
+	if proxy.SrcAddress != nil {
+		fields = append(fields, zap.Stringer("proxy_src_addr", proxy.SrcAddress))
+	}
+	if tlvs := proxy.TLVValues(); len(tlvs) > 0 {
+		fields = append(fields, zap.Any("proxy_tlvs", tlvs))
+	}
+	return fields
+}
+
 func (mgr *BackendConnManager) ConnInfo() []zap.Field {
 	mgr.processLock.Lock()
 	var fields []zap.Field
@@ -837,5 +899,10 @@ func (mgr *BackendConnManager) ConnInfo() []zap.Field {
 	}
 	mgr.processLock.Unlock()
 	fields = append(fields, zap.String("backend_addr", mgr.ServerAddr()))
+	if mgr.clientIO != nil {
+		if proxy := mgr.clientIO.Proxy(); proxy != nil {
+			fields = append(fields, proxyFields(proxy)...)
+		}
+	}
 	return fields
 }
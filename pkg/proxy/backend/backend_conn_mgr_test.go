@@ -7,15 +7,18 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/lib/util/logger"
 	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/pingcap/tiproxy/pkg/balance/observer"
 	"github.com/pingcap/tiproxy/pkg/balance/router"
 	"github.com/pingcap/tiproxy/pkg/metrics"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
@@ -106,10 +109,20 @@ func (mbi *mockBackendInst) Local() bool {
 	return mbi.local.Load()
 }
 
+func (mbi *mockBackendInst) CrossLocationLevel() string {
+	if mbi.local.Load() {
+		return ""
+	}
+	return config.LocationLabelName
+}
+
 func (mbi *mockBackendInst) setLocal(local bool) {
 	mbi.local.Store(local)
 }
 
+func (mbi *mockBackendInst) ObserveLatency(cmd pnet.Command, d time.Duration) {
+}
+
 type runner struct {
 	client  func(packetIO pnet.PacketIO) error
 	proxy   func(clientIO, backendIO pnet.PacketIO) error
@@ -564,10 +577,11 @@ func TestSpecialCmds(t *testing.T) {
 			client: nil,
 			proxy:  ts.redirectSucceed4Proxy,
 			backend: func(packetIO pnet.PacketIO) error {
-				ts.mb.sessionStates = "{\"current-db\":\"session_db\"}"
+				ts.mb.sessionStates = "{\"current-db\":\"session_db\",\"rs-group\":\"rg1\"}"
 				require.NoError(t, ts.redirectSucceed4Backend(packetIO))
 				require.Equal(t, "another_user", ts.mb.username)
 				require.Equal(t, "session_db", ts.mb.db)
+				require.Equal(t, "rg1", ts.mp.ResourceGroup())
 				expectCap := ts.mp.handshakeHandler.GetCapability() & defaultTestClientCapability &^ (pnet.ClientMultiStatements | pnet.ClientPluginAuthLenencClientData)
 				gotCap := ts.mb.capability &^ pnet.ClientPluginAuthLenencClientData
 				require.Equal(t, expectCap, gotCap, "expected=%s,got=%s", expectCap, gotCap)
@@ -953,6 +967,101 @@ func TestGetBackendIO(t *testing.T) {
 	}
 }
 
+func TestGetBackendIOUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tidb.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+	addr := "unix://" + path
+	handler := &CustomHandshakeHandler{
+		getRouter: func(ctx ConnContext, resp *pnet.HandshakeResp) (router.Router, error) {
+			return router.NewStaticRouter([]string{addr}), nil
+		},
+	}
+	lg, text := logger.CreateLoggerForTest(t)
+	mgr := NewBackendConnManager(lg, handler, &mockCapture{}, 0, &BCConfig{
+		ConnectTimeout:   time.Second,
+		HealthyKeepAlive: config.KeepAlive{Enabled: true, Idle: time.Minute, Timeout: time.Minute},
+	})
+	var wg waitgroup.WaitGroup
+	wg.Run(func() {
+		cn, err := listener.Accept()
+		require.NoError(t, err)
+		require.NoError(t, cn.Close())
+	})
+	io, err := mgr.getBackendIO(context.Background(), mgr, nil)
+	require.NoError(t, err)
+	require.Equal(t, addr, io.RemoteAddr().String())
+	// The keepalive settings don't apply to Unix sockets but they don't fail.
+	require.Equal(t, mgr.config.HealthyKeepAlive, io.LastKeepAlive())
+	require.NotContains(t, text.String(), "failed to set keepalive")
+	require.NoError(t, io.Close())
+	wg.Wait()
+}
+
+func TestConnLimitMetrics(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	addr := listener.Addr().String()
+	var wg waitgroup.WaitGroup
+	wg.Run(func() {
+		cn, err := listener.Accept()
+		require.NoError(t, err)
+		require.NoError(t, cn.Close())
+	})
+
+	// The only backend takes at most 1 connection.
+	lg, _ := logger.CreateLoggerForTest(t)
+	cfg := &config.Config{Balance: config.Balance{ConnLimit: config.BalanceConnLimit{MaxConnections: 1, Strategy: config.ConnLimitStrategyQueue}}}
+	cfgCh := make(chan *config.Config)
+	bo := newMockBackendObserver()
+	rt := router.NewScoreBasedRouter(lg)
+	rt.Init(context.Background(), bo, &mockBalancePolicy{}, cfg, cfgCh)
+	t.Cleanup(rt.Close)
+	bo.healthCh <- observer.NewHealthResult(map[string]*observer.BackendHealth{addr: {Healthy: true}}, nil)
+	require.Eventually(t, func() bool {
+		return rt.HealthyBackendCount() == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	handler := &CustomHandshakeHandler{
+		getRouter: func(ctx ConnContext, resp *pnet.HandshakeResp) (router.Router, error) {
+			return rt, nil
+		},
+	}
+	newMgr := func() *BackendConnManager {
+		return NewBackendConnManager(lg, handler, &mockCapture{}, 0, &BCConfig{ConnectTimeout: 300 * time.Millisecond})
+	}
+	mgr := newMgr()
+	io, err := mgr.getBackendIO(context.Background(), mgr, nil)
+	require.NoError(t, err)
+	require.NoError(t, io.Close())
+	wg.Wait()
+
+	// The connection is counted once although it retries until timeout in the queue.
+	readCounter := func(strategy string) int {
+		count, err := metrics.ReadCounter(metrics.ConnLimitCounter.WithLabelValues(strategy))
+		require.NoError(t, err)
+		return count
+	}
+	queued, rejected := readCounter(config.ConnLimitStrategyQueue), readCounter(config.ConnLimitStrategyReject)
+	mgr = newMgr()
+	_, err = mgr.getBackendIO(context.Background(), mgr, nil)
+	require.ErrorIs(t, err, ErrProxyBackendFull)
+	require.Equal(t, queued+1, readCounter(config.ConnLimitStrategyQueue))
+	require.Equal(t, rejected, readCounter(config.ConnLimitStrategyReject))
+
+	// The connection is counted once when it's rejected.
+	cfg = cfg.Clone()
+	cfg.Balance.ConnLimit.Strategy = config.ConnLimitStrategyReject
+	// The second send returns after the router applies the first one.
+	cfgCh <- cfg
+	cfgCh <- cfg
+	_, err = mgr.getBackendIO(context.Background(), mgr, nil)
+	require.ErrorIs(t, err, ErrProxyBackendFull)
+	require.Equal(t, queued+1, readCounter(config.ConnLimitStrategyQueue))
+	require.Equal(t, rejected+1, readCounter(config.ConnLimitStrategyReject))
+}
+
 func TestBackendInactive(t *testing.T) {
 	ts := newBackendMgrTester(t, func(config *testConfig) {
 		config.proxyConfig.bcConfig.TickerInterval = time.Millisecond
@@ -1297,7 +1406,7 @@ func TestTrafficMetrics(t *testing.T) {
 				inBytes, inPackets, outBytes, outPackets, err = readTraffic(addr)
 				require.NoError(t, err)
 				require.True(t, inBytes > 0 && inPackets > 0 && outBytes > 0 && outPackets > 0)
-				crossLocationBytes, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter)
+				crossLocationBytes, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter.WithLabelValues(config.LocationLabelName))
 				require.NoError(t, err)
 				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
 				inBytes2, inPackets2, outBytes2, outPackets2, err := readTraffic(addr)
@@ -1306,7 +1415,7 @@ func TestTrafficMetrics(t *testing.T) {
 				require.True(t, inBytes2 > 4096 && inPackets2 > 1000)
 				inBytes, inPackets, outBytes, outPackets = inBytes2, inPackets2, outBytes2, outPackets2
 				// The first backend is local, so no cross-az traffic.
-				crossLocationBytes2, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter)
+				crossLocationBytes2, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter.WithLabelValues(config.LocationLabelName))
 				require.NoError(t, err)
 				require.True(t, crossLocationBytes2 == crossLocationBytes)
 				return nil
@@ -1341,14 +1450,14 @@ func TestTrafficMetrics(t *testing.T) {
 				inBytes1, inPackets1, outBytes1, outPackets1, err := readTraffic(addr)
 				require.NoError(t, err)
 				require.True(t, inBytes1 > inBytes && inPackets1 > inPackets && outBytes1 > outBytes && outPackets1 > outPackets)
-				crossLocationBytes, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter)
+				crossLocationBytes, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter.WithLabelValues(config.LocationLabelName))
 				require.NoError(t, err)
 				require.NoError(t, ts.forwardCmd4Proxy(clientIO, backendIO))
 				inBytes2, inPackets2, outBytes2, outPackets2, err := readTraffic(addr)
 				require.NoError(t, err)
 				require.True(t, inBytes2 > inBytes1 && inPackets2 > inPackets1 && outBytes2 > outBytes1 && outPackets2 > outPackets1)
 				// The second backend is remote, so exists cross-az traffic.
-				crossLocationBytes2, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter)
+				crossLocationBytes2, err := metrics.ReadCounter(metrics.CrossLocationBytesCounter.WithLabelValues(config.LocationLabelName))
 				require.NoError(t, err)
 				require.True(t, crossLocationBytes2 > crossLocationBytes)
 				return nil
@@ -1450,6 +1559,7 @@ func TestCapture(t *testing.T) {
 				require.NoError(t, err)
 				cpt := ts.mp.cpt.(*mockCapture)
 				require.Equal(t, "test", cpt.db)
+				require.Equal(t, mockUsername, cpt.user)
 				require.GreaterOrEqual(t, cpt.startTime, now)
 				require.EqualValues(t, 100, cpt.connID)
 				return nil
@@ -1530,3 +1640,75 @@ func BenchmarkLockedMap(b *testing.B) {
 		lock.Unlock()
 	}
 }
+
+// Test that an idle session is handed off to another manager and resumed with the session states.
+func TestHandoff(t *testing.T) {
+	ts := newBackendMgrTester(t)
+	var session *SessionHandoff
+	runners := []runner{
+		// 1st handshake
+		{
+			client:  ts.mc.authenticate,
+			proxy:   ts.firstHandshake4Proxy,
+			backend: ts.handshake4Backend,
+		},
+		// start a transaction to make it active
+		{
+			client:  ts.mc.request,
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.startTxn4Backend,
+		},
+		// the session can't be handed off in a transaction
+		{
+			proxy: func(_, _ pnet.PacketIO) error {
+				_, err := ts.mp.PrepareHandoff()
+				require.ErrorIs(t, err, ErrInTxn)
+				return nil
+			},
+		},
+		// finish the transaction
+		{
+			client:  ts.mc.request,
+			proxy:   ts.forwardCmd4Proxy,
+			backend: ts.respondWithNoTxn4Backend,
+		},
+		// query the session states
+		{
+			proxy: func(_, _ pnet.PacketIO) error {
+				var err error
+				session, err = ts.mp.PrepareHandoff()
+				require.NoError(t, err)
+				require.Equal(t, ts.mc.username, session.User)
+				require.Equal(t, statusClosing, ts.mp.closeStatus.Load())
+				require.Equal(t, SrcProxyQuit, ts.mp.QuitSource())
+				_, err = ts.mp.PrepareHandoff()
+				require.ErrorIs(t, err, ErrClosing)
+				return nil
+			},
+			backend: func(packetIO pnet.PacketIO) error {
+				ts.mb.respondType = responseTypeResultSet
+				return ts.mb.respond(packetIO)
+			},
+		},
+		// resume the session with a new manager
+		{
+			proxy: func(clientIO, _ pnet.PacketIO) error {
+				mgr := NewBackendConnManager(ts.lg, ts.mp.handler, nil, 100, ts.mp.bcConfig)
+				err := mgr.Resume(context.Background(), clientIO, ts.mp.backendTLSConfig, session)
+				require.NoError(t, err)
+				require.Equal(t, session.User, mgr.authenticator.user)
+				require.Equal(t, SrcNone, mgr.QuitSource())
+				require.NoError(t, mgr.Close())
+				return nil
+			},
+			backend: func(_ pnet.PacketIO) error {
+				if err := ts.handshake4Backend(nil); err != nil {
+					return err
+				}
+				// respond to `SET SESSION STATES`
+				return ts.respondWithNoTxn4Backend(ts.tc.backendIO)
+			},
+		},
+	}
+	ts.runTests(runners)
+}
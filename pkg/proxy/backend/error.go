@@ -24,6 +24,7 @@ var (
 	ErrClientAuthFail   = errors.New("Authentication fails")
 	ErrProxyErr         = errors.New("Other serverless error")
 	ErrProxyNoBackend   = errors.New("No available TiDB instances, please make sure TiDB is available")
+	ErrProxyBackendFull = errors.New("All TiDB instances reach the max connections, please try again later")
 	ErrProxyNoTLS       = errors.New("Require TLS enabled on TiProxy when require-backend-tls=true")
 	ErrBackendCap       = errors.New("Verify TiDB capability failed, please upgrade TiDB")
 	ErrBackendHandshake = errors.New("TiProxy fails to connect to TiDB, please make sure TiDB is available")
@@ -39,6 +40,8 @@ func ErrToClient(err error) error {
 		return nil
 	case errors.Is(err, ErrProxyNoBackend):
 		return ErrProxyNoBackend
+	case errors.Is(err, ErrProxyBackendFull):
+		return ErrProxyBackendFull
 	case errors.Is(err, ErrProxyNoTLS):
 		return ErrProxyNoTLS
 	case errors.Is(err, ErrBackendCap):
@@ -83,7 +86,7 @@ const (
 	SrcProxyQuit
 	// SrcProxyMalformed includes: malformed packet
 	SrcProxyMalformed
-	// SrcProxyNoBackend includes: no backends
+	// SrcProxyNoBackend includes: no backends; all backends reach the max connections
 	SrcProxyNoBackend
 	// SrcProxyErr includes: HandshakeHandler returns error; proxy disables TLS; unexpected errors
 	SrcProxyErr
@@ -118,7 +121,7 @@ func Error2Source(err error) ErrorSource {
 		return SrcClientAuthFail
 	case errors.Is(err, ErrBackendHandshake), errors.Is(err, ErrBackendCap), errors.Is(err, ErrBackendNoTLS), errors.Is(err, ErrBackendPPV2):
 		return SrcBackendHandshake
-	case errors.Is(err, ErrProxyNoBackend):
+	case errors.Is(err, ErrProxyNoBackend), errors.Is(err, ErrProxyBackendFull):
 		return SrcProxyNoBackend
 	case pnet.IsMySQLError(err):
 		// ErrClientAuthFail and ErrBackendHandshake may also contain MySQL error.
@@ -0,0 +1,106 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/siddontang/go/hack"
+	"go.uber.org/zap"
+)
+
+// SessionHandoff is the state of a session that is handed off to another TiProxy process during upgrading.
+// The new process connects to a backend with the session token and restores the session states, the same as
+// session migration.
+type SessionHandoff struct {
+	// Addr is the listening address that accepted the client connection.
+	Addr          string            `json:"addr"`
+	User          string            `json:"user"`
+	DB            string            `json:"db,omitempty"`
+	Attrs         map[string]string `json:"attrs,omitempty"`
+	Capability    pnet.Capability   `json:"capability"`
+	Collation     uint8             `json:"collation"`
+	ZstdLevel     int               `json:"zstd_level,omitempty"`
+	SessionStates string            `json:"session_states"`
+	SessionToken  string            `json:"session_token"`
+}
+
+// PrepareHandoff reads the session states from the backend so that the session can be restored by another process.
+// It returns ErrInTxn if the session is not ready, and the caller should try again after the next command.
+// Once it succeeds, the manager won't execute any command.
+func (mgr *BackendConnManager) PrepareHandoff() (*SessionHandoff, error) {
+	mgr.processLock.Lock()
+	defer mgr.processLock.Unlock()
+	if mgr.closeStatus.Load() >= statusClosing {
+		return nil, ErrClosing
+	}
+	if !mgr.cmdProcessor.finishedTxn() {
+		return nil, ErrInTxn
+	}
+	sessionStates, sessionToken, err := mgr.querySessionStates(*mgr.backendIO.Load())
+	if err != nil {
+		return nil, err
+	}
+	addr, _ := mgr.Value(ConnContextKeyConnAddr).(string)
+	mgr.quitSource = SrcProxyQuit
+	mgr.closeStatus.Store(statusClosing)
+	return &SessionHandoff{
+		Addr:          addr,
+		User:          mgr.authenticator.user,
+		DB:            mgr.authenticator.dbname,
+		Attrs:         mgr.authenticator.attrs,
+		Capability:    mgr.authenticator.capability,
+		Collation:     mgr.authenticator.collation,
+		ZstdLevel:     mgr.authenticator.zstdLevel,
+		SessionStates: sessionStates,
+		SessionToken:  sessionToken,
+	}, nil
+}
+
+// Resume connects to a backend with the session handed off from another process and then starts watching
+// redirection signals. The client has finished the handshake with the old process, so nothing is sent to the client.
+func (mgr *BackendConnManager) Resume(ctx context.Context, clientIO pnet.PacketIO, backendTLSConfig *tls.Config, session *SessionHandoff) error {
+	mgr.processLock.Lock()
+	defer mgr.processLock.Unlock()
+
+	mgr.backendTLS = backendTLSConfig
+	mgr.clientIO = clientIO
+	if mgr.closeStatus.Load() >= statusNotifyClose {
+		mgr.quitSource = SrcProxyQuit
+		return errors.New("graceful shutdown before resuming")
+	}
+	startTime := time.Now()
+	auth := mgr.authenticator
+	auth.user, auth.dbname, auth.attrs = session.User, session.DB, session.Attrs
+	auth.capability, auth.collation, auth.zstdLevel = session.Capability, session.Collation, session.ZstdLevel
+	err := func() error {
+		if err := setCompress(clientIO, auth.capability, auth.zstdLevel); err != nil {
+			return errors.Wrap(err, ErrClientConn)
+		}
+		backendIO, err := mgr.getBackendIO(ctx, mgr, &pnet.HandshakeResp{User: auth.user, DB: auth.dbname, Attrs: auth.attrs})
+		if err != nil {
+			return err
+		}
+		if err = auth.handshakeSecondTime(mgr.logger, clientIO, backendIO, backendTLSConfig, session.SessionToken); err != nil {
+			return err
+		}
+		if err = mgr.initSessionStates(backendIO, session.SessionStates); err != nil {
+			return err
+		}
+		return mgr.updateAuthInfoFromSessionStates(hack.Slice(session.SessionStates))
+	}()
+	if err != nil {
+		src := Error2Source(err)
+		mgr.handshakeHandler.OnHandshake(mgr, mgr.ServerAddr(), err, src)
+		mgr.logger.Warn("resume session failed", zap.Error(err))
+		mgr.quitSource = src
+		return err
+	}
+	mgr.onConnected(ctx, startTime)
+	return nil
+}
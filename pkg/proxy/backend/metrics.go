@@ -56,9 +56,11 @@ func addCmdMetrics(cmd pnet.Command, addr string, startTime time.Time) {
 	mc.observer.Observe(cost.Seconds())
 }
 
-func addTraffic(addr string, inBytes, inPackets, outBytes, outPackets uint64, local bool) {
-	if !local {
-		metrics.CrossLocationBytesCounter.Add(float64(inBytes + outBytes))
+// addTraffic records the traffic of the backend. crossLevel is the location level on which the backend differs from
+// TiProxy, and it's empty if the backend is local.
+func addTraffic(addr string, inBytes, inPackets, outBytes, outPackets uint64, crossLevel string) {
+	if len(crossLevel) > 0 {
+		metrics.CrossLocationBytesCounter.WithLabelValues(crossLevel).Add(float64(inBytes + outBytes))
 	}
 	cache.Lock()
 	defer cache.Unlock()
@@ -122,3 +124,7 @@ func addGetBackendMetrics(duration time.Duration, succeed bool) {
 	}
 	metrics.GetBackendCounter.WithLabelValues(lbl).Inc()
 }
+
+func addConnLimitMetrics(strategy string) {
+	metrics.ConnLimitCounter.WithLabelValues(strategy).Inc()
+}
@@ -11,7 +11,10 @@ import (
 	"time"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/pkg/balance/observer"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"go.uber.org/zap"
@@ -117,6 +120,7 @@ var _ capture.Capture = (*mockCapture)(nil)
 
 type mockCapture struct {
 	db        string
+	user      string
 	initSql   string
 	packet    []byte
 	startTime time.Time
@@ -130,18 +134,19 @@ func (mc *mockCapture) Start(cfg capture.CaptureConfig) error {
 func (mc *mockCapture) Stop(err error) {
 }
 
-func (mc *mockCapture) InitConn(startTime time.Time, connID uint64, dbname string) {
+func (mc *mockCapture) InitConn(startTime time.Time, connID uint64, user, dbname string) {
+	mc.user = user
 	mc.db = dbname
 	mc.startTime = startTime
 	mc.connID = connID
 }
 
-func (mc *mockCapture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error)) {
+func (mc *mockCapture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, string, error)) {
 	mc.packet = packet
 	mc.startTime = startTime
 	mc.connID = connID
 	if initSession != nil {
-		mc.initSql, _ = initSession()
+		_, mc.initSql, _ = initSession()
 	}
 }
 
@@ -151,3 +156,58 @@ func (mc *mockCapture) Progress() (float64, time.Time, bool, error) {
 
 func (mc *mockCapture) Close() {
 }
+
+var _ observer.BackendObserver = (*mockBackendObserver)(nil)
+
+// mockBackendObserver reports the health results that are sent to healthCh.
+type mockBackendObserver struct {
+	healthCh chan observer.HealthResult
+}
+
+func newMockBackendObserver() *mockBackendObserver {
+	return &mockBackendObserver{
+		healthCh: make(chan observer.HealthResult, 1),
+	}
+}
+
+func (mbo *mockBackendObserver) Start(ctx context.Context) {
+}
+
+func (mbo *mockBackendObserver) Subscribe(name string) <-chan observer.HealthResult {
+	return mbo.healthCh
+}
+
+func (mbo *mockBackendObserver) Unsubscribe(name string) {
+}
+
+func (mbo *mockBackendObserver) Refresh() {
+}
+
+func (mbo *mockBackendObserver) Close() {
+}
+
+var _ policy.BalancePolicy = (*mockBalancePolicy)(nil)
+
+// mockBalancePolicy routes to the first backend and never balances.
+type mockBalancePolicy struct{}
+
+func (mbp *mockBalancePolicy) Init(cfg *config.Config) {
+}
+
+func (mbp *mockBalancePolicy) BackendToRoute(backends []policy.BackendCtx, hint policy.RouteHint) policy.BackendCtx {
+	if len(backends) == 0 {
+		return nil
+	}
+	return backends[0]
+}
+
+func (mbp *mockBalancePolicy) BackendsToBalance(backends []policy.BackendCtx) (from, to policy.BackendCtx, balanceCount float64, reason string, logFields []zap.Field) {
+	return nil, nil, 0, "", nil
+}
+
+func (mbp *mockBalancePolicy) BalanceScores(backends []policy.BackendCtx) policy.BalanceScores {
+	return policy.BalanceScores{}
+}
+
+func (mbp *mockBalancePolicy) SetConfig(cfg *config.Config) {
+}
@@ -7,21 +7,34 @@ import (
 	"context"
 	"crypto/tls"
 	"net"
+	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/pkg/metrics"
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"go.uber.org/zap"
 )
 
+// HandoffFunc passes the client connection and its session to another TiProxy process.
+// The file is a duplicate of the client connection and the caller closes it after the function returns.
+type HandoffFunc func(session *backend.SessionHandoff, file *os.File) error
+
 type ClientConnection struct {
 	logger            *zap.Logger
 	frontendTLSConfig *tls.Config   // the TLS config to connect to clients.
 	backendTLSConfig  *tls.Config   // the TLS config to connect to TiDB server.
+	conn              net.Conn      // the raw connection, which is passed to another process when handing off.
 	pkt               pnet.PacketIO // a helper to read and write data in packet format.
 	connMgr           *backend.BackendConnManager
+	// handoff is set once the connection should be handed off to another process.
+	handoff atomic.Pointer[HandoffFunc]
+	// connected is set after the handshake. The connection can't be handed off during the handshake.
+	connected atomic.Bool
 }
 
 func NewClientConnection(logger *zap.Logger, conn net.Conn, frontendTLSConfig *tls.Config, backendTLSConfig *tls.Config,
@@ -31,27 +44,43 @@ func NewClientConnection(logger *zap.Logger, conn net.Conn, frontendTLSConfig *t
 	opts := make([]pnet.PacketIOption, 0, 2)
 	opts = append(opts, pnet.WithWrapError(backend.ErrClientConn))
 	if bcConfig.ProxyProtocol {
-		opts = append(opts, pnet.WithProxy)
+		trusted := bcConfig.ProxyProtocolAllowlist.Trusts(conn.RemoteAddr())
+		opts = append(opts, pnet.WithProxyServer(bcConfig.ProxyProtocolVersion, trusted))
 	}
 	pkt := pnet.NewPacketIO(conn, logger, bcConfig.ConnBufferSize, opts...)
 	return &ClientConnection{
 		logger:            logger,
 		frontendTLSConfig: frontendTLSConfig,
 		backendTLSConfig:  backendTLSConfig,
+		conn:              conn,
 		pkt:               pkt,
 		connMgr:           bemgr,
 	}
 }
 
 func (cc *ClientConnection) Run(ctx context.Context) {
+	cc.run(ctx, func() error {
+		return cc.connMgr.Connect(ctx, cc.pkt, cc.frontendTLSConfig, cc.backendTLSConfig, "", "")
+	})
+}
+
+// Resume serves the connection handed off from another process, which has finished the handshake.
+func (cc *ClientConnection) Resume(ctx context.Context, session *backend.SessionHandoff) {
+	cc.run(ctx, func() error {
+		return cc.connMgr.Resume(ctx, cc.pkt, cc.backendTLSConfig, session)
+	})
+}
+
+func (cc *ClientConnection) run(ctx context.Context, connect func() error) {
 	var err error
 	var msg string
 
-	if err = cc.connMgr.Connect(ctx, cc.pkt, cc.frontendTLSConfig, cc.backendTLSConfig, "", ""); err != nil {
+	if err = connect(); err != nil {
 		msg = "new connection failed"
 		goto clean
 	}
 	cc.logger.Debug("connected to backend", cc.connMgr.ConnInfo()...)
+	cc.connected.Store(true)
 	if err = cc.processMsg(ctx); err != nil {
 		msg = "fails to relay the connection"
 		goto clean
@@ -64,15 +93,23 @@ clean:
 		fields = append(fields, zap.Stringer("quit_source", src), zap.Error(err))
 		cc.logger.Warn(msg, fields...)
 	}
+	if errors.Is(err, proxyprotocol.ErrUntrustedPeer) {
+		metrics.UntrustedProxyHeaderCounter.Inc()
+	}
 	metrics.DisConnCounter.WithLabelValues(src.String()).Inc()
 }
 
 func (cc *ClientConnection) processMsg(ctx context.Context) error {
 	for {
 		cc.pkt.ResetSequence()
+		inBytes := cc.pkt.InBytes()
 		clientPkt, err := cc.pkt.ReadPacket()
 		if err != nil {
-			return err
+			retry, err := cc.tryHandoff(err, inBytes)
+			if !retry {
+				return err
+			}
+			continue
 		}
 		err = cc.connMgr.ExecuteCmd(ctx, clientPkt)
 		if err != nil && !pnet.IsMySQLError(err) {
@@ -81,7 +118,61 @@ func (cc *ClientConnection) processMsg(ctx context.Context) error {
 		if pnet.Command(clientPkt[0]) == pnet.ComQuit {
 			return nil
 		}
+		// The last handoff failed because the session was in a transaction, try again.
+		if cc.handoff.Load() != nil {
+			_ = cc.conn.SetReadDeadline(time.Now())
+		}
+	}
+}
+
+// Handoff makes the connection be handed off to another process once the session is idle.
+// It returns false if the connection can't be handed off, e.g. the TLS state can't be passed to another process.
+func (cc *ClientConnection) Handoff(fn HandoffFunc) bool {
+	if !cc.connected.Load() || cc.pkt.TLSConnectionState().HandshakeComplete || cc.pkt.Proxy() != nil {
+		return false
+	}
+	if _, ok := cc.conn.(interface{ File() (*os.File, error) }); !ok {
+		return false
+	}
+	cc.handoff.Store(&fn)
+	// Interrupt reading the next command. The read deadline doesn't affect writing the current response.
+	return cc.conn.SetReadDeadline(time.Now()) == nil
+}
+
+// tryHandoff hands off the connection if reading is interrupted by Handoff.
+// It returns true if the connection should keep reading, otherwise it returns the error to quit with.
+func (cc *ClientConnection) tryHandoff(readErr error, inBytes uint64) (bool, error) {
+	fn := cc.handoff.Load()
+	if fn == nil || !errors.Is(readErr, os.ErrDeadlineExceeded) {
+		return false, readErr
+	}
+	// Part of a packet has been read and it can't be read again by the new process.
+	if cc.pkt.InBytes() != inBytes {
+		return false, readErr
+	}
+	session, err := cc.connMgr.PrepareHandoff()
+	if errors.Is(err, backend.ErrInTxn) {
+		// Try again after the next command.
+		if err = cc.conn.SetReadDeadline(time.Time{}); err != nil {
+			return false, errors.WithStack(err)
+		}
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	// The duplicated fd keeps the connection open after this process closes it.
+	file, err := cc.conn.(interface{ File() (*os.File, error) }).File()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if err = (*fn)(session, file); err != nil {
+		return false, err
 	}
+	cc.logger.Debug("connection is handed off")
+	return false, nil
 }
 
 func (cc *ClientConnection) GracefulClose() {
@@ -0,0 +1,59 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package handoff
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+func oobSpace(fileNum int) int {
+	return syscall.CmsgSpace(fileNum * 4)
+}
+
+func filesToOOB(files []*os.File) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	fds := make([]int, 0, len(files))
+	for _, f := range files {
+		// Fd() puts the file in blocking mode, which is shared with the serving socket, so read the fd by Control.
+		rc, err := f.SyscallConn()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err = rc.Control(func(fd uintptr) {
+			fds = append(fds, int(fd))
+		}); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return syscall.UnixRights(fds...), nil
+}
+
+func oobToFiles(oob []byte) ([]*os.File, error) {
+	if len(oob) == 0 {
+		return nil, nil
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var files []*os.File
+	for i := range msgs {
+		fds, err := syscall.ParseUnixRights(&msgs[i])
+		if err != nil {
+			closeFiles(files)
+			return nil, errors.WithStack(err)
+		}
+		for _, fd := range fds {
+			files = append(files, os.NewFile(uintptr(fd), "handoff"))
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package handoff
+
+import (
+	"os"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+var errUnsupported = errors.New("handoff is not supported on windows")
+
+func oobSpace(int) int {
+	return 0
+}
+
+func filesToOOB(files []*os.File) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return nil, errUnsupported
+}
+
+func oobToFiles(oob []byte) ([]*os.File, error) {
+	if len(oob) == 0 {
+		return nil, nil
+	}
+	return nil, errUnsupported
+}
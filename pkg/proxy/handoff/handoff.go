@@ -0,0 +1,263 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package handoff passes the listening sockets and the client connections from an old TiProxy process to a new one
+// through a Unix socket, so that TiProxy can be upgraded without disconnecting the clients.
+//
+// The new process dials the Unix socket of the old process at startup and receives the listening sockets, so that
+// both processes accept new connections during the upgrade. When the old process shuts down, it sends the idle
+// client connections together with their session states to the new process.
+package handoff
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+var (
+	ErrUnexpectedMsg = errors.New("unexpected handoff message")
+	ErrTooManyFiles  = errors.New("too many files in a handoff message")
+)
+
+const (
+	msgListeners = "listeners"
+	msgSession   = "session"
+	msgAck       = "ack"
+
+	// maxFiles is the max number of files in a message. The fds are all received by one read, so the limit is small.
+	maxFiles = 64
+	// maxMsgLen protects the receiver from a broken peer.
+	maxMsgLen   = 64 * 1024 * 1024
+	dialTimeout = 3 * time.Second
+)
+
+// message is the unit exchanged between the processes. Each message is acknowledged by the receiver before the
+// sender sends the next one, so that the files attached to different messages are never read together.
+type message struct {
+	Type string `json:"type"`
+	// Addrs are the addresses of the listeners, in the same order as the attached files.
+	Addrs []string `json:"addrs,omitempty"`
+	// Data is the session state of the attached client connection.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Error is returned by the receiver in the ack message.
+	Error string `json:"error,omitempty"`
+}
+
+// Conn is the connection between the old process and the new process.
+type Conn struct {
+	// mu serializes the request-ack exchanges.
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// Dial connects to the Unix socket of the old process.
+func Dial(path string) (*Conn, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &Conn{conn: conn.(*net.UnixConn)}, nil
+}
+
+// ListenUnix listens on the Unix socket for the next process. The stale socket file left by a previous process is
+// removed, so the caller must make sure that no other process is serving on the path.
+// The socket file is not removed when the listener is closed, because it may belong to the next process by then.
+func ListenUnix(path string) (*net.UnixListener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	listener.SetUnlinkOnClose(false)
+	return listener, nil
+}
+
+// NewConn wraps a connection accepted from the Unix listener.
+func NewConn(conn *net.UnixConn) *Conn {
+	return &Conn{conn: conn}
+}
+
+// SendListeners sends all the active listeners created by Listen to the new process.
+func (c *Conn) SendListeners() error {
+	addrs, files, err := activeListenerFiles()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+	return c.request(&message{Type: msgListeners, Addrs: addrs}, files)
+}
+
+// RecvListeners receives the listeners from the old process. The listeners are used by the following Listen calls.
+func (c *Conn) RecvListeners() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg, files, err := c.read()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+	if msg.Type != msgListeners || len(msg.Addrs) != len(files) {
+		err = errors.Wrapf(ErrUnexpectedMsg, "type %s, %d addresses, %d files", msg.Type, len(msg.Addrs), len(files))
+	} else {
+		err = inherit(msg.Addrs, files)
+	}
+	return c.ack(err)
+}
+
+// SendSession sends a client connection and its session state to the new process.
+// It returns after the new process receives the connection, so the caller can close its connection then.
+func (c *Conn) SendSession(data []byte, file *os.File) error {
+	return c.request(&message{Type: msgSession, Data: data}, []*os.File{file})
+}
+
+// RecvSession receives a client connection and its session state from the old process.
+// It returns io.EOF after the old process finishes the handoff.
+func (c *Conn) RecvSession() ([]byte, net.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg, files, err := c.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeFiles(files)
+	var conn net.Conn
+	if msg.Type != msgSession || len(files) != 1 {
+		err = errors.Wrapf(ErrUnexpectedMsg, "type %s, %d files", msg.Type, len(files))
+	} else {
+		// FileConn dups the fd, so the file can be closed.
+		conn, err = net.FileConn(files[0])
+		err = errors.WithStack(err)
+	}
+	if ackErr := c.ack(err); ackErr != nil {
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return nil, nil, ackErr
+	}
+	return msg.Data, conn, err
+}
+
+func (c *Conn) request(msg *message, files []*os.File) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.write(msg, files); err != nil {
+		return err
+	}
+	resp, respFiles, err := c.read()
+	closeFiles(respFiles)
+	if err != nil {
+		return err
+	}
+	if resp.Type != msgAck {
+		return errors.Wrapf(ErrUnexpectedMsg, "type %s", resp.Type)
+	}
+	if resp.Error != "" {
+		return errors.Errorf("peer failed to receive %s: %s", msg.Type, resp.Error)
+	}
+	return nil
+}
+
+// ack replies to the sender and returns the error of receiving the message, or the error of replying if it fails.
+func (c *Conn) ack(recvErr error) error {
+	msg := &message{Type: msgAck}
+	if recvErr != nil {
+		msg.Error = recvErr.Error()
+	}
+	if err := c.write(msg, nil); err != nil {
+		return err
+	}
+	return recvErr
+}
+
+// write sends the message in a 4-byte length header and the JSON body. The files are attached to the first write.
+func (c *Conn) write(msg *message, files []*os.File) error {
+	if len(files) > maxFiles {
+		return errors.Wrapf(ErrTooManyFiles, "%d files", len(files))
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	buf := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(len(body)))
+	copy(buf[4:], body)
+	oob, err := filesToOOB(files)
+	if err != nil {
+		return err
+	}
+	n, oobn, err := c.conn.WriteMsgUnix(buf, oob, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if oobn != len(oob) {
+		return errors.Errorf("short write of the files: %d of %d", oobn, len(oob))
+	}
+	if n < len(buf) {
+		if _, err = c.conn.Write(buf[n:]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (c *Conn) read() (*message, []*os.File, error) {
+	buf := make([]byte, 4096)
+	oob := make([]byte, oobSpace(maxFiles))
+	n, oobn, _, _, err := c.conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if n == 0 {
+		return nil, nil, io.EOF
+	}
+	files, err := oobToFiles(oob[:oobn])
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := func() (*message, error) {
+		if n < 4 {
+			if _, err := io.ReadFull(c.conn, buf[n:4]); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			n = 4
+		}
+		length := int(binary.BigEndian.Uint32(buf))
+		if length > maxMsgLen {
+			return nil, errors.Wrapf(ErrUnexpectedMsg, "message length %d", length)
+		}
+		body := make([]byte, length)
+		copied := copy(body, buf[4:n])
+		if _, err := io.ReadFull(c.conn, body[copied:]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &msg, nil
+	}()
+	if err != nil {
+		closeFiles(files)
+		return nil, nil, err
+	}
+	return msg, files, nil
+}
+
+func (c *Conn) Close() error {
+	return errors.WithStack(c.conn.Close())
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package handoff
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newConnPair(t *testing.T) (*Conn, *Conn) {
+	listener, err := ListenUnix(filepath.Join(t.TempDir(), "handoff.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := listener.AcceptUnix()
+		require.NoError(t, err)
+		ch <- NewConn(conn)
+	}()
+	client, err := Dial(listener.Addr().String())
+	require.NoError(t, err)
+	server := <-ch
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return server, client
+}
+
+func TestHandoffListeners(t *testing.T) {
+	oldConn, newConn := newConnPair(t)
+	l, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	// Listeners are indexed by the configured address, so listen again on the bound address.
+	require.NoError(t, l.Close())
+	l, err = Listen(addr)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- oldConn.SendListeners()
+	}()
+	require.NoError(t, newConn.RecvListeners())
+	require.NoError(t, <-errCh)
+
+	// The old listener is closed but the inherited one still accepts.
+	require.NoError(t, l.Close())
+	inherited, err := Listen(addr)
+	require.NoError(t, err)
+	require.Equal(t, addr, inherited.Addr().String())
+	go func() {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+	conn, err := inherited.Accept()
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.NoError(t, inherited.Close())
+
+	// The closed listeners are not passed to the next process.
+	registry.Lock()
+	require.Empty(t, registry.active)
+	registry.Unlock()
+	CloseInherited()
+	registry.Lock()
+	require.Empty(t, registry.inherited)
+	registry.Unlock()
+}
+
+func TestHandoffSession(t *testing.T) {
+	oldConn, newConn := newConnPair(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer clientConn.Close()
+	serverConn, err := l.Accept()
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		file, err := serverConn.(*net.TCPConn).File()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer file.Close()
+		if err = oldConn.SendSession([]byte(`{"user":"root"}`), file); err == nil {
+			err = serverConn.Close()
+		}
+		errCh <- err
+		// Finish the handoff.
+		_ = oldConn.Close()
+	}()
+	data, conn, err := newConn.RecvSession()
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+	require.JSONEq(t, `{"user":"root"}`, string(data))
+
+	// The received connection still talks to the client after the old one is closed.
+	_, err = clientConn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+	require.NoError(t, conn.Close())
+
+	_, _, err = newConn.RecvSession()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestUnexpectedMsg(t *testing.T) {
+	oldConn, newConn := newConnPair(t)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- oldConn.request(&message{Type: msgSession}, nil)
+	}()
+	_, _, err := newConn.RecvSession()
+	require.ErrorIs(t, err, ErrUnexpectedMsg)
+	// The sender is told the reason.
+	require.ErrorContains(t, <-errCh, "unexpected handoff message")
+}
+
+func TestHandoffUnixListener(t *testing.T) {
+	oldConn, newConn := newConnPair(t)
+	path := filepath.Join(t.TempDir(), "tiproxy.sock")
+	addr := "unix://" + path
+	l, err := Listen(addr)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- oldConn.SendListeners()
+	}()
+	require.NoError(t, newConn.RecvListeners())
+	require.NoError(t, <-errCh)
+
+	// The socket file is kept for the new process after the old listener is closed.
+	require.NoError(t, l.Close())
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+	inherited, err := Listen(addr)
+	require.NoError(t, err)
+	go func() {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+	conn, err := inherited.Accept()
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// The socket file is removed when the last process closes the listener.
+	require.NoError(t, inherited.Close())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
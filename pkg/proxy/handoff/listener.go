@@ -0,0 +1,167 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package handoff
+
+import (
+	"net"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// The listening sockets are process-wide resources, like the fds passed by systemd socket activation, so the
+// registry is global. All the TCP listeners of TiProxy are created by Listen so that they can be passed to the
+// next process, no matter which component owns them.
+var registry = struct {
+	sync.Mutex
+	// inherited are the listeners received from the old process and not taken yet, indexed by the addresses.
+	inherited map[string]net.Listener
+	// active are the listeners that are serving, which will be passed to the next process.
+	active map[*listener]struct{}
+}{
+	inherited: make(map[string]net.Listener),
+	active:    make(map[*listener]struct{}),
+}
+
+// fileListener is implemented by both *net.TCPListener and *net.UnixListener.
+type fileListener interface {
+	net.Listener
+	File() (*os.File, error)
+}
+
+// listener removes itself from the registry once it's closed.
+type listener struct {
+	fileListener
+	addr string
+}
+
+func (l *listener) Close() error {
+	registry.Lock()
+	delete(registry.active, l)
+	registry.Unlock()
+	return l.fileListener.Close()
+}
+
+// Listen takes the listener inherited from the old process for the address if there is one, otherwise it listens
+// on the address. The address is either a TCP address or a Unix socket in the form of unix:///path/to/socket.
+func Listen(addr string) (net.Listener, error) {
+	registry.Lock()
+	defer registry.Unlock()
+	path, isUnix := config.ParseUnixSocketAddr(addr)
+	nl, ok := registry.inherited[addr]
+	if ok {
+		delete(registry.inherited, addr)
+	} else {
+		var err error
+		if isUnix {
+			nl, err = listenUnixSocket(path)
+		} else {
+			nl, err = net.Listen("tcp", addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	fl, ok := nl.(fileListener)
+	if !ok {
+		_ = nl.Close()
+		return nil, errors.Errorf("listener of %s can't be passed to other processes", addr)
+	}
+	// The socket file of an inherited listener is not removed on close by default. Remove it unless the listener
+	// is passed to the next process again.
+	if ul, ok := nl.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(true)
+	}
+	l := &listener{fileListener: fl, addr: addr}
+	registry.active[l] = struct{}{}
+	return l, nil
+}
+
+// listenUnixSocket removes the socket file left by a crashed process before listening, because the file makes
+// the listening fail. It fails if another process is serving on the socket.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.DialTimeout("unix", path, dialTimeout); err == nil {
+			_ = conn.Close()
+			return nil, errors.Errorf("unix socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.WithStack(err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	return listener, errors.WithStack(err)
+}
+
+// CloseInherited closes the inherited listeners that are not taken, e.g. the address is removed from the config.
+func CloseInherited() {
+	registry.Lock()
+	defer registry.Unlock()
+	for addr, l := range registry.inherited {
+		_ = l.Close()
+		delete(registry.inherited, addr)
+	}
+}
+
+func inherit(addrs []string, files []*os.File) error {
+	listeners := make(map[string]net.Listener, len(addrs))
+	for i, addr := range addrs {
+		// FileListener dups the fd, so the file can be closed.
+		l, err := net.FileListener(files[i])
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return errors.WithStack(err)
+		}
+		listeners[addr] = l
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	for addr, l := range listeners {
+		if old, ok := registry.inherited[addr]; ok {
+			_ = old.Close()
+		}
+		registry.inherited[addr] = l
+	}
+	return nil
+}
+
+func activeListenerFiles() ([]string, []*os.File, error) {
+	registry.Lock()
+	defer registry.Unlock()
+	addrs := make([]string, 0, len(registry.active))
+	files := make([]*os.File, 0, len(registry.active))
+	for l := range registry.active {
+		// The socket file is used by the next process after this listener is closed.
+		if ul, ok := l.fileListener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		f, err := l.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, errors.WithStack(err)
+		}
+		addrs = append(addrs, l.addr)
+		files = append(files, f)
+	}
+	// Keep the order stable for logging and testing.
+	sort.Sort(byAddr{addrs, files})
+	return addrs, files, nil
+}
+
+type byAddr struct {
+	addrs []string
+	files []*os.File
+}
+
+func (b byAddr) Len() int           { return len(b.addrs) }
+func (b byAddr) Less(i, j int) bool { return b.addrs[i] < b.addrs[j] }
+func (b byAddr) Swap(i, j int) {
+	b.addrs[i], b.addrs[j] = b.addrs[j], b.addrs[i]
+	b.files[i], b.files[j] = b.files[j], b.files[i]
+}
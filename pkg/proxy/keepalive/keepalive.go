@@ -15,6 +15,11 @@ var (
 )
 
 func SetKeepalive(conn net.Conn, cfg config.KeepAlive) error {
+	// Keepalive and timeout only apply to TCP. The peer of a Unix socket or a Windows named pipe is on the same
+	// host and a crash of the peer closes the connection immediately.
+	if _, ok := conn.(*net.UnixConn); ok || conn.LocalAddr().Network() == "pipe" {
+		return nil
+	}
 	tcpcn, ok := conn.(*net.TCPConn)
 	if !ok {
 		return errors.Wrapf(ErrKeepAlive, "not net.TCPConn")
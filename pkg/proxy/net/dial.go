@@ -0,0 +1,24 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package net
+
+import (
+	"net"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/config"
+)
+
+// DialTimeout connects to a backend address, which is host:port, a Unix socket in the form of
+// unix:///path/to/socket, or a Windows named pipe in the form of npipe:////./pipe/name.
+// Windows also supports Unix sockets since Windows 10, while named pipes are only supported on Windows.
+func DialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	if path, ok := config.ParseUnixSocketAddr(addr); ok {
+		return net.DialTimeout("unix", path, timeout)
+	}
+	if path, ok := config.ParseNamedPipeAddr(addr); ok {
+		return dialPipe(path, timeout)
+	}
+	return net.DialTimeout("tcp", addr, timeout)
+}
@@ -15,6 +15,14 @@ func WithProxy(pi *packetIO) {
 	pi.EnableProxyServer()
 }
 
+// WithProxyServer only accepts the proxy header of the version. 0 accepts both v1 and v2.
+// The proxy header is rejected if the peer is not trusted.
+func WithProxyServer(version proxyprotocol.ProxyVersion, trusted bool) func(pi *packetIO) {
+	return func(pi *packetIO) {
+		pi.readWriter = newProxyServer(pi.readWriter, version, trusted)
+	}
+}
+
 func WithWrapError(err error) func(pi *packetIO) {
 	return func(pi *packetIO) {
 		pi.wrap = err
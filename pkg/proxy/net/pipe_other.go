@@ -0,0 +1,19 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package net
+
+import (
+	"net"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+var errPipeUnsupported = errors.New("named pipes are only supported on windows")
+
+func dialPipe(path string, _ time.Duration) (net.Conn, error) {
+	return nil, errors.Wrapf(errPipeUnsupported, "dial %s", path)
+}
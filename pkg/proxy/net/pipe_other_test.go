@@ -0,0 +1,18 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package net
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialPipeUnsupported(t *testing.T) {
+	_, err := DialTimeout("npipe:////./pipe/tidb", time.Second)
+	require.ErrorIs(t, err, errPipeUnsupported)
+}
@@ -0,0 +1,289 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package net
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	pipeNetwork = "pipe"
+	// pipeBusyRetryInterval is the interval to retry dialing when all the instances of the pipe are busy.
+	pipeBusyRetryInterval = 10 * time.Millisecond
+)
+
+var _ net.Conn = (*pipeConn)(nil)
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string {
+	return pipeNetwork
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
+
+// dialPipe connects to a named pipe in the form of \\.\pipe\name.
+func dialPipe(path string, timeout time.Duration) (net.Conn, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: pipeNetwork, Addr: pipeAddr(path), Err: err}
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		// SECURITY_IDENTIFICATION prevents the server from impersonating the client.
+		handle, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING,
+			windows.FILE_FLAG_OVERLAPPED|windows.SECURITY_SQOS_PRESENT|windows.SECURITY_IDENTIFICATION, 0)
+		if err == nil {
+			return newPipeConn(handle, path)
+		}
+		// All the instances of the pipe are busy, retry until one is available or it times out.
+		if err != windows.ERROR_PIPE_BUSY || (timeout > 0 && time.Now().After(deadline)) {
+			return nil, &net.OpError{Op: "dial", Net: pipeNetwork, Addr: pipeAddr(path), Err: os.NewSyscallError("CreateFile", err)}
+		}
+		time.Sleep(pipeBusyRetryInterval)
+	}
+}
+
+// pipeDeadline is the read or write deadline of a pipeConn.
+// The event is signaled when the deadline changes so that the pending operation re-evaluates its timeout.
+type pipeDeadline struct {
+	sync.Mutex
+	t       time.Time
+	changed windows.Handle
+}
+
+func (d *pipeDeadline) set(t time.Time) error {
+	d.Lock()
+	d.t = t
+	d.Unlock()
+	return windows.SetEvent(d.changed)
+}
+
+// timeout returns the milliseconds to wait and whether the deadline is already exceeded.
+func (d *pipeDeadline) timeout() (uint32, bool) {
+	d.Lock()
+	t := d.t
+	d.Unlock()
+	if t.IsZero() {
+		return windows.INFINITE, false
+	}
+	dur := time.Until(t)
+	if dur <= 0 {
+		return 0, true
+	}
+	ms := (dur + time.Millisecond - 1) / time.Millisecond
+	if ms >= windows.INFINITE {
+		ms = windows.INFINITE - 1
+	}
+	return uint32(ms), false
+}
+
+// pipeConn is a client connection to a named pipe.
+// os.File doesn't support deadlines on pipes, which the health check and graceful close rely on, so pipeConn
+// issues overlapped I/O and cancels the pending operation once the deadline exceeds or the connection is closed.
+// There is at most one pending read and one pending write at a time, the same as the other backend connections.
+type pipeConn struct {
+	handle windows.Handle
+	addr   pipeAddr
+	// closeEvent is manual-reset and is signaled on Close to wake up all the pending operations.
+	closeEvent windows.Handle
+	rd, wd     pipeDeadline
+	mu         sync.Mutex
+	closed     bool
+	ops        sync.WaitGroup
+}
+
+func newPipeConn(handle windows.Handle, path string) (*pipeConn, error) {
+	pc := &pipeConn{handle: handle, addr: pipeAddr(path)}
+	var err error
+	if pc.closeEvent, err = windows.CreateEvent(nil, 1, 0, nil); err == nil {
+		if pc.rd.changed, err = windows.CreateEvent(nil, 0, 0, nil); err == nil {
+			pc.wd.changed, err = windows.CreateEvent(nil, 0, 0, nil)
+		}
+	}
+	if err != nil {
+		pc.closeEvents()
+		_ = windows.CloseHandle(handle)
+		return nil, &net.OpError{Op: "dial", Net: pipeNetwork, Addr: pc.addr, Err: os.NewSyscallError("CreateEvent", err)}
+	}
+	return pc, nil
+}
+
+func (pc *pipeConn) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return pc.do("read", &pc.rd, b)
+}
+
+func (pc *pipeConn) Write(b []byte) (int, error) {
+	return pc.do("write", &pc.wd, b)
+}
+
+// do issues an overlapped read or write and waits until it completes, the deadline exceeds, or the connection closes.
+func (pc *pipeConn) do(op string, dl *pipeDeadline, b []byte) (int, error) {
+	if err := pc.begin(); err != nil {
+		return 0, pc.opError(op, err)
+	}
+	defer pc.ops.Done()
+
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, pc.opError(op, os.NewSyscallError("CreateEvent", err))
+	}
+	defer func() {
+		_ = windows.CloseHandle(event)
+	}()
+	ov := windows.Overlapped{HEvent: event}
+	var n uint32
+	if op == "read" {
+		err = windows.ReadFile(pc.handle, b, &n, &ov)
+	} else {
+		err = windows.WriteFile(pc.handle, b, &n, &ov)
+	}
+	if err != nil && err != windows.ERROR_IO_PENDING {
+		return pc.result(op, n, err)
+	}
+
+	events := []windows.Handle{event, pc.closeEvent, dl.changed}
+	for {
+		var cause error
+		ms, exceeded := dl.timeout()
+		if exceeded {
+			cause = os.ErrDeadlineExceeded
+		} else {
+			idx, err := windows.WaitForMultipleObjects(events, false, ms)
+			switch {
+			case err != nil:
+				cause = os.NewSyscallError("WaitForMultipleObjects", err)
+			case idx == windows.WAIT_OBJECT_0:
+				err = windows.GetOverlappedResult(pc.handle, &ov, &n, false)
+				return pc.result(op, n, err)
+			case idx == windows.WAIT_OBJECT_0+1:
+				cause = net.ErrClosed
+			case idx == windows.WAIT_OBJECT_0+2:
+				continue
+			case idx == uint32(windows.WAIT_TIMEOUT):
+				continue
+			default:
+				cause = os.NewSyscallError("WaitForMultipleObjects", syscall.Errno(idx))
+			}
+		}
+		// The buffer and the overlapped struct must outlive the operation, so wait for the cancellation to finish.
+		// The operation may complete before it's cancelled, and then the result is returned as usual.
+		_ = windows.CancelIoEx(pc.handle, &ov)
+		err = windows.GetOverlappedResult(pc.handle, &ov, &n, true)
+		if err == windows.ERROR_OPERATION_ABORTED {
+			err = cause
+		}
+		return pc.result(op, n, err)
+	}
+}
+
+func (pc *pipeConn) result(op string, n uint32, err error) (int, error) {
+	switch err {
+	case nil, windows.ERROR_MORE_DATA:
+		// ERROR_MORE_DATA only happens in message mode and the rest of the message is read next time.
+		return int(n), nil
+	case windows.ERROR_BROKEN_PIPE, windows.ERROR_PIPE_NOT_CONNECTED, windows.ERROR_NO_DATA:
+		if op == "read" {
+			return int(n), io.EOF
+		}
+		err = syscall.EPIPE
+	case net.ErrClosed, os.ErrDeadlineExceeded:
+	default:
+		if _, ok := err.(*os.SyscallError); !ok {
+			err = os.NewSyscallError(op, err)
+		}
+	}
+	return int(n), pc.opError(op, err)
+}
+
+// begin registers an operation so that Close waits for it before closing the handles.
+func (pc *pipeConn) begin() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.closed {
+		return net.ErrClosed
+	}
+	pc.ops.Add(1)
+	return nil
+}
+
+func (pc *pipeConn) Close() error {
+	pc.mu.Lock()
+	if pc.closed {
+		pc.mu.Unlock()
+		return pc.opError("close", net.ErrClosed)
+	}
+	pc.closed = true
+	pc.mu.Unlock()
+
+	// The pending operations cancel themselves once they see the close event.
+	_ = windows.SetEvent(pc.closeEvent)
+	pc.ops.Wait()
+	err := windows.CloseHandle(pc.handle)
+	pc.closeEvents()
+	if err != nil {
+		return pc.opError("close", os.NewSyscallError("CloseHandle", err))
+	}
+	return nil
+}
+
+func (pc *pipeConn) closeEvents() {
+	for _, h := range []windows.Handle{pc.closeEvent, pc.rd.changed, pc.wd.changed} {
+		if h != 0 {
+			_ = windows.CloseHandle(h)
+		}
+	}
+}
+
+func (pc *pipeConn) LocalAddr() net.Addr {
+	return pc.addr
+}
+
+func (pc *pipeConn) RemoteAddr() net.Addr {
+	return pc.addr
+}
+
+func (pc *pipeConn) SetDeadline(t time.Time) error {
+	if err := pc.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return pc.SetWriteDeadline(t)
+}
+
+func (pc *pipeConn) SetReadDeadline(t time.Time) error {
+	return pc.setDeadline(&pc.rd, t)
+}
+
+func (pc *pipeConn) SetWriteDeadline(t time.Time) error {
+	return pc.setDeadline(&pc.wd, t)
+}
+
+func (pc *pipeConn) setDeadline(dl *pipeDeadline, t time.Time) error {
+	if err := pc.begin(); err != nil {
+		return pc.opError("set deadline", err)
+	}
+	defer pc.ops.Done()
+	if err := dl.set(t); err != nil {
+		return pc.opError("set deadline", os.NewSyscallError("SetEvent", err))
+	}
+	return nil
+}
+
+func (pc *pipeConn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: pipeNetwork, Source: pc.addr, Addr: pc.addr, Err: err}
+}
@@ -16,8 +16,9 @@ func (p *packetIO) EnableProxyClient(proxy *proxyprotocol.Proxy) {
 	p.readWriter = newProxyClient(p.readWriter, proxy)
 }
 
+// EnableProxyServer accepts the proxy headers of both v1 and v2.
 func (p *packetIO) EnableProxyServer() {
-	p.readWriter = newProxyServer(p.readWriter)
+	p.readWriter = newProxyServer(p.readWriter, 0, true)
 }
 
 // Proxy returned parsed proxy header from clients if any.
@@ -33,6 +34,10 @@ type proxyReadWriter struct {
 	proxy       *proxyprotocol.Proxy
 	addr        net.Addr
 	client      bool
+	// version is the only accepted version of the proxy header on the server side. 0 accepts both v1 and v2.
+	version proxyprotocol.ProxyVersion
+	// trusted is false if the peer is not allowed to send the proxy header on the server side.
+	trusted bool
 }
 
 func newProxyClient(rw packetReadWriter, proxy *proxyprotocol.Proxy) *proxyReadWriter {
@@ -44,10 +49,12 @@ func newProxyClient(rw packetReadWriter, proxy *proxyprotocol.Proxy) *proxyReadW
 	return prw
 }
 
-func newProxyServer(rw packetReadWriter) *proxyReadWriter {
+func newProxyServer(rw packetReadWriter, version proxyprotocol.ProxyVersion, trusted bool) *proxyReadWriter {
 	prw := &proxyReadWriter{
 		packetReadWriter: rw,
 		client:           false,
+		version:          version,
+		trusted:          trusted,
 	}
 	return prw
 }
@@ -60,7 +67,7 @@ func (prw *proxyReadWriter) Read(b []byte) (int, error) {
 }
 
 func (prw *proxyReadWriter) readProxy() error {
-	// probe proxy V2
+	// probe proxy V1 and V2
 	if !prw.client && !prw.proxyInited.Load() {
 		// We don't know whether the client has enabled proxy protocol.
 		// If it doesn't, reading data of len(MagicV2) may block forever.
@@ -68,14 +75,22 @@ func (prw *proxyReadWriter) readProxy() error {
 		if err != nil {
 			return errors.Wrap(err, ErrReadConn)
 		}
-		if bytes.Equal(header[:], proxyprotocol.MagicV2[:4]) {
-			proxyHeader, err := prw.parseProxyV2()
-			if err != nil {
-				return errors.Wrap(err, ErrReadConn)
-			}
-			if proxyHeader != nil {
-				prw.proxy = proxyHeader
-			}
+		var proxyHeader *proxyprotocol.Proxy
+		isV2, isV1 := bytes.Equal(header[:], proxyprotocol.MagicV2[:4]), bytes.Equal(header[:], proxyprotocol.MagicV1[:4])
+		// Reject the header instead of ignoring it, otherwise the client address may be spoofed.
+		if (isV2 || isV1) && !prw.trusted {
+			return errors.Wrapf(proxyprotocol.ErrUntrustedPeer, "peer %s", prw.packetReadWriter.RemoteAddr())
+		}
+		if isV2 && prw.accepts(proxyprotocol.ProxyVersion2) {
+			proxyHeader, err = prw.parseProxyV2()
+		} else if isV1 && prw.accepts(proxyprotocol.ProxyVersion1) {
+			proxyHeader, err = prw.parseProxyV1()
+		}
+		if err != nil {
+			return errors.Wrap(err, ErrReadConn)
+		}
+		if proxyHeader != nil {
+			prw.proxy = proxyHeader
 		}
 		prw.proxyInited.Store(true)
 	}
@@ -146,6 +161,31 @@ func (prw *proxyReadWriter) parseProxyV2() (*proxyprotocol.Proxy, error) {
 	return m, err
 }
 
+func (prw *proxyReadWriter) accepts(version proxyprotocol.ProxyVersion) bool {
+	return prw.version == 0 || prw.version == version
+}
+
+func (prw *proxyReadWriter) parseProxyV1() (*proxyprotocol.Proxy, error) {
+	rem, err := prw.packetReadWriter.Peek(len(proxyprotocol.MagicV1))
+	if err != nil {
+		return nil, errors.WithStack(errors.Wrap(err, ErrReadConn))
+	}
+	if !bytes.Equal(rem, proxyprotocol.MagicV1) {
+		return nil, nil
+	}
+	m, _, err := proxyprotocol.ParseProxyV1(prw.packetReadWriter)
+	if err != nil {
+		return nil, err
+	}
+	// The UNKNOWN header has no addresses, so the connection is treated as if it had no header.
+	if m.SrcAddress == nil {
+		return nil, nil
+	}
+	// set RemoteAddr in case of proxy.
+	prw.addr = m.SrcAddress
+	return m, nil
+}
+
 func (prw *proxyReadWriter) RemoteAddr() net.Addr {
 	if prw.addr != nil {
 		return prw.addr
@@ -48,7 +48,7 @@ func TestProxyReadWrite(t *testing.T) {
 			require.NoError(t, prw.Flush())
 		},
 		func(t *testing.T, c net.Conn) {
-			prw := newProxyServer(newBasicReadWriter(c, DefaultConnBufferSize))
+			prw := newProxyServer(newBasicReadWriter(c, DefaultConnBufferSize), 0, true)
 			data := make([]byte, len(message))
 			n, err := prw.Read(data)
 			require.NoError(t, err)
@@ -58,6 +58,100 @@ func TestProxyReadWrite(t *testing.T) {
 		}, 1)
 }
 
+func TestProxyVersion(t *testing.T) {
+	_, p := mockProxy(t)
+	v2Header, err := p.ToBytes()
+	require.NoError(t, err)
+	v1Header := []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n")
+	tests := []struct {
+		header  []byte
+		version proxyprotocol.ProxyVersion
+		parsed  bool
+	}{
+		{v1Header, 0, true},
+		{v2Header, 0, true},
+		{v1Header, proxyprotocol.ProxyVersion1, true},
+		{v2Header, proxyprotocol.ProxyVersion1, false},
+		{v1Header, proxyprotocol.ProxyVersion2, false},
+		{v2Header, proxyprotocol.ProxyVersion2, true},
+	}
+	for i, test := range tests {
+		testkit.TestTCPConn(t,
+			func(t *testing.T, c net.Conn) {
+				_, err := c.Write(append(test.header, "hello"...))
+				require.NoError(t, err)
+			},
+			func(t *testing.T, c net.Conn) {
+				prw := newProxyServer(newBasicReadWriter(c, DefaultConnBufferSize), test.version, true)
+				if !test.parsed {
+					// The header is left as data and the handshake will fail.
+					data := make([]byte, len(test.header))
+					_, err := io.ReadFull(prw, data)
+					require.NoError(t, err, "case %d", i)
+					require.Equal(t, test.header, data, "case %d", i)
+					require.Nil(t, prw.Proxy(), "case %d", i)
+					return
+				}
+				data := make([]byte, 5)
+				_, err := io.ReadFull(prw, data)
+				require.NoError(t, err, "case %d", i)
+				require.Equal(t, "hello", string(data), "case %d", i)
+				require.NotNil(t, prw.Proxy(), "case %d", i)
+				require.Equal(t, prw.Proxy().SrcAddress.String(), prw.RemoteAddr().String(), "case %d", i)
+			}, 1)
+	}
+
+	// The UNKNOWN header is consumed but ignored.
+	testkit.TestTCPConn(t,
+		func(t *testing.T, c net.Conn) {
+			_, err := c.Write([]byte("PROXY UNKNOWN\r\nhello"))
+			require.NoError(t, err)
+		},
+		func(t *testing.T, c net.Conn) {
+			prw := newProxyServer(newBasicReadWriter(c, DefaultConnBufferSize), 0, true)
+			data := make([]byte, 5)
+			_, err := io.ReadFull(prw, data)
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(data))
+			require.Nil(t, prw.Proxy())
+			require.Equal(t, c.RemoteAddr().String(), prw.RemoteAddr().String())
+		}, 1)
+}
+
+func TestUntrustedProxy(t *testing.T) {
+	_, p := mockProxy(t)
+	v2Header, err := p.ToBytes()
+	require.NoError(t, err)
+	v1Header := []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n")
+	for i, header := range [][]byte{v1Header, v2Header} {
+		testkit.TestTCPConn(t,
+			func(t *testing.T, c net.Conn) {
+				_, err := c.Write(append(header, "hello"...))
+				require.NoError(t, err)
+			},
+			func(t *testing.T, c net.Conn) {
+				prw := newProxyServer(newBasicReadWriter(c, DefaultConnBufferSize), 0, false)
+				_, err := prw.Read(make([]byte, 5))
+				require.ErrorIs(t, err, proxyprotocol.ErrUntrustedPeer, "case %d", i)
+				require.Nil(t, prw.Proxy(), "case %d", i)
+			}, 1)
+	}
+
+	// The connection without the header is not affected.
+	testkit.TestTCPConn(t,
+		func(t *testing.T, c net.Conn) {
+			_, err := c.Write([]byte("hello"))
+			require.NoError(t, err)
+		},
+		func(t *testing.T, c net.Conn) {
+			prw := newProxyServer(newBasicReadWriter(c, DefaultConnBufferSize), 0, false)
+			data := make([]byte, 5)
+			_, err := io.ReadFull(prw, data)
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(data))
+		}, 1)
+}
+
 func mockProxy(t *testing.T) (*net.TCPAddr, *proxyprotocol.Proxy) {
 	tcpaddr, err := net.ResolveTCPAddr("tcp", "192.168.1.1:34")
 	require.NoError(t, err)
@@ -5,7 +5,11 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net"
+	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,8 +22,10 @@ import (
 	"github.com/pingcap/tiproxy/pkg/metrics"
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
 	"github.com/pingcap/tiproxy/pkg/proxy/client"
+	"github.com/pingcap/tiproxy/pkg/proxy/handoff"
 	"github.com/pingcap/tiproxy/pkg/proxy/keepalive"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
 	"go.uber.org/zap"
 )
@@ -34,11 +40,20 @@ type serverState struct {
 	requireBackendTLS  bool
 	tcpKeepAlive       bool
 	proxyProtocol      bool
+	proxyVersion       proxyprotocol.ProxyVersion
+	proxyAllowlist     proxyprotocol.Allowlist
 	gracefulWait       int // graceful-wait-before-shutdown
 	gracefulClose      int // graceful-close-conn-timeout
+	// closing is set after the listeners are closed during shutdown so that they won't be opened again.
+	closing bool
+	// handoffListener accepts the new process that takes over this one.
+	handoffListener *net.UnixListener
+	// handoffTo is the connection to the new process, to which the sessions are handed off when shutting down.
+	handoffTo *handoff.Conn
 }
 
 type SQLServer struct {
+	// listeners and addrs are updated with mu locked when proxy.addr changes.
 	listeners  []net.Listener
 	addrs      []string
 	logger     *zap.Logger
@@ -48,6 +63,12 @@ type SQLServer struct {
 	cpt        capture.Capture
 	wg         waitgroup.WaitGroup
 	cancelFunc context.CancelFunc
+	// handoffPath is the Unix socket path to hand off the listeners and sessions.
+	handoffPath string
+	// handoffFrom is the connection to the old process, from which the sessions are received.
+	handoffFrom *handoff.Conn
+	// unixSocketPerm is the file mode of the Unix sockets. It's 0 if not set.
+	unixSocketPerm os.FileMode
 
 	mu serverState
 }
@@ -56,23 +77,37 @@ type SQLServer struct {
 func NewSQLServer(logger *zap.Logger, cfg *config.Config, certMgr *cert.CertManager, idMgr *id.IDManager, cpt capture.Capture, hsHandler backend.HandshakeHandler) (*SQLServer, error) {
 	var err error
 	s := &SQLServer{
-		logger:    logger,
-		certMgr:   certMgr,
-		idMgr:     idMgr,
-		hsHandler: hsHandler,
-		cpt:       cpt,
+		logger:      logger,
+		certMgr:     certMgr,
+		idMgr:       idMgr,
+		hsHandler:   hsHandler,
+		cpt:         cpt,
+		handoffPath: cfg.Proxy.HandoffSocket,
 		mu: serverState{
 			clients: make(map[uint64]*client.ClientConnection),
 		},
 	}
 
+	if s.unixSocketPerm, err = cfg.Proxy.GetUnixSocketPermission(); err != nil {
+		return nil, err
+	}
 	s.reset(cfg)
+	// Take over the listeners before listening so that the addresses are not conflicted.
+	if s.handoffPath != "" {
+		s.takeOver()
+	}
 
 	s.addrs = strings.Split(cfg.Proxy.Addr, ",")
 	s.listeners = make([]net.Listener, len(s.addrs))
 	for i, addr := range s.addrs {
-		s.listeners[i], err = net.Listen("tcp", addr)
+		s.listeners[i], err = s.listen(addr)
 		if err != nil {
+			for j := 0; j < i; j++ {
+				_ = s.listeners[j].Close()
+			}
+			if s.handoffFrom != nil {
+				_ = s.handoffFrom.Close()
+			}
 			return nil, err
 		}
 	}
@@ -80,12 +115,82 @@ func NewSQLServer(logger *zap.Logger, cfg *config.Config, certMgr *cert.CertMana
 	return s, nil
 }
 
+// resetListeners closes the listeners of the removed addresses and opens the listeners of the added addresses.
+// The connections accepted by the closed listeners are kept.
+func (s *SQLServer) resetListeners(ctx context.Context, addr string) {
+	newAddrs := strings.Split(addr, ",")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mu.closing || slices.Equal(newAddrs, s.addrs) {
+		return
+	}
+
+	addrs := make([]string, 0, len(newAddrs))
+	listeners := make([]net.Listener, 0, len(newAddrs))
+	// Close the removed listeners first in case the new addresses use the same ports.
+	for i, oldAddr := range s.addrs {
+		if slices.Contains(newAddrs, oldAddr) {
+			addrs = append(addrs, oldAddr)
+			listeners = append(listeners, s.listeners[i])
+			continue
+		}
+		if err := s.listeners[i].Close(); err != nil {
+			s.logger.Warn("closing listener fails", zap.String("addr", oldAddr), zap.Error(err))
+		}
+		s.logger.Info("stop listening", zap.String("addr", oldAddr))
+	}
+	for _, newAddr := range newAddrs {
+		if slices.Contains(addrs, newAddr) {
+			continue
+		}
+		listener, err := s.listen(newAddr)
+		if err != nil {
+			s.logger.Error("listen failed", zap.String("addr", newAddr), zap.Error(err))
+			continue
+		}
+		s.logger.Info("start listening", zap.String("addr", newAddr))
+		addrs = append(addrs, newAddr)
+		listeners = append(listeners, listener)
+		s.serve(ctx, listener, newAddr)
+	}
+	s.addrs, s.listeners = addrs, listeners
+}
+
+// listen listens on the TCP address or the Unix socket and sets the file mode of the Unix socket.
+func (s *SQLServer) listen(addr string) (net.Listener, error) {
+	listener, err := handoff.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	if path, ok := config.ParseUnixSocketAddr(addr); ok && s.unixSocketPerm != 0 {
+		if err = os.Chmod(path, s.unixSocketPerm); err != nil {
+			_ = listener.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
+	return listener, nil
+}
+
 func (s *SQLServer) reset(cfg *config.Config) {
 	s.mu.Lock()
 	s.mu.tcpKeepAlive = cfg.Proxy.FrontendKeepalive.Enabled
 	s.mu.maxConnections = cfg.Proxy.MaxConnections
 	s.mu.requireBackendTLS = cfg.Security.RequireBackendTLS
 	s.mu.proxyProtocol = cfg.Proxy.ProxyProtocol != ""
+	switch cfg.Proxy.ProxyProtocol {
+	case "v1":
+		s.mu.proxyVersion = proxyprotocol.ProxyVersion1
+	case "v2":
+		s.mu.proxyVersion = proxyprotocol.ProxyVersion2
+	default:
+		s.mu.proxyVersion = 0
+	}
+	// The CIDRs are validated when the config is loaded.
+	if allowlist, err := proxyprotocol.NewAllowlist(cfg.Proxy.ProxyProtocolTrustedCIDRs); err == nil {
+		s.mu.proxyAllowlist = allowlist
+	} else {
+		s.logger.Error("invalid proxy protocol trusted CIDRs", zap.Error(err))
+	}
 	s.mu.gracefulWait = cfg.Proxy.GracefulWaitBeforeShutdown
 	s.mu.gracefulClose = cfg.Proxy.GracefulCloseConnTimeout
 	s.mu.healthyKeepAlive = cfg.Proxy.BackendHealthyKeepalive
@@ -109,36 +214,55 @@ func (s *SQLServer) Run(ctx context.Context, cfgch <-chan *config.Config) {
 					return
 				}
 				s.reset(ach)
+				s.resetListeners(ctx, ach.Proxy.Addr)
 			}
 		}
 	}, nil, s.logger)
 
+	s.mu.RLock()
 	for i := range s.listeners {
-		j := i
-		s.wg.Run(func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					conn, err := s.listeners[j].Accept()
-					if err != nil {
-						if errors.Is(err, net.ErrClosed) {
-							return
-						}
-
-						s.logger.Error("accept failed", zap.Error(err))
-						continue
+		s.serve(ctx, s.listeners[i], s.addrs[i])
+	}
+	s.mu.RUnlock()
+
+	if s.handoffFrom != nil {
+		s.wg.RunWithRecover(func() {
+			s.recvSessions(ctx, s.handoffFrom)
+		}, nil, s.logger)
+	}
+	if s.handoffPath != "" {
+		s.listenHandoff()
+	}
+}
+
+func (s *SQLServer) serve(ctx context.Context, listener net.Listener, addr string) {
+	s.wg.Run(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				conn, err := listener.Accept()
+				if err != nil {
+					if errors.Is(err, net.ErrClosed) {
+						return
 					}
 
-					s.wg.RunWithRecover(func() { s.onConn(ctx, conn, s.addrs[j]) }, nil, s.logger)
+					s.logger.Error("accept failed", zap.Error(err))
+					continue
 				}
+
+				s.wg.RunWithRecover(func() { s.onConn(ctx, conn, addr, nil) }, nil, s.logger)
 			}
-		})
-	}
+		}
+	})
 }
 
-func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
+// onConn serves a new connection, or a connection handed off from the old process if the session is not nil.
+func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string, session *backend.SessionHandoff) {
+	if uc, ok := conn.(*net.UnixConn); ok {
+		conn = &unixConn{UnixConn: uc}
+	}
 	tcpKeepAlive, logger, connID, clientConn := func() (bool, *zap.Logger, uint64, *client.ClientConnection) {
 		s.mu.Lock()
 		defer s.mu.Unlock()
@@ -146,8 +270,9 @@ func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
 		conns := uint64(len(s.mu.clients))
 		maxConns := s.mu.maxConnections
 		// 'maxConns == 0' => unlimited connections
-		if maxConns != 0 && conns >= maxConns {
-			s.logger.Warn("too many connections", zap.Uint64("max connections", maxConns), zap.String("client_addr", conn.RemoteAddr().Network()), zap.Error(conn.Close()))
+		// The handed off connections are already accepted by the old process, so they are not limited.
+		if session == nil && maxConns != 0 && conns >= maxConns {
+			s.logger.Warn("too many connections", zap.Uint64("max connections", maxConns), zap.String("client_addr", conn.RemoteAddr().String()), zap.Error(conn.Close()))
 			return false, nil, 0, nil
 		}
 
@@ -156,11 +281,13 @@ func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
 			zap.String("addr", addr))
 		clientConn := client.NewClientConnection(logger.Named("conn"), conn, s.certMgr.ServerSQLTLS(), s.certMgr.SQLTLS(),
 			s.hsHandler, s.cpt, connID, addr, &backend.BCConfig{
-				ProxyProtocol:      s.mu.proxyProtocol,
-				RequireBackendTLS:  s.mu.requireBackendTLS,
-				HealthyKeepAlive:   s.mu.healthyKeepAlive,
-				UnhealthyKeepAlive: s.mu.unhealthyKeepAlive,
-				ConnBufferSize:     s.mu.connBufferSize,
+				ProxyProtocol:          s.mu.proxyProtocol,
+				ProxyProtocolVersion:   s.mu.proxyVersion,
+				ProxyProtocolAllowlist: s.mu.proxyAllowlist,
+				RequireBackendTLS:      s.mu.requireBackendTLS,
+				HealthyKeepAlive:       s.mu.healthyKeepAlive,
+				UnhealthyKeepAlive:     s.mu.unhealthyKeepAlive,
+				ConnBufferSize:         s.mu.connBufferSize,
 			})
 		s.mu.clients[connID] = clientConn
 		logger.Debug("new connection", zap.Bool("proxy-protocol", s.mu.proxyProtocol), zap.Bool("require_backend_tls", s.mu.requireBackendTLS))
@@ -187,11 +314,18 @@ func (s *SQLServer) onConn(ctx context.Context, conn net.Conn, addr string) {
 		metrics.ConnGauge.Dec()
 	}()
 
-	if err := keepalive.SetKeepalive(conn, config.KeepAlive{Enabled: tcpKeepAlive}); err != nil {
-		logger.Warn("failed to set tcp keep alive option", zap.Error(err))
+	// Keepalive doesn't apply to Unix sockets.
+	if _, ok := conn.(*net.TCPConn); ok {
+		if err := keepalive.SetKeepalive(conn, config.KeepAlive{Enabled: tcpKeepAlive}); err != nil {
+			logger.Warn("failed to set tcp keep alive option", zap.Error(err))
+		}
 	}
 
-	clientConn.Run(ctx)
+	if session != nil {
+		clientConn.Resume(ctx, session)
+	} else {
+		clientConn.Run(ctx)
+	}
 }
 
 func (s *SQLServer) PreClose() {
@@ -205,22 +339,30 @@ func (s *SQLServer) PreClose() {
 	}
 
 	// Step 2: reject new connections
+	s.mu.Lock()
+	s.mu.closing = true
 	for i := range s.listeners {
 		if err := s.listeners[i].Close(); err != nil {
 			s.logger.Warn("closing listener fails", zap.Error(err))
 		}
 	}
+	s.mu.Unlock()
 
 	// Step 3: gracefully waiting for connections to finish the current transactions
+	// If a new process has taken over, hand off the idle sessions to it and close the others gracefully.
 	s.mu.Lock()
 	gracefulClose := s.mu.gracefulClose
-	s.logger.Info("SQL server is shutting down", zap.Int("graceful_close", gracefulClose), zap.Int("conn_count", len(s.mu.clients)))
+	handingOff := s.mu.handoffTo != nil
+	s.logger.Info("SQL server is shutting down", zap.Int("graceful_close", gracefulClose), zap.Int("conn_count", len(s.mu.clients)),
+		zap.Bool("handoff", handingOff))
 	if gracefulClose <= 0 {
 		s.mu.Unlock()
 		return
 	}
 	for _, conn := range s.mu.clients {
-		conn.GracefulClose()
+		if !handingOff || !conn.Handoff(s.sendSession) {
+			conn.GracefulClose()
+		}
 	}
 	s.mu.Unlock()
 
@@ -248,15 +390,151 @@ func (s *SQLServer) Close() error {
 		s.cancelFunc = nil
 	}
 
-	s.mu.RLock()
+	s.mu.Lock()
+	s.mu.closing = true
+	if s.mu.handoffListener != nil {
+		if err := s.mu.handoffListener.Close(); err != nil {
+			s.logger.Warn("close handoff listener error", zap.Error(err))
+		}
+	}
+	// The new process finishes receiving sessions after the connection is closed.
+	if s.mu.handoffTo != nil {
+		if err := s.mu.handoffTo.Close(); err != nil {
+			s.logger.Warn("close handoff connection error", zap.Error(err))
+		}
+	}
 	s.logger.Info("force closing connections", zap.Int("conn_count", len(s.mu.clients)))
 	for _, conn := range s.mu.clients {
 		if err := conn.Close(); err != nil {
 			s.logger.Warn("close connection error", zap.Error(err))
 		}
 	}
-	s.mu.RUnlock()
+	s.mu.Unlock()
+	if s.handoffFrom != nil {
+		_ = s.handoffFrom.Close()
+	}
 
 	s.wg.Wait()
 	return nil
 }
+
+// takeOver receives the listeners from the old process if it's running.
+func (s *SQLServer) takeOver() {
+	conn, err := handoff.Dial(s.handoffPath)
+	if err != nil {
+		s.logger.Info("no process to take over", zap.String("path", s.handoffPath), zap.Error(err))
+		return
+	}
+	if err = conn.RecvListeners(); err != nil {
+		s.logger.Warn("failed to receive listeners from the old process", zap.String("path", s.handoffPath), zap.Error(err))
+		_ = conn.Close()
+		return
+	}
+	s.logger.Info("took over listeners from the old process", zap.String("path", s.handoffPath))
+	s.handoffFrom = conn
+}
+
+// recvSessions serves the connections handed off from the old process until the old process exits.
+func (s *SQLServer) recvSessions(ctx context.Context, conn *handoff.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	for {
+		data, clientConn, err := conn.RecvSession()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				s.logger.Info("the old process finished handing off")
+			} else {
+				s.logger.Warn("failed to receive sessions from the old process", zap.Error(err))
+			}
+			return
+		}
+		var session backend.SessionHandoff
+		if err = json.Unmarshal(data, &session); err != nil {
+			s.logger.Warn("failed to parse the handed off session", zap.Error(err))
+			_ = clientConn.Close()
+			continue
+		}
+		s.wg.RunWithRecover(func() { s.onConn(ctx, clientConn, session.Addr, &session) }, nil, s.logger)
+	}
+}
+
+// listenHandoff listens on the Unix socket so that the next process can take over this one.
+func (s *SQLServer) listenHandoff() {
+	listener, err := handoff.ListenUnix(s.handoffPath)
+	if err != nil {
+		s.logger.Error("failed to listen on the handoff socket", zap.String("path", s.handoffPath), zap.Error(err))
+		return
+	}
+	s.mu.Lock()
+	if s.mu.closing {
+		s.mu.Unlock()
+		_ = listener.Close()
+		return
+	}
+	s.mu.handoffListener = listener
+	s.mu.Unlock()
+
+	s.wg.Run(func() {
+		for {
+			conn, err := listener.AcceptUnix()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				s.logger.Error("accept handoff connection failed", zap.Error(err))
+				continue
+			}
+			s.onHandoffConn(handoff.NewConn(conn))
+		}
+	})
+}
+
+// onHandoffConn passes the listeners to the new process. The sessions are passed when this process shuts down.
+func (s *SQLServer) onHandoffConn(conn *handoff.Conn) {
+	s.mu.RLock()
+	taken := s.mu.handoffTo != nil || s.mu.closing
+	s.mu.RUnlock()
+	if taken {
+		s.logger.Warn("another process has taken over, reject the new one")
+		_ = conn.Close()
+		return
+	}
+	if err := conn.SendListeners(); err != nil {
+		s.logger.Warn("failed to pass listeners to the new process", zap.Error(err))
+		_ = conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.mu.handoffTo = conn
+	s.mu.Unlock()
+	s.logger.Info("a new process took over the listeners, sessions will be handed off when shutting down")
+}
+
+// sendSession passes a client connection to the new process.
+func (s *SQLServer) sendSession(session *backend.SessionHandoff, file *os.File) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.mu.RLock()
+	conn := s.mu.handoffTo
+	s.mu.RUnlock()
+	if conn == nil {
+		return errors.New("no process to hand off to")
+	}
+	return conn.SendSession(data, file)
+}
+
+// unixConn reports the socket path as the client address because the client of a Unix socket is usually unnamed.
+// It embeds *net.UnixConn so that the connection can still be handed off.
+type unixConn struct {
+	*net.UnixConn
+}
+
+func (c *unixConn) RemoteAddr() net.Addr {
+	if addr, ok := c.UnixConn.RemoteAddr().(*net.UnixAddr); ok && addr != nil && addr.Name != "" {
+		return addr
+	}
+	return c.UnixConn.LocalAddr()
+}
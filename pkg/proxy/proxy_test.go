@@ -7,7 +7,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -24,6 +28,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
 	"github.com/pingcap/tiproxy/pkg/proxy/client"
 	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	"github.com/stretchr/testify/require"
 )
 
@@ -228,10 +233,11 @@ func TestWatchCfg(t *testing.T) {
 	cfg := &config.Config{
 		Proxy: config.ProxyServer{
 			ProxyServerOnline: config.ProxyServerOnline{
-				MaxConnections:           100,
-				ConnBufferSize:           1024 * 1024,
-				ProxyProtocol:            "v2",
-				GracefulCloseConnTimeout: 100,
+				MaxConnections:            100,
+				ConnBufferSize:            1024 * 1024,
+				ProxyProtocol:             "v2",
+				ProxyProtocolTrustedCIDRs: []string{"10.0.0.0/8"},
+				GracefulCloseConnTimeout:  100,
 			},
 		},
 		Security: config.Security{
@@ -246,12 +252,222 @@ func TestWatchCfg(t *testing.T) {
 			server.mu.maxConnections == cfg.Proxy.MaxConnections &&
 			server.mu.connBufferSize == cfg.Proxy.ConnBufferSize &&
 			server.mu.proxyProtocol == (cfg.Proxy.ProxyProtocol != "") &&
+			len(server.mu.proxyAllowlist) == 1 &&
 			server.mu.gracefulWait == cfg.Proxy.GracefulWaitBeforeShutdown
 	}, 3*time.Second, 10*time.Millisecond)
 	server.PreClose()
 	require.NoError(t, server.Close())
 }
 
+func TestReloadAddr(t *testing.T) {
+	lg, _ := logger.CreateLoggerForTest(t)
+	certManager := cert.NewCertManager()
+	require.NoError(t, certManager.Init(&config.Config{}, lg, nil))
+	cfgch := make(chan *config.Config)
+	server, err := NewSQLServer(lg, &config.Config{
+		Proxy: config.ProxyServer{
+			Addr: "127.0.0.1:0",
+		},
+	}, certManager, id.NewIDManager(), nil, &mockHsHandler{})
+	require.NoError(t, err)
+	server.Run(context.Background(), cfgch)
+	oldAddr := server.listeners[0].Addr().String()
+	conn, err := net.Dial("tcp", oldAddr)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		server.mu.RLock()
+		defer server.mu.RUnlock()
+		return len(server.mu.clients) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// Find a free port for the new address.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	newAddr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	cfgch <- &config.Config{
+		Proxy: config.ProxyServer{
+			Addr: newAddr,
+		},
+	}
+	require.Eventually(t, func() bool {
+		c, err := net.Dial("tcp", newAddr)
+		if err != nil {
+			return false
+		}
+		require.NoError(t, c.Close())
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+	_, err = net.Dial("tcp", oldAddr)
+	require.Error(t, err)
+	// The connection on the removed address is still alive.
+	server.mu.RLock()
+	require.Len(t, server.mu.clients, 1)
+	server.mu.RUnlock()
+	require.NoError(t, conn.Close())
+
+	// The listeners are not opened again after shutdown.
+	server.PreClose()
+	server.resetListeners(context.Background(), oldAddr)
+	_, err = net.Dial("tcp", oldAddr)
+	require.Error(t, err)
+	require.NoError(t, server.Close())
+	certManager.Close()
+}
+
+func TestTakeOver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skipf("unsupported on %s", runtime.GOOS)
+	}
+	lg, _ := logger.CreateLoggerForTest(t)
+	certManager := cert.NewCertManager()
+	require.NoError(t, certManager.Init(&config.Config{}, lg, nil))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	cfg := &config.Config{
+		Proxy: config.ProxyServer{
+			Addr:          addr,
+			HandoffSocket: filepath.Join(t.TempDir(), "handoff.sock"),
+		},
+	}
+	server1, err := NewSQLServer(lg, cfg, certManager, id.NewIDManager(), nil, &mockHsHandler{})
+	require.NoError(t, err)
+	require.Nil(t, server1.handoffFrom)
+	server1.Run(context.Background(), nil)
+
+	// The new process takes over the listener, so the address is not bound twice.
+	server2, err := NewSQLServer(lg, cfg, certManager, id.NewIDManager(), nil, &mockHsHandler{})
+	require.NoError(t, err)
+	require.NotNil(t, server2.handoffFrom)
+	server2.Run(context.Background(), nil)
+	require.Eventually(t, func() bool {
+		server1.mu.RLock()
+		defer server1.mu.RUnlock()
+		return server1.mu.handoffTo != nil
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// The old process exits and the new one still accepts on the address.
+	server1.PreClose()
+	require.NoError(t, server1.Close())
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		server2.mu.RLock()
+		defer server2.mu.RUnlock()
+		return len(server2.mu.clients) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	require.NoError(t, conn.Close())
+	server2.PreClose()
+	require.NoError(t, server2.Close())
+	certManager.Close()
+}
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skipf("unsupported on %s", runtime.GOOS)
+	}
+	lg, text := logger.CreateLoggerForTest(t)
+	certManager := cert.NewCertManager()
+	require.NoError(t, certManager.Init(&config.Config{}, lg, nil))
+	path := filepath.Join(t.TempDir(), "tiproxy.sock")
+	// The socket file left by a crashed process is removed.
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	listener.SetUnlinkOnClose(false)
+	require.NoError(t, listener.Close())
+
+	cfg := &config.Config{
+		Proxy: config.ProxyServer{
+			Addr:                 "127.0.0.1:0,unix://" + path,
+			UnixSocketPermission: "0666",
+		},
+	}
+	server, err := NewSQLServer(lg, cfg, certManager, id.NewIDManager(), nil, &mockHsHandler{})
+	require.NoError(t, err)
+	server.Run(context.Background(), nil)
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0666), fi.Mode().Perm())
+
+	// The socket is in use.
+	_, err = NewSQLServer(lg, &config.Config{Proxy: config.ProxyServer{Addr: "unix://" + path}}, certManager, id.NewIDManager(), nil, &mockHsHandler{})
+	require.ErrorContains(t, err, "in use")
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		server.mu.RLock()
+		defer server.mu.RUnlock()
+		return len(server.mu.clients) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Contains(t, text.String(), path)
+	require.NotContains(t, text.String(), "failed to set tcp keep alive option")
+	require.NoError(t, conn.Close())
+
+	// The socket file is removed after shutdown.
+	server.PreClose()
+	require.NoError(t, server.Close())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+	certManager.Close()
+}
+
+func TestUntrustedProxyHeader(t *testing.T) {
+	lg, text := logger.CreateLoggerForTest(t)
+	certManager := cert.NewCertManager()
+	require.NoError(t, certManager.Init(&config.Config{}, lg, nil))
+	cfg := &config.Config{
+		Proxy: config.ProxyServer{
+			ProxyServerOnline: config.ProxyServerOnline{
+				ProxyProtocol:             "v2",
+				ProxyProtocolTrustedCIDRs: []string{"10.0.0.0/8"},
+			},
+		},
+	}
+	server, err := NewSQLServer(lg, cfg, certManager, id.NewIDManager(), nil, &mockHsHandler{})
+	require.NoError(t, err)
+	server.Run(context.Background(), nil)
+	rejected, err := metrics.ReadCounter(metrics.UntrustedProxyHeaderCounter)
+	require.NoError(t, err)
+
+	// The header from 127.0.0.1 is rejected.
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	p := &proxyprotocol.Proxy{
+		Version:    proxyprotocol.ProxyVersion2,
+		Command:    proxyprotocol.ProxyCommandProxy,
+		SrcAddress: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1).To4(), Port: 3306},
+		DstAddress: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2).To4(), Port: 6000},
+	}
+	header, err := p.ToBytes()
+	require.NoError(t, err)
+	_, err = conn.Write(header)
+	require.NoError(t, err)
+	_, err = io.Copy(io.Discard, conn)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool {
+		value, err := metrics.ReadCounter(metrics.UntrustedProxyHeaderCounter)
+		require.NoError(t, err)
+		return value == rejected+1
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Contains(t, text.String(), "untrusted peer")
+	require.NotContains(t, text.String(), "10.0.0.1")
+
+	// The connection without the header still works.
+	_, port, err := net.SplitHostPort(server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	mdb, err := sql.Open("mysql", fmt.Sprintf("root@tcp(localhost:%s)/test", port))
+	require.NoError(t, err)
+	require.ErrorContains(t, mdb.Ping(), "no router")
+	require.NoError(t, mdb.Close())
+	server.PreClose()
+	require.NoError(t, server.Close())
+	certManager.Close()
+}
+
 func TestRecoverPanic(t *testing.T) {
 	lg, text := logger.CreateLoggerForTest(t)
 	certManager := cert.NewCertManager()
@@ -0,0 +1,48 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package proxyprotocol
+
+import (
+	"net"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+// Allowlist contains the peers that are trusted to send the proxy headers. An empty allowlist trusts all peers.
+type Allowlist []*net.IPNet
+
+func NewAllowlist(cidrs []string) (Allowlist, error) {
+	allowlist := make(Allowlist, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		allowlist = append(allowlist, ipNet)
+	}
+	return allowlist, nil
+}
+
+// Trusts returns whether the peer is allowed to send the proxy headers.
+// Unix socket peers are always trusted because the access is controlled by the file mode of the socket.
+func (al Allowlist) Trusts(addr net.Addr) bool {
+	if len(al) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch addr := unwrapOriginAddr(addr).(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UnixAddr:
+		return true
+	default:
+		return false
+	}
+	for _, ipNet := range al {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -8,7 +8,8 @@ import "net"
 type ProxyVersion int
 
 const (
-	ProxyVersion2 ProxyVersion = iota + 2
+	ProxyVersion1 ProxyVersion = iota + 1
+	ProxyVersion2
 )
 
 type ProxyCommand int
@@ -37,18 +38,22 @@ const (
 
 type ProxyTlvType int
 
+// The TLV types defined by the PROXY protocol spec and the cloud vendors.
 const (
-	ProxyTlvALPN ProxyTlvType = iota + 0x01
-	ProxyTlvAuthority
-	ProxyTlvCRC32C
-	ProxyTlvNoop
-	ProxyTlvUniqueID
-	ProxyTlvSSL ProxyTlvType = iota + 0x20
-	ProxyTlvSSLCN
-	ProxyTlvSSLCipher
-	ProxyTlvSSLSignALG
-	ProxyTlvSSLKeyALG
-	ProxyTlvNetns ProxyTlvType = iota + 0x30
+	ProxyTlvALPN       ProxyTlvType = 0x01
+	ProxyTlvAuthority  ProxyTlvType = 0x02
+	ProxyTlvCRC32C     ProxyTlvType = 0x03
+	ProxyTlvNoop       ProxyTlvType = 0x04
+	ProxyTlvUniqueID   ProxyTlvType = 0x05
+	ProxyTlvSSL        ProxyTlvType = 0x20
+	ProxyTlvSSLVersion ProxyTlvType = 0x21
+	ProxyTlvSSLCN      ProxyTlvType = 0x22
+	ProxyTlvSSLCipher  ProxyTlvType = 0x23
+	ProxyTlvSSLSignALG ProxyTlvType = 0x24
+	ProxyTlvSSLKeyALG  ProxyTlvType = 0x25
+	ProxyTlvNetns      ProxyTlvType = 0x30
+	// ProxyTlvAWS is sent by AWS NLB. The first byte of the content is the subtype.
+	ProxyTlvAWS ProxyTlvType = 0xEA
 )
 
 type ProxyTlv struct {
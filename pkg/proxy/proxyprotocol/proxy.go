@@ -22,6 +22,23 @@ func unwrapOriginAddr(addr net.Addr) net.Addr {
 	}
 }
 
+// AddressFamilyMismatch returns true if the source and destination addresses can't be in the same header.
+func AddressFamilyMismatch(src, dst net.Addr) bool {
+	src, dst = unwrapOriginAddr(src), unwrapOriginAddr(dst)
+	switch src.(type) {
+	case *net.TCPAddr:
+		_, ok := dst.(*net.TCPAddr)
+		return !ok
+	case *net.UDPAddr:
+		_, ok := dst.(*net.UDPAddr)
+		return !ok
+	case *net.UnixAddr:
+		_, ok := dst.(*net.UnixAddr)
+		return !ok
+	}
+	return false
+}
+
 func (p *Proxy) ToBytes() ([]byte, error) {
 	magicLen := len(MagicV2)
 	buf := make([]byte, magicLen+4)
@@ -187,18 +204,7 @@ func ParseProxyV2(rd io.Reader) (m *Proxy, n int, err error) {
 		buf = buf[len(buf):]
 	}
 
-	for len(buf) >= 3 {
-		typ := ProxyTlvType(buf[0])
-		length := int(buf[1])<<8 | int(buf[2])
-		if len(buf) < length+3 {
-			length = len(buf) - 3
-		}
-		m.TLV = append(m.TLV, ProxyTlv{
-			Typ:     typ,
-			Content: buf[3 : 3+length],
-		})
-		buf = buf[3+length:]
-	}
+	m.TLV = parseTLVs(buf)
 
 	return
 }
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"io"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/pingcap/tiproxy/pkg/testkit"
@@ -87,8 +88,153 @@ func TestProxyToBytes(t *testing.T) {
 	hdr.DstAddress = &net.UDPAddr{}
 	_, err = hdr.ToBytes()
 	require.ErrorIs(t, err, ErrAddressFamilyMismatch)
+	require.True(t, AddressFamilyMismatch(hdr.SrcAddress, hdr.DstAddress))
+	require.True(t, AddressFamilyMismatch(&net.UnixAddr{Name: "/tmp/tiproxy.sock"}, hdr.SrcAddress))
+	require.False(t, AddressFamilyMismatch(hdr.SrcAddress, &originAddr{Addr: &net.TCPAddr{}}))
 
 	hdr.DstAddress = &originAddr{Addr: &net.TCPAddr{IP: make(net.IP, net.IPv6len), Port: 0}}
 	_, err = hdr.ToBytes()
 	require.NoError(t, err)
 }
+
+func TestProxyParseV1(t *testing.T) {
+	tests := []struct {
+		header string
+		src    string
+		dst    string
+		err    bool
+	}{
+		{
+			header: "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n",
+			src:    "192.168.0.1:56324",
+			dst:    "192.168.0.11:443",
+		},
+		{
+			header: "PROXY TCP6 ::1 ::2 56324 443\r\n",
+			src:    "[::1]:56324",
+			dst:    "[::2]:443",
+		},
+		{
+			header: "PROXY UNKNOWN\r\n",
+		},
+		{
+			header: "PROXY UNKNOWN ::1 ::2 56324 443\r\n",
+		},
+		{
+			header: "PROXY TCP4 ::1 ::2 56324 443\r\n",
+			err:    true,
+		},
+		{
+			header: "PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n",
+			err:    true,
+		},
+		{
+			header: "PROXY TCP4 192.168.0.1 192.168.0.11 56324 65536\r\n",
+			err:    true,
+		},
+		{
+			header: "PROXY UDP4 192.168.0.1 192.168.0.11 56324 443\r\n",
+			err:    true,
+		},
+		{
+			header: "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\n",
+			err:    true,
+		},
+		{
+			header: "PROXY TCP6 " + strings.Repeat("f", maxV1Len) + "\r\n",
+			err:    true,
+		},
+	}
+	for i, test := range tests {
+		// The data after the header is not consumed.
+		rd := bytes.NewReader([]byte(test.header + "data"))
+		p, n, err := ParseProxyV1(rd)
+		if test.err {
+			require.ErrorIs(t, err, ErrInvalidHeader, "case %d", i)
+			continue
+		}
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, len(test.header), n, "case %d", i)
+		require.Equal(t, len("data"), rd.Len(), "case %d", i)
+		require.Equal(t, ProxyVersion1, p.Version, "case %d", i)
+		if test.src == "" {
+			require.Nil(t, p.SrcAddress, "case %d", i)
+			continue
+		}
+		require.Equal(t, test.src, p.SrcAddress.String(), "case %d", i)
+		require.Equal(t, test.dst, p.DstAddress.String(), "case %d", i)
+	}
+
+	// IPv4 addresses are forwarded as IPv4 in v2 headers.
+	p, _, err := ParseProxyV1(strings.NewReader("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	require.NoError(t, err)
+	p.Version = ProxyVersion2
+	b, err := p.ToBytes()
+	require.NoError(t, err)
+	require.Equal(t, byte(0x11), b[len(MagicV2)+1])
+}
+
+func TestTLVValues(t *testing.T) {
+	ssl := []byte{sslClientSSL | sslClientCertConn, 0, 0, 0, 0}
+	ssl = append(ssl, byte(ProxyTlvSSLVersion), 0, 7)
+	ssl = append(ssl, "TLSv1.3"...)
+	ssl = append(ssl, byte(ProxyTlvSSLCN), 0, 6)
+	ssl = append(ssl, "client"...)
+	p := &Proxy{
+		Version: ProxyVersion2,
+		Command: ProxyCommandProxy,
+		TLV: []ProxyTlv{
+			{Typ: ProxyTlvALPN, Content: []byte("h2")},
+			{Typ: ProxyTlvUniqueID, Content: []byte{0xff, 0xfe}},
+			{Typ: ProxyTlvSSL, Content: ssl},
+			{Typ: ProxyTlvAWS, Content: append([]byte{awsVPCEndpointID}, "vpce-1"...)},
+			{Typ: ProxyTlvNoop, Content: []byte("noop")},
+		},
+	}
+	require.Equal(t, map[string]string{
+		TLVNameALPN:             "h2",
+		TLVNameUniqueID:         "fffe",
+		TLVNameSSL:              "true",
+		TLVNameSSLVerified:      "true",
+		TLVNameSSLVersion:       "TLSv1.3",
+		TLVNameSSLCN:            "client",
+		TLVNameAWSVPCEndpointID: "vpce-1",
+	}, p.TLVValues())
+
+	// The TLVs survive the round trip.
+	p.SrcAddress = &net.TCPAddr{IP: net.IPv4(192, 168, 0, 1).To4(), Port: 56324}
+	p.DstAddress = &net.TCPAddr{IP: net.IPv4(192, 168, 0, 11).To4(), Port: 443}
+	b, err := p.ToBytes()
+	require.NoError(t, err)
+	parsed, _, err := ParseProxyV2(bytes.NewReader(b[len(MagicV2):]))
+	require.NoError(t, err)
+	require.Equal(t, p.TLVValues(), parsed.TLVValues())
+}
+
+func TestAllowlist(t *testing.T) {
+	_, err := NewAllowlist([]string{"10.0.0.1"})
+	require.Error(t, err)
+
+	allowlist, err := NewAllowlist(nil)
+	require.NoError(t, err)
+	require.True(t, allowlist.Trusts(&net.TCPAddr{IP: net.IPv4(192, 168, 0, 1)}))
+
+	allowlist, err = NewAllowlist([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+	tests := []struct {
+		addr    net.Addr
+		trusted bool
+	}{
+		{&net.TCPAddr{IP: net.IPv4(10, 1, 2, 3)}, true},
+		{&net.TCPAddr{IP: net.IPv4(10, 1, 2, 3).To4()}, true},
+		{&net.TCPAddr{IP: net.IPv4(192, 168, 0, 1)}, false},
+		{&net.TCPAddr{IP: net.ParseIP("fd00::1")}, true},
+		{&net.TCPAddr{IP: net.ParseIP("::1")}, false},
+		{&originAddr{Addr: &net.TCPAddr{IP: net.IPv4(10, 1, 2, 3)}}, true},
+		{&net.UnixAddr{Name: "/tmp/tiproxy.sock"}, true},
+		{&net.UDPAddr{IP: net.IPv4(10, 1, 2, 3)}, false},
+	}
+	for i, test := range tests {
+		require.Equal(t, test.trusted, allowlist.Trusts(test.addr), "case %d", i)
+	}
+}
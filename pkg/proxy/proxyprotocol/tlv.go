@@ -0,0 +1,108 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package proxyprotocol
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"unicode/utf8"
+)
+
+// The names of the TLVs returned by TLVValues. They are used in the routing rules and the logs.
+const (
+	TLVNameALPN             = "alpn"
+	TLVNameAuthority        = "authority"
+	TLVNameUniqueID         = "unique-id"
+	TLVNameSSL              = "ssl"
+	TLVNameSSLVerified      = "ssl-verified"
+	TLVNameSSLVersion       = "ssl-version"
+	TLVNameSSLCN            = "ssl-cn"
+	TLVNameSSLCipher        = "ssl-cipher"
+	TLVNameSSLSignALG       = "ssl-sig-alg"
+	TLVNameSSLKeyALG        = "ssl-key-alg"
+	TLVNameNetns            = "netns"
+	TLVNameAWSVPCEndpointID = "aws-vpce-id"
+)
+
+const (
+	// The client flags in the SSL TLV.
+	sslClientSSL      = 0x01
+	sslClientCertConn = 0x02
+	sslClientCertSess = 0x04
+	// awsVPCEndpointID is the subtype of the AWS TLV.
+	awsVPCEndpointID = 0x01
+)
+
+// TLVValues returns the known TLVs in readable strings, indexed by the TLV names. The unknown TLVs are ignored.
+func (p *Proxy) TLVValues() map[string]string {
+	values := make(map[string]string)
+	for _, tlv := range p.TLV {
+		switch tlv.Typ {
+		case ProxyTlvALPN:
+			values[TLVNameALPN] = string(tlv.Content)
+		case ProxyTlvAuthority:
+			values[TLVNameAuthority] = string(tlv.Content)
+		case ProxyTlvUniqueID:
+			// The unique ID is opaque bytes, but most proxies fill it with text.
+			if utf8.Valid(tlv.Content) {
+				values[TLVNameUniqueID] = string(tlv.Content)
+			} else {
+				values[TLVNameUniqueID] = hex.EncodeToString(tlv.Content)
+			}
+		case ProxyTlvNetns:
+			values[TLVNameNetns] = string(tlv.Content)
+		case ProxyTlvSSL:
+			parseSSLTLV(tlv.Content, values)
+		case ProxyTlvAWS:
+			if len(tlv.Content) > 0 && tlv.Content[0] == awsVPCEndpointID {
+				values[TLVNameAWSVPCEndpointID] = string(tlv.Content[1:])
+			}
+		}
+	}
+	return values
+}
+
+// parseSSLTLV parses the SSL TLV, which consists of a 1-byte client flag, a 4-byte verify result, and the sub-TLVs.
+func parseSSLTLV(content []byte, values map[string]string) {
+	if len(content) < 5 {
+		return
+	}
+	client, verify := content[0], binary.BigEndian.Uint32(content[1:5])
+	values[TLVNameSSL] = strconv.FormatBool(client&sslClientSSL != 0)
+	// The verify result is 0 only if the client presented a certificate and it was verified.
+	values[TLVNameSSLVerified] = strconv.FormatBool(verify == 0 && client&(sslClientCertConn|sslClientCertSess) != 0)
+	for _, sub := range parseTLVs(content[5:]) {
+		switch sub.Typ {
+		case ProxyTlvSSLVersion:
+			values[TLVNameSSLVersion] = string(sub.Content)
+		case ProxyTlvSSLCN:
+			values[TLVNameSSLCN] = string(sub.Content)
+		case ProxyTlvSSLCipher:
+			values[TLVNameSSLCipher] = string(sub.Content)
+		case ProxyTlvSSLSignALG:
+			values[TLVNameSSLSignALG] = string(sub.Content)
+		case ProxyTlvSSLKeyALG:
+			values[TLVNameSSLKeyALG] = string(sub.Content)
+		}
+	}
+}
+
+// parseTLVs parses the type-length-value vectors. A truncated TLV takes the remaining bytes.
+func parseTLVs(buf []byte) []ProxyTlv {
+	var tlvs []ProxyTlv
+	for len(buf) >= 3 {
+		typ := ProxyTlvType(buf[0])
+		length := int(buf[1])<<8 | int(buf[2])
+		if len(buf) < length+3 {
+			length = len(buf) - 3
+		}
+		tlvs = append(tlvs, ProxyTlv{
+			Typ:     typ,
+			Content: buf[3 : 3+length],
+		})
+		buf = buf[3+length:]
+	}
+	return tlvs
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package proxyprotocol
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+)
+
+var (
+	MagicV1 = []byte("PROXY ")
+)
+
+// maxV1Len is the max length of a v1 header including the CRLF, defined by the spec.
+const maxV1Len = 107
+
+// ParseProxyV1 parses the text header, e.g. "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n".
+// The reader should start with MagicV1. The header is read byte by byte so that nothing after it is consumed.
+func ParseProxyV1(rd io.Reader) (m *Proxy, n int, err error) {
+	line := make([]byte, 0, maxV1Len)
+	var b [1]byte
+	for {
+		if _, err = io.ReadFull(rd, b[:]); err != nil {
+			return nil, n, err
+		}
+		n++
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			break
+		}
+		if len(line) >= maxV1Len {
+			return nil, n, errors.Wrapf(ErrInvalidHeader, "v1 header is longer than %d bytes", maxV1Len)
+		}
+	}
+	text, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok || !strings.HasPrefix(text, string(MagicV1)) {
+		return nil, n, errors.Wrapf(ErrInvalidHeader, "%q", line)
+	}
+	fields := strings.Split(text[len(MagicV1):], " ")
+	m = &Proxy{Version: ProxyVersion1, Command: ProxyCommandProxy}
+	switch fields[0] {
+	case "UNKNOWN":
+		// The receiver must ignore the addresses.
+		return m, n, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, n, errors.Wrapf(ErrInvalidHeader, "unknown protocol %q", fields[0])
+	}
+	if len(fields) != 5 {
+		return nil, n, errors.Wrapf(ErrInvalidHeader, "%q", text)
+	}
+	ipv4 := fields[0] == "TCP4"
+	if m.SrcAddress, err = parseV1Addr(fields[1], fields[3], ipv4); err != nil {
+		return nil, n, err
+	}
+	if m.DstAddress, err = parseV1Addr(fields[2], fields[4], ipv4); err != nil {
+		return nil, n, err
+	}
+	return m, n, nil
+}
+
+func parseV1Addr(ip, port string, ipv4 bool) (*net.TCPAddr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	// Keep the IPv4 address in 4 bytes so that it's forwarded as IPv4 in the v2 header.
+	if ipv4 {
+		addr.IP = addr.IP.To4()
+	}
+	if addr.IP == nil {
+		return nil, errors.Wrapf(ErrInvalidHeader, "invalid IP %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidHeader, "invalid port %q", port)
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	"github.com/pingcap/tiproxy/pkg/balance/router"
+)
+
+// BalanceHistory returns the recent balance decisions of each namespace.
+// The result can be filtered by the `namespace` parameter.
+func (h *Server) BalanceHistory(c *gin.Context) {
+	history := h.mgr.NsMgr.BalanceHistory()
+	if ns := c.Query("namespace"); ns != "" {
+		records, ok := history[ns]
+		if !ok {
+			c.JSON(http.StatusNotFound, "namespace not found")
+			return
+		}
+		history = map[string][]router.BalanceRecord{ns: records}
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// BalanceScores returns the score breakdown of the backends of each namespace.
+// The result can be filtered by the `namespace` parameter.
+func (h *Server) BalanceScores(c *gin.Context) {
+	scores := h.mgr.NsMgr.BalanceScores()
+	if ns := c.Query("namespace"); ns != "" {
+		nsScores, ok := scores[ns]
+		if !ok {
+			c.JSON(http.StatusNotFound, "namespace not found")
+			return
+		}
+		scores = map[string]policy.BalanceScores{ns: nsScores}
+	}
+	c.JSON(http.StatusOK, scores)
+}
+
+func (h *Server) registerBalance(group *gin.RouterGroup) {
+	group.GET("/history", h.BalanceHistory)
+	group.GET("/scores", h.BalanceScores)
+}
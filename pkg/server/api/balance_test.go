@@ -0,0 +1,128 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	"github.com/pingcap/tiproxy/pkg/balance/router"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceHistory(t *testing.T) {
+	server, doHTTP := createServer(t)
+	mgr := server.mgr.NsMgr.(*mockNamespaceManager)
+	mgr.history = map[string][]router.BalanceRecord{
+		"ns1": {{ID: 1, ConnID: 10, From: "addr1", To: "addr2", Reason: "cpu", Result: router.BalanceResultSucceed}},
+		"ns2": {},
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		expect map[string][]router.BalanceRecord
+	}{
+		{
+			query:  "",
+			status: http.StatusOK,
+			expect: mgr.history,
+		},
+		{
+			query:  "?namespace=ns1",
+			status: http.StatusOK,
+			expect: map[string][]router.BalanceRecord{"ns1": mgr.history["ns1"]},
+		},
+		{
+			query:  "?namespace=ns3",
+			status: http.StatusNotFound,
+		},
+	}
+	for i, test := range tests {
+		doHTTP(t, http.MethodGet, "/api/balance/history"+test.query, httpOpts{}, func(t *testing.T, r *http.Response) {
+			require.Equal(t, test.status, r.StatusCode, "case %d", i)
+			if test.status != http.StatusOK {
+				return
+			}
+			all, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var history map[string][]router.BalanceRecord
+			require.NoError(t, json.Unmarshal(all, &history))
+			require.Equal(t, len(test.expect), len(history), "case %d", i)
+			for ns, records := range test.expect {
+				require.Equal(t, len(records), len(history[ns]), "case %d", i)
+				for j := range records {
+					require.Equal(t, records[j].ConnID, history[ns][j].ConnID, "case %d", i)
+					require.Equal(t, records[j].Result, history[ns][j].Result, "case %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBalanceScores(t *testing.T) {
+	server, doHTTP := createServer(t)
+	mgr := server.mgr.NsMgr.(*mockNamespaceManager)
+	mgr.scores = map[string]policy.BalanceScores{
+		"ns1": {
+			Backends: []policy.BackendScore{
+				{
+					Addr:         "addr1",
+					Healthy:      true,
+					Score:        100,
+					FactorScores: []policy.FactorScore{{Factor: "conn", Score: 100, BitNum: 16}},
+					Inputs:       map[string]float64{"conn_score": 100},
+				},
+				{
+					Addr:         "addr2",
+					Healthy:      true,
+					FactorScores: []policy.FactorScore{{Factor: "conn", Score: 0, BitNum: 16}},
+					Inputs:       map[string]float64{"conn_score": 0},
+				},
+			},
+			BalanceFactor: "conn",
+			From:          "addr1",
+			To:            "addr2",
+			BalanceCount:  1,
+		},
+		"ns2": {},
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		expect map[string]policy.BalanceScores
+	}{
+		{
+			query:  "",
+			status: http.StatusOK,
+			expect: mgr.scores,
+		},
+		{
+			query:  "?namespace=ns1",
+			status: http.StatusOK,
+			expect: map[string]policy.BalanceScores{"ns1": mgr.scores["ns1"]},
+		},
+		{
+			query:  "?namespace=ns3",
+			status: http.StatusNotFound,
+		},
+	}
+	for i, test := range tests {
+		doHTTP(t, http.MethodGet, "/api/balance/scores"+test.query, httpOpts{}, func(t *testing.T, r *http.Response) {
+			require.Equal(t, test.status, r.StatusCode, "case %d", i)
+			if test.status != http.StatusOK {
+				return
+			}
+			all, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var scores map[string]policy.BalanceScores
+			require.NoError(t, json.Unmarshal(all, &scores))
+			require.Equal(t, test.expect, scores, "case %d", i)
+		})
+	}
+}
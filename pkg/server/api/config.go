@@ -4,14 +4,23 @@
 package api
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/errors"
+	mgrcfg "github.com/pingcap/tiproxy/pkg/manager/config"
 )
 
+// ClusterReader reads the health of all the TiProxy instances in the cluster.
+type ClusterReader interface {
+	GetClusterHealth(ctx context.Context) ([]config.InstanceHealth, error)
+}
+
 func (h *Server) ConfigSet(c *gin.Context) {
 	data, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -23,18 +32,43 @@ func (h *Server) ConfigSet(c *gin.Context) {
 		return
 	}
 
-	if err := h.mgr.CfgMgr.SetTOMLConfig(data); err != nil {
+	// With cluster=true, the config is pushed to all the instances, or the instances with the label if label is set.
+	if strings.EqualFold(c.Query("cluster"), "true") {
+		err = h.mgr.CfgMgr.SetClusterConfig(c, c.Query("label"), data)
+	} else {
+		err = h.mgr.CfgMgr.SetTOMLConfigFrom(data, configSource(c))
+	}
+	if err != nil {
 		c.Errors = append(c.Errors, &gin.Error{
 			Type: gin.ErrorTypePrivate,
 			Err:  errors.Errorf("can not update config: %+v", err),
 		})
-		c.JSON(http.StatusInternalServerError, "can not update config")
+		if errors.Is(err, mgrcfg.ErrClusterConfigDisabled) {
+			c.JSON(http.StatusBadRequest, mgrcfg.ErrClusterConfigDisabled.Error())
+		} else {
+			c.JSON(http.StatusInternalServerError, "can not update config")
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, "")
 }
 
+// ConfigClusterGet returns the config checksums of all the instances so that users know which instances apply the
+// cluster config.
+func (h *Server) ConfigClusterGet(c *gin.Context) {
+	healths, err := h.mgr.ClusterReader.GetClusterHealth(c)
+	if err != nil {
+		c.Errors = append(c.Errors, &gin.Error{
+			Type: gin.ErrorTypePrivate,
+			Err:  errors.Errorf("can not read the cluster: %+v", err),
+		})
+		c.JSON(http.StatusInternalServerError, "can not read the cluster")
+		return
+	}
+	c.JSON(http.StatusOK, healths)
+}
+
 func (h *Server) ConfigGet(c *gin.Context) {
 	// TiDB cluster_config uses format=json, while tiproxyctl expects toml (both PUT and GET) by default.
 	// Users can choose the format on TiDB-Dashboard.
@@ -45,7 +79,58 @@ func (h *Server) ConfigGet(c *gin.Context) {
 	}
 }
 
+// ConfigValidate checks the config without applying it and reports the changed items.
+func (h *Server) ConfigValidate(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Errors = append(c.Errors, &gin.Error{
+			Type: gin.ErrorTypePrivate,
+			Err:  errors.Errorf("fail to read config: %+v", err),
+		})
+		c.JSON(http.StatusInternalServerError, "fail to read config")
+		return
+	}
+	c.JSON(http.StatusOK, h.mgr.CfgMgr.ValidateTOMLConfig(data))
+}
+
+func (h *Server) ConfigHistory(c *gin.Context) {
+	c.JSON(http.StatusOK, h.mgr.CfgMgr.GetConfigHistory())
+}
+
+func (h *Server) ConfigRollback(c *gin.Context) {
+	version, err := strconv.ParseUint(c.Param("version"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "bad version parameter")
+		return
+	}
+	if err := h.mgr.CfgMgr.RollbackConfig(version, configSource(c)); err != nil {
+		c.Errors = append(c.Errors, &gin.Error{
+			Type: gin.ErrorTypePrivate,
+			Err:  errors.Errorf("can not rollback config: %+v", err),
+		})
+		if errors.Is(err, mgrcfg.ErrNoResults) {
+			c.JSON(http.StatusNotFound, "config version not found")
+		} else {
+			c.JSON(http.StatusInternalServerError, "can not rollback config")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
+
+// configSource tells who changes the config, which is recorded in the config history.
+func configSource(c *gin.Context) string {
+	if user, _, ok := c.Request.BasicAuth(); ok {
+		return "api " + user + "@" + c.ClientIP()
+	}
+	return "api " + c.ClientIP()
+}
+
 func (h *Server) registerConfig(group *gin.RouterGroup) {
 	group.PUT("/", h.ConfigSet)
 	group.GET("/", h.ConfigGet)
+	group.GET("/cluster", h.ConfigClusterGet)
+	group.GET("/history", h.ConfigHistory)
+	group.POST("/validate", h.ConfigValidate)
+	group.POST("/rollback/:version", h.ConfigRollback)
 }
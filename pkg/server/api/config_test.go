@@ -64,6 +64,86 @@ func TestConfig(t *testing.T) {
 	})
 }
 
+func TestClusterConfig(t *testing.T) {
+	srv, doHTTP := createServer(t)
+
+	// The cluster config is disabled by default.
+	doHTTP(t, http.MethodPut, "/api/admin/config?cluster=true", httpOpts{reader: strings.NewReader("security.require-backend-tls = true")}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+	require.False(t, srv.mgr.CfgMgr.GetConfig().Security.RequireBackendTLS)
+
+	healths := []config.InstanceHealth{
+		{Addr: "127.0.0.1:3080", HealthInfo: config.HealthInfo{ConfigChecksum: 1}},
+		{Addr: "127.0.0.1:3081", Error: "timeout"},
+	}
+	srv.mgr.ClusterReader.(*mockClusterReader).healths = healths
+	doHTTP(t, http.MethodGet, "/api/admin/config/cluster", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var result []config.InstanceHealth
+		require.NoError(t, json.Unmarshal(all, &result))
+		require.Equal(t, healths, result)
+	})
+}
+
+func TestConfigHistory(t *testing.T) {
+	srv, doHTTP := createServer(t)
+
+	doHTTP(t, http.MethodPut, "/api/admin/config", httpOpts{reader: strings.NewReader("proxy.max-connections = 100")}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+	})
+	var history []config.ConfigVersion
+	doHTTP(t, http.MethodGet, "/api/admin/config/history", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(all, &history))
+	})
+	require.Len(t, history, 2)
+	require.True(t, strings.HasPrefix(history[1].Source, "api "))
+	require.Equal(t, []config.FieldDiff{{Field: "proxy.max-connections", Old: "0", New: "100"}}, history[1].Diff)
+
+	doHTTP(t, http.MethodPost, "/api/admin/config/rollback/1", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+	})
+	require.Zero(t, srv.mgr.CfgMgr.GetConfig().Proxy.MaxConnections)
+	doHTTP(t, http.MethodPost, "/api/admin/config/rollback/100", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusNotFound, r.StatusCode)
+	})
+	doHTTP(t, http.MethodPost, "/api/admin/config/rollback/abc", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	srv, doHTTP := createServer(t)
+	checksum := srv.mgr.CfgMgr.GetConfigChecksum()
+
+	var validation config.ConfigValidation
+	doHTTP(t, http.MethodPost, "/api/admin/config/validate", httpOpts{reader: strings.NewReader("proxy.max-connections = 100\nproxy.pd-addrs = '127.0.0.1:2380'")}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(all, &validation))
+	})
+	require.True(t, validation.Valid)
+	require.Equal(t, []string{"proxy.max-connections"}, validation.OnlineFields)
+	require.Equal(t, []string{"proxy.pd-addrs"}, validation.RestartFields)
+
+	validation = config.ConfigValidation{}
+	doHTTP(t, http.MethodPost, "/api/admin/config/validate", httpOpts{reader: strings.NewReader("proxy.proxy-protocol = 'v3'")}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(all, &validation))
+	})
+	require.False(t, validation.Valid)
+	require.NotEmpty(t, validation.Error)
+	require.Equal(t, checksum, srv.mgr.CfgMgr.GetConfigChecksum())
+}
+
 func TestAcceptType(t *testing.T) {
 	_, doHTTP := createServer(t)
 	checkRespContentType := func(expectedType string, r *http.Response) {
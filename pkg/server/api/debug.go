@@ -16,8 +16,12 @@ func (h *Server) DebugHealth(c *gin.Context) {
 	if h.isClosing.Load() || !h.mgr.NsMgr.Ready() {
 		status = http.StatusBadGateway
 	}
+	checksums, clusterConfig := h.mgr.CfgMgr.ClusterConfigChecksums()
 	c.JSON(status, config.HealthInfo{
-		ConfigChecksum: h.mgr.CfgMgr.GetConfigChecksum(),
+		ConfigChecksum:         h.mgr.CfgMgr.GetConfigChecksum(),
+		ClusterConfig:          clusterConfig,
+		Labels:                 h.mgr.CfgMgr.GetConfig().Labels,
+		ClusterConfigChecksums: checksums,
 	})
 }
 
@@ -11,6 +11,8 @@ import (
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/pkg/balance/metricsreader"
 	"github.com/pingcap/tiproxy/pkg/balance/observer"
+	"github.com/pingcap/tiproxy/pkg/balance/policy"
+	"github.com/pingcap/tiproxy/pkg/balance/router"
 	mconfig "github.com/pingcap/tiproxy/pkg/manager/config"
 	"github.com/pingcap/tiproxy/pkg/manager/namespace"
 	"github.com/pingcap/tiproxy/pkg/util/http"
@@ -21,6 +23,8 @@ var _ namespace.NamespaceManager = (*mockNamespaceManager)(nil)
 
 type mockNamespaceManager struct {
 	success atomic.Bool
+	history map[string][]router.BalanceRecord
+	scores  map[string]policy.BalanceScores
 }
 
 func newMockNamespaceManager() *mockNamespaceManager {
@@ -64,6 +68,14 @@ func (m *mockNamespaceManager) RedirectConnections() []error {
 	return []error{errors.New("mock error")}
 }
 
+func (m *mockNamespaceManager) BalanceHistory() map[string][]router.BalanceRecord {
+	return m.history
+}
+
+func (m *mockNamespaceManager) BalanceScores() map[string]policy.BalanceScores {
+	return m.scores
+}
+
 func (m *mockNamespaceManager) Close() error {
 	return nil
 }
@@ -74,3 +86,11 @@ func (m *mockNamespaceManager) CommitNamespaces(_ []*config.Namespace, _ []bool)
 	}
 	return errors.New("mock error")
 }
+
+type mockClusterReader struct {
+	healths []config.InstanceHealth
+}
+
+func (m *mockClusterReader) GetClusterHealth(_ context.Context) ([]config.InstanceHealth, error) {
+	return m.healths, nil
+}
@@ -4,10 +4,12 @@
 package api
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +23,7 @@ import (
 	mgrcrt "github.com/pingcap/tiproxy/pkg/manager/cert"
 	mgrcfg "github.com/pingcap/tiproxy/pkg/manager/config"
 	mgrns "github.com/pingcap/tiproxy/pkg/manager/namespace"
+	"github.com/pingcap/tiproxy/pkg/proxy/handoff"
 	"github.com/pingcap/tiproxy/pkg/proxy/proxyprotocol"
 	mgrrp "github.com/pingcap/tiproxy/pkg/sqlreplay/manager"
 	"go.uber.org/atomic"
@@ -48,10 +51,16 @@ type Managers struct {
 	CertMgr       *mgrcrt.CertManager
 	BackendReader BackendReader
 	ReplayJobMgr  mgrrp.JobManager
+	ClusterReader ClusterReader
 }
 
 type Server struct {
+	// mu protects listener and cfg, which are replaced when the API address changes.
+	mu        sync.Mutex
 	listener  net.Listener
+	cfg       config.API
+	hsrv      *http.Server
+	cancel    context.CancelFunc
 	wg        waitgroup.WaitGroup
 	limit     ratelimit.Limiter
 	ready     *atomic.Bool
@@ -84,16 +93,6 @@ func NewServer(cfg config.API, lg *zap.Logger, mgr Managers, handler HTTPHandler
 		mgr: mgr,
 	}
 
-	var err error
-	h.listener, err = net.Listen("tcp", cfg.Addr)
-	if err != nil {
-		return nil, err
-	}
-	switch cfg.ProxyProtocol {
-	case "v2":
-		h.listener = proxyprotocol.NewListener(h.listener)
-	}
-
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
 	engine.UseH2C = true
@@ -117,23 +116,112 @@ func NewServer(cfg config.API, lg *zap.Logger, mgr Managers, handler HTTPHandler
 		}
 	}
 
-	if tlscfg := mgr.CertMgr.ServerHTTPTLS(); tlscfg != nil {
-		h.listener = tls.NewListener(h.listener, tlscfg)
-	}
-
-	hsrv := http.Server{
+	h.hsrv = &http.Server{
 		Handler:           engine.Handler(),
 		ReadHeaderTimeout: DefConnTimeout,
 		IdleTimeout:       DefConnTimeout,
 	}
+	listener, err := h.listen(cfg)
+	if err != nil {
+		h.grpc.Stop()
+		return nil, err
+	}
+	h.listener, h.cfg = listener, cfg
+	h.serve(listener)
 
+	// Watch before reading the current config so that no change is missed.
+	cfgch := mgr.CfgMgr.WatchConfig()
+	current, changed := mgr.CfgMgr.GetConfig().API, false
+	var ctx context.Context
+	ctx, h.cancel = context.WithCancel(context.Background())
 	h.wg.RunWithRecover(func() {
-		lg.Info("HTTP closed", zap.Error(hsrv.Serve(h.listener)))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ach := <-cfgch:
+				if ach == nil {
+					return
+				}
+				// The config passed to NewServer may differ from the config manager, e.g. in tests,
+				// so only follow the config manager after the API config changes.
+				// Once changed, retry on every config change in case the last rebind failed.
+				if ach.API != current {
+					current, changed = ach.API, true
+				}
+				if changed {
+					h.rebind(current)
+				}
+			}
+		}
 	}, nil, h.lg)
-
 	return h, nil
 }
 
+// listen opens a listener on the API address, wrapped with the PROXY protocol and TLS if needed.
+func (h *Server) listen(cfg config.API) (net.Listener, error) {
+	// The listener may be inherited from the old process when upgrading.
+	listener, err := handoff.Listen(cfg.Addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch cfg.ProxyProtocol {
+	case "v2":
+		listener = proxyprotocol.NewListener(listener)
+	}
+	if tlscfg := h.mgr.CertMgr.ServerHTTPTLS(); tlscfg != nil {
+		listener = tls.NewListener(listener, tlscfg)
+	}
+	return listener, nil
+}
+
+func (h *Server) serve(listener net.Listener) {
+	h.wg.RunWithRecover(func() {
+		h.lg.Info("HTTP closed", zap.String("addr", listener.Addr().String()), zap.Error(h.hsrv.Serve(listener)))
+	}, nil, h.lg)
+}
+
+// rebind switches to the new API address. The new listener is opened before the old one is closed,
+// so that the old listener keeps working if the new address is unavailable.
+// The in-flight requests on the old listener are not interrupted.
+func (h *Server) rebind(cfg config.API) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.isClosing.Load() || cfg == h.cfg {
+		return
+	}
+	var listener net.Listener
+	var err error
+	if cfg.Addr == h.cfg.Addr {
+		// Only the PROXY protocol changes and the port can't be bound twice, so close the old one first.
+		if err = h.listener.Close(); err != nil {
+			h.lg.Warn("close API listener failed", zap.Error(err))
+		}
+		if listener, err = h.listen(cfg); err != nil {
+			h.lg.Error("reopen API listener failed", zap.String("addr", cfg.Addr), zap.Error(err))
+			return
+		}
+	} else {
+		if listener, err = h.listen(cfg); err != nil {
+			h.lg.Error("listen on the new API address failed, keep the old one", zap.String("addr", cfg.Addr), zap.Error(err))
+			return
+		}
+		if err = h.listener.Close(); err != nil {
+			h.lg.Warn("close API listener failed", zap.Error(err))
+		}
+	}
+	h.listener, h.cfg = listener, cfg
+	h.serve(listener)
+	h.lg.Info("API address changed", zap.String("addr", listener.Addr().String()))
+}
+
+// Addr returns the address that the API server is listening on.
+func (h *Server) Addr() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.listener.Addr().String()
+}
+
 func (h *Server) rateLimit(c *gin.Context) {
 	_ = h.limit.Take()
 }
@@ -210,6 +298,7 @@ func (h *Server) registerAPI(g *gin.RouterGroup) {
 	h.registerDebug(g.Group("debug"))
 	h.registerBackend(g.Group("backend"))
 	h.registerTraffic(g.Group("traffic"))
+	h.registerBalance(g.Group("balance"))
 }
 
 func (h *Server) PreClose() {
@@ -217,7 +306,12 @@ func (h *Server) PreClose() {
 }
 
 func (h *Server) Close() error {
+	h.cancel()
+	h.mu.Lock()
+	// Prevent rebind from opening a new listener.
+	h.isClosing.Store(true)
 	err := h.listener.Close()
+	h.mu.Unlock()
 	h.wg.Wait()
 	h.grpc.Stop()
 	return err
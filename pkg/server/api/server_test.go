@@ -7,8 +7,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/pingcap/tiproxy/lib/config"
 	"github.com/pingcap/tiproxy/lib/util/logger"
@@ -43,6 +45,7 @@ func createServer(t *testing.T) (*Server, doHTTPFunc) {
 		CertMgr:       crtmgr,
 		BackendReader: &mockBackendReader{},
 		ReplayJobMgr:  &mockReplayJobManager{},
+		ClusterReader: &mockClusterReader{},
 	}, nil, ready)
 	require.NoError(t, err)
 	t.Cleanup(func() {
@@ -73,3 +76,28 @@ func TestGrpc(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, cc.Close())
 }
+
+func TestRebind(t *testing.T) {
+	srv, _ := createServer(t)
+	oldAddr := srv.Addr()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	newAddr := listener.Addr().String()
+
+	// The new address is occupied, so the old listener is kept.
+	require.NoError(t, srv.mgr.CfgMgr.SetTOMLConfig([]byte(fmt.Sprintf("api.addr = '%s'", newAddr))))
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, oldAddr, srv.Addr())
+
+	require.NoError(t, listener.Close())
+	require.NoError(t, srv.mgr.CfgMgr.SetTOMLConfig([]byte(fmt.Sprintf("api.addr = '%s'\nproxy.max-connections = 1", newAddr))))
+	require.Eventually(t, func() bool {
+		return srv.Addr() == newAddr
+	}, 3*time.Second, 10*time.Millisecond)
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/debug/health", newAddr))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+	_, err = net.Dial("tcp", oldAddr)
+	require.Error(t, err)
+}
@@ -4,6 +4,7 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,6 +12,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"go.uber.org/zap"
 )
@@ -18,7 +21,12 @@ import (
 func (h *Server) registerTraffic(group *gin.RouterGroup) {
 	group.POST("/capture", h.TrafficCapture)
 	group.POST("/replay", h.TrafficReplay)
+	group.POST("/convert", h.TrafficConvert)
+	group.POST("/export", h.TrafficExport)
 	group.POST("/cancel", h.TrafficStop)
+	group.POST("/pause", h.TrafficPause)
+	group.POST("/resume", h.TrafficResume)
+	group.POST("/speed", h.TrafficSpeed)
 	group.GET("/show", h.TrafficShow)
 }
 
@@ -66,6 +74,7 @@ func (h *Server) TrafficCapture(c *gin.Context) {
 func (h *Server) TrafficReplay(c *gin.Context) {
 	cfg := replay.ReplayConfig{}
 	cfg.Input = c.PostForm("input")
+	cfg.Format = c.PostForm("format")
 	if speedStr := c.PostForm("speed"); speedStr != "" {
 		speed, err := strconv.ParseFloat(speedStr, 64)
 		if err != nil {
@@ -86,6 +95,18 @@ func (h *Server) TrafficReplay(c *gin.Context) {
 	}
 	cfg.Username = c.PostForm("username")
 	cfg.Password = c.PostForm("password")
+	if credentialsStr := c.PostForm("credentials"); credentialsStr != "" {
+		if err := json.Unmarshal([]byte(credentialsStr), &cfg.Credentials); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if schemaMappingStr := c.PostForm("schema-mapping"); schemaMappingStr != "" {
+		if err := json.Unmarshal([]byte(schemaMappingStr), &cfg.SchemaMapping); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	cfg.ReadOnly = strings.EqualFold(c.PostForm("readonly"), "true")
 	cfg.KeyFile = h.mgr.CfgMgr.GetConfig().Security.Encryption.KeyPath
 
@@ -96,11 +117,80 @@ func (h *Server) TrafficReplay(c *gin.Context) {
 	c.String(http.StatusOK, "replay started")
 }
 
+func (h *Server) TrafficConvert(c *gin.Context) {
+	cfg := convert.ConvertConfig{}
+	cfg.Input = c.PostForm("input")
+	cfg.Output = c.PostForm("output")
+	cfg.Format = c.PostForm("format")
+	cfg.EncryptMethod = c.PostForm("encrypt-method")
+	compress := true
+	if compressStr := c.PostForm("compress"); compressStr != "" {
+		var err error
+		if compress, err = strconv.ParseBool(compressStr); err != nil {
+			h.lg.Warn("parsing argument 'compress' error, using true", zap.String("compress", c.PostForm("compress")), zap.Error(err))
+			compress = true
+		}
+	}
+	cfg.Compress = compress
+	cfg.KeyFile = h.mgr.CfgMgr.GetConfig().Security.Encryption.KeyPath
+	cfg.StartTime = time.Now()
+
+	if err := h.mgr.ReplayJobMgr.StartConvert(cfg); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "convert started")
+}
+
+func (h *Server) TrafficExport(c *gin.Context) {
+	cfg := export.ExportConfig{}
+	cfg.Input = c.PostForm("input")
+	cfg.Output = c.PostForm("output")
+	cfg.Merge = strings.EqualFold(c.PostForm("merge"), "true")
+	cfg.KeyFile = h.mgr.CfgMgr.GetConfig().Security.Encryption.KeyPath
+	cfg.StartTime = time.Now()
+
+	if err := h.mgr.ReplayJobMgr.StartExport(cfg); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "export started")
+}
+
 func (h *Server) TrafficStop(c *gin.Context) {
 	result := h.mgr.ReplayJobMgr.Stop()
 	c.String(http.StatusOK, result)
 }
 
+func (h *Server) TrafficPause(c *gin.Context) {
+	if err := h.mgr.ReplayJobMgr.PauseReplay(); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "replay paused")
+}
+
+func (h *Server) TrafficResume(c *gin.Context) {
+	if err := h.mgr.ReplayJobMgr.ResumeReplay(); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "replay resumed")
+}
+
+func (h *Server) TrafficSpeed(c *gin.Context) {
+	speed, err := strconv.ParseFloat(c.PostForm("speed"), 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.mgr.ReplayJobMgr.SetReplaySpeed(speed); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "replay speed changed")
+}
+
 func (h *Server) TrafficShow(c *gin.Context) {
 	result := h.mgr.ReplayJobMgr.Jobs()
 	c.String(http.StatusOK, result)
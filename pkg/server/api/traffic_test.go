@@ -12,6 +12,8 @@ import (
 
 	"github.com/pingcap/tiproxy/lib/cli"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/manager"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"github.com/stretchr/testify/require"
@@ -98,6 +100,72 @@ func TestTraffic(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "strconv.ParseFloat: parsing \"abc\": invalid syntax", string(all))
 	})
+	// parse credentials error
+	doHTTP(t, http.MethodPost, "/api/traffic/replay", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"input": "/tmp", "username": "u1", "credentials": "u2"}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+	// replay succeeds with credentials
+	doHTTP(t, http.MethodPost, "/api/traffic/replay", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"input": "/tmp", "username": "u1", "password": "p1", "credentials": `{"u2":"p2"}`}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.Equal(t, map[string]string{"u2": "p2"}, mgr.replayCfg.Credentials)
+	})
+	cancelJob(t, doHTTP)
+	// replay succeeds with schema mapping
+	doHTTP(t, http.MethodPost, "/api/traffic/replay", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"input": "/tmp", "username": "u1", "schema-mapping": `{"app":"app_shadow"}`}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.Equal(t, map[string]string{"app": "app_shadow"}, mgr.replayCfg.SchemaMapping)
+	})
+	cancelJob(t, doHTTP)
+	// replay succeeds with format
+	doHTTP(t, http.MethodPost, "/api/traffic/replay", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"input": "/tmp", "username": "u1", "format": "tidb-slow-log"}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.Equal(t, "tidb-slow-log", mgr.replayCfg.Format)
+	})
+	cancelJob(t, doHTTP)
+	// convert succeeds
+	doHTTP(t, http.MethodPost, "/api/traffic/convert", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"input": "/tmp/slowlog", "output": "/tmp/traffic", "format": "tidb-slow-log",
+			"compress": "false"}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "convert started", string(all))
+		require.Equal(t, "convert", mgr.curJob)
+		startTime := mgr.convertCfg.StartTime
+		require.False(t, startTime.IsZero())
+		require.Equal(t, convert.ConvertConfig{Input: "/tmp/slowlog", Output: "/tmp/traffic", Format: "tidb-slow-log",
+			StartTime: startTime}, mgr.convertCfg)
+	})
+	cancelJob(t, doHTTP)
+	// export succeeds
+	doHTTP(t, http.MethodPost, "/api/traffic/export", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"input": "/tmp/traffic", "output": "/tmp/sql", "merge": "true"}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "export started", string(all))
+		require.Equal(t, "export", mgr.curJob)
+		startTime := mgr.exportCfg.StartTime
+		require.False(t, startTime.IsZero())
+		require.Equal(t, export.ExportConfig{Input: "/tmp/traffic", Output: "/tmp/sql", Merge: true, StartTime: startTime}, mgr.exportCfg)
+	})
+	cancelJob(t, doHTTP)
 	// replay succeeds
 	doHTTP(t, http.MethodPost, "/api/traffic/replay", httpOpts{
 		reader: cli.GetFormReader(map[string]string{"input": "/tmp", "speed": "2.0", "username": "u1", "password": "p1"}),
@@ -119,7 +187,38 @@ func TestTraffic(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "replay", string(all))
 	})
+	// pause and resume succeed
+	doHTTP(t, http.MethodPost, "/api/traffic/pause", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.True(t, mgr.paused)
+	})
+	doHTTP(t, http.MethodPost, "/api/traffic/resume", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.False(t, mgr.paused)
+	})
+	// parse speed error
+	doHTTP(t, http.MethodPost, "/api/traffic/speed", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"speed": "abc"}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	})
+	// set speed succeeds
+	doHTTP(t, http.MethodPost, "/api/traffic/speed", httpOpts{
+		reader: cli.GetFormReader(map[string]string{"speed": "5"}),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		require.Equal(t, 5.0, mgr.replayCfg.Speed)
+	})
 	cancelJob(t, doHTTP)
+	// no replay job running
+	doHTTP(t, http.MethodPost, "/api/traffic/pause", httpOpts{}, func(t *testing.T, r *http.Response) {
+		require.Equal(t, http.StatusInternalServerError, r.StatusCode)
+		all, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "no replay job running", string(all))
+	})
 }
 
 func cancelJob(t *testing.T, doHTTP doHTTPFunc) {
@@ -134,6 +233,9 @@ type mockReplayJobManager struct {
 	curJob     string
 	captureCfg capture.CaptureConfig
 	replayCfg  replay.ReplayConfig
+	convertCfg convert.ConvertConfig
+	exportCfg  export.ExportConfig
+	paused     bool
 }
 
 func (m *mockReplayJobManager) Close() {
@@ -165,7 +267,50 @@ func (m *mockReplayJobManager) StartReplay(replayCfg replay.ReplayConfig) error
 	return nil
 }
 
+func (m *mockReplayJobManager) StartConvert(convertCfg convert.ConvertConfig) error {
+	if m.curJob != "" {
+		return errors.New("job is running")
+	}
+	m.convertCfg = convertCfg
+	m.curJob = "convert"
+	return nil
+}
+
+func (m *mockReplayJobManager) StartExport(exportCfg export.ExportConfig) error {
+	if m.curJob != "" {
+		return errors.New("job is running")
+	}
+	m.exportCfg = exportCfg
+	m.curJob = "export"
+	return nil
+}
+
 func (m *mockReplayJobManager) Stop() string {
 	m.curJob = ""
+	m.paused = false
 	return "stopped"
 }
+
+func (m *mockReplayJobManager) PauseReplay() error {
+	if m.curJob != "replay" {
+		return errors.New("no replay job running")
+	}
+	m.paused = true
+	return nil
+}
+
+func (m *mockReplayJobManager) ResumeReplay() error {
+	if m.curJob != "replay" {
+		return errors.New("no replay job running")
+	}
+	m.paused = false
+	return nil
+}
+
+func (m *mockReplayJobManager) SetReplaySpeed(speed float64) error {
+	if m.curJob != "replay" {
+		return errors.New("no replay job running")
+	}
+	m.replayCfg.Speed = speed
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pingcap/tiproxy/lib/config"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/manager/infosync"
+	"github.com/pingcap/tiproxy/pkg/util/http"
+)
+
+const (
+	healthPath           = "/api/debug/health"
+	clusterHealthTimeout = 3 * time.Second
+)
+
+// clusterReader reads the health of all the TiProxy instances so that users know whether they apply the cluster config.
+type clusterReader struct {
+	infoSyncer *infosync.InfoSyncer
+	httpCli    *http.Client
+}
+
+func (cr *clusterReader) GetClusterHealth(ctx context.Context) ([]config.InstanceHealth, error) {
+	if cr.infoSyncer == nil {
+		return nil, errors.New("reading the cluster requires pd-addrs")
+	}
+	infos, err := cr.infoSyncer.GetTiProxyTopology(ctx)
+	if err != nil {
+		return nil, err
+	}
+	healths := make([]config.InstanceHealth, 0, len(infos))
+	for _, info := range infos {
+		health := config.InstanceHealth{Addr: net.JoinHostPort(info.IP, info.StatusPort)}
+		b := backoff.WithContext(backoff.WithMaxRetries(backoff.NewConstantBackOff(0), 0), ctx)
+		resp, err := cr.httpCli.Get(health.Addr, healthPath, b, clusterHealthTimeout)
+		if err == nil {
+			err = errors.WithStack(json.Unmarshal(resp, &health.HealthInfo))
+		}
+		if err != nil {
+			health.Error = err.Error()
+		}
+		healths = append(healths, health)
+	}
+	sort.Slice(healths, func(i, j int) bool {
+		return healths[i].Addr < healths[j].Addr
+	})
+	return healths, nil
+}
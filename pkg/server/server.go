@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/tiproxy/pkg/metrics"
 	"github.com/pingcap/tiproxy/pkg/proxy"
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
+	"github.com/pingcap/tiproxy/pkg/proxy/handoff"
 	"github.com/pingcap/tiproxy/pkg/sctx"
 	"github.com/pingcap/tiproxy/pkg/server/api"
 	mgrrp "github.com/pingcap/tiproxy/pkg/sqlreplay/manager"
@@ -106,6 +107,19 @@ func NewServer(ctx context.Context, sctx *sctx.Context) (srv *Server, err error)
 		return
 	}
 
+	// setup namespace storage
+	// Watch the namespaces before the storage starts so that no change is missed. The changes are committed
+	// after the namespace manager is initialized, and the namespaces that are already built by Init are skipped.
+	nsCh := srv.configManager.WatchNamespace()
+	if err = srv.configManager.InitNamespaceStorage(srv.etcdCli); err != nil {
+		return
+	}
+
+	// setup cluster config
+	if err = srv.configManager.InitClusterConfig(srv.etcdCli); err != nil {
+		return
+	}
+
 	// general cluster HTTP client
 	{
 		srv.httpCli = http.NewHTTPClient(srv.certManager.ClusterTLS)
@@ -154,6 +168,22 @@ func NewServer(ctx context.Context, sctx *sctx.Context) (srv *Server, err error)
 		if err != nil {
 			return
 		}
+
+		// Commit the namespaces once they change in the storage, no matter which instance changes them.
+		nslg := lg.Named("nsmgr")
+		srv.wg.RunWithRecover(func() {
+			for events := range nsCh {
+				nss := make([]*config.Namespace, 0, len(events))
+				nssDelete := make([]bool, 0, len(events))
+				for _, ev := range events {
+					nss = append(nss, ev.Namespace)
+					nssDelete = append(nssDelete, ev.Deleted)
+				}
+				if err := srv.namespaceManager.CommitNamespaces(nss, nssDelete); err != nil {
+					nslg.Error("commit namespaces failed", zap.Error(err))
+				}
+			}
+		}, nil, lg)
 	}
 
 	var hsHandler backend.HandshakeHandler
@@ -185,10 +215,13 @@ func NewServer(ctx context.Context, sctx *sctx.Context) (srv *Server, err error)
 		CertMgr:       srv.certManager,
 		BackendReader: srv.metricsReader,
 		ReplayJobMgr:  srv.replay,
+		ClusterReader: &clusterReader{infoSyncer: srv.infoSyncer, httpCli: srv.httpCli},
 	}
 	if srv.apiServer, err = api.NewServer(cfg.API, lg.Named("api"), mgrs, handler, ready); err != nil {
 		return
 	}
+	// All the listeners are created, close the ones inherited from the old process but not used.
+	handoff.CloseInherited()
 
 	// setup vip manager
 	{
@@ -43,9 +43,9 @@ type Capture interface {
 	// err means the error that caused the capture to stop. nil means the capture stopped manually.
 	Stop(err error)
 	// InitConn is called when a new connection is created.
-	InitConn(startTime time.Time, connID uint64, db string)
+	InitConn(startTime time.Time, connID uint64, user, db string)
 	// Capture captures traffic
-	Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error))
+	Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (user, sql string, err error))
 	// Progress returns the progress of the capture job
 	Progress() (float64, time.Time, bool, error)
 	// Close closes the capture
@@ -106,12 +106,19 @@ func (cfg *CaptureConfig) Validate() (storage.ExternalStorage, error) {
 	return storage, nil
 }
 
+// connInfo is the information of a captured connection.
+type connInfo struct {
+	user string
+	// userRecorded indicates whether the user has been written to a command.
+	userRecorded bool
+}
+
 var _ Capture = (*capture)(nil)
 
 type capture struct {
 	sync.Mutex
 	cfg          CaptureConfig
-	conns        map[uint64]struct{}
+	conns        map[uint64]*connInfo
 	wg           waitgroup.WaitGroup
 	cancel       context.CancelFunc
 	storage      storage.ExternalStorage
@@ -153,7 +160,7 @@ func (c *capture) Start(cfg CaptureConfig) error {
 	c.filteredCmds = 0
 	c.status = statusRunning
 	c.err = nil
-	c.conns = make(map[uint64]struct{})
+	c.conns = make(map[uint64]*connInfo)
 	childCtx, cancel := context.WithTimeout(context.Background(), c.cfg.Duration)
 	c.cancel = cancel
 	bufCh := make(chan *bytes.Buffer, cfg.maxBuffers)
@@ -275,12 +282,14 @@ func (c *capture) flushBuffer(bufCh <-chan *bytes.Buffer) {
 	c.writeMeta(storage, time.Since(startTime), capturedCmds, filteredCmds)
 }
 
-func (c *capture) InitConn(startTime time.Time, connID uint64, db string) {
+func (c *capture) InitConn(startTime time.Time, connID uint64, user, db string) {
 	c.Lock()
 	defer c.Unlock()
 	if c.status != statusRunning {
 		return
 	}
+	// The user will be recorded in the first command of this connection.
+	c.conns[connID] = &connInfo{user: user}
 	if db != "" {
 		packet := make([]byte, 0, len(db)+1)
 		packet = append(packet, pnet.ComInitDB.Byte())
@@ -291,10 +300,9 @@ func (c *capture) InitConn(startTime time.Time, connID uint64, db string) {
 		}
 		c.putCommand(command)
 	}
-	c.conns[connID] = struct{}{}
 }
 
-func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error)) {
+func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, string, error)) {
 	c.Lock()
 	if c.status != statusRunning {
 		c.Unlock()
@@ -310,7 +318,7 @@ func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, ini
 			return
 		}
 		// initSession is slow, do not call it in the lock.
-		sql, err := initSession()
+		user, sql, err := initSession()
 		if err != nil {
 			// Maybe the connection is in transaction or closing.
 			c.lg.Debug("failed to init session", zap.Uint64("connID", connID), zap.Error(err))
@@ -320,9 +328,10 @@ func (c *capture) Capture(packet []byte, startTime time.Time, connID uint64, ini
 		initPacket = append(initPacket, pnet.ComQuery.Byte())
 		initPacket = append(initPacket, hack.Slice(sql)...)
 		command := cmd.NewCommand(initPacket, startTime, connID)
+		command.User = user
 		c.Lock()
 		if c.putCommand(command) {
-			c.conns[connID] = struct{}{}
+			c.conns[connID] = &connInfo{user: user, userRecorded: true}
 		}
 		c.Unlock()
 	}
@@ -340,6 +349,10 @@ func (c *capture) putCommand(command *cmd.Command) bool {
 	if c.status != statusRunning {
 		return false
 	}
+	if info, ok := c.conns[command.ConnID]; ok && !info.userRecorded {
+		command.User = info.user
+		info.userRecorded = true
+	}
 	switch command.Type {
 	case pnet.ComQuit:
 		if _, ok := c.conns[command.ConnID]; ok {
@@ -403,7 +416,7 @@ func (c *capture) stopNoLock(err error) {
 	if c.err == nil {
 		c.err = err
 	}
-	c.conns = map[uint64]struct{}{}
+	c.conns = map[uint64]*connInfo{}
 }
 
 func (c *capture) stop(err error) {
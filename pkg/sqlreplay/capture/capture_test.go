@@ -99,7 +99,7 @@ func TestConcurrency(t *testing.T) {
 			case <-ctx.Done():
 				return
 			case <-time.After(100 * time.Microsecond):
-				cpt.InitConn(time.Now(), uint64(i), "abc")
+				cpt.InitConn(time.Now(), uint64(i), "u1", "abc")
 			}
 		}
 	})
@@ -246,24 +246,30 @@ func TestInitConn(t *testing.T) {
 	}
 
 	require.NoError(t, cpt.Start(cfg))
-	cpt.InitConn(time.Now(), 100, "mockDB")
-	cpt.Capture(packet, time.Now(), 100, func() (string, error) {
-		return "init session 100", nil
+	cpt.InitConn(time.Now(), 100, "u100", "mockDB")
+	cpt.Capture(packet, time.Now(), 100, func() (string, string, error) {
+		return "u100", "init session 100", nil
 	})
-	cpt.Capture(packet, time.Now(), 101, func() (string, error) {
-		return "init session fail 101", errors.New("init session fail 101")
+	cpt.Capture(packet, time.Now(), 101, func() (string, string, error) {
+		return "u101", "init session fail 101", errors.New("init session fail 101")
 	})
-	cpt.Capture(packet, time.Now(), 101, func() (string, error) {
-		return "init session 101", nil
+	cpt.Capture(packet, time.Now(), 101, func() (string, string, error) {
+		return "u101", "init session 101", nil
 	})
+	cpt.InitConn(time.Now(), 102, "u102", "")
+	cpt.Capture(packet, time.Now(), 102, nil)
 	cpt.Stop(errors.Errorf("mock error"))
 	data := string(writer.getData())
 	require.Equal(t, 1, strings.Count(data, "mockDB"))
 	require.Equal(t, 0, strings.Count(data, "init session 100"))
 	require.Equal(t, 0, strings.Count(data, "init session fail 101"))
 	require.Equal(t, 1, strings.Count(data, "init session 101"))
-	require.Equal(t, 2, strings.Count(data, "select 1"))
-	require.Equal(t, uint64(4), cpt.capturedCmds)
+	require.Equal(t, 3, strings.Count(data, "select 1"))
+	require.Equal(t, uint64(5), cpt.capturedCmds)
+	// The user is only recorded in the first command of each connection.
+	require.Equal(t, 1, strings.Count(data, "# User: u100\n"))
+	require.Equal(t, 1, strings.Count(data, "# User: u101\n"))
+	require.Equal(t, 1, strings.Count(data, "# User: u102\n"))
 }
 
 func TestQuit(t *testing.T) {
@@ -282,18 +288,18 @@ func TestQuit(t *testing.T) {
 
 	require.NoError(t, cpt.Start(cfg))
 	// 100: quit
-	cpt.Capture(quitPacket, time.Now(), 100, func() (string, error) {
-		return "init session 100", nil
+	cpt.Capture(quitPacket, time.Now(), 100, func() (string, string, error) {
+		return "mockUser", "init session 100", nil
 	})
 	// 101: select + quit + quit
-	cpt.Capture(queryPacket, time.Now(), 101, func() (string, error) {
-		return "init session 101", nil
+	cpt.Capture(queryPacket, time.Now(), 101, func() (string, string, error) {
+		return "mockUser", "init session 101", nil
 	})
-	cpt.Capture(quitPacket, time.Now(), 101, func() (string, error) {
-		return "init session 101", nil
+	cpt.Capture(quitPacket, time.Now(), 101, func() (string, string, error) {
+		return "mockUser", "init session 101", nil
 	})
-	cpt.Capture(quitPacket, time.Now(), 101, func() (string, error) {
-		return "init session 101", nil
+	cpt.Capture(quitPacket, time.Now(), 101, func() (string, string, error) {
+		return "mockUser", "init session 101", nil
 	})
 	cpt.Stop(errors.Errorf("mock error"))
 
@@ -341,8 +347,8 @@ func TestFilterCmds(t *testing.T) {
 		cfg.cmdLogger = writer
 		removeMeta(dir)
 		require.NoError(t, cpt.Start(cfg))
-		cpt.Capture(test.packet, time.Now(), 100, func() (string, error) {
-			return "init session 100", nil
+		cpt.Capture(test.packet, time.Now(), 100, func() (string, string, error) {
+			return "mockUser", "init session 100", nil
 		})
 		cpt.Stop(nil)
 
@@ -38,6 +38,6 @@ func (w *mockWriter) Close() error {
 	return nil
 }
 
-func mockInitSession() (string, error) {
-	return "init session", nil
+func mockInitSession() (string, string, error) {
+	return "mockUser", "init session", nil
 }
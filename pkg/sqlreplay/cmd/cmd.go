@@ -23,6 +23,7 @@ const (
 	commonKeySuffix = ": "
 	keyStartTs      = "# Time: "
 	keyConnID       = "# Conn_ID: "
+	keyUser         = "# User: "
 	keyType         = "# Cmd_type: "
 	keySuccess      = "# Success: "
 	keyPayloadLen   = "# Payload_len: "
@@ -45,6 +46,8 @@ type Command struct {
 	ConnID   uint64
 	Type     pnet.Command
 	Succeess bool
+	// User is the username of the captured connection. It's only set in the first command of each connection.
+	User string
 }
 
 func NewCommand(packet []byte, startTs time.Time, connID uint64) *Command {
@@ -67,6 +70,7 @@ func (c *Command) Equal(that *Command) bool {
 	}
 	return c.StartTs.Equal(that.StartTs) &&
 		c.ConnID == that.ConnID &&
+		c.User == that.User &&
 		c.Type == that.Type &&
 		c.Succeess == that.Succeess &&
 		bytes.Equal(c.Payload, that.Payload)
@@ -93,6 +97,11 @@ func (c *Command) Encode(writer *bytes.Buffer) error {
 	if err = writeString(keyConnID, strconv.FormatUint(c.ConnID, 10), writer); err != nil {
 		return err
 	}
+	if c.User != "" {
+		if err = writeString(keyUser, c.User, writer); err != nil {
+			return err
+		}
+	}
 	if c.Type != pnet.ComQuery {
 		if err = writeString(keyType, c.Type.String(), writer); err != nil {
 			return err
@@ -157,6 +166,11 @@ func (c *Command) Decode(reader LineReader) error {
 			if err != nil {
 				return errors.Errorf("%s, line %d: parsing Conn_ID failed: %s", filename, lineIdx, line)
 			}
+		case keyUser:
+			if c.User != "" {
+				return errors.Errorf("%s, line %d: redundant User: %s, User was %s", filename, lineIdx, line, c.User)
+			}
+			c.User = strings.Clone(value)
 		case keyType:
 			if c.Type != pnet.ComQuery {
 				return errors.Errorf("%s, line %d: redundant Cmd_type: %s, Cmd_type was %v", filename, lineIdx, line, c.Type)
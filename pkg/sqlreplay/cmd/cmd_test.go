@@ -16,11 +16,17 @@ func TestEncode(t *testing.T) {
 	tests := []struct {
 		payload []byte
 		cmd     pnet.Command
+		user    string
 	}{
 		{
 			cmd:     pnet.ComQuery,
 			payload: []byte("select 1"),
 		},
+		{
+			cmd:     pnet.ComInitDB,
+			payload: []byte("db"),
+			user:    "u1",
+		},
 		{
 			cmd:     pnet.ComStmtSendLongData,
 			payload: []byte{0x01, 0x02, 0x03},
@@ -44,6 +50,7 @@ func TestEncode(t *testing.T) {
 		packet := append([]byte{byte(test.cmd)}, test.payload...)
 		now := time.Now()
 		cmd := NewCommand(packet, now, 100)
+		cmd.User = test.user
 		require.NoError(t, cmd.Encode(&buf), "case %d", i)
 		cmds = append(cmds, cmd)
 	}
@@ -114,6 +121,13 @@ select 1
 		`# Time: 2024-08-28T18:51:20.477067+08:00
 # Conn_ID: 100
 # Payload_len: abc
+`,
+		`# Time: 2024-08-28T18:51:20.477067+08:00
+# Conn_ID: 100
+# User: u1
+# User: u2
+# Payload_len: 8
+select 1
 `,
 	}
 
@@ -0,0 +1,109 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+	"github.com/siddontang/go/hack"
+)
+
+const (
+	// FormatNative is the format of the traffic files captured by TiProxy.
+	FormatNative = "native"
+	// FormatTiDBSlowLog is the format of TiDB slow log files.
+	FormatTiDBSlowLog = "tidb-slow-log"
+	// FormatMySQLGeneralLog is the format of MySQL general log files.
+	FormatMySQLGeneralLog = "mysql-general-log"
+)
+
+// CmdDecoder decodes commands from a LineReader. A decoder may keep states across commands, so it can't be shared.
+type CmdDecoder interface {
+	Decode(reader LineReader) (*Command, error)
+}
+
+// NewCmdDecoder creates a decoder for the format. An empty format means FormatNative.
+func NewCmdDecoder(format string) (CmdDecoder, error) {
+	switch format {
+	case "", FormatNative:
+		return &nativeDecoder{}, nil
+	case FormatTiDBSlowLog:
+		return newSlowLogDecoder(), nil
+	case FormatMySQLGeneralLog:
+		return newGeneralLogDecoder(), nil
+	}
+	return nil, errors.Errorf("unsupported format: %s", format)
+}
+
+var _ CmdDecoder = (*nativeDecoder)(nil)
+
+type nativeDecoder struct{}
+
+func (d *nativeDecoder) Decode(reader LineReader) (*Command, error) {
+	command := &Command{}
+	if err := command.Decode(reader); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// logConn is the state of a connection in the logs.
+type logConn struct {
+	user string
+	db   string
+	// userRecorded indicates whether the user has been attached to a command.
+	userRecorded bool
+}
+
+// logConns records the states of the connections in the logs. They are used to generate the commands that are not
+// logged explicitly, such as COM_INIT_DB, and to attach the user to the first command of each connection.
+type logConns map[uint64]*logConn
+
+// connect records the user of a new connection.
+func (lc logConns) connect(connID uint64, user string) {
+	lc[connID] = &logConn{user: user}
+}
+
+// appendCommands appends the command to cmds. If the database of the connection changes, a COM_INIT_DB is prepended.
+// An empty db means the database is unknown.
+func (lc logConns) appendCommands(cmds []*Command, startTs time.Time, connID uint64, user, db string, tp pnet.Command,
+	data string, success bool) []*Command {
+	// Avoid password leakage, the same as capture.
+	if tp == pnet.ComQuery && lex.IsSensitiveSQL(data) {
+		return cmds
+	}
+	conn, ok := lc[connID]
+	if !ok {
+		conn = &logConn{user: user}
+		lc[connID] = conn
+	}
+	start := len(cmds)
+	if db != "" && db != conn.db && tp != pnet.ComInitDB {
+		cmds = append(cmds, newLogCommand(pnet.ComInitDB, db, startTs, connID, true))
+		conn.db = db
+	}
+	cmds = append(cmds, newLogCommand(tp, data, startTs, connID, success))
+	if !conn.userRecorded {
+		cmds[start].User = conn.user
+		conn.userRecorded = true
+	}
+	switch tp {
+	case pnet.ComInitDB:
+		if success {
+			conn.db = data
+		}
+	case pnet.ComQuit:
+		delete(lc, connID)
+	}
+	return cmds
+}
+
+func newLogCommand(tp pnet.Command, data string, startTs time.Time, connID uint64, success bool) *Command {
+	command := NewCommand(append([]byte{tp.Byte()}, hack.Slice(data)...), startTs, connID)
+	command.Succeess = success
+	return command
+}
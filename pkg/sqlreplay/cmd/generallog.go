@@ -0,0 +1,172 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
+
+const (
+	// generalLogTimeLayout56 is the time layout of MySQL 5.6 and earlier versions, which is in local time.
+	generalLogTimeLayout56 = "060102 15:04:05"
+	generalLogAccessDenied = "Access denied"
+)
+
+// generalLogEntryRegexp matches the first line of an entry: `time\t  id Command\targument`.
+// MySQL 5.6 omits the time if it's the same as the previous entry.
+var generalLogEntryRegexp = regexp.MustCompile(`^(\d{6} [ \d]\d:\d\d:\d\d|\S+)?\t+ *(\d+) ([A-Za-z][A-Za-z ]*?)(?:\t(.*))?$`)
+
+var _ CmdDecoder = (*generalLogDecoder)(nil)
+
+// generalLogDecoder decodes MySQL general logs. The entries look like:
+//
+//	2024-08-28T10:51:20.477067Z	    8 Connect	root@localhost on test using Socket
+//	2024-08-28T10:51:20.477300Z	    8 Query	select 1
+//	2024-08-28T10:51:27.000000Z	    8 Quit
+//
+// A query may span multiple lines. The server headers are skipped.
+type generalLogDecoder struct {
+	conns       logConns
+	pendingCmds []*Command
+	// The first line of the next entry has been read when reading the current entry.
+	nextMatches  []string
+	nextFileName string
+	nextLineIdx  int
+	// lastTs is used when the time is omitted.
+	lastTs time.Time
+	eof    bool
+}
+
+func newGeneralLogDecoder() *generalLogDecoder {
+	return &generalLogDecoder{
+		conns: make(logConns),
+	}
+}
+
+func (d *generalLogDecoder) Decode(reader LineReader) (*Command, error) {
+	for len(d.pendingCmds) == 0 {
+		if err := d.decodeEntry(reader); err != nil {
+			return nil, err
+		}
+	}
+	command := d.pendingCmds[0]
+	d.pendingCmds = d.pendingCmds[1:]
+	return command, nil
+}
+
+func (d *generalLogDecoder) decodeEntry(reader LineReader) error {
+	if d.eof && d.nextMatches == nil {
+		return io.EOF
+	}
+	matches, filename, lineIdx := d.nextMatches, d.nextFileName, d.nextLineIdx
+	d.nextMatches = nil
+	var argLines []string
+	if matches != nil {
+		argLines = append(argLines, matches[4])
+	}
+	for !d.eof {
+		line, curFilename, curLineIdx, err := reader.ReadLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			d.eof = true
+			break
+		}
+		str := string(line)
+		if curMatches := generalLogEntryRegexp.FindStringSubmatch(str); curMatches != nil {
+			if matches == nil {
+				matches, filename, lineIdx = curMatches, curFilename, curLineIdx
+				argLines = append(argLines, curMatches[4])
+				continue
+			}
+			d.nextMatches, d.nextFileName, d.nextLineIdx = curMatches, curFilename, curLineIdx
+			break
+		}
+		// Skip the server headers before the first entry.
+		if matches == nil {
+			continue
+		}
+		argLines = append(argLines, str)
+	}
+	if matches == nil {
+		return io.EOF
+	}
+
+	startTs, err := d.parseTime(matches[1])
+	if err != nil {
+		return errors.Errorf("%s, line %d: parsing time failed: %s", filename, lineIdx, matches[1])
+	}
+	connID, err := strconv.ParseUint(matches[2], 10, 64)
+	if err != nil {
+		return errors.Errorf("%s, line %d: parsing connection ID failed: %s", filename, lineIdx, matches[2])
+	}
+	arg := strings.TrimSpace(strings.Join(argLines, "\n"))
+	switch matches[3] {
+	case "Connect":
+		if strings.HasPrefix(arg, generalLogAccessDenied) {
+			return nil
+		}
+		user, db := parseGeneralLogConnect(arg)
+		d.conns.connect(connID, user)
+		if db != "" {
+			d.pendingCmds = d.conns.appendCommands(d.pendingCmds, startTs, connID, user, "", pnet.ComInitDB, db, true)
+		}
+	case "Query", "Execute":
+		if arg != "" {
+			d.pendingCmds = d.conns.appendCommands(d.pendingCmds, startTs, connID, "", "", pnet.ComQuery, arg, true)
+		}
+	case "Init DB":
+		d.pendingCmds = d.conns.appendCommands(d.pendingCmds, startTs, connID, "", "", pnet.ComInitDB, arg, true)
+	case "Ping":
+		d.pendingCmds = d.conns.appendCommands(d.pendingCmds, startTs, connID, "", "", pnet.ComPing, "", true)
+	case "Quit":
+		d.pendingCmds = d.conns.appendCommands(d.pendingCmds, startTs, connID, "", "", pnet.ComQuit, "", true)
+	}
+	return nil
+}
+
+// parseTime parses the time in RFC3339 (MySQL 5.7+) or `YYMMDD hh:mm:ss` (MySQL 5.6).
+func (d *generalLogDecoder) parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return d.lastTs, nil
+	}
+	var ts time.Time
+	var err error
+	if len(str) == len(generalLogTimeLayout56) && str[6] == ' ' {
+		// The hour is padded with a space.
+		str = strings.Replace(str, "  ", " 0", 1)
+		ts, err = time.ParseInLocation(generalLogTimeLayout56, str, time.Local)
+	} else {
+		ts, err = time.Parse(time.RFC3339Nano, str)
+	}
+	if err != nil {
+		return ts, err
+	}
+	d.lastTs = ts
+	return ts, nil
+}
+
+// parseGeneralLogConnect parses the argument of Connect: `user@host on db using TCP/IP`.
+func parseGeneralLogConnect(arg string) (user, db string) {
+	user = arg
+	if idx := strings.IndexByte(arg, '@'); idx >= 0 {
+		user = arg[:idx]
+	}
+	if idx := strings.Index(arg, " on "); idx >= 0 {
+		db = arg[idx+len(" on "):]
+		if idx := strings.Index(db, " using "); idx >= 0 {
+			db = db[:idx]
+		}
+		db = strings.TrimSpace(db)
+	}
+	return user, db
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeGeneralLog(t *testing.T) {
+	data := "/usr/sbin/mysqld, Version: 8.0.39 (MySQL Community Server - GPL). started with:\n" +
+		"Tcp port: 3306  Unix socket: /tmp/mysql.sock\n" +
+		"Time                 Id Command    Argument\n" +
+		"2024-08-28T10:51:20.000000Z\t    8 Connect\troot@localhost on test using Socket\n" +
+		"2024-08-28T10:51:20.100000Z\t    8 Query\tselect @@version_comment limit 1\n" +
+		"2024-08-28T10:51:21.000000Z\t    9 Connect\tu1@localhost on  using TCP/IP\n" +
+		"2024-08-28T10:51:21.500000Z\t    9 Connect\tAccess denied for user 'u2'@'localhost' (using password: YES)\n" +
+		"2024-08-28T10:51:22.000000Z\t    8 Query\tselect *\nfrom t\n" +
+		"2024-08-28T10:51:23.000000Z\t    9 Init DB\tdb1\n" +
+		"2024-08-28T10:51:24.000000Z\t    9 Prepare\tselect ?\n" +
+		"2024-08-28T10:51:24.100000Z\t    9 Execute\tselect 1\n" +
+		"2024-08-28T10:51:25.000000Z\t    9 Quit\t\n" +
+		"2024-08-28T10:51:26.000000Z\t    8 Quit\n"
+	startTs, err := time.Parse(time.RFC3339, "2024-08-28T10:51:20Z")
+	require.NoError(t, err)
+	expected := []struct {
+		tp      pnet.Command
+		connID  uint64
+		user    string
+		data    string
+		startTs time.Time
+	}{
+		{tp: pnet.ComInitDB, connID: 8, user: "root", data: "test", startTs: startTs},
+		{tp: pnet.ComQuery, connID: 8, data: "select @@version_comment limit 1", startTs: startTs.Add(100 * time.Millisecond)},
+		{tp: pnet.ComQuery, connID: 8, data: "select *\nfrom t", startTs: startTs.Add(2 * time.Second)},
+		{tp: pnet.ComInitDB, connID: 9, user: "u1", data: "db1", startTs: startTs.Add(3 * time.Second)},
+		{tp: pnet.ComQuery, connID: 9, data: "select 1", startTs: startTs.Add(4100 * time.Millisecond)},
+		{tp: pnet.ComQuit, connID: 9, startTs: startTs.Add(5 * time.Second)},
+		{tp: pnet.ComQuit, connID: 8, startTs: startTs.Add(6 * time.Second)},
+	}
+
+	decoder, err := NewCmdDecoder(FormatMySQLGeneralLog)
+	require.NoError(t, err)
+	mr := mockReader{data: []byte(data)}
+	for i, exp := range expected {
+		command, err := decoder.Decode(&mr)
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, exp.tp, command.Type, "case %d", i)
+		require.Equal(t, exp.connID, command.ConnID, "case %d", i)
+		require.Equal(t, exp.user, command.User, "case %d", i)
+		require.Equal(t, exp.data, string(command.Payload[1:]), "case %d", i)
+		require.True(t, exp.startTs.Equal(command.StartTs), "case %d", i)
+	}
+	_, err = decoder.Decode(&mr)
+	require.True(t, errors.Is(err, io.EOF), err)
+}
+
+func TestDecodeGeneralLog56(t *testing.T) {
+	data := "240828  9:51:20\t    8 Connect\troot@localhost on test\n" +
+		"\t\t    8 Query\tselect 1\n" +
+		"240828 10:51:21\t    8 Query\tselect 2\n"
+	startTs := time.Date(2024, 8, 28, 9, 51, 20, 0, time.Local)
+	expected := []struct {
+		tp      pnet.Command
+		data    string
+		startTs time.Time
+	}{
+		{tp: pnet.ComInitDB, data: "test", startTs: startTs},
+		{tp: pnet.ComQuery, data: "select 1", startTs: startTs},
+		{tp: pnet.ComQuery, data: "select 2", startTs: startTs.Add(time.Hour + time.Second)},
+	}
+
+	decoder := newGeneralLogDecoder()
+	mr := mockReader{data: []byte(data)}
+	for i, exp := range expected {
+		command, err := decoder.Decode(&mr)
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, exp.tp, command.Type, "case %d", i)
+		require.Equal(t, exp.data, string(command.Payload[1:]), "case %d", i)
+		require.True(t, exp.startTs.Equal(command.StartTs), "case %d", i)
+	}
+	_, err := decoder.Decode(&mr)
+	require.True(t, errors.Is(err, io.EOF), err)
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+)
+
+const (
+	slowLogKeyTime      = "# Time: "
+	slowLogKeyUserHost  = "# User@Host: "
+	slowLogKeyConnID    = "# Conn_ID: "
+	slowLogKeyQueryTime = "# Query_time: "
+	slowLogKeyDB        = "# DB: "
+	slowLogKeyInternal  = "# Is_internal: "
+	slowLogKeyPrepared  = "# Prepared: "
+	slowLogKeySucc      = "# Succ: "
+)
+
+var _ CmdDecoder = (*slowLogDecoder)(nil)
+
+// slowLogDecoder decodes TiDB slow logs. Each entry looks like:
+//
+//	# Time: 2024-08-28T18:51:20.477067+08:00
+//	# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+//	# Conn_ID: 100
+//	# Query_time: 1.527627037
+//	# DB: test
+//	...
+//	use test;
+//	select * from t;
+//
+// Only the statements are replayed, so internal statements and the executions of prepared statements are skipped.
+// The time in the slow log is the end time, so the start time is calculated by subtracting the query time.
+type slowLogDecoder struct {
+	conns logConns
+	// One entry may generate multiple commands, so the commands are cached.
+	pendingCmds []*Command
+	// The time line of the next entry has been read when reading the current entry.
+	nextTimeLine string
+	nextFileName string
+	nextLineIdx  int
+	eof          bool
+}
+
+func newSlowLogDecoder() *slowLogDecoder {
+	return &slowLogDecoder{
+		conns: make(logConns),
+	}
+}
+
+func (d *slowLogDecoder) Decode(reader LineReader) (*Command, error) {
+	for len(d.pendingCmds) == 0 {
+		if err := d.decodeEntry(reader); err != nil {
+			return nil, err
+		}
+	}
+	command := d.pendingCmds[0]
+	d.pendingCmds = d.pendingCmds[1:]
+	return command, nil
+}
+
+func (d *slowLogDecoder) decodeEntry(reader LineReader) error {
+	if d.eof && d.nextTimeLine == "" {
+		return io.EOF
+	}
+	timeLine, filename, lineIdx := d.nextTimeLine, d.nextFileName, d.nextLineIdx
+	d.nextTimeLine = ""
+	headers := make(map[string]string)
+	var sqlLines []string
+	for !d.eof {
+		line, curFilename, curLineIdx, err := reader.ReadLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			d.eof = true
+			break
+		}
+		str := string(line)
+		if strings.HasPrefix(str, slowLogKeyTime) {
+			if timeLine == "" {
+				timeLine, filename, lineIdx = str, curFilename, curLineIdx
+				continue
+			}
+			d.nextTimeLine, d.nextFileName, d.nextLineIdx = str, curFilename, curLineIdx
+			break
+		}
+		// Skip the lines before the first entry.
+		if timeLine == "" {
+			continue
+		}
+		if len(sqlLines) == 0 && strings.HasPrefix(str, commonKeyPrefix) {
+			if idx := strings.Index(str, commonKeySuffix); idx >= 0 {
+				idx += len(commonKeySuffix)
+				headers[str[:idx]] = str[idx:]
+			}
+			continue
+		}
+		sqlLines = append(sqlLines, str)
+	}
+	if timeLine == "" {
+		return io.EOF
+	}
+
+	endTs, err := time.Parse(time.RFC3339Nano, timeLine[len(slowLogKeyTime):])
+	if err != nil {
+		return errors.Errorf("%s, line %d: parsing Time failed: %s", filename, lineIdx, timeLine)
+	}
+	if headers[slowLogKeyInternal] == "true" || headers[slowLogKeyPrepared] == "true" {
+		return nil
+	}
+	connIDStr, ok := headers[slowLogKeyConnID]
+	if !ok {
+		return nil
+	}
+	connID, err := strconv.ParseUint(connIDStr, 10, 64)
+	if err != nil {
+		return errors.Errorf("%s, line %d: parsing Conn_ID failed: %s", filename, lineIdx, connIDStr)
+	}
+	if connID == 0 {
+		return nil
+	}
+	startTs := endTs
+	if queryTime, err := strconv.ParseFloat(headers[slowLogKeyQueryTime], 64); err == nil {
+		startTs = endTs.Add(-time.Duration(queryTime * float64(time.Second)))
+	}
+	// TiDB writes `use db;` before the statement when the current database changes, but the database is also
+	// recorded in the DB field.
+	if len(sqlLines) > 1 && strings.HasPrefix(strings.ToLower(sqlLines[0]), "use ") && strings.HasSuffix(sqlLines[0], ";") {
+		sqlLines = sqlLines[1:]
+	}
+	sql := strings.TrimSpace(strings.Join(sqlLines, "\n"))
+	sql = strings.TrimSpace(strings.TrimSuffix(sql, ";"))
+	if sql == "" {
+		return nil
+	}
+	// The format is `user[user] @ host [ip]`.
+	user := headers[slowLogKeyUserHost]
+	if idx := strings.IndexByte(user, '['); idx >= 0 {
+		user = user[:idx]
+	}
+	success := headers[slowLogKeySucc] != "false"
+	d.pendingCmds = d.conns.appendCommands(d.pendingCmds, startTs, connID, user, headers[slowLogKeyDB], pnet.ComQuery, sql, success)
+	return nil
+}
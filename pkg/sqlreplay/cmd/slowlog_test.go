@@ -0,0 +1,111 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeSlowLog(t *testing.T) {
+	data := `/usr/local/bin/tidb-server
+# Time: 2024-08-28T18:51:21.5+08:00
+# Txn_start_ts: 452225311196823554
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 100
+# Query_time: 1.5
+# DB: test
+# Is_internal: false
+# Prepared: false
+# Succ: true
+use test;
+select * from t
+where a = 1;
+# Time: 2024-08-28T18:51:22+08:00
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 0
+# Query_time: 1
+# Is_internal: true
+select 1;
+# Time: 2024-08-28T18:51:23+08:00
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 100
+# Query_time: 1
+# DB: test
+# Prepared: true
+select * from t where a = ? [arguments: 1];
+# Time: 2024-08-28T18:51:24+08:00
+# User@Host: u1[u1] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 101
+# Query_time: 0.5
+# DB: db1
+# Succ: false
+insert into t values(1);
+# Time: 2024-08-28T18:51:25+08:00
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 100
+# Query_time: 1
+# DB: test
+# Succ: true
+select 2;
+`
+	startTs, err := time.Parse(time.RFC3339, "2024-08-28T18:51:20+08:00")
+	require.NoError(t, err)
+	expected := []struct {
+		tp      pnet.Command
+		connID  uint64
+		user    string
+		data    string
+		startTs time.Time
+		success bool
+	}{
+		{tp: pnet.ComInitDB, connID: 100, user: "root", data: "test", startTs: startTs, success: true},
+		{tp: pnet.ComQuery, connID: 100, data: "select * from t\nwhere a = 1", startTs: startTs, success: true},
+		{tp: pnet.ComInitDB, connID: 101, user: "u1", data: "db1", startTs: startTs.Add(3500 * time.Millisecond), success: true},
+		{tp: pnet.ComQuery, connID: 101, data: "insert into t values(1)", startTs: startTs.Add(3500 * time.Millisecond), success: false},
+		{tp: pnet.ComQuery, connID: 100, data: "select 2", startTs: startTs.Add(4 * time.Second), success: true},
+	}
+
+	decoder, err := NewCmdDecoder(FormatTiDBSlowLog)
+	require.NoError(t, err)
+	mr := mockReader{data: []byte(data)}
+	for i, exp := range expected {
+		command, err := decoder.Decode(&mr)
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, exp.tp, command.Type, "case %d", i)
+		require.Equal(t, exp.connID, command.ConnID, "case %d", i)
+		require.Equal(t, exp.user, command.User, "case %d", i)
+		require.Equal(t, exp.data, string(command.Payload[1:]), "case %d", i)
+		require.True(t, exp.startTs.Equal(command.StartTs), "case %d", i)
+		require.Equal(t, exp.success, command.Succeess, "case %d", i)
+	}
+	_, err = decoder.Decode(&mr)
+	require.True(t, errors.Is(err, io.EOF), err)
+}
+
+func TestDecodeSlowLogError(t *testing.T) {
+	tests := []string{
+		`# Time: 100
+# Conn_ID: 100
+select 1;
+`,
+		`# Time: 2024-08-28T18:51:20.477067+08:00
+# Conn_ID: abc
+select 1;
+`,
+	}
+
+	for i, test := range tests {
+		decoder := newSlowLogDecoder()
+		mr := mockReader{data: []byte(test)}
+		_, err := decoder.Decode(&mr)
+		require.Error(t, err, "case %d", i)
+		require.False(t, errors.Is(err, io.EOF), "case %d", i)
+	}
+}
@@ -40,7 +40,8 @@ type Conn interface {
 	Stop()
 }
 
-type ConnCreator func(connID uint64) Conn
+// ConnCreator creates a connection that connects to the backend with the username and password.
+type ConnCreator func(connID uint64, username, password string) Conn
 
 var _ Conn = (*conn)(nil)
 
@@ -0,0 +1,285 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb/br/pkg/storage"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/store"
+	"go.uber.org/zap"
+)
+
+const (
+	flushThreshold = 1 << 22 // 4MB
+)
+
+// Convert converts the logs of other formats, such as TiDB slow logs and MySQL general logs, into a capture
+// directory so that they can be replayed in the same way as the captured traffic.
+type Convert interface {
+	// Start starts the conversion
+	Start(cfg ConvertConfig) error
+	// Stop stops the conversion
+	Stop(err error)
+	// Progress returns the progress of the conversion job
+	Progress() (float64, time.Time, bool, error)
+	// Close closes the conversion
+	Close()
+}
+
+type ConvertConfig struct {
+	Input  string
+	Output string
+	// Format is the format of the input files.
+	Format        string
+	EncryptMethod string
+	KeyFile       string
+	Compress      bool
+	// StartTime acts as the job ID.
+	StartTime time.Time
+}
+
+func (cfg *ConvertConfig) Validate() (storage.ExternalStorage, storage.ExternalStorage, error) {
+	if cfg.Input == "" {
+		return nil, nil, errors.New("input is required")
+	}
+	if cfg.Output == "" {
+		return nil, nil, errors.New("output is required")
+	}
+	if cfg.Format == "" || cfg.Format == cmd.FormatNative {
+		return nil, nil, errors.New("format is required")
+	}
+	if _, err := cmd.NewCmdDecoder(cfg.Format); err != nil {
+		return nil, nil, err
+	}
+	if cfg.StartTime.IsZero() {
+		return nil, nil, errors.New("start time is not specified")
+	}
+	inStorage, err := store.NewStorage(cfg.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	outStorage, err := store.NewStorage(cfg.Output)
+	if err != nil {
+		return inStorage, nil, err
+	}
+	if err = store.PreCheckMeta(outStorage); err != nil {
+		return inStorage, outStorage, err
+	}
+	return inStorage, outStorage, nil
+}
+
+var _ Convert = (*convert)(nil)
+
+type convert struct {
+	sync.Mutex
+	cfg           ConvertConfig
+	inStorage     storage.ExternalStorage
+	outStorage    storage.ExternalStorage
+	wg            waitgroup.WaitGroup
+	cancel        context.CancelFunc
+	err           error
+	startTime     time.Time
+	endTime       time.Time
+	progress      float64
+	convertedCmds uint64
+	lg            *zap.Logger
+}
+
+func NewConvert(lg *zap.Logger) *convert {
+	return &convert{
+		lg: lg,
+	}
+}
+
+func (c *convert) Start(cfg ConvertConfig) error {
+	inStorage, outStorage, err := cfg.Validate()
+	if err != nil {
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if !c.startTime.IsZero() {
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+		return errors.Errorf("traffic conversion is running, start time: %s", c.startTime.String())
+	}
+	c.cfg = cfg
+	c.inStorage = inStorage
+	c.outStorage = outStorage
+	c.startTime = cfg.StartTime
+	c.endTime = time.Time{}
+	c.progress = 0
+	c.convertedCmds = 0
+	c.err = nil
+	childCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.RunWithRecover(func() {
+		c.run(childCtx)
+	}, nil, c.lg)
+	return nil
+}
+
+func (c *convert) run(ctx context.Context) {
+	duration, err := c.convert(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = errors.New("conversion is canceled")
+	}
+	if err == nil {
+		c.Lock()
+		cmds := c.convertedCmds
+		c.Unlock()
+		meta := store.NewMeta(duration, cmds, 0, c.cfg.EncryptMethod)
+		err = meta.Write(c.outStorage)
+	}
+	c.stop(err)
+}
+
+// convert decodes all the commands from the input and writes them to the output.
+// It returns the duration between the first and the last command.
+func (c *convert) convert(ctx context.Context) (time.Duration, error) {
+	reader, err := store.NewReader(c.lg.Named("loader"), c.inStorage, store.ReaderCfg{
+		Dir:    c.cfg.Input,
+		Format: c.cfg.Format,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	decoder, err := cmd.NewCmdDecoder(c.cfg.Format)
+	if err != nil {
+		return 0, err
+	}
+	writer, err := store.NewWriter(c.lg.Named("writer"), c.outStorage, store.WriterCfg{
+		Dir:           c.cfg.Output,
+		EncryptMethod: c.cfg.EncryptMethod,
+		KeyFile:       c.cfg.KeyFile,
+		Compress:      c.cfg.Compress,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			c.lg.Warn("failed to close writer", zap.Error(err))
+		}
+	}()
+
+	// The commands are written in the order of the logs rather than the start time. The start time in slow logs
+	// is calculated from the end time so it may be out of order across connections, but it's in order within a
+	// connection.
+	var firstTs, lastTs time.Time
+	var buf bytes.Buffer
+	for ctx.Err() == nil {
+		command, err := decoder.Decode(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, err
+		}
+		if firstTs.IsZero() || command.StartTs.Before(firstTs) {
+			firstTs = command.StartTs
+		}
+		if command.StartTs.After(lastTs) {
+			lastTs = command.StartTs
+		}
+		if err := command.Encode(&buf); err != nil {
+			return 0, errors.Wrapf(err, "failed to encode command")
+		}
+		c.Lock()
+		c.convertedCmds++
+		c.Unlock()
+		if buf.Len() > flushThreshold {
+			if _, err := writer.Write(buf.Bytes()); err != nil {
+				return 0, errors.Wrapf(err, "failed to write traffic")
+			}
+			buf.Reset()
+		}
+	}
+	if buf.Len() > 0 {
+		if _, err := writer.Write(buf.Bytes()); err != nil {
+			return 0, errors.Wrapf(err, "failed to write traffic")
+		}
+	}
+	return lastTs.Sub(firstTs), nil
+}
+
+// Progress returns 0 until the conversion finishes because the total size of the input is unknown.
+func (c *convert) Progress() (float64, time.Time, bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.progress, c.endTime, c.startTime.IsZero(), c.err
+}
+
+func (c *convert) stop(err error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.startTime.IsZero() {
+		return
+	}
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.endTime = time.Now()
+	if c.err == nil {
+		c.err = err
+	}
+	fields := []zap.Field{
+		zap.Time("start_time", c.startTime),
+		zap.Time("end_time", c.endTime),
+		zap.String("input", c.cfg.Input),
+		zap.String("format", c.cfg.Format),
+		zap.Uint64("converted_cmds", c.convertedCmds),
+	}
+	if c.err != nil {
+		fields = append(fields, zap.Error(c.err))
+		c.lg.Error("conversion failed", fields...)
+	} else {
+		c.progress = 1
+		c.lg.Info("conversion finished", fields...)
+	}
+	c.startTime = time.Time{}
+	closeStorage(c.inStorage)
+	closeStorage(c.outStorage)
+	c.inStorage, c.outStorage = nil, nil
+}
+
+func (c *convert) Stop(err error) {
+	c.Lock()
+	// already stopped
+	if c.startTime.IsZero() {
+		c.Unlock()
+		return
+	}
+	c.err = err
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.Unlock()
+	c.wg.Wait()
+}
+
+func (c *convert) Close() {
+	c.Stop(errors.New("shutting down"))
+}
+
+func closeStorage(s storage.ExternalStorage) {
+	if s != nil && !reflect.ValueOf(s).IsNil() {
+		s.Close()
+	}
+}
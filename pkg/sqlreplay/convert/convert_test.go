@@ -0,0 +1,141 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package convert
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCfg(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	cfgs := []ConvertConfig{
+		{
+			Output:    dir,
+			Format:    cmd.FormatTiDBSlowLog,
+			StartTime: now,
+		},
+		{
+			Input:     dir,
+			Format:    cmd.FormatTiDBSlowLog,
+			StartTime: now,
+		},
+		{
+			Input:     dir,
+			Output:    dir,
+			StartTime: now,
+		},
+		{
+			Input:     dir,
+			Output:    dir,
+			Format:    cmd.FormatNative,
+			StartTime: now,
+		},
+		{
+			Input:     dir,
+			Output:    dir,
+			Format:    "unknown",
+			StartTime: now,
+		},
+		{
+			Input:  dir,
+			Output: dir,
+			Format: cmd.FormatTiDBSlowLog,
+		},
+	}
+
+	for i, cfg := range cfgs {
+		inStorage, outStorage, err := cfg.Validate()
+		require.Error(t, err, "case %d", i)
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+	}
+}
+
+func TestConvertSlowLog(t *testing.T) {
+	inDir, outDir := t.TempDir(), t.TempDir()
+	slowLogs := []string{
+		`# Time: 2024-08-28T18:51:21+08:00
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 100
+# Query_time: 1
+# DB: test
+select 1;
+`,
+		`# Time: 2024-08-28T18:51:25+08:00
+# User@Host: root[root] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 100
+# Query_time: 1
+# DB: test
+select 2;
+`,
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(inDir, "tidb-slow-2024-08-28T18-52-00.000.log"), []byte(slowLogs[0]), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(inDir, "tidb-slow.log"), []byte(slowLogs[1]), 0600))
+
+	lg, _ := logger.CreateLoggerForTest(t)
+	c := NewConvert(lg)
+	defer c.Close()
+	require.NoError(t, c.Start(ConvertConfig{
+		Input:     inDir,
+		Output:    outDir,
+		Format:    cmd.FormatTiDBSlowLog,
+		StartTime: time.Now(),
+	}))
+	require.Eventually(t, func() bool {
+		_, _, done, _ := c.Progress()
+		return done
+	}, 5*time.Second, 10*time.Millisecond)
+	progress, endTime, _, err := c.Progress()
+	require.NoError(t, err)
+	require.Equal(t, 1.0, progress)
+	require.False(t, endTime.IsZero())
+
+	outStorage, err := store.NewStorage(outDir)
+	require.NoError(t, err)
+	defer outStorage.Close()
+	var meta store.Meta
+	require.NoError(t, meta.Read(outStorage))
+	require.EqualValues(t, 3, meta.Cmds)
+	require.Equal(t, 4*time.Second, meta.Duration)
+
+	reader, err := store.NewReader(lg, outStorage, store.ReaderCfg{Dir: outDir})
+	require.NoError(t, err)
+	defer reader.Close()
+	expected := []struct {
+		tp   pnet.Command
+		data string
+	}{
+		{tp: pnet.ComInitDB, data: "test"},
+		{tp: pnet.ComQuery, data: "select 1"},
+		{tp: pnet.ComQuery, data: "select 2"},
+	}
+	for i, exp := range expected {
+		command := &cmd.Command{}
+		require.NoError(t, command.Decode(reader), "case %d", i)
+		require.Equal(t, exp.tp, command.Type, "case %d", i)
+		require.Equal(t, exp.data, string(command.Payload[1:]), "case %d", i)
+	}
+	err = (&cmd.Command{}).Decode(reader)
+	require.True(t, errors.Is(err, io.EOF), err)
+
+	// The output already has a meta.
+	require.Error(t, c.Start(ConvertConfig{
+		Input:     inDir,
+		Output:    outDir,
+		Format:    cmd.FormatTiDBSlowLog,
+		StartTime: time.Now(),
+	}))
+}
@@ -0,0 +1,323 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb/br/pkg/storage"
+	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/lib/util/waitgroup"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/store"
+	"go.uber.org/zap"
+)
+
+const (
+	mergedFileName = "traffic.sql"
+	connFileFormat = "conn-%d.sql"
+	opTimeout      = 10 * time.Second
+	bufferSize     = 1 << 16
+)
+
+// Export exports the captured traffic to SQL scripts so that the traffic is human-readable.
+type Export interface {
+	// Start starts the export
+	Start(cfg ExportConfig) error
+	// Stop stops the export
+	Stop(err error)
+	// Progress returns the progress of the export job
+	Progress() (float64, time.Time, bool, error)
+	// Close closes the export
+	Close()
+}
+
+type ExportConfig struct {
+	Input   string
+	Output  string
+	KeyFile string
+	// Merge writes all the connections to one file in the order of time instead of one file per connection.
+	Merge bool
+	// StartTime acts as the job ID.
+	StartTime time.Time
+}
+
+func (cfg *ExportConfig) Validate() (storage.ExternalStorage, storage.ExternalStorage, error) {
+	if cfg.Input == "" {
+		return nil, nil, errors.New("input is required")
+	}
+	if cfg.Output == "" {
+		return nil, nil, errors.New("output is required")
+	}
+	if cfg.StartTime.IsZero() {
+		return nil, nil, errors.New("start time is not specified")
+	}
+	inStorage, err := store.NewStorage(cfg.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	outStorage, err := store.NewStorage(cfg.Output)
+	if err != nil {
+		return inStorage, nil, err
+	}
+	return inStorage, outStorage, nil
+}
+
+// sqlWriter writes SQL to a file in the output storage.
+type sqlWriter struct {
+	file   io.WriteCloser
+	writer *bufio.Writer
+}
+
+func newSQLWriter(externalStorage storage.ExternalStorage, fileName string) (*sqlWriter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	fileWriter, err := externalStorage.Create(ctx, fileName, &storage.WriterOption{})
+	cancel()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file := store.NewStorageWriter(fileWriter)
+	return &sqlWriter{
+		file:   file,
+		writer: bufio.NewWriterSize(file, bufferSize),
+	}, nil
+}
+
+func (w *sqlWriter) writeLines(lines ...string) error {
+	for _, line := range lines {
+		if _, err := w.writer.WriteString(line); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := w.writer.WriteByte('\n'); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (w *sqlWriter) close() error {
+	err := w.writer.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.WithStack(err)
+}
+
+var _ Export = (*export)(nil)
+
+type export struct {
+	sync.Mutex
+	cfg          ExportConfig
+	meta         store.Meta
+	inStorage    storage.ExternalStorage
+	outStorage   storage.ExternalStorage
+	wg           waitgroup.WaitGroup
+	cancel       context.CancelFunc
+	err          error
+	startTime    time.Time
+	endTime      time.Time
+	progress     float64
+	exportedCmds uint64
+	lg           *zap.Logger
+}
+
+func NewExport(lg *zap.Logger) *export {
+	return &export{
+		lg: lg,
+	}
+}
+
+func (e *export) Start(cfg ExportConfig) error {
+	inStorage, outStorage, err := cfg.Validate()
+	if err != nil {
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+		return err
+	}
+	var meta store.Meta
+	if err := meta.Read(inStorage); err != nil {
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+		return errors.Wrapf(err, "read meta failed")
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if !e.startTime.IsZero() {
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+		return errors.Errorf("traffic export is running, start time: %s", e.startTime.String())
+	}
+	e.cfg = cfg
+	e.meta = meta
+	e.inStorage = inStorage
+	e.outStorage = outStorage
+	e.startTime = cfg.StartTime
+	e.endTime = time.Time{}
+	e.progress = 0
+	e.exportedCmds = 0
+	e.err = nil
+	childCtx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.wg.RunWithRecover(func() {
+		e.stop(e.export(childCtx))
+	}, nil, e.lg)
+	return nil
+}
+
+func (e *export) export(ctx context.Context) error {
+	reader, err := store.NewReader(e.lg.Named("loader"), e.inStorage, store.ReaderCfg{
+		Dir:           e.cfg.Input,
+		EncryptMethod: e.meta.EncryptMethod,
+		KeyFile:       e.cfg.KeyFile,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	states := make(map[uint64]*connState)
+	writers := make(map[uint64]*sqlWriter)
+	var mergedWriter *sqlWriter
+	if e.cfg.Merge {
+		if mergedWriter, err = newSQLWriter(e.outStorage, mergedFileName); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if mergedWriter != nil {
+			if err := mergedWriter.close(); err != nil {
+				e.lg.Warn("failed to close file", zap.String("file", mergedFileName), zap.Error(err))
+			}
+		}
+		for connID, writer := range writers {
+			if err := writer.close(); err != nil {
+				e.lg.Warn("failed to close file", zap.Uint64("conn_id", connID), zap.Error(err))
+			}
+		}
+	}()
+
+	var captureStartTs time.Time
+	for ctx.Err() == nil {
+		command := &cmd.Command{}
+		if err := command.Decode(reader); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if captureStartTs.IsZero() {
+			captureStartTs = command.StartTs
+		}
+		state, ok := states[command.ConnID]
+		if !ok {
+			state = newConnState(e.lg)
+			states[command.ConnID] = state
+		}
+		if sql := state.toSQL(command); sql != "" {
+			writer := mergedWriter
+			if writer == nil {
+				if writer, ok = writers[command.ConnID]; !ok {
+					if writer, err = newSQLWriter(e.outStorage, fmt.Sprintf(connFileFormat, command.ConnID)); err != nil {
+						return err
+					}
+					writers[command.ConnID] = writer
+				}
+			}
+			if err := writer.writeLines(timingComment(command, captureStartTs), sql); err != nil {
+				return err
+			}
+		}
+		// Close the file once the connection quits to limit the number of open files.
+		if command.Type == pnet.ComQuit {
+			delete(states, command.ConnID)
+			if writer, ok := writers[command.ConnID]; ok {
+				delete(writers, command.ConnID)
+				if err := writer.close(); err != nil {
+					return err
+				}
+			}
+		}
+		e.Lock()
+		e.exportedCmds++
+		e.Unlock()
+	}
+	return errors.New("export is canceled")
+}
+
+func (e *export) Progress() (float64, time.Time, bool, error) {
+	e.Lock()
+	defer e.Unlock()
+	if !e.startTime.IsZero() && e.meta.Cmds > 0 {
+		e.progress = float64(e.exportedCmds) / float64(e.meta.Cmds)
+	}
+	return e.progress, e.endTime, e.startTime.IsZero(), e.err
+}
+
+func (e *export) stop(err error) {
+	e.Lock()
+	defer e.Unlock()
+	if e.startTime.IsZero() {
+		return
+	}
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.endTime = time.Now()
+	if e.err == nil {
+		e.err = err
+	}
+	fields := []zap.Field{
+		zap.Time("start_time", e.startTime),
+		zap.Time("end_time", e.endTime),
+		zap.String("input", e.cfg.Input),
+		zap.String("output", e.cfg.Output),
+		zap.Uint64("exported_cmds", e.exportedCmds),
+	}
+	if e.err != nil {
+		fields = append(fields, zap.Error(e.err))
+		e.lg.Error("export failed", fields...)
+	} else {
+		e.progress = 1
+		e.lg.Info("export finished", fields...)
+	}
+	e.startTime = time.Time{}
+	closeStorage(e.inStorage)
+	closeStorage(e.outStorage)
+	e.inStorage, e.outStorage = nil, nil
+}
+
+func (e *export) Stop(err error) {
+	e.Lock()
+	// already stopped
+	if e.startTime.IsZero() {
+		e.Unlock()
+		return
+	}
+	e.err = err
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.Unlock()
+	e.wg.Wait()
+}
+
+func (e *export) Close() {
+	e.Stop(errors.New("shutting down"))
+}
+
+func closeStorage(s storage.ExternalStorage) {
+	if s != nil && !reflect.ValueOf(s).IsNil() {
+		s.Close()
+	}
+}
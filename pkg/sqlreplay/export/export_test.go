@@ -0,0 +1,136 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiproxy/lib/util/logger"
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/store"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateCfg(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	cfgs := []ExportConfig{
+		{
+			Output:    dir,
+			StartTime: now,
+		},
+		{
+			Input:     dir,
+			StartTime: now,
+		},
+		{
+			Input:  dir,
+			Output: dir,
+		},
+	}
+
+	for i, cfg := range cfgs {
+		inStorage, outStorage, err := cfg.Validate()
+		require.Error(t, err, "case %d", i)
+		closeStorage(inStorage)
+		closeStorage(outStorage)
+	}
+}
+
+func TestExport(t *testing.T) {
+	inDir := t.TempDir()
+	captureStartTs := time.Date(2024, 8, 28, 18, 51, 20, 0, time.UTC)
+	execute1, err := pnet.MakeExecuteStmtRequest(1, []any{int64(1), "a'b"}, true)
+	require.NoError(t, err)
+	execute2, err := pnet.MakeExecuteStmtRequest(1, []any{int64(2), nil}, false)
+	require.NoError(t, err)
+	commands := []*cmd.Command{
+		cmd.NewCommand(append([]byte{pnet.ComInitDB.Byte()}, []byte("test")...), captureStartTs, 100),
+		cmd.NewCommand(append([]byte{pnet.ComStmtPrepare.Byte()}, []byte("select * from t where a = ? and b = ?")...), captureStartTs.Add(time.Second), 100),
+		cmd.NewCommand(execute1, captureStartTs.Add(2*time.Second), 100),
+		cmd.NewCommand(append([]byte{pnet.ComQuery.Byte()}, []byte("select 1")...), captureStartTs.Add(3*time.Second), 101),
+		cmd.NewCommand(execute2, captureStartTs.Add(4*time.Second), 100),
+		cmd.NewCommand([]byte{pnet.ComQuit.Byte()}, captureStartTs.Add(5*time.Second), 100),
+	}
+	storage, err := store.NewStorage(inDir)
+	require.NoError(t, err)
+	writer, err := store.NewWriter(zap.NewNop(), storage, store.WriterCfg{Dir: inDir})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	for _, command := range commands {
+		require.NoError(t, command.Encode(&buf))
+	}
+	_, err = writer.Write(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, store.NewMeta(5*time.Second, uint64(len(commands)), 0, "").Write(storage))
+	storage.Close()
+
+	tests := []struct {
+		merge bool
+		files map[string]string
+	}{
+		{
+			merge: false,
+			files: map[string]string{
+				"conn-100.sql": "-- 2024-08-28T18:51:20Z conn: 100, offset: 0s\n" +
+					"USE `test`;\n" +
+					"-- 2024-08-28T18:51:22Z conn: 100, offset: 2s\n" +
+					"select * from t where a = 1 and b = 'a\\'b';\n" +
+					"-- 2024-08-28T18:51:24Z conn: 100, offset: 4s\n" +
+					"select * from t where a = 2 and b = NULL;\n",
+				"conn-101.sql": "-- 2024-08-28T18:51:23Z conn: 101, offset: 3s\n" +
+					"select 1;\n",
+			},
+		},
+		{
+			merge: true,
+			files: map[string]string{
+				"traffic.sql": "-- 2024-08-28T18:51:20Z conn: 100, offset: 0s\n" +
+					"USE `test`;\n" +
+					"-- 2024-08-28T18:51:22Z conn: 100, offset: 2s\n" +
+					"select * from t where a = 1 and b = 'a\\'b';\n" +
+					"-- 2024-08-28T18:51:23Z conn: 101, offset: 3s\n" +
+					"select 1;\n" +
+					"-- 2024-08-28T18:51:24Z conn: 100, offset: 4s\n" +
+					"select * from t where a = 2 and b = NULL;\n",
+			},
+		},
+	}
+
+	lg, _ := logger.CreateLoggerForTest(t)
+	e := NewExport(lg)
+	defer e.Close()
+	for i, test := range tests {
+		outDir := t.TempDir()
+		require.NoError(t, e.Start(ExportConfig{
+			Input:     inDir,
+			Output:    outDir,
+			Merge:     test.merge,
+			StartTime: time.Now(),
+		}), "case %d", i)
+		require.Eventually(t, func() bool {
+			_, _, done, _ := e.Progress()
+			return done
+		}, 5*time.Second, 10*time.Millisecond, "case %d", i)
+		progress, _, _, err := e.Progress()
+		require.NoError(t, err, "case %d", i)
+		require.Equal(t, 1.0, progress, "case %d", i)
+
+		entries, err := os.ReadDir(outDir)
+		require.NoError(t, err, "case %d", i)
+		require.Len(t, entries, len(test.files), "case %d", i)
+		for name, content := range test.files {
+			b, err := os.ReadFile(filepath.Join(outDir, name))
+			require.NoError(t, err, "case %d", i)
+			require.Equal(t, content, string(b), "case %d, file %s", i, name)
+		}
+	}
+}
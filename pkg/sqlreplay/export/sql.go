@@ -0,0 +1,151 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+	"github.com/siddontang/go/hack"
+	"go.uber.org/zap"
+)
+
+const setSessionStates = "SET SESSION_STATES "
+
+// sessionStates is used to unmarshal the prepared statements in `SET SESSION_STATES '...'`.
+type sessionStates struct {
+	PreparedStmts map[uint32]*preparedStmtInfo `json:"prepared-stmts,omitempty"`
+}
+
+type preparedStmtInfo struct {
+	StmtText   string `json:"text"`
+	ParamTypes []byte `json:"types,omitempty"`
+}
+
+type preparedStmt struct {
+	text       string
+	paramTypes []byte
+	paramNum   int
+}
+
+// connState tracks the prepared statements of a connection to expand the executions into literal SQL.
+// The capture doesn't record the statement IDs returned by the server, so they are assigned in the same way as TiDB:
+// the IDs start from 1 and increase by 1 in each session.
+type connState struct {
+	preparedStmts map[uint32]preparedStmt
+	nextStmtID    uint32
+	lg            *zap.Logger
+}
+
+func newConnState(lg *zap.Logger) *connState {
+	return &connState{
+		preparedStmts: make(map[uint32]preparedStmt),
+		nextStmtID:    1,
+		lg:            lg,
+	}
+}
+
+// toSQL translates the command into a SQL statement ended with a semicolon.
+// It returns an empty string if the command has no SQL equivalent.
+func (cs *connState) toSQL(command *cmd.Command) string {
+	switch command.Type {
+	case pnet.ComQuery:
+		query := hack.String(command.Payload[1:])
+		if len(query) > len(setSessionStates) && strings.EqualFold(query[:len(setSessionStates)], setSessionStates) {
+			cs.updateSessionStates(query)
+		}
+		return terminate(query)
+	case pnet.ComInitDB:
+		return fmt.Sprintf("USE `%s`;", strings.ReplaceAll(hack.String(command.Payload[1:]), "`", "``"))
+	case pnet.ComStmtPrepare:
+		// TiDB doesn't allocate a statement ID if the prepare fails.
+		if !command.Succeess {
+			break
+		}
+		text := string(command.Payload[1:])
+		cs.preparedStmts[cs.nextStmtID] = preparedStmt{text: text, paramNum: lex.ParamCount(text)}
+		cs.nextStmtID++
+	case pnet.ComStmtExecute:
+		return cs.expandExecute(command)
+	case pnet.ComStmtClose:
+		if len(command.Payload) >= 5 {
+			delete(cs.preparedStmts, binary.LittleEndian.Uint32(command.Payload[1:5]))
+		}
+	case pnet.ComResetConnection, pnet.ComChangeUser:
+		// TiDB creates a new session, so the statement IDs start from 1 again.
+		cs.preparedStmts = make(map[uint32]preparedStmt)
+		cs.nextStmtID = 1
+	}
+	return ""
+}
+
+func (cs *connState) expandExecute(command *cmd.Command) string {
+	if len(command.Payload) < 5 {
+		return ""
+	}
+	stmtID := binary.LittleEndian.Uint32(command.Payload[1:5])
+	ps, ok := cs.preparedStmts[stmtID]
+	if !ok {
+		cs.lg.Warn("prepared stmt not found", zap.Uint64("conn_id", command.ConnID), zap.Uint32("stmt_id", stmtID))
+		return ""
+	}
+	_, args, paramTypes, err := pnet.ParseExecuteStmtRequest(command.Payload, ps.paramNum, ps.paramTypes)
+	if err != nil {
+		cs.lg.Warn("parsing ComExecuteStmt request failed", zap.Uint64("conn_id", command.ConnID), zap.Uint32("stmt_id", stmtID),
+			zap.Error(err))
+		return ""
+	}
+	// paramTypes is only sent in the first execution, so it's reused by the following executions.
+	if len(ps.paramTypes) == 0 && len(paramTypes) > 0 {
+		ps.paramTypes = bytes.Clone(paramTypes)
+		cs.preparedStmts[stmtID] = ps
+	}
+	return terminate(lex.ExpandParams(ps.text, args))
+}
+
+// updateSessionStates records the prepared statements in the session states, which are the prepared statements
+// before the capture starts.
+func (cs *connState) updateSessionStates(query string) {
+	states := strings.TrimSpace(query[len(setSessionStates):])
+	states = strings.Trim(states, "'\"")
+	states = strings.ReplaceAll(states, "\\\\", "\\")
+	states = strings.ReplaceAll(states, "\\'", "'")
+	var ss sessionStates
+	if err := json.Unmarshal(hack.Slice(states), &ss); err != nil {
+		cs.lg.Warn("failed to unmarshal session states", zap.Error(err))
+		return
+	}
+	for stmtID, stmt := range ss.PreparedStmts {
+		cs.preparedStmts[stmtID] = preparedStmt{text: stmt.StmtText, paramNum: lex.ParamCount(stmt.StmtText), paramTypes: stmt.ParamTypes}
+		if stmtID >= cs.nextStmtID {
+			cs.nextStmtID = stmtID + 1
+		}
+	}
+}
+
+// terminate appends a semicolon to the statement if it doesn't have one.
+func terminate(sql string) string {
+	sql = strings.TrimRight(sql, " \t\r\n")
+	if strings.HasSuffix(sql, ";") {
+		return sql
+	}
+	return sql + ";"
+}
+
+// timingComment returns a comment that records when the command is executed in the capture.
+func timingComment(command *cmd.Command, captureStartTs time.Time) string {
+	comment := fmt.Sprintf("-- %s conn: %d, offset: %s", command.StartTs.Format(time.RFC3339Nano), command.ConnID,
+		command.StartTs.Sub(captureStartTs).String())
+	if !command.Succeess {
+		comment += ", failed"
+	}
+	return comment
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPreparedStmts(t *testing.T) {
+	cs := newConnState(zap.NewNop())
+	newCmd := func(tp pnet.Command, data []byte) *cmd.Command {
+		return cmd.NewCommand(append([]byte{tp.Byte()}, data...), time.Now(), 100)
+	}
+	newExecute := func(stmtID uint32, args []any) *cmd.Command {
+		request, err := pnet.MakeExecuteStmtRequest(stmtID, args, true)
+		require.NoError(t, err)
+		return cmd.NewCommand(request, time.Now(), 100)
+	}
+	stmtIDPayload := func(stmtID uint32) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, stmtID)
+		return b
+	}
+
+	// The prepared statements in the session states are restored and the next ID follows them.
+	states := `SET SESSION_STATES '{"prepared-stmts":{"3":{"text":"select \'?\', ?"}}}'`
+	require.Equal(t, states+";", cs.toSQL(newCmd(pnet.ComQuery, []byte(states))))
+	require.Equal(t, "select '?', 'a';", cs.toSQL(newExecute(3, []any{"a"})))
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComStmtPrepare, []byte("select ?"))))
+	require.Equal(t, "select 1;", cs.toSQL(newExecute(4, []any{int64(1)})))
+
+	// Closed statements can't be expanded.
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComStmtClose, stmtIDPayload(4))))
+	require.Empty(t, cs.toSQL(newExecute(4, []any{int64(1)})))
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComResetConnection, nil)))
+	require.Empty(t, cs.toSQL(newExecute(3, []any{"a"})))
+
+	// The statement IDs start from 1 again after the session is reset.
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComStmtPrepare, []byte("select ?"))))
+	require.Equal(t, "select 1;", cs.toSQL(newExecute(1, []any{int64(1)})))
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComChangeUser, nil)))
+	require.Empty(t, cs.toSQL(newExecute(1, []any{int64(1)})))
+
+	// The failed prepare doesn't allocate a statement ID.
+	failed := newCmd(pnet.ComStmtPrepare, []byte("select * from"))
+	failed.Succeess = false
+	require.Empty(t, cs.toSQL(failed))
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComStmtPrepare, []byte("select ? + 1"))))
+	require.Equal(t, "select 1 + 1;", cs.toSQL(newExecute(1, []any{int64(1)})))
+	require.Empty(t, cs.toSQL(newExecute(2, []any{int64(1)})))
+
+	// Other commands.
+	require.Equal(t, "select 1;", cs.toSQL(newCmd(pnet.ComQuery, []byte("select 1;  "))))
+	require.Equal(t, "USE `a``b`;", cs.toSQL(newCmd(pnet.ComInitDB, []byte("a`b"))))
+	require.Empty(t, cs.toSQL(newCmd(pnet.ComPing, nil)))
+}
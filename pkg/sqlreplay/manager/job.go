@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"github.com/siddontang/go/hack"
 )
@@ -18,6 +20,8 @@ type jobType int
 const (
 	Capture jobType = iota
 	Replay
+	Convert
+	Export
 )
 
 type Job interface {
@@ -37,17 +41,20 @@ type job struct {
 }
 
 type job4Marshal struct {
-	Type      string  `json:"type"`
-	Status    string  `json:"status"`
-	StartTime string  `json:"start_time"`
-	EndTime   string  `json:"end_time,omitempty"`
-	Duration  string  `json:"duration,omitempty"`
-	Output    string  `json:"output,omitempty"`
-	Input     string  `json:"input,omitempty"`
-	Username  string  `json:"username,omitempty"`
-	Speed     float64 `json:"speed,omitempty"`
-	Progress  string  `json:"progress"`
-	Err       string  `json:"error,omitempty"`
+	Type          string            `json:"type"`
+	Status        string            `json:"status"`
+	StartTime     string            `json:"start_time"`
+	EndTime       string            `json:"end_time,omitempty"`
+	Duration      string            `json:"duration,omitempty"`
+	Output        string            `json:"output,omitempty"`
+	Input         string            `json:"input,omitempty"`
+	Format        string            `json:"format,omitempty"`
+	Merge         bool              `json:"merge,omitempty"`
+	Username      string            `json:"username,omitempty"`
+	Speed         float64           `json:"speed,omitempty"`
+	SchemaMapping map[string]string `json:"schema_mapping,omitempty"`
+	Progress      string            `json:"progress"`
+	Err           string            `json:"error,omitempty"`
 }
 
 func (job *job) IsRunning() bool {
@@ -113,7 +120,8 @@ var _ Job = (*replayJob)(nil)
 
 type replayJob struct {
 	job
-	cfg replay.ReplayConfig
+	cfg    replay.ReplayConfig
+	paused bool
 }
 
 func (job *replayJob) Type() jobType {
@@ -123,9 +131,14 @@ func (job *replayJob) Type() jobType {
 func (job *replayJob) MarshalJSON() ([]byte, error) {
 	job4Marshal := job.getJob4Marshal()
 	job4Marshal.Type = "replay"
+	if job.paused && job.IsRunning() {
+		job4Marshal.Status = "paused"
+	}
 	job4Marshal.Input = job.cfg.Input
+	job4Marshal.Format = job.cfg.Format
 	job4Marshal.Username = job.cfg.Username
 	job4Marshal.Speed = job.cfg.Speed
+	job4Marshal.SchemaMapping = job.cfg.SchemaMapping
 	if job4Marshal.Speed == 0 {
 		job4Marshal.Speed = 1
 	}
@@ -139,3 +152,59 @@ func (job *replayJob) String() string {
 	}
 	return hack.String(b)
 }
+
+var _ Job = (*convertJob)(nil)
+
+type convertJob struct {
+	job
+	cfg convert.ConvertConfig
+}
+
+func (job *convertJob) Type() jobType {
+	return Convert
+}
+
+func (job *convertJob) MarshalJSON() ([]byte, error) {
+	job4Marshal := job.getJob4Marshal()
+	job4Marshal.Type = "convert"
+	job4Marshal.Input = job.cfg.Input
+	job4Marshal.Output = job.cfg.Output
+	job4Marshal.Format = job.cfg.Format
+	return json.Marshal(job4Marshal)
+}
+
+func (job *convertJob) String() string {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return ""
+	}
+	return hack.String(b)
+}
+
+var _ Job = (*exportJob)(nil)
+
+type exportJob struct {
+	job
+	cfg export.ExportConfig
+}
+
+func (job *exportJob) Type() jobType {
+	return Export
+}
+
+func (job *exportJob) MarshalJSON() ([]byte, error) {
+	job4Marshal := job.getJob4Marshal()
+	job4Marshal.Type = "export"
+	job4Marshal.Input = job.cfg.Input
+	job4Marshal.Output = job.cfg.Output
+	job4Marshal.Merge = job.cfg.Merge
+	return json.Marshal(job4Marshal)
+}
+
+func (job *exportJob) String() string {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return ""
+	}
+	return hack.String(b)
+}
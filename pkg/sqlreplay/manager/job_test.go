@@ -9,6 +9,8 @@ import (
 
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"github.com/stretchr/testify/require"
 )
@@ -151,12 +153,43 @@ func TestMarshalJob(t *testing.T) {
 					done:      true,
 				},
 				cfg: replay.ReplayConfig{
-					Input:    "/tmp/traffic",
-					Username: "root",
-					Speed:    0.5,
+					Input:         "/tmp/traffic",
+					Username:      "root",
+					Speed:         0.5,
+					SchemaMapping: map[string]string{"app": "app_shadow"},
+				},
+			},
+			marshal: `{"type":"replay","status":"done","start_time":"2020-01-01T00:00:00Z","end_time":"2020-01-01T02:01:01Z","input":"/tmp/traffic","username":"root","speed":0.5,"schema_mapping":{"app":"app_shadow"},"progress":"100%"}`,
+		},
+		{
+			job: &convertJob{
+				job: job{
+					startTime: startTime,
+					endTime:   endTime,
+					progress:  1,
+					done:      true,
+				},
+				cfg: convert.ConvertConfig{
+					Input:  "/tmp/slowlog",
+					Output: "/tmp/traffic",
+					Format: "tidb-slow-log",
+				},
+			},
+			marshal: `{"type":"convert","status":"done","start_time":"2020-01-01T00:00:00Z","end_time":"2020-01-01T02:01:01Z","output":"/tmp/traffic","input":"/tmp/slowlog","format":"tidb-slow-log","progress":"100%"}`,
+		},
+		{
+			job: &exportJob{
+				job: job{
+					startTime: startTime,
+					progress:  0.5,
+				},
+				cfg: export.ExportConfig{
+					Input:  "/tmp/traffic",
+					Output: "/tmp/sql",
+					Merge:  true,
 				},
 			},
-			marshal: `{"type":"replay","status":"done","start_time":"2020-01-01T00:00:00Z","end_time":"2020-01-01T02:01:01Z","input":"/tmp/traffic","username":"root","speed":0.5,"progress":"100%"}`,
+			marshal: `{"type":"export","status":"running","start_time":"2020-01-01T00:00:00Z","output":"/tmp/sql","input":"/tmp/traffic","merge":true,"progress":"50%"}`,
 		},
 	}
 
@@ -12,6 +12,8 @@ import (
 	"github.com/pingcap/tiproxy/pkg/manager/id"
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"github.com/siddontang/go/hack"
 	"go.uber.org/zap"
@@ -28,8 +30,13 @@ type CertManager interface {
 type JobManager interface {
 	StartCapture(capture.CaptureConfig) error
 	StartReplay(replay.ReplayConfig) error
+	StartConvert(convert.ConvertConfig) error
+	StartExport(export.ExportConfig) error
 	GetCapture() capture.Capture
 	Stop() string
+	PauseReplay() error
+	ResumeReplay() error
+	SetReplaySpeed(speed float64) error
 	Jobs() string
 	Close()
 }
@@ -40,6 +47,8 @@ type jobManager struct {
 	jobHistory  []Job
 	capture     capture.Capture
 	replay      replay.Replay
+	convert     convert.Convert
+	export      export.Export
 	hsHandler   backend.HandshakeHandler
 	certManager CertManager
 	cfg         *config.Config
@@ -51,6 +60,8 @@ func NewJobManager(lg *zap.Logger, cfg *config.Config, certMgr CertManager, idMg
 		lg:          lg,
 		capture:     capture.NewCapture(lg.Named("capture")),
 		replay:      replay.NewReplay(lg.Named("replay"), idMgr),
+		convert:     convert.NewConvert(lg.Named("convert")),
+		export:      export.NewExport(lg.Named("export")),
 		hsHandler:   hsHandler,
 		cfg:         cfg,
 		certManager: certMgr,
@@ -70,6 +81,12 @@ func (jm *jobManager) updateProgress() {
 		case Replay:
 			progress, endTime, done, err := jm.replay.Progress()
 			job.SetProgress(progress, endTime, done, err)
+		case Convert:
+			progress, endTime, done, err := jm.convert.Progress()
+			job.SetProgress(progress, endTime, done, err)
+		case Export:
+			progress, endTime, done, err := jm.export.Progress()
+			job.SetProgress(progress, endTime, done, err)
 		}
 	}
 }
@@ -136,6 +153,46 @@ func (jm *jobManager) StartReplay(cfg replay.ReplayConfig) error {
 	return nil
 }
 
+func (jm *jobManager) StartConvert(cfg convert.ConvertConfig) error {
+	running := jm.runningJob()
+	if running != nil {
+		return errors.Errorf("a job is running: %s", running.String())
+	}
+	if err := jm.convert.Start(cfg); err != nil {
+		jm.lg.Warn("start convert failed", zap.Error(err))
+		return errors.Wrapf(err, "start convert failed")
+	}
+	newJob := &convertJob{
+		job: job{
+			startTime: cfg.StartTime,
+		},
+		cfg: cfg,
+	}
+	jm.lg.Info("start convert", zap.String("job", newJob.String()))
+	jm.addToHistory(newJob)
+	return nil
+}
+
+func (jm *jobManager) StartExport(cfg export.ExportConfig) error {
+	running := jm.runningJob()
+	if running != nil {
+		return errors.Errorf("a job is running: %s", running.String())
+	}
+	if err := jm.export.Start(cfg); err != nil {
+		jm.lg.Warn("start export failed", zap.Error(err))
+		return errors.Wrapf(err, "start export failed")
+	}
+	newJob := &exportJob{
+		job: job{
+			startTime: cfg.StartTime,
+		},
+		cfg: cfg,
+	}
+	jm.lg.Info("start export", zap.String("job", newJob.String()))
+	jm.addToHistory(newJob)
+	return nil
+}
+
 func (jm *jobManager) addToHistory(newJob Job) {
 	if len(jm.jobHistory) >= maxJobHistoryCount {
 		copy(jm.jobHistory, jm.jobHistory[1:])
@@ -168,11 +225,63 @@ func (jm *jobManager) Stop() string {
 		jm.capture.Stop(errors.Errorf("manually stopped"))
 	case Replay:
 		jm.replay.Stop(errors.Errorf("manually stopped"))
+	case Convert:
+		jm.convert.Stop(errors.Errorf("manually stopped"))
+	case Export:
+		jm.export.Stop(errors.Errorf("manually stopped"))
 	}
 	jm.updateProgress()
 	return "stopped: " + job.String()
 }
 
+// runningReplay returns the running replay job or an error if no replay job is running.
+func (jm *jobManager) runningReplay() (*replayJob, error) {
+	job := jm.runningJob()
+	if job == nil || job.Type() != Replay {
+		return nil, errors.New("no replay job running")
+	}
+	return job.(*replayJob), nil
+}
+
+func (jm *jobManager) PauseReplay() error {
+	job, err := jm.runningReplay()
+	if err != nil {
+		return err
+	}
+	if err := jm.replay.Pause(); err != nil {
+		return errors.Wrapf(err, "pause replay failed")
+	}
+	job.paused = true
+	jm.lg.Info("pause replay", zap.String("job", job.String()))
+	return nil
+}
+
+func (jm *jobManager) ResumeReplay() error {
+	job, err := jm.runningReplay()
+	if err != nil {
+		return err
+	}
+	if err := jm.replay.Resume(); err != nil {
+		return errors.Wrapf(err, "resume replay failed")
+	}
+	job.paused = false
+	jm.lg.Info("resume replay", zap.String("job", job.String()))
+	return nil
+}
+
+func (jm *jobManager) SetReplaySpeed(speed float64) error {
+	job, err := jm.runningReplay()
+	if err != nil {
+		return err
+	}
+	if err := jm.replay.SetSpeed(speed); err != nil {
+		return errors.Wrapf(err, "set replay speed failed")
+	}
+	job.cfg.Speed = speed
+	jm.lg.Info("set replay speed", zap.String("job", job.String()))
+	return nil
+}
+
 func (jm *jobManager) Close() {
 	if jm.capture != nil {
 		jm.capture.Close()
@@ -180,4 +289,10 @@ func (jm *jobManager) Close() {
 	if jm.replay != nil {
 		jm.replay.Close()
 	}
+	if jm.convert != nil {
+		jm.convert.Close()
+	}
+	if jm.export != nil {
+		jm.export.Close()
+	}
 }
@@ -11,6 +11,8 @@ import (
 	"github.com/pingcap/tiproxy/lib/util/errors"
 	"github.com/pingcap/tiproxy/pkg/manager/id"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -57,6 +59,76 @@ func TestStartAndStop(t *testing.T) {
 	require.ErrorContains(t, job.(*captureJob).err, "mock error")
 }
 
+func TestStartConvert(t *testing.T) {
+	mgr := NewJobManager(zap.NewNop(), &config.Config{}, &mockCertMgr{}, id.NewIDManager(), nil)
+	defer mgr.Close()
+	rep, cvt := &mockReplay{}, &mockConvert{}
+	mgr.replay, mgr.convert = rep, cvt
+
+	require.NoError(t, mgr.StartConvert(convert.ConvertConfig{}))
+	require.Error(t, mgr.StartConvert(convert.ConvertConfig{}))
+	require.Error(t, mgr.StartReplay(replay.ReplayConfig{}))
+	require.Error(t, mgr.PauseReplay())
+	require.Contains(t, mgr.Jobs(), `"type": "convert"`)
+	require.Contains(t, mgr.Stop(), "stopped")
+	require.Contains(t, mgr.Stop(), "no job running")
+
+	require.NoError(t, mgr.StartConvert(convert.ConvertConfig{}))
+	cvt.progress = 1
+	cvt.done = true
+	require.Contains(t, mgr.Jobs(), `"status": "done"`)
+	require.NoError(t, mgr.StartReplay(replay.ReplayConfig{}))
+	require.Len(t, mgr.jobHistory, 3)
+}
+
+func TestStartExport(t *testing.T) {
+	mgr := NewJobManager(zap.NewNop(), &config.Config{}, &mockCertMgr{}, id.NewIDManager(), nil)
+	defer mgr.Close()
+	cpt, exp := &mockCapture{}, &mockExport{}
+	mgr.capture, mgr.export = cpt, exp
+
+	require.NoError(t, mgr.StartExport(export.ExportConfig{}))
+	require.Error(t, mgr.StartExport(export.ExportConfig{}))
+	require.Error(t, mgr.StartCapture(capture.CaptureConfig{}))
+	require.Contains(t, mgr.Jobs(), `"type": "export"`)
+	require.Contains(t, mgr.Stop(), "stopped")
+	require.Contains(t, mgr.Stop(), "no job running")
+
+	require.NoError(t, mgr.StartExport(export.ExportConfig{}))
+	exp.progress = 1
+	exp.done = true
+	require.Contains(t, mgr.Jobs(), `"status": "done"`)
+	require.NoError(t, mgr.StartCapture(capture.CaptureConfig{}))
+	require.Len(t, mgr.jobHistory, 3)
+}
+
+func TestPauseAndResume(t *testing.T) {
+	mgr := NewJobManager(zap.NewNop(), &config.Config{}, &mockCertMgr{}, id.NewIDManager(), nil)
+	defer mgr.Close()
+	cpt, rep := &mockCapture{}, &mockReplay{}
+	mgr.capture, mgr.replay = cpt, rep
+
+	// no job running
+	require.Error(t, mgr.PauseReplay())
+	require.Error(t, mgr.ResumeReplay())
+	require.Error(t, mgr.SetReplaySpeed(2))
+
+	// capture is running
+	require.NoError(t, mgr.StartCapture(capture.CaptureConfig{}))
+	require.Error(t, mgr.PauseReplay())
+	require.Contains(t, mgr.Stop(), "stopped")
+
+	require.NoError(t, mgr.StartReplay(replay.ReplayConfig{}))
+	require.NoError(t, mgr.PauseReplay())
+	require.Contains(t, mgr.Jobs(), `"status": "paused"`)
+	require.NoError(t, mgr.SetReplaySpeed(2))
+	require.Contains(t, mgr.Jobs(), `"speed": 2`)
+	require.NoError(t, mgr.ResumeReplay())
+	require.Contains(t, mgr.Jobs(), `"status": "running"`)
+	require.Contains(t, mgr.Stop(), "stopped")
+	require.Error(t, mgr.ResumeReplay())
+}
+
 func TestMarshalJobHistory(t *testing.T) {
 	startTime, err := time.Parse("2006-01-02 15:04:05", "2020-01-01 00:00:00")
 	require.NoError(t, err)
@@ -9,6 +9,8 @@ import (
 
 	"github.com/pingcap/tiproxy/pkg/proxy/backend"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/capture"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/convert"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/export"
 	"github.com/pingcap/tiproxy/pkg/sqlreplay/replay"
 )
 
@@ -29,10 +31,10 @@ type mockCapture struct {
 	done     bool
 }
 
-func (m *mockCapture) InitConn(startTime time.Time, connID uint64, db string) {
+func (m *mockCapture) InitConn(startTime time.Time, connID uint64, user, db string) {
 }
 
-func (m *mockCapture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, error)) {
+func (m *mockCapture) Capture(packet []byte, startTime time.Time, connID uint64, initSession func() (string, string, error)) {
 }
 
 func (m *mockCapture) Close() {
@@ -78,3 +80,67 @@ func (m *mockReplay) Start(cfg replay.ReplayConfig, backendTLSConfig *tls.Config
 func (m *mockReplay) Stop(err error) {
 	m.err = err
 }
+
+func (m *mockReplay) Pause() error {
+	return nil
+}
+
+func (m *mockReplay) Resume() error {
+	return nil
+}
+
+func (m *mockReplay) SetSpeed(speed float64) error {
+	return nil
+}
+
+var _ convert.Convert = (*mockConvert)(nil)
+
+type mockConvert struct {
+	progress float64
+	err      error
+	done     bool
+}
+
+func (m *mockConvert) Close() {
+}
+
+func (m *mockConvert) Progress() (float64, time.Time, bool, error) {
+	return m.progress, time.Time{}, m.done, m.err
+}
+
+func (m *mockConvert) Start(cfg convert.ConvertConfig) error {
+	m.progress = 0
+	m.err = nil
+	m.done = false
+	return nil
+}
+
+func (m *mockConvert) Stop(err error) {
+	m.err = err
+}
+
+var _ export.Export = (*mockExport)(nil)
+
+type mockExport struct {
+	progress float64
+	err      error
+	done     bool
+}
+
+func (m *mockExport) Close() {
+}
+
+func (m *mockExport) Progress() (float64, time.Time, bool, error) {
+	return m.progress, time.Time{}, m.done, m.err
+}
+
+func (m *mockExport) Start(cfg export.ExportConfig) error {
+	m.progress = 0
+	m.err = nil
+	m.done = false
+	return nil
+}
+
+func (m *mockExport) Stop(err error) {
+	m.err = err
+}
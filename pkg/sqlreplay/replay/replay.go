@@ -43,6 +43,12 @@ type Replay interface {
 	Start(cfg ReplayConfig, backendTLSConfig *tls.Config, hsHandler backend.HandshakeHandler, bcConfig *backend.BCConfig) error
 	// Stop stops the replay
 	Stop(err error)
+	// Pause pauses dispatching commands. The dispatched commands are still executed.
+	Pause() error
+	// Resume resumes a paused replay.
+	Resume() error
+	// SetSpeed changes the speed of the running replay.
+	SetSpeed(speed float64) error
 	// Progress returns the progress of the replay job
 	Progress() (float64, time.Time, bool, error)
 	// Close closes the replay
@@ -50,10 +56,19 @@ type Replay interface {
 }
 
 type ReplayConfig struct {
-	Input    string
+	Input string
+	// Format is the format of the input files. It can be native, tidb-slow-log or mysql-general-log.
+	Format string
+	// Username and Password are the default credential. They are used for the connections whose captured users
+	// are not in Credentials and for writing the replay report.
 	Username string
 	Password string
-	KeyFile  string
+	// Credentials maps the captured usernames to their passwords, so that the replayed connections run as the
+	// same users as in the capture.
+	Credentials map[string]string
+	// SchemaMapping maps the captured schema names to new names, so that the replay runs on shadow schemas.
+	SchemaMapping map[string]string
+	KeyFile       string
 	// It's specified when executing with the statement `TRAFFIC REPLAY` so that all TiProxy instances
 	// use the same start time and the time acts as the job ID.
 	StartTime time.Time
@@ -76,13 +91,26 @@ func (cfg *ReplayConfig) Validate() (storage.ExternalStorage, error) {
 	if err != nil {
 		return storage, err
 	}
+	if _, err := cmd.NewCmdDecoder(cfg.Format); err != nil {
+		return storage, err
+	}
 	if cfg.Username == "" {
 		return storage, errors.New("username is required")
 	}
+	for user := range cfg.Credentials {
+		if user == "" {
+			return storage, errors.New("username in credentials should not be empty")
+		}
+	}
+	for from, to := range cfg.SchemaMapping {
+		if from == "" || to == "" {
+			return storage, errors.New("schema name in schema mapping should not be empty")
+		}
+	}
 	if cfg.Speed == 0 {
 		cfg.Speed = 1
-	} else if cfg.Speed < minSpeed || cfg.Speed > maxSpeed {
-		return storage, errors.Errorf("speed should be between %f and %f", minSpeed, maxSpeed)
+	} else if err := validateSpeed(cfg.Speed); err != nil {
+		return storage, err
 	}
 	// Maybe there's a time bias between TiDB and TiProxy, so add one minute.
 	now := time.Now()
@@ -105,6 +133,21 @@ func (cfg *ReplayConfig) Validate() (storage.ExternalStorage, error) {
 	return storage, nil
 }
 
+func validateSpeed(speed float64) error {
+	if speed < minSpeed || speed > maxSpeed {
+		return errors.Errorf("speed should be between %f and %f", minSpeed, maxSpeed)
+	}
+	return nil
+}
+
+// credential returns the username and password to connect for the captured user.
+func (cfg *ReplayConfig) credential(user string) (string, string) {
+	if password, ok := cfg.Credentials[user]; ok && user != "" {
+		return user, password
+	}
+	return cfg.Username, cfg.Password
+}
+
 type replay struct {
 	sync.Mutex
 	cfg              ReplayConfig
@@ -117,6 +160,7 @@ type replay struct {
 	wg               waitgroup.WaitGroup
 	cancel           context.CancelFunc
 	connCreator      conn.ConnCreator
+	schemaRewriter   *schemaRewriter
 	report           report.Report
 	err              error
 	startTime        time.Time
@@ -124,7 +168,16 @@ type replay struct {
 	progress         float64
 	decodedCmds      atomic.Uint64
 	backendTLSConfig *tls.Config
-	lg               *zap.Logger
+	// The following fields decide when to dispatch the next command. They are updated by Pause, Resume and SetSpeed.
+	// replayStartTs is the time when the first command is dispatched. When the replay is resumed or the speed changes,
+	// it's adjusted as if the replay had always run at the current speed without pausing.
+	replayStartTs time.Time
+	pauseTime     time.Time
+	speed         float64
+	paused        bool
+	// timingCh notifies readCommands to recalculate the wait time.
+	timingCh chan struct{}
+	lg       *zap.Logger
 }
 
 func NewReplay(lg *zap.Logger, idMgr *id.IDManager) *replay {
@@ -150,15 +203,21 @@ func (r *replay) Start(cfg ReplayConfig, backendTLSConfig *tls.Config, hsHandler
 	r.endTime = time.Time{}
 	r.progress = 0
 	r.decodedCmds.Store(0)
+	r.replayStartTs = time.Time{}
+	r.pauseTime = time.Time{}
+	r.speed = cfg.Speed
+	r.paused = false
+	r.timingCh = make(chan struct{}, 1)
 	r.err = nil
 	r.replayStats.Reset()
 	r.exceptionCh = make(chan conn.Exception, maxPendingExceptions)
 	r.closeCh = make(chan uint64, maxPendingExceptions)
+	r.schemaRewriter = newSchemaRewriter(r.lg.Named("rewriter"), cfg.SchemaMapping)
 	hsHandler = NewHandshakeHandler(hsHandler)
 	r.connCreator = cfg.connCreator
 	if r.connCreator == nil {
-		r.connCreator = func(connID uint64) conn.Conn {
-			return conn.NewConn(r.lg.Named("conn"), r.cfg.Username, r.cfg.Password, backendTLSConfig, hsHandler, r.idMgr,
+		r.connCreator = func(connID uint64, username, password string) conn.Conn {
+			return conn.NewConn(r.lg.Named("conn"), username, password, backendTLSConfig, hsHandler, r.idMgr,
 				connID, bcConfig, r.exceptionCh, r.closeCh, cfg.ReadOnly, &r.replayStats)
 		}
 	}
@@ -193,6 +252,7 @@ func (r *replay) readCommands(ctx context.Context) {
 			Dir:           r.cfg.Input,
 			KeyFile:       r.cfg.KeyFile,
 			EncryptMethod: r.meta.EncryptMethod,
+			Format:        r.cfg.Format,
 		})
 		if err != nil {
 			r.stop(err)
@@ -200,11 +260,15 @@ func (r *replay) readCommands(ctx context.Context) {
 		}
 	}
 	defer reader.Close()
+	decoder, err := cmd.NewCmdDecoder(r.cfg.Format)
+	if err != nil {
+		r.stop(err)
+		return
+	}
 
-	var captureStartTs, replayStartTs time.Time
+	var captureStartTs time.Time
 	conns := make(map[uint64]conn.Conn) // both alive and dead connections
 	connCount := 0                      // alive connection count
-	var err error
 	maxPendingCmds := int64(0)
 	totalWaitTime := time.Duration(0)
 	for ctx.Err() == nil {
@@ -217,8 +281,8 @@ func (r *replay) readCommands(ctx context.Context) {
 			}
 		}
 
-		command := &cmd.Command{}
-		if err = command.Decode(reader); err != nil {
+		var command *cmd.Command
+		if command, err = decoder.Decode(reader); err != nil {
 			if errors.Is(err, io.EOF) {
 				r.lg.Info("replay reads EOF", zap.String("reader", reader.String()))
 				err = nil
@@ -226,9 +290,12 @@ func (r *replay) readCommands(ctx context.Context) {
 			break
 		}
 		if captureStartTs.IsZero() {
-			// first command
+			// first command, it only waits when the replay is paused
+			r.waitForCmd(ctx, 0)
 			captureStartTs = command.StartTs
-			replayStartTs = time.Now()
+			r.Lock()
+			r.replayStartTs = time.Now()
+			r.Unlock()
 		} else {
 			pendingCmds := r.replayStats.PendingCmds.Load()
 			if pendingCmds > maxPendingCmds {
@@ -242,27 +309,17 @@ func (r *replay) readCommands(ctx context.Context) {
 				break
 			}
 
-			// Do not use calculate the wait time by the duration since last command because the go scheduler
-			// may wait a little bit longer than expected, and then the difference becomes larger and larger.
-			expectedInterval := command.StartTs.Sub(captureStartTs)
-			if r.cfg.Speed != 1 {
-				expectedInterval = time.Duration(float64(expectedInterval) / r.cfg.Speed)
-			}
-			expectedInterval = time.Until(replayStartTs.Add(expectedInterval))
-			if expectedInterval < 0 {
-				expectedInterval = 0
-			}
+			r.waitForCmd(ctx, command.StartTs.Sub(captureStartTs))
 			// If there are too many pending commands, slow it down to reduce memory usage.
 			if pendingCmds > r.cfg.slowDownThreshold {
 				extraWait := time.Duration(pendingCmds-r.cfg.slowDownThreshold) * r.cfg.slowDownFactor
 				totalWaitTime += extraWait
-				expectedInterval += extraWait
 				metrics.ReplayWaitTime.Set(float64(totalWaitTime.Nanoseconds()))
-			}
-			if expectedInterval > time.Microsecond {
-				select {
-				case <-ctx.Done():
-				case <-time.After(expectedInterval):
+				if extraWait > time.Microsecond {
+					select {
+					case <-ctx.Done():
+					case <-time.After(extraWait):
+					}
 				}
 			}
 		}
@@ -289,10 +346,116 @@ func (r *replay) readCommands(ctx context.Context) {
 	r.stop(err)
 }
 
+// waitForCmd waits until the time to dispatch the command. captureInterval is the duration between the first command
+// and this command in the capture. The wait time is recalculated once the replay is paused, resumed, or the speed changes.
+func (r *replay) waitForCmd(ctx context.Context, captureInterval time.Duration) {
+	for ctx.Err() == nil {
+		r.Lock()
+		paused, speed, replayStartTs := r.paused, r.speed, r.replayStartTs
+		r.Unlock()
+		if paused {
+			select {
+			case <-ctx.Done():
+			case <-r.timingCh:
+			}
+			continue
+		}
+		// Do not use calculate the wait time by the duration since last command because the go scheduler
+		// may wait a little bit longer than expected, and then the difference becomes larger and larger.
+		expectedInterval := captureInterval
+		if speed != 1 {
+			expectedInterval = time.Duration(float64(expectedInterval) / speed)
+		}
+		expectedInterval = time.Until(replayStartTs.Add(expectedInterval))
+		if expectedInterval <= time.Microsecond {
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case <-r.timingCh:
+		case <-time.After(expectedInterval):
+			return
+		}
+	}
+}
+
+func (r *replay) Pause() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.startTime.IsZero() {
+		return errors.New("no replay is running")
+	}
+	if r.paused {
+		return errors.New("replay is already paused")
+	}
+	r.paused = true
+	r.pauseTime = time.Now()
+	r.notifyTimingChange()
+	r.lg.Info("replay paused")
+	return nil
+}
+
+func (r *replay) Resume() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.startTime.IsZero() {
+		return errors.New("no replay is running")
+	}
+	if !r.paused {
+		return errors.New("replay is not paused")
+	}
+	pausedDuration := time.Since(r.pauseTime)
+	// replayStartTs is zero if the replay is paused before dispatching the first command.
+	if !r.replayStartTs.IsZero() {
+		r.replayStartTs = r.replayStartTs.Add(pausedDuration)
+	}
+	r.paused = false
+	r.pauseTime = time.Time{}
+	r.notifyTimingChange()
+	r.lg.Info("replay resumed", zap.Duration("paused_duration", pausedDuration))
+	return nil
+}
+
+func (r *replay) SetSpeed(speed float64) error {
+	if err := validateSpeed(speed); err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.startTime.IsZero() {
+		return errors.New("no replay is running")
+	}
+	// Keep the replayed position of the capture unchanged:
+	// (now - replayStartTs) * oldSpeed = (now - newReplayStartTs) * newSpeed
+	if !r.replayStartTs.IsZero() {
+		now := time.Now()
+		if r.paused {
+			now = r.pauseTime
+		}
+		elapsed := time.Duration(float64(now.Sub(r.replayStartTs)) * r.speed / speed)
+		r.replayStartTs = now.Add(-elapsed)
+	}
+	r.lg.Info("replay speed changed", zap.Float64("old_speed", r.speed), zap.Float64("new_speed", speed))
+	r.speed = speed
+	r.notifyTimingChange()
+	return nil
+}
+
+// notifyTimingChange must be called after holding the lock.
+func (r *replay) notifyTimingChange() {
+	select {
+	case r.timingCh <- struct{}{}:
+	default:
+	}
+}
+
 func (r *replay) executeCmd(ctx context.Context, command *cmd.Command, conns map[uint64]conn.Conn, connCount *int) {
 	conn, ok := conns[command.ConnID]
 	if !ok {
-		conn = r.connCreator(command.ConnID)
+		// The captured user is recorded in the first command of each connection.
+		// If it's not recorded or its password is unknown, use the default credential.
+		username, password := r.cfg.credential(command.User)
+		conn = r.connCreator(command.ConnID, username, password)
 		conns[command.ConnID] = conn
 		*connCount++
 		r.wg.RunWithRecover(func() {
@@ -300,6 +463,7 @@ func (r *replay) executeCmd(ctx context.Context, command *cmd.Command, conns map
 		}, nil, r.lg)
 	}
 	if conn != nil && !reflect.ValueOf(conn).IsNil() {
+		r.schemaRewriter.rewrite(command)
 		conn.ExecuteCmd(command)
 	}
 	r.decodedCmds.Add(1)
@@ -326,6 +490,10 @@ func (r *replay) Progress() (float64, time.Time, bool, error) {
 
 func (r *replay) readMeta() *store.Meta {
 	m := new(store.Meta)
+	// The logs of other formats have no meta.
+	if r.cfg.Format != "" && r.cfg.Format != cmd.FormatNative {
+		return m
+	}
 	if err := m.Read(r.storage); err != nil {
 		r.lg.Error("read meta failed", zap.Error(err))
 	}
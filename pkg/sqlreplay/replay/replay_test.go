@@ -5,6 +5,7 @@ package replay
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,7 +30,7 @@ func TestManageConns(t *testing.T) {
 		Username:  "u1",
 		StartTime: time.Now(),
 		reader:    loader,
-		connCreator: func(connID uint64) conn.Conn {
+		connCreator: func(connID uint64, username, password string) conn.Conn {
 			connCount++
 			return &mockConn{
 				connID:  connID,
@@ -67,6 +68,49 @@ func TestManageConns(t *testing.T) {
 	}, 3*time.Second, 10*time.Millisecond)
 }
 
+func TestCredentials(t *testing.T) {
+	replay := NewReplay(zap.NewNop(), id.NewIDManager())
+	defer replay.Close()
+
+	loader := newMockNormalLoader()
+	var lock sync.Mutex
+	credentials := make(map[uint64][2]string)
+	cfg := ReplayConfig{
+		Input:       t.TempDir(),
+		Username:    "u1",
+		Password:    "p1",
+		Credentials: map[string]string{"u2": "p2"},
+		StartTime:   time.Now(),
+		reader:      loader,
+		connCreator: func(connID uint64, username, password string) conn.Conn {
+			lock.Lock()
+			credentials[connID] = [2]string{username, password}
+			lock.Unlock()
+			return &mockConn{
+				connID:  connID,
+				closeCh: replay.closeCh,
+				closed:  make(chan struct{}),
+			}
+		},
+		report: newMockReport(replay.exceptionCh),
+	}
+	// conn 1: no user recorded, conn 2: user with credential, conn 3: user without credential
+	for i, user := range []string{"", "u2", "u3"} {
+		command := newMockCommand(uint64(i + 1))
+		command.User = user
+		loader.writeCommand(command)
+	}
+	require.NoError(t, replay.Start(cfg, nil, nil, &backend.BCConfig{}))
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(credentials) == 3
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, [2]string{"u1", "p1"}, credentials[1])
+	require.Equal(t, [2]string{"u2", "p2"}, credentials[2])
+	require.Equal(t, [2]string{"u1", "p1"}, credentials[3])
+}
+
 func TestValidateCfg(t *testing.T) {
 	dir := t.TempDir()
 	now := time.Now()
@@ -101,6 +145,18 @@ func TestValidateCfg(t *testing.T) {
 			Username:  "u1",
 			StartTime: now.Add(-time.Hour),
 		},
+		{
+			Input:       dir,
+			Username:    "u1",
+			Credentials: map[string]string{"": "p1"},
+			StartTime:   now,
+		},
+		{
+			Input:     dir,
+			Username:  "u1",
+			Format:    "unknown",
+			StartTime: now,
+		},
 	}
 
 	for i, cfg := range cfgs {
@@ -127,7 +183,7 @@ func TestReplaySpeed(t *testing.T) {
 			StartTime: time.Now(),
 			reader:    loader,
 			report:    newMockReport(replay.exceptionCh),
-			connCreator: func(connID uint64) conn.Conn {
+			connCreator: func(connID uint64, username, password string) conn.Conn {
 				return &mockConn{
 					connID:  connID,
 					cmdCh:   cmdCh,
@@ -169,6 +225,63 @@ func TestReplaySpeed(t *testing.T) {
 	}
 }
 
+func TestPauseAndResume(t *testing.T) {
+	replay := NewReplay(zap.NewNop(), id.NewIDManager())
+	defer replay.Close()
+	require.Error(t, replay.Pause())
+	require.Error(t, replay.Resume())
+	require.Error(t, replay.SetSpeed(2))
+
+	cmdCh := make(chan *cmd.Command, 10)
+	loader := newMockNormalLoader()
+	defer loader.Close()
+	cfg := ReplayConfig{
+		Input:     t.TempDir(),
+		Username:  "u1",
+		StartTime: time.Now(),
+		reader:    loader,
+		report:    newMockReport(replay.exceptionCh),
+		connCreator: func(connID uint64, username, password string) conn.Conn {
+			return &mockConn{
+				connID:  connID,
+				cmdCh:   cmdCh,
+				closeCh: replay.closeCh,
+				closed:  make(chan struct{}),
+			}
+		},
+	}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		command := newMockCommand(1)
+		command.StartTs = now.Add(time.Duration(i) * 500 * time.Millisecond)
+		loader.writeCommand(command)
+	}
+	require.NoError(t, replay.Start(cfg, nil, nil, &backend.BCConfig{}))
+	<-cmdCh
+
+	// No command is dispatched when paused.
+	require.NoError(t, replay.Pause())
+	require.Error(t, replay.Pause())
+	select {
+	case <-cmdCh:
+		t.Fatal("command is dispatched while paused")
+	case <-time.After(time.Second):
+	}
+	require.NoError(t, replay.Resume())
+	require.Error(t, replay.Resume())
+	// The paused duration is excluded, so the second command is dispatched about 500ms later.
+	resumeTime := time.Now()
+	<-cmdCh
+	require.Greater(t, time.Since(resumeTime), 100*time.Millisecond)
+
+	// The third command is dispatched 50ms later after speeding up.
+	require.Error(t, replay.SetSpeed(100))
+	require.NoError(t, replay.SetSpeed(10))
+	speedupTime := time.Now()
+	<-cmdCh
+	require.Less(t, time.Since(speedupTime), 400*time.Millisecond)
+}
+
 func TestProgress(t *testing.T) {
 	dir := t.TempDir()
 	meta := store.NewMeta(10*time.Second, 10, 0, "")
@@ -191,7 +304,7 @@ func TestProgress(t *testing.T) {
 		StartTime: time.Now(),
 		reader:    loader,
 		report:    newMockReport(replay.exceptionCh),
-		connCreator: func(connID uint64) conn.Conn {
+		connCreator: func(connID uint64, username, password string) conn.Conn {
 			return &mockConn{
 				connID:  connID,
 				cmdCh:   cmdCh,
@@ -218,6 +331,11 @@ func TestProgress(t *testing.T) {
 			// Maybe unstable due to goroutine schedule.
 			// require.LessOrEqual(t, progress, float64(i+2)/10)
 		}
+		// Wait for the replay to finish before starting the next one.
+		require.Eventually(t, func() bool {
+			_, _, done, _ := replay.Progress()
+			return done
+		}, 3*time.Second, 10*time.Millisecond)
 	}
 }
 
@@ -240,7 +358,7 @@ func TestPendingCmds(t *testing.T) {
 		StartTime: time.Now(),
 		reader:    loader,
 		report:    newMockReport(replay.exceptionCh),
-		connCreator: func(connID uint64) conn.Conn {
+		connCreator: func(connID uint64, username, password string) conn.Conn {
 			return &mockPendingConn{
 				connID:  connID,
 				closeCh: replay.closeCh,
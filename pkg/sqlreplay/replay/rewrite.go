@@ -0,0 +1,115 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/pingcap/tiproxy/pkg/util/lex"
+	"github.com/siddontang/go/hack"
+	"go.uber.org/zap"
+)
+
+const (
+	setSessionStates = "SET SESSION_STATES "
+	currentDBKey     = "current-db"
+	preparedStmtsKey = "prepared-stmts"
+	stmtTextKey      = "text"
+)
+
+// schemaRewriter rewrites the schema names in the commands so that the replayed writes never touch the original schemas.
+type schemaRewriter struct {
+	// mapping maps lowercased original schema names to the new names.
+	mapping map[string]string
+	lg      *zap.Logger
+}
+
+func newSchemaRewriter(lg *zap.Logger, mapping map[string]string) *schemaRewriter {
+	if len(mapping) == 0 {
+		return nil
+	}
+	sr := &schemaRewriter{
+		mapping: make(map[string]string, len(mapping)),
+		lg:      lg,
+	}
+	for from, to := range mapping {
+		sr.mapping[strings.ToLower(from)] = to
+	}
+	return sr
+}
+
+func (sr *schemaRewriter) rewrite(command *cmd.Command) {
+	if sr == nil {
+		return
+	}
+	switch command.Type {
+	case pnet.ComInitDB, pnet.ComCreateDB, pnet.ComDropDB:
+		db := hack.String(command.Payload[1:])
+		if newDB, ok := sr.mapping[strings.ToLower(db)]; ok {
+			command.Payload = append([]byte{command.Type.Byte()}, hack.Slice(newDB)...)
+		}
+	case pnet.ComQuery:
+		query := hack.String(command.Payload[1:])
+		var newQuery string
+		if len(query) > len(setSessionStates) && strings.EqualFold(query[:len(setSessionStates)], setSessionStates) {
+			newQuery = sr.rewriteSessionStates(query)
+		} else {
+			newQuery = lex.RewriteSchemas(query, sr.mapping)
+		}
+		if newQuery != query {
+			command.Payload = append([]byte{command.Type.Byte()}, hack.Slice(newQuery)...)
+		}
+	case pnet.ComStmtPrepare:
+		query := hack.String(command.Payload[1:])
+		if newQuery := lex.RewriteSchemas(query, sr.mapping); newQuery != query {
+			command.Payload = append([]byte{command.Type.Byte()}, hack.Slice(newQuery)...)
+		}
+	}
+}
+
+// rewriteSessionStates rewrites the current database and the prepared statements in `SET SESSION_STATES '...'`.
+// The escaping is the same as that in capture.
+func (sr *schemaRewriter) rewriteSessionStates(query string) string {
+	states := strings.TrimSpace(query[len(setSessionStates):])
+	states = strings.Trim(states, "'\"")
+	states = strings.ReplaceAll(states, "\\\\", "\\")
+	states = strings.ReplaceAll(states, "\\'", "'")
+	// Use map instead of struct to keep the fields that TiProxy doesn't care about.
+	var stateMap map[string]any
+	decoder := json.NewDecoder(strings.NewReader(states))
+	decoder.UseNumber()
+	if err := decoder.Decode(&stateMap); err != nil {
+		sr.lg.Warn("failed to unmarshal session states", zap.Error(err))
+		return query
+	}
+	if db, ok := stateMap[currentDBKey].(string); ok {
+		if newDB, ok := sr.mapping[strings.ToLower(db)]; ok {
+			stateMap[currentDBKey] = newDB
+		}
+	}
+	if stmts, ok := stateMap[preparedStmtsKey].(map[string]any); ok {
+		for _, stmt := range stmts {
+			if stmtMap, ok := stmt.(map[string]any); ok {
+				if text, ok := stmtMap[stmtTextKey].(string); ok {
+					stmtMap[stmtTextKey] = lex.RewriteSchemas(text, sr.mapping)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(stateMap); err != nil {
+		sr.lg.Warn("failed to marshal session states", zap.Error(err))
+		return query
+	}
+	states = strings.TrimSpace(buf.String())
+	states = strings.ReplaceAll(states, "\\", "\\\\")
+	states = strings.ReplaceAll(states, "'", "\\'")
+	return setSessionStates + "'" + states + "'"
+}
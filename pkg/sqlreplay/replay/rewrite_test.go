@@ -0,0 +1,72 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package replay
+
+import (
+	"testing"
+	"time"
+
+	pnet "github.com/pingcap/tiproxy/pkg/proxy/net"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRewriteSchema(t *testing.T) {
+	sr := newSchemaRewriter(zap.NewNop(), map[string]string{"App": "app_shadow"})
+	tests := []struct {
+		tp     pnet.Command
+		data   string
+		expect string
+	}{
+		{
+			tp:     pnet.ComInitDB,
+			data:   "app",
+			expect: "app_shadow",
+		},
+		{
+			tp:     pnet.ComInitDB,
+			data:   "test",
+			expect: "test",
+		},
+		{
+			tp:     pnet.ComCreateDB,
+			data:   "APP",
+			expect: "app_shadow",
+		},
+		{
+			tp:     pnet.ComQuery,
+			data:   "insert into app.t values(1)",
+			expect: "insert into app_shadow.t values(1)",
+		},
+		{
+			tp:     pnet.ComStmtPrepare,
+			data:   "select * from `app`.t where a=?",
+			expect: "select * from `app_shadow`.t where a=?",
+		},
+		{
+			tp:     pnet.ComQuery,
+			data:   `SET SESSION_STATES '{"current-db":"app","prepared-stmts":{"1":{"text":"select * from app.t where a=\'<a>\'","types":"CAA="}},"last-insert-id":18446744073709551615}'`,
+			expect: `SET SESSION_STATES '{"current-db":"app_shadow","last-insert-id":18446744073709551615,"prepared-stmts":{"1":{"text":"select * from app_shadow.t where a=\'<a>\'","types":"CAA="}}}'`,
+		},
+		{
+			tp:     pnet.ComQuery,
+			data:   `SET SESSION_STATES 'abc'`,
+			expect: `SET SESSION_STATES 'abc'`,
+		},
+	}
+
+	for i, test := range tests {
+		command := cmd.NewCommand(append([]byte{test.tp.Byte()}, []byte(test.data)...), time.Now(), 100)
+		sr.rewrite(command)
+		require.Equal(t, test.tp, command.Type, "case %d", i)
+		require.Equal(t, test.expect, string(command.Payload[1:]), "case %d", i)
+	}
+
+	// no mapping
+	sr = newSchemaRewriter(zap.NewNop(), nil)
+	command := cmd.NewCommand(append([]byte{pnet.ComInitDB.Byte()}, []byte("app")...), time.Now(), 100)
+	sr.rewrite(command)
+	require.Equal(t, "app", string(command.Payload[1:]))
+}
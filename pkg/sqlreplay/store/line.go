@@ -31,6 +31,8 @@ type ReaderCfg struct {
 	Dir           string
 	EncryptMethod string
 	KeyFile       string
+	// Format is the format of the files. The files in other formats are read in the order of file names.
+	Format string
 }
 
 var _ cmd.LineReader = (*loader)(nil)
@@ -13,6 +13,7 @@ import (
 
 	"github.com/pingcap/tidb/br/pkg/storage"
 	"github.com/pingcap/tiproxy/lib/util/errors"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
 	"go.uber.org/zap"
 )
 
@@ -165,34 +166,19 @@ func (r *rotateReader) Close() error {
 func (r *rotateReader) nextReader() error {
 	var minFileIdx int
 	var minFileName string
-	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
-	err := r.storage.WalkDir(ctx, &storage.WalkOption{},
-		func(name string, size int64) error {
-			if !strings.HasPrefix(name, fileNamePrefix) {
-				return nil
-			}
-			fileIdx := parseFileIdx(name)
-			if fileIdx == 0 {
-				r.lg.Warn("traffic file name is invalid", zap.String("filename", name))
-				return nil
-			}
-			if fileIdx <= r.curFileIdx {
-				return nil
-			}
-			if minFileName == "" || fileIdx < minFileIdx {
-				minFileIdx = fileIdx
-				minFileName = name
-			}
-			return nil
-		})
-	cancel()
+	var err error
+	if isNativeFormat(r.cfg.Format) {
+		minFileIdx, minFileName, err = r.nextTrafficFile()
+	} else {
+		minFileName, err = r.nextLogFile()
+	}
 	if err != nil {
 		return err
 	}
 	if minFileName == "" {
 		return io.EOF
 	}
-	ctx, cancel = context.WithTimeout(context.Background(), opTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
 	fileReader, err := r.storage.Open(ctx, minFileName, &storage.ReaderOption{})
 	cancel()
 	if err != nil {
@@ -208,14 +194,68 @@ func (r *rotateReader) nextReader() error {
 			return err
 		}
 	}
-	r.reader, err = newReaderWithEncryptOpts(r.reader, r.cfg.EncryptMethod, r.cfg.KeyFile)
-	if err != nil {
-		return err
+	// The logs of other formats are not encrypted.
+	if isNativeFormat(r.cfg.Format) {
+		r.reader, err = newReaderWithEncryptOpts(r.reader, r.cfg.EncryptMethod, r.cfg.KeyFile)
+		if err != nil {
+			return err
+		}
 	}
 	r.lg.Info("reading next file", zap.String("file", minFileName))
 	return nil
 }
 
+// nextTrafficFile returns the traffic file with the smallest index that is bigger than the current one.
+func (r *rotateReader) nextTrafficFile() (int, string, error) {
+	var minFileIdx int
+	var minFileName string
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	err := r.storage.WalkDir(ctx, &storage.WalkOption{},
+		func(name string, size int64) error {
+			if !strings.HasPrefix(name, fileNamePrefix) {
+				return nil
+			}
+			fileIdx := parseFileIdx(name)
+			if fileIdx == 0 {
+				r.lg.Warn("traffic file name is invalid", zap.String("filename", name))
+				return nil
+			}
+			if fileIdx <= r.curFileIdx {
+				return nil
+			}
+			if minFileName == "" || fileIdx < minFileIdx {
+				minFileIdx = fileIdx
+				minFileName = name
+			}
+			return nil
+		})
+	cancel()
+	return minFileIdx, minFileName, err
+}
+
+// nextLogFile returns the smallest file name that is bigger than the current one.
+// The rotated logs of TiDB and MySQL are named with timestamps or sequence numbers, so they are in lexical order.
+func (r *rotateReader) nextLogFile() (string, error) {
+	var minFileName string
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	err := r.storage.WalkDir(ctx, &storage.WalkOption{},
+		func(name string, size int64) error {
+			if name == metaFile || name <= r.curFileName {
+				return nil
+			}
+			if minFileName == "" || name < minFileName {
+				minFileName = name
+			}
+			return nil
+		})
+	cancel()
+	return minFileName, err
+}
+
+func isNativeFormat(format string) bool {
+	return format == "" || format == cmd.FormatNative
+}
+
 // Parse the file name to get the file index.
 // filename pattern: traffic-1.log.gz
 func parseFileIdx(name string) int {
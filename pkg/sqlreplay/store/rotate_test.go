@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/pingcap/tiproxy/lib/util/logger"
+	"github.com/pingcap/tiproxy/pkg/sqlreplay/cmd"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -124,6 +125,7 @@ func TestIterateFiles(t *testing.T) {
 	tests := []struct {
 		fileNames []string
 		order     []string
+		format    string
 	}{
 		{
 			fileNames: []string{},
@@ -170,6 +172,20 @@ func TestIterateFiles(t *testing.T) {
 				"traffic-2.log.gz",
 			},
 		},
+		{
+			fileNames: []string{
+				"tidb-slow.log",
+				"tidb-slow-2024-08-29T10-00-00.000.log.gz",
+				"tidb-slow-2024-08-28T10-00-00.000.log",
+				"meta",
+			},
+			order: []string{
+				"tidb-slow-2024-08-28T10-00-00.000.log",
+				"tidb-slow-2024-08-29T10-00-00.000.log.gz",
+				"tidb-slow.log",
+			},
+			format: cmd.FormatTiDBSlowLog,
+		},
 	}
 
 	dir := t.TempDir()
@@ -195,7 +211,7 @@ func TestIterateFiles(t *testing.T) {
 			}
 			require.NoError(t, f.Close())
 		}
-		l, err := newRotateReader(lg, storage, ReaderCfg{Dir: dir})
+		l, err := newRotateReader(lg, storage, ReaderCfg{Dir: dir, Format: test.format})
 		require.NoError(t, err)
 		fileOrder := make([]string, 0, len(test.order))
 		for {
@@ -3,10 +3,37 @@
 
 package lex
 
+import "strings"
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	// tokenWord is an unquoted identifier or keyword.
+	tokenWord
+	// tokenQuotedIdent is an identifier quoted by backticks.
+	tokenQuotedIdent
+	// tokenString is a string quoted by single or double quotes.
+	tokenString
+	tokenDot
+	tokenOther
+)
+
+type token struct {
+	tp tokenType
+	// text is the unquoted text of words and quoted identifiers, or the symbol of other tokens.
+	text string
+	// start and end are the offsets of the token in the SQL, including the quotes.
+	start, end int
+}
+
 type Lexer struct {
 	sql      string
 	curToken []byte
 	curIdx   int
+	// word and wordIdx are the word being split by NextToken.
+	word    string
+	wordIdx int
 }
 
 func NewLexer(sql string) *Lexer {
@@ -18,59 +45,117 @@ func NewLexer(sql string) *Lexer {
 
 // It only returns uppercased identifiers and keywords. It's used to search for some specified keywords.
 // It doesn't need to strict but it needs to be fast enough.
+// Words are split by the characters other than letters and underscores, and quoted identifiers are skipped.
 func (l *Lexer) NextToken() string {
-	l.curToken = l.curToken[:0]
-	inSingleLineComment, inMultiLineComment, inSingleQuote, inDoubleQuote := false, false, false, false
-	for ; l.curIdx < len(l.sql); l.curIdx++ {
-		char := l.sql[l.curIdx]
+	for {
+		l.curToken = l.curToken[:0]
+		for ; l.wordIdx < len(l.word); l.wordIdx++ {
+			char := l.word[l.wordIdx]
+			switch {
+			case char >= 'a' && char <= 'z':
+				l.curToken = append(l.curToken, char-'a'+'A')
+			case char >= 'A' && char <= 'Z' || char == '_':
+				l.curToken = append(l.curToken, char)
+			default:
+				if len(l.curToken) > 0 {
+					l.wordIdx++
+					return string(l.curToken)
+				}
+			}
+		}
+		if len(l.curToken) > 0 {
+			return string(l.curToken)
+		}
+		tk := l.scan()
+		switch tk.tp {
+		case tokenEOF:
+			return ""
+		case tokenWord:
+			l.word, l.wordIdx = tk.text, 0
+		}
+	}
+}
+
+// scan returns the next token. Comments and whitespaces are skipped.
+func (l *Lexer) scan() token {
+	sql := l.sql
+	for l.curIdx < len(sql) {
+		start, char := l.curIdx, sql[l.curIdx]
 		switch {
-		case inSingleLineComment:
-			if char == '\n' {
-				inSingleLineComment = false
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			l.curIdx++
+		case char == '#' || (char == '-' && start+1 < len(sql) && sql[start+1] == '-'):
+			for l.curIdx < len(sql) && sql[l.curIdx] != '\n' {
+				l.curIdx++
 			}
-		case inMultiLineComment:
-			if char == '*' {
-				if l.curIdx+1 < len(l.sql) && l.sql[l.curIdx+1] == '/' {
-					inMultiLineComment = false
-					l.curIdx++
-				}
+		case char == '/' && start+1 < len(sql) && sql[start+1] == '*':
+			if end := strings.Index(sql[start+2:], "*/"); end < 0 {
+				l.curIdx = len(sql)
+			} else {
+				l.curIdx = start + end + 4
 			}
-		case inSingleQuote:
-			if char == '\\' {
+		case char == '\'' || char == '"':
+			l.curIdx++
+			for l.curIdx < len(sql) {
+				if sql[l.curIdx] == '\\' {
+					l.curIdx += 2
+					continue
+				}
 				l.curIdx++
-			} else if char == '\'' {
-				inSingleQuote = false
+				if sql[l.curIdx-1] == char {
+					// '' or "" is an escaped quote.
+					if l.curIdx < len(sql) && sql[l.curIdx] == char {
+						l.curIdx++
+						continue
+					}
+					break
+				}
+			}
+			l.curIdx = min(l.curIdx, len(sql))
+			return token{tp: tokenString, start: start, end: l.curIdx}
+		case char == '`':
+			var sb strings.Builder
+			for l.curIdx++; l.curIdx < len(sql); l.curIdx++ {
+				if sql[l.curIdx] == '`' {
+					// `` is an escaped backtick.
+					if l.curIdx+1 < len(sql) && sql[l.curIdx+1] == '`' {
+						sb.WriteByte('`')
+						l.curIdx++
+						continue
+					}
+					l.curIdx++
+					break
+				}
+				sb.WriteByte(sql[l.curIdx])
 			}
-		case inDoubleQuote:
-			if char == '\\' {
+			return token{tp: tokenQuotedIdent, text: sb.String(), start: start, end: l.curIdx}
+		case isIdentChar(char):
+			for l.curIdx < len(sql) && isIdentChar(sql[l.curIdx]) {
 				l.curIdx++
-			} else if char == '"' {
-				inDoubleQuote = false
 			}
-		case char == '-' && l.curIdx+1 < len(l.sql) && l.sql[l.curIdx+1] == '-':
+			return token{tp: tokenWord, text: sql[start:l.curIdx], start: start, end: l.curIdx}
+		case char == '.':
 			l.curIdx++
-			inSingleLineComment = true
-		case char == '/' && l.curIdx+1 < len(l.sql) && l.sql[l.curIdx+1] == '*':
-			l.curIdx++
-			inMultiLineComment = true
-		case char == '\'':
-			inSingleQuote = true
-		case char == '"':
-			inDoubleQuote = true
-		case char >= 'a' && char <= 'z':
-			l.curToken = append(l.curToken, char-'a'+'A')
-		case char >= 'A' && char <= 'Z' || char == '_':
-			l.curToken = append(l.curToken, char)
+			return token{tp: tokenDot, start: start, end: l.curIdx}
 		default:
-			if len(l.curToken) > 0 {
-				l.curIdx++
-				return string(l.curToken)
-			}
+			l.curIdx++
+			return token{tp: tokenOther, text: sql[start:l.curIdx], start: start, end: l.curIdx}
 		}
 	}
+	return token{tp: tokenEOF, start: len(sql), end: len(sql)}
+}
 
-	if len(l.curToken) > 0 {
-		return string(l.curToken)
+// tokenize splits the SQL into words, quoted identifiers, strings, dots, and other symbols.
+func tokenize(sql string) []token {
+	l := NewLexer(sql)
+	tokens := make([]token, 0, 16)
+	for tk := l.scan(); tk.tp != tokenEOF; tk = l.scan() {
+		tokens = append(tokens, tk)
 	}
-	return ""
+	return tokens
+}
+
+func isIdentChar(char byte) bool {
+	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' ||
+		char == '_' || char == '$' || char >= 0x80
 }
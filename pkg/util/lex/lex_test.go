@@ -42,6 +42,10 @@ func TestNextToken(t *testing.T) {
 			sql:    `sEleCt ** from; t5ble_name`,
 			tokens: []string{"SELECT", "FROM", "T", "BLE_NAME"},
 		},
+		{
+			sql:    "# comment\nselect `from` from t",
+			tokens: []string{"SELECT", "FROM", "T"},
+		},
 	}
 
 	for i, test := range tests {
@@ -57,3 +61,26 @@ func TestNextToken(t *testing.T) {
 		require.Equal(t, test.tokens, tokens, "case %d", i)
 	}
 }
+
+func TestScan(t *testing.T) {
+	sql := "select `a``b`.c, 'x''y' from t -- t\n/* t */ where d = ?"
+	expected := []token{
+		{tp: tokenWord, text: "select", start: 0, end: 6},
+		{tp: tokenQuotedIdent, text: "a`b", start: 7, end: 13},
+		{tp: tokenDot, start: 13, end: 14},
+		{tp: tokenWord, text: "c", start: 14, end: 15},
+		{tp: tokenOther, text: ",", start: 15, end: 16},
+		{tp: tokenString, start: 17, end: 23},
+		{tp: tokenWord, text: "from", start: 24, end: 28},
+		{tp: tokenWord, text: "t", start: 29, end: 30},
+		{tp: tokenWord, text: "where", start: 44, end: 49},
+		{tp: tokenWord, text: "d", start: 50, end: 51},
+		{tp: tokenOther, text: "=", start: 52, end: 53},
+		{tp: tokenOther, text: "?", start: 54, end: 55},
+	}
+	require.Equal(t, expected, tokenize(sql))
+
+	// Unterminated quotes end at the end of the SQL.
+	require.Equal(t, []token{{tp: tokenString, start: 0, end: 3}}, tokenize(`'a\`))
+	require.Equal(t, []token{{tp: tokenQuotedIdent, text: "a", start: 0, end: 2}}, tokenize("`a"))
+}
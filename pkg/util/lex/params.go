@@ -0,0 +1,100 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package lex
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParamCount returns the number of placeholders in a prepared statement.
+func ParamCount(sql string) int {
+	count := 0
+	for _, tk := range tokenize(sql) {
+		if isSymbol(tk, "?") {
+			count++
+		}
+	}
+	return count
+}
+
+// ExpandParams replaces the placeholders in a prepared statement with the literals of args.
+// The placeholders that have no corresponding args are kept.
+func ExpandParams(sql string, args []any) string {
+	if len(args) == 0 {
+		return sql
+	}
+	var sb strings.Builder
+	lastIdx, argIdx := 0, 0
+	for _, tk := range tokenize(sql) {
+		if argIdx >= len(args) {
+			break
+		}
+		if !isSymbol(tk, "?") {
+			continue
+		}
+		sb.WriteString(sql[lastIdx:tk.start])
+		writeLiteral(&sb, args[argIdx])
+		lastIdx = tk.end
+		argIdx++
+	}
+	sb.WriteString(sql[lastIdx:])
+	return sb.String()
+}
+
+// writeLiteral writes the arg as a SQL literal. The types are the same as those returned by ParseExecuteStmtRequest.
+func writeLiteral(sb *strings.Builder, arg any) {
+	switch v := arg.(type) {
+	case nil:
+		sb.WriteString("NULL")
+	case int8:
+		sb.WriteString(strconv.FormatInt(int64(v), 10))
+	case int16:
+		sb.WriteString(strconv.FormatInt(int64(v), 10))
+	case int32:
+		sb.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		sb.WriteString(strconv.FormatInt(v, 10))
+	case uint8:
+		sb.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		sb.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		sb.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		sb.WriteString(strconv.FormatUint(v, 10))
+	case float32:
+		sb.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		sb.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		writeQuotedString(sb, v)
+	case []byte:
+		writeQuotedString(sb, string(v))
+	default:
+		sb.WriteString("NULL")
+	}
+}
+
+func writeQuotedString(sb *strings.Builder, str string) {
+	sb.WriteByte('\'')
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case 0:
+			sb.WriteString("\\0")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		case 0x1a:
+			sb.WriteString("\\Z")
+		case '\'', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('\'')
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package lex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandParams(t *testing.T) {
+	tests := []struct {
+		sql    string
+		args   []any
+		count  int
+		expect string
+	}{
+		{
+			sql:    "select 1",
+			count:  0,
+			expect: "select 1",
+		},
+		{
+			sql:    "select * from t where a = ? and b = ?",
+			args:   []any{int64(-1), uint8(2)},
+			count:  2,
+			expect: "select * from t where a = -1 and b = 2",
+		},
+		{
+			sql:    "insert into t values(?, '?', \"?\", ?, ?) -- ?",
+			args:   []any{nil, "it's\n\\", 1.5},
+			count:  3,
+			expect: "insert into t values(NULL, '?', \"?\", 'it\\'s\\n\\\\', 1.5) -- ?",
+		},
+		{
+			sql:    "/* ? */ update t set a = ? where b = ?",
+			args:   []any{float32(0.1), "2024-01-01 00:00:00"},
+			count:  2,
+			expect: "/* ? */ update t set a = 0.1 where b = '2024-01-01 00:00:00'",
+		},
+		{
+			sql:    "select ?, ?",
+			args:   []any{int32(1)},
+			count:  2,
+			expect: "select 1, ?",
+		},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.count, ParamCount(test.sql), "case %d", i)
+		require.Equal(t, test.expect, ExpandParams(test.sql, test.args), "case %d", i)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package lex
+
+import (
+	"strings"
+)
+
+// tableRefKeywords are followed by table references.
+var tableRefKeywords = map[string]struct{}{
+	"FROM": {}, "JOIN": {}, "STRAIGHT_JOIN": {}, "UPDATE": {}, "INTO": {},
+}
+
+// nonAliasKeywords may follow a table reference, so they are not aliases.
+var nonAliasKeywords = map[string]struct{}{
+	"WHERE": {}, "ON": {}, "USING": {}, "JOIN": {}, "INNER": {}, "LEFT": {}, "RIGHT": {}, "CROSS": {}, "NATURAL": {},
+	"STRAIGHT_JOIN": {}, "OUTER": {}, "FULL": {}, "GROUP": {}, "ORDER": {}, "LIMIT": {}, "HAVING": {}, "SET": {},
+	"VALUES": {}, "VALUE": {}, "SELECT": {}, "UNION": {}, "EXCEPT": {}, "INTERSECT": {}, "FOR": {}, "LOCK": {},
+	"PARTITION": {}, "USE": {}, "FORCE": {}, "IGNORE": {}, "WINDOW": {}, "TABLESAMPLE": {}, "DEFAULT": {},
+}
+
+// tableRefs is the table references in a statement.
+type tableRefs struct {
+	// names are the lowercased unqualified table names and aliases, which may be used as qualifiers.
+	names map[string]struct{}
+	// schemas are the indexes of the tokens that are schema names of the table references.
+	schemas map[int]struct{}
+}
+
+// RewriteSchemas replaces the schema names in the SQL according to the mapping.
+// The keys of the mapping must be lowercased because schema names are case-insensitive.
+// Schema names are recognized in the following positions:
+// - The qualifier of an object name, such as `db`.`tbl` or db.tbl.col.
+// - The name after USE.
+// - The name after DATABASE or SCHEMA, such as CREATE DATABASE IF NOT EXISTS db.
+// The qualifier of a column, such as `t.col`, is kept if `t` is declared as a table or alias in the same statement.
+func RewriteSchemas(sql string, mapping map[string]string) string {
+	if len(mapping) == 0 {
+		return sql
+	}
+	tokens := tokenize(sql)
+	var sb strings.Builder
+	lastIdx := 0
+	for stmtStart := 0; stmtStart < len(tokens); {
+		stmtEnd := stmtStart
+		for stmtEnd < len(tokens) && !isSymbol(tokens[stmtEnd], ";") {
+			stmtEnd++
+		}
+		stmt := tokens[stmtStart:stmtEnd]
+		refs := parseTableRefs(stmt)
+		expectSchema := false
+		for i, tk := range stmt {
+			if !isName(tk) {
+				expectSchema = false
+				continue
+			}
+			isSchema := expectSchema
+			if tk.tp == tokenWord {
+				switch strings.ToUpper(tk.text) {
+				case "USE", "DATABASE", "SCHEMA":
+					expectSchema = true
+					continue
+				case "IF", "NOT", "EXISTS":
+					if expectSchema {
+						continue
+					}
+				}
+			}
+			expectSchema = false
+			// The first part of a qualified name `a.b` or `a.b.c`.
+			if !isSchema && isQualifier(stmt, i) {
+				_, declared := refs.names[strings.ToLower(tk.text)]
+				_, isTableRef := refs.schemas[i]
+				isSchema = isTableRef || !declared || hasThreeParts(stmt, i)
+			}
+			if !isSchema {
+				continue
+			}
+			newName, ok := mapping[strings.ToLower(tk.text)]
+			if !ok {
+				continue
+			}
+			sb.WriteString(sql[lastIdx:tk.start])
+			sb.WriteString(quoteIdent(newName, tk.tp == tokenQuotedIdent))
+			lastIdx = tk.end
+		}
+		stmtStart = stmtEnd + 1
+	}
+	if lastIdx == 0 {
+		return sql
+	}
+	sb.WriteString(sql[lastIdx:])
+	return sb.String()
+}
+
+// parseTableRefs collects the table names and aliases after FROM, JOIN, UPDATE, and INTO, such as
+// `FROM db.t1 AS a, t2 b JOIN t3`.
+func parseTableRefs(tokens []token) tableRefs {
+	refs := tableRefs{names: make(map[string]struct{}), schemas: make(map[int]struct{})}
+	for i, tk := range tokens {
+		if tk.tp != tokenWord {
+			continue
+		}
+		kw := strings.ToUpper(tk.text)
+		if _, ok := tableRefKeywords[kw]; !ok {
+			continue
+		}
+		// ON DUPLICATE KEY UPDATE is followed by assignments.
+		if kw == "UPDATE" && i > 0 && strings.EqualFold(tokens[i-1].text, "KEY") {
+			continue
+		}
+		for j := i + 1; j < len(tokens) && isName(tokens[j]); {
+			k := j + 1
+			if isQualifier(tokens, j) {
+				refs.schemas[j] = struct{}{}
+				k = j + 3
+			} else {
+				refs.names[strings.ToLower(tokens[j].text)] = struct{}{}
+			}
+			if k < len(tokens) && tokens[k].tp == tokenWord && strings.EqualFold(tokens[k].text, "AS") {
+				k++
+				if k < len(tokens) && isName(tokens[k]) {
+					refs.names[strings.ToLower(tokens[k].text)] = struct{}{}
+					k++
+				}
+			} else if k < len(tokens) && isName(tokens[k]) && !isNonAlias(tokens[k]) {
+				refs.names[strings.ToLower(tokens[k].text)] = struct{}{}
+				k++
+			}
+			if k >= len(tokens) || !isSymbol(tokens[k], ",") {
+				break
+			}
+			j = k + 1
+		}
+	}
+	return refs
+}
+
+// isQualifier returns true if tokens[i] is the first part of a qualified name `a.b`.
+func isQualifier(tokens []token, i int) bool {
+	return i+2 < len(tokens) && isName(tokens[i]) && tokens[i+1].tp == tokenDot && isName(tokens[i+2]) &&
+		(i == 0 || tokens[i-1].tp != tokenDot)
+}
+
+// hasThreeParts returns true if tokens[i] is the first part of `a.b.c`, where `a` must be a schema.
+func hasThreeParts(tokens []token, i int) bool {
+	return isQualifier(tokens, i) && i+4 < len(tokens) && tokens[i+3].tp == tokenDot && isName(tokens[i+4])
+}
+
+func isName(tk token) bool {
+	return tk.tp == tokenWord || tk.tp == tokenQuotedIdent
+}
+
+func isNonAlias(tk token) bool {
+	if tk.tp != tokenWord {
+		return false
+	}
+	_, ok := nonAliasKeywords[strings.ToUpper(tk.text)]
+	return ok
+}
+
+func isSymbol(tk token, symbol string) bool {
+	return tk.tp == tokenOther && tk.text == symbol
+}
+
+// quoteIdent quotes the identifier if it was quoted or it contains special characters.
+func quoteIdent(name string, quoted bool) string {
+	if !quoted {
+		for i := 0; i < len(name); i++ {
+			if !isIdentChar(name[i]) {
+				quoted = true
+				break
+			}
+		}
+	}
+	if !quoted {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
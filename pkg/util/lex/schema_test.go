@@ -0,0 +1,119 @@
+// Copyright 2024 PingCAP, Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package lex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteSchemas(t *testing.T) {
+	mapping := map[string]string{
+		"app":  "app_shadow",
+		"test": "test shadow",
+	}
+	tests := []struct {
+		sql    string
+		expect string
+	}{
+		{
+			sql:    "select * from t",
+			expect: "select * from t",
+		},
+		{
+			sql:    "select * from app.t",
+			expect: "select * from app_shadow.t",
+		},
+		{
+			sql:    "select * from `APP`.`t` join App . t2",
+			expect: "select * from `app_shadow`.`t` join app_shadow . t2",
+		},
+		{
+			sql:    "select app.t.a, t.app from app.t",
+			expect: "select app_shadow.t.a, t.app from app_shadow.t",
+		},
+		{
+			sql:    "insert into test.t values ('app.t', \"app.t\", 1.5) -- app.t",
+			expect: "insert into `test shadow`.t values ('app.t', \"app.t\", 1.5) -- app.t",
+		},
+		{
+			sql:    "/* app.t */ update app.t set a='it''s app.t' where b='\\'app.t'",
+			expect: "/* app.t */ update app_shadow.t set a='it''s app.t' where b='\\'app.t'",
+		},
+		{
+			sql:    "use app",
+			expect: "use app_shadow",
+		},
+		{
+			sql:    "USE `app`",
+			expect: "USE `app_shadow`",
+		},
+		{
+			sql:    "create database if not exists app",
+			expect: "create database if not exists app_shadow",
+		},
+		{
+			sql:    "drop schema app",
+			expect: "drop schema app_shadow",
+		},
+		{
+			sql:    "select database(), app from t",
+			expect: "select database(), app from t",
+		},
+		{
+			sql:    "select * from app_1.t",
+			expect: "select * from app_1.t",
+		},
+		{
+			sql:    "select * from `a``b`.t",
+			expect: "select * from `a``b`.t",
+		},
+		{
+			sql:    "SELECT app.id FROM app",
+			expect: "SELECT app.id FROM app",
+		},
+		{
+			sql:    "select app.id from t app where app.id > 0",
+			expect: "select app.id from t app where app.id > 0",
+		},
+		{
+			sql:    "select app.id from t1 as `APP` join app.t2 on app.id = t2.id",
+			expect: "select app.id from t1 as `APP` join app_shadow.t2 on app.id = t2.id",
+		},
+		{
+			sql:    "select a.id from app.t1 a, test t where a.id = test.id",
+			expect: "select a.id from app_shadow.t1 a, test t where a.id = test.id",
+		},
+		{
+			sql:    "update app set app.a = 1 where app.b = 2",
+			expect: "update app set app.a = 1 where app.b = 2",
+		},
+		{
+			sql:    "insert into app.t select * from app",
+			expect: "insert into app_shadow.t select * from app",
+		},
+		{
+			sql:    "select app.t.a from app",
+			expect: "select app_shadow.t.a from app",
+		},
+		{
+			sql:    "select app.id from app; select app.id from t",
+			expect: "select app.id from app; select app_shadow.id from t",
+		},
+		{
+			sql:    "insert into t values (1) on duplicate key update app = app.t",
+			expect: "insert into t values (1) on duplicate key update app = app_shadow.t",
+		},
+		{
+			sql:    "# app.t\nselect * from app.t",
+			expect: "# app.t\nselect * from app_shadow.t",
+		},
+	}
+
+	for i, test := range tests {
+		require.Equal(t, test.expect, RewriteSchemas(test.sql, mapping), "case %d", i)
+	}
+	require.Equal(t, "select * from app.t", RewriteSchemas("select * from app.t", nil))
+}